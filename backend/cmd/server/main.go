@@ -3,17 +3,24 @@ package main
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Contact timezones must resolve in minimal container images
 
 	"github.com/developertyrone/notimulti/internal/api"
+	"github.com/developertyrone/notimulti/internal/commands"
 	"github.com/developertyrone/notimulti/internal/config"
 	"github.com/developertyrone/notimulti/internal/logging"
 	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/routing"
+	"github.com/developertyrone/notimulti/internal/scheduler"
 	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/internal/templates"
 	"github.com/joho/godotenv"
 )
 
@@ -76,6 +83,25 @@ func main() {
 	// Create provider registry
 	registry := providers.NewRegistry()
 	registry.SetLogger(logger)
+	registry.SetCircuitBreakerSettings(
+		intFromEnv(logger, "CIRCUIT_BREAKER_THRESHOLD", providers.DefaultCircuitFailureThreshold),
+		durationFromEnv(logger, "CIRCUIT_BREAKER_COOLDOWN", providers.DefaultCircuitCooldown),
+	)
+
+	// Deliver on priority lanes so high priority notifications skip queued informational ones
+	deliveryQueue := providers.NewDeliveryQueue(
+		intFromEnv(logger, "DELIVERY_WORKERS_HIGH", providers.DefaultHighPriorityWorkers),
+		intFromEnv(logger, "DELIVERY_WORKERS_NORMAL", providers.DefaultNormalPriorityWorkers),
+		intFromEnv(logger, "DELIVERY_WORKERS_LOW", providers.DefaultLowPriorityWorkers),
+		durationFromEnv(logger, "DELIVERY_STARVATION_AGE", providers.DefaultStarvationAge),
+		intFromEnv(logger, "DELIVERY_QUEUE_CAPACITY", providers.DefaultQueueCapacity),
+		logger,
+	)
+	registry.SetDeliveryQueue(deliveryQueue)
+	deliveryQueue.Start()
+
+	// Handle inbound chat commands (/ack, /mute, /status) for providers that support them
+	registry.SetCommandHandler(commands.NewHandler(registry, repo, logger))
 
 	// Load initial providers from existing config files
 	factory := providers.NewFactory()
@@ -87,31 +113,9 @@ func main() {
 		}
 
 		// Convert config.Config to providers.ProviderConfig
-		providerConfig := &providers.ProviderConfig{
-			ID:   cfg.ID,
-			Type: cfg.Type,
-		}
-
-		// Set type-specific config
-		var parseErr error
-		switch cfg.Type {
-		case "telegram":
-			if tgConfig, err := parseTelegramConfig(cfg.Config); err == nil {
-				providerConfig.Telegram = tgConfig
-			} else {
-				parseErr = err
-				logger.Error("Failed to parse Telegram config", "id", cfg.ID, "error", err)
-			}
-		case "email":
-			if emailConfig, err := parseEmailConfig(cfg.Config); err == nil {
-				providerConfig.Email = emailConfig
-			} else {
-				parseErr = err
-				logger.Error("Failed to parse Email config", "id", cfg.ID, "error", err)
-			}
-		default:
-			parseErr = fmt.Errorf("unknown provider type: %s", cfg.Type)
-			logger.Error("Unknown provider type", "id", cfg.ID, "type", cfg.Type)
+		providerConfig, parseErr := config.BuildProviderConfig(cfg)
+		if parseErr != nil {
+			logger.Error("Failed to parse provider config", "id", cfg.ID, "type", cfg.Type, "error", parseErr)
 		}
 
 		// Create provider (or failed provider if parsing failed)
@@ -145,17 +149,105 @@ func main() {
 
 	logger.Info("Provider registry initialized", "count", registry.Count())
 
+	// Sync the topics defined in topics.json; topics created through the API are kept
+	if count, err := config.SyncTopicsFile(repo, loader.GetConfigPath(config.TopicsFileName)); err != nil {
+		logger.Error("Failed to load topics", "error", err)
+	} else {
+		logger.Info("Topics loaded", "topics", count)
+	}
+
+	// Load routing rules for notifications sent without a provider_id
+	routingEngine := routing.NewEngine()
+	routingPath := loader.GetConfigPath(routing.FileName)
+	if _, err := os.Stat(routingPath); err == nil {
+		if err := routingEngine.LoadFile(routingPath); err != nil {
+			logger.Error("Failed to load routing rules", "error", err)
+		} else {
+			logger.Info("Routing rules loaded", "rules", len(routingEngine.Rules()))
+		}
+	}
+
+	// Load message templates; invalid templates are reported like invalid provider configs
+	templateStore := templates.NewStore()
+	templateErrors := templateStore.LoadDir(loader.GetConfigPath(templates.Dir))
+	for _, err := range templateErrors {
+		logger.Error("Failed to load template", "error", err)
+	}
+	logger.Info("Templates loaded", "templates", len(templateStore.List()), "errors", len(templateErrors))
+
+	// Probe providers in the background so status requests never block on slow hosts
+	healthMonitor := providers.NewHealthMonitor(
+		registry,
+		durationFromEnv(logger, "HEALTH_CHECK_INTERVAL", providers.DefaultHealthCheckInterval),
+		durationFromEnv(logger, "HEALTH_CHECK_TIMEOUT", providers.DefaultHealthCheckTimeout),
+		logger,
+	)
+	healthMonitor.Start()
+
+	// Dispatch scheduled notifications, including any that became due while the server was down
+	notificationScheduler := scheduler.New(
+		repo,
+		api.ScheduledDispatcher(registry, notifLogger, repo),
+		durationFromEnv(logger, "SCHEDULER_INTERVAL", scheduler.DefaultInterval),
+		logger,
+	)
+	notificationScheduler.Start()
+
+	// Run recurring jobs defined in jobs.json or through the API
+	if count, err := config.SyncJobsFile(repo, loader.GetConfigPath(config.JobsFileName)); err != nil {
+		logger.Error("Failed to load jobs", "error", err)
+	} else {
+		logger.Info("Jobs loaded", "jobs", count)
+	}
+	jobRunner := scheduler.NewJobRunner(
+		repo,
+		api.JobSender(registry, notifLogger, repo, routingEngine, templateStore),
+		durationFromEnv(logger, "SCHEDULER_INTERVAL", scheduler.DefaultInterval),
+		logger,
+	)
+	jobRunner.Start()
+
+	// Send digests when their window ends, including any left open by the last run
+	digestFlusher := scheduler.NewDigestFlusher(
+		repo,
+		api.DigestSender(registry, notifLogger, repo, templateStore),
+		durationFromEnv(logger, "SCHEDULER_INTERVAL", scheduler.DefaultInterval),
+		logger,
+	)
+	digestFlusher.Start()
+
+	// Close deduplication windows when they end and send their "repeated" follow-ups
+	dedupSweeper := scheduler.NewDedupSweeper(
+		repo,
+		api.RepeatSender(registry, notifLogger, repo),
+		durationFromEnv(logger, "SCHEDULER_INTERVAL", scheduler.DefaultInterval),
+		logger,
+	)
+	dedupSweeper.Start()
+
+	// Remove old history and expired idempotency keys, now and every hour
+	retention := scheduler.NewRetention(repo, intFromEnv(logger, "LOG_RETENTION_DAYS", scheduler.DefaultRetentionDays), scheduler.DefaultRetentionInterval, logger)
+	retention.Start()
+
 	// Start configuration file watcher
 	watcher, err := config.NewWatcher(configDir, registry, logger)
 	if err != nil {
 		logger.Error("Failed to initialize configuration watcher", "error", err)
 		os.Exit(1)
 	}
+	watcher.SetRoutingEngine(routingEngine)
+	watcher.SetTopicStore(repo)
+	watcher.SetJobStore(repo)
+	watcher.SetTemplateStore(templateStore)
 	watcher.Start()
 	logger.Info("Configuration watcher started", "directory", configDir)
 
 	// Setup API router and serve frontend from built assets (copied into /app/cmd/server/dist)
-	router := api.SetupRouter(registry, notifLogger, repo)
+	router := api.SetupRouter(registry, notifLogger, repo,
+		api.WithRoutingEngine(routingEngine),
+		api.WithTemplates(templateStore),
+		api.WithIdempotencyTTL(durationFromEnv(logger, "IDEMPOTENCY_KEY_TTL", api.DefaultIdempotencyTTL)),
+	)
 	api.ServeFrontendFromDisk(router, "./cmd/server/dist")
 
 	// Get server port
@@ -192,6 +284,16 @@ func main() {
 		logger.Error("Error stopping watcher", "error", err)
 	}
 
+	// Stop background health checks
+	healthMonitor.Stop()
+
+	// Stop dispatching scheduled notifications, jobs, digests and follow-ups; pending ones stay in the database
+	notificationScheduler.Stop()
+	jobRunner.Stop()
+	digestFlusher.Stop()
+	dedupSweeper.Stop()
+	retention.Stop()
+
 	// Graceful shutdown with 10 second timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -200,6 +302,9 @@ func main() {
 		logger.Error("Server forced to shutdown", "error", err)
 	}
 
+	// Finish the deliveries still queued before closing their providers
+	deliveryQueue.Stop()
+
 	// Close all providers
 	for _, provider := range registry.List() {
 		if err := provider.Close(); err != nil {
@@ -210,67 +315,32 @@ func main() {
 	logger.Info("Server stopped")
 }
 
-// Helper functions to parse type-specific configs
-func parseTelegramConfig(config map[string]interface{}) (*providers.TelegramConfig, error) {
-	tgConfig := &providers.TelegramConfig{}
-
-	if botToken, ok := config["bot_token"].(string); ok {
-		tgConfig.BotToken = botToken
-	} else {
-		return nil, fmt.Errorf("missing or invalid bot_token")
-	}
-
-	if chatID, ok := config["default_chat_id"].(string); ok {
-		tgConfig.DefaultChatID = chatID
+// durationFromEnv reads a duration such as "30s" from an environment variable
+func durationFromEnv(logger *slog.Logger, name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
 	}
 
-	if parseMode, ok := config["parse_mode"].(string); ok {
-		tgConfig.ParseMode = parseMode
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		logger.Warn("Invalid duration in environment, using default", "name", name, "value", value, "default", fallback)
+		return fallback
 	}
-
-	if timeout, ok := config["timeout_seconds"].(float64); ok {
-		tgConfig.TimeoutSeconds = int(timeout)
-	}
-
-	return tgConfig, nil
+	return duration
 }
 
-func parseEmailConfig(config map[string]interface{}) (*providers.EmailConfig, error) {
-	emailConfig := &providers.EmailConfig{}
-
-	if host, ok := config["host"].(string); ok {
-		emailConfig.Host = host
-	} else {
-		return nil, fmt.Errorf("missing or invalid host")
-	}
-
-	if port, ok := config["port"].(float64); ok {
-		emailConfig.Port = int(port)
-	} else {
-		return nil, fmt.Errorf("missing or invalid port")
+// intFromEnv reads a positive integer from an environment variable
+func intFromEnv(logger *slog.Logger, name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
 	}
 
-	if username, ok := config["username"].(string); ok {
-		emailConfig.Username = username
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		logger.Warn("Invalid integer in environment, using default", "name", name, "value", value, "default", fallback)
+		return fallback
 	}
-
-	if password, ok := config["password"].(string); ok {
-		emailConfig.Password = password
-	}
-
-	if from, ok := config["from"].(string); ok {
-		emailConfig.From = from
-	} else {
-		return nil, fmt.Errorf("missing or invalid from")
-	}
-
-	if useTLS, ok := config["use_tls"].(bool); ok {
-		emailConfig.UseTLS = useTLS
-	}
-
-	if timeout, ok := config["timeout_seconds"].(float64); ok {
-		emailConfig.TimeoutSeconds = int(timeout)
-	}
-
-	return emailConfig, nil
+	return n
 }
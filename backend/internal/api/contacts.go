@@ -0,0 +1,300 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/internal/templates"
+	"github.com/gin-gonic/gin"
+)
+
+// ContactPrefix marks a recipient that names a contact, e.g. "contact:alice"
+const ContactPrefix = "contact:"
+
+// ContactResolution reports how a contact recipient was resolved
+type ContactResolution struct {
+	ID         string `json:"id"`
+	ProviderID string `json:"provider_id"`
+	Recipient  string `json:"recipient"`
+}
+
+// contactError writes the response for an error returned by the contact store
+func contactError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrContactNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, storage.ErrContactExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// HandleListContacts handles GET /api/v1/contacts
+func HandleListContacts(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		contacts, err := repo.ListContacts()
+		if err != nil {
+			contactError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"contacts": contacts,
+			"count":    len(contacts),
+		})
+	}
+}
+
+// HandleGetContact handles GET /api/v1/contacts/:id
+func HandleGetContact(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		contact, err := repo.GetContact(c.Param("id"))
+		if err != nil {
+			contactError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, contact)
+	}
+}
+
+// HandleCreateContact handles POST /api/v1/contacts
+func HandleCreateContact(registry *providers.Registry, repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var contact storage.Contact
+		if err := c.ShouldBindJSON(&contact); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if validationErrors := validateContact(registry, &contact); len(validationErrors) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "validation failed",
+				"details": validationErrors,
+			})
+			return
+		}
+
+		created, err := repo.CreateContact(&contact)
+		if err != nil {
+			contactError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, created)
+	}
+}
+
+// HandleUpdateContact handles PUT /api/v1/contacts/:id.
+// The body replaces the contact, including all of its identities.
+func HandleUpdateContact(registry *providers.Registry, repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var contact storage.Contact
+		if err := c.ShouldBindJSON(&contact); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+		contact.ID = c.Param("id")
+
+		if validationErrors := validateContact(registry, &contact); len(validationErrors) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "validation failed",
+				"details": validationErrors,
+			})
+			return
+		}
+
+		updated, err := repo.UpdateContact(&contact)
+		if err != nil {
+			contactError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// HandleDeleteContact handles DELETE /api/v1/contacts/:id
+func HandleDeleteContact(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := repo.DeleteContact(c.Param("id")); err != nil {
+			contactError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// validateContact checks a contact before it is stored. Identities on providers that
+// are not loaded are accepted so contacts can be set up before their providers.
+func validateContact(registry *providers.Registry, contact *storage.Contact) []ValidationError {
+	var validationErrors []ValidationError
+
+	if !topicNamePattern.MatchString(contact.ID) {
+		validationErrors = append(validationErrors, ValidationError{
+			Field:   "id",
+			Message: "id must contain only lowercase letters, numbers, and hyphens",
+		})
+	}
+
+	if contact.Timezone != "" {
+		if _, err := time.LoadLocation(contact.Timezone); err != nil {
+			validationErrors = append(validationErrors, ValidationError{
+				Field:   "timezone",
+				Message: fmt.Sprintf("unknown timezone %q", contact.Timezone),
+			})
+		}
+	}
+
+	if contact.Locale != "" {
+		if locale, err := templates.ParseLocale(contact.Locale); err != nil {
+			validationErrors = append(validationErrors, ValidationError{Field: "locale", Message: err.Error()})
+		} else {
+			contact.Locale = locale
+		}
+	}
+
+	if q := contact.QuietHours; q != nil {
+		if err := q.Validate(); err != nil {
+			validationErrors = append(validationErrors, ValidationError{Field: "quiet_hours", Message: err.Error()})
+		}
+	}
+
+	if len(contact.Identities) == 0 {
+		validationErrors = append(validationErrors, ValidationError{
+			Field:   "identities",
+			Message: "at least one identity is required",
+		})
+	}
+
+	seen := make(map[string]bool, len(contact.Identities))
+	for i, identity := range contact.Identities {
+		field := fmt.Sprintf("identities[%d]", i)
+		switch {
+		case !topicNamePattern.MatchString(identity.ProviderID):
+			validationErrors = append(validationErrors, ValidationError{
+				Field:   field + ".provider_id",
+				Message: "provider_id must be a valid provider ID",
+			})
+			continue
+		case seen[identity.ProviderID]:
+			validationErrors = append(validationErrors, ValidationError{
+				Field:   field + ".provider_id",
+				Message: fmt.Sprintf("duplicate identity for provider %s", identity.ProviderID),
+			})
+			continue
+		case identity.Address == "":
+			validationErrors = append(validationErrors, ValidationError{
+				Field:   field + ".address",
+				Message: "address is required",
+			})
+			continue
+		}
+		seen[identity.ProviderID] = true
+
+		provider, err := registry.Get(identity.ProviderID)
+		if err != nil {
+			continue
+		}
+		if providers.IsVirtual(provider) {
+			validationErrors = append(validationErrors, ValidationError{
+				Field:   field + ".provider_id",
+				Message: "identities must use a delivering provider, not a group or failover chain",
+			})
+			continue
+		}
+		for _, verr := range validateRecipient(registry, provider, identity.Address) {
+			verr.Field = field + ".address"
+			validationErrors = append(validationErrors, verr)
+		}
+	}
+
+	if contact.PreferredProvider != "" && !seen[contact.PreferredProvider] {
+		validationErrors = append(validationErrors, ValidationError{
+			Field:   "preferred_provider",
+			Message: "preferred_provider must be the provider of one of the identities",
+		})
+	}
+
+	return validationErrors
+}
+
+// resolveContact replaces a "contact:<id>" recipient with the contact's address.
+// With a provider_id the contact's identity on that provider is used; otherwise the
+// preferred provider, falling back to the other identities in order, skipping providers
+// that are not loaded or in error. Problems with the request are returned as validation errors.
+func resolveContact(registry *providers.Registry, repo *storage.Repository, req *NotificationRequest) (*storage.Contact, []ValidationError, error) {
+	id := strings.TrimPrefix(req.Recipient, ContactPrefix)
+	if repo == nil {
+		return nil, []ValidationError{{Field: "recipient", Message: "contacts are not available"}}, nil
+	}
+
+	contact, err := repo.GetContact(id)
+	if errors.Is(err, storage.ErrContactNotFound) {
+		return nil, []ValidationError{{Field: "recipient", Message: fmt.Sprintf("unknown contact: %s", id)}}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if req.ProviderID != "" {
+		address, ok := contact.Identity(req.ProviderID)
+		if !ok {
+			return nil, []ValidationError{{
+				Field:   "provider_id",
+				Message: fmt.Sprintf("contact %s has no identity on provider %s", id, req.ProviderID),
+			}}, nil
+		}
+		req.Recipient = address
+		return contact, nil, nil
+	}
+
+	candidates := make([]string, 0, len(contact.Identities)+1)
+	if contact.PreferredProvider != "" {
+		candidates = append(candidates, contact.PreferredProvider)
+	}
+	for _, identity := range contact.Identities {
+		if identity.ProviderID != contact.PreferredProvider {
+			candidates = append(candidates, identity.ProviderID)
+		}
+	}
+
+	for _, providerID := range candidates {
+		provider, err := registry.Get(providerID)
+		if err != nil || provider.GetStatus().Status == providers.StatusError {
+			continue
+		}
+		address, _ := contact.Identity(providerID)
+		req.ProviderID = providerID
+		req.Recipient = address
+		return contact, nil, nil
+	}
+
+	return nil, []ValidationError{{
+		Field:   "recipient",
+		Message: fmt.Sprintf("contact %s has no identity on an available provider", id),
+	}}, nil
+}
+
+// validateRecipient checks a recipient against the capabilities of its provider
+func validateRecipient(registry *providers.Registry, provider providers.Provider, recipient string) []ValidationError {
+	// Reuse the notification checks for the recipient only
+	caps := registry.CapabilitiesOf(provider)
+	probe := NotificationRequest{ProviderID: provider.GetID(), Recipient: recipient, Message: "-"}
+	var validationErrors []ValidationError
+	for _, verr := range ValidateNotificationRequest(&probe, &caps) {
+		if verr.Field == "recipient" {
+			validationErrors = append(validationErrors, verr)
+		}
+	}
+	return validationErrors
+}
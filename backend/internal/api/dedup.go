@@ -0,0 +1,120 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/scheduler"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// suppressDuplicate checks a notification about to be queued for delivery against the
+// open deduplication windows of its provider. A duplicate is counted on the window of the
+// first notification with its fingerprint and recorded in history as suppressed instead
+// of being delivered; otherwise a new window is opened, which dropDedupWindow removes if
+// the notification is not delivered. It returns the window of a duplicate, or nil when
+// the notification should be delivered: deduplication is off, there is no database, it
+// is the first with its fingerprint or the window could not be stored.
+func suppressDuplicate(repo *storage.Repository, logger *storage.NotificationLogger, provider providers.Provider, notification *providers.Notification, parentID string) *storage.DedupWindow {
+	config := providers.DedupOf(provider)
+	if config == nil || repo == nil {
+		return nil
+	}
+
+	window, duplicate, err := repo.TrackDuplicate(&storage.DedupWindow{
+		ID:            notification.ID,
+		Fingerprint:   config.Fingerprint(notification),
+		ProviderID:    notification.ProviderID,
+		ProviderType:  provider.GetType(),
+		Recipient:     notification.Recipient,
+		NotifyRepeats: config.NotifyRepeats,
+		ExpiresAt:     time.Now().Add(config.Window()),
+		Notification:  notification,
+	})
+	if err != nil {
+		fmt.Printf("Error checking notification %s for duplicates, sending it: %v\n", notification.ID, err)
+		return nil
+	}
+	if !duplicate {
+		return nil
+	}
+
+	if logger != nil {
+		logger.Log(storage.LogEntry{
+			Notification: notification,
+			Status:       storage.StatusSuppressed,
+			ErrorMessage: fmt.Sprintf("duplicate of %s, suppressed until %s (%d so far)",
+				window.ID, window.ExpiresAt.UTC().Format(time.RFC3339), window.Suppressed),
+			ParentID:     parentID,
+			ProviderType: provider.GetType(),
+		})
+	}
+	return window
+}
+
+// dropDedupWindow removes the window a notification opened when it was not delivered,
+// because it failed, expired or found no room in the delivery queue, so its next
+// duplicate is delivered instead of suppressed. Duplicates already suppressed stay so.
+func dropDedupWindow(repo *storage.Repository, provider providers.Provider, notification *providers.Notification) {
+	if repo == nil || providers.DedupOf(provider) == nil {
+		return
+	}
+	if err := repo.DeleteDedupWindow(notification.ID); err != nil {
+		fmt.Printf("Error removing dedup window of undelivered notification %s: %v\n", notification.ID, err)
+	}
+}
+
+// RepeatSender returns the function the dedup sweeper calls for a window that suppressed
+// duplicates and asked for a follow-up. The follow-up repeats the first notification with
+// the number of duplicates; mutes, quiet hours and provider changes are checked when it
+// is sent. repo stores follow-ups deferred for quiet hours and may be nil.
+func RepeatSender(registry *providers.Registry, logger *storage.NotificationLogger, repo *storage.Repository) scheduler.RepeatSender {
+	return func(window *storage.DedupWindow) {
+		first := window.Notification
+		if first == nil {
+			return
+		}
+
+		provider, err := resolveMember(registry, window.ProviderID)
+		notification := &providers.Notification{
+			ID:         uuid.New().String(),
+			ProviderID: window.ProviderID,
+			Recipient:  window.Recipient,
+			Subject:    first.Subject,
+			Message: fmt.Sprintf("Repeated %d more times since %s: %s",
+				window.Suppressed, first.Timestamp.UTC().Format(time.RFC3339), first.Message),
+			Metadata: map[string]interface{}{
+				"repeat_count": window.Suppressed,
+				"repeat_of":    window.ID,
+			},
+			Priority:  first.Priority,
+			Timestamp: time.Now(),
+			Format:    first.Format,
+		}
+
+		if err == nil && !deferForQuietHours(repo, logger, provider, nil, notification, "").IsZero() {
+			return
+		}
+		dispatchChild(registry, logger, nil, provider, err, window.ProviderType, notification, "", nil, nil)
+	}
+}
+
+// HandleListDedupWindows handles GET /api/v1/dedup-windows
+func HandleListDedupWindows(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		windows, err := repo.ListDedupWindows()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"windows": windows,
+			"count":   len(windows),
+		})
+	}
+}
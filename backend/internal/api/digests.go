@@ -0,0 +1,185 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/scheduler"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/internal/templates"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// holdForDigest adds a notification to the open digest for its provider and recipient
+// instead of delivering it, and records it in history as digested. routeDigest is the
+// digest mode of the routing rule that selected the provider and wins over the provider's
+// own. It returns nil when the notification should be delivered now: digest mode is off,
+// the notification is high priority, there is no database or the digest could not be stored.
+func holdForDigest(repo *storage.Repository, logger *storage.NotificationLogger, provider providers.Provider, notification *providers.Notification, parentID string, routeDigest *providers.DigestConfig) *storage.Digest {
+	config := routeDigest
+	if config == nil {
+		config = providers.DigestOf(provider)
+	}
+	if config == nil || repo == nil || notification.Priority == providers.PriorityHigh {
+		return nil
+	}
+
+	digest, err := repo.AddToDigest(&storage.Digest{
+		ID:           uuid.New().String(),
+		ProviderID:   notification.ProviderID,
+		ProviderType: provider.GetType(),
+		Recipient:    notification.Recipient,
+		Template:     config.Template,
+		FlushAt:      time.Now().Add(config.Window()),
+	}, notification, config.Limit())
+	if err != nil {
+		fmt.Printf("Error adding notification %s to a digest, sending it now: %v\n", notification.ID, err)
+		return nil
+	}
+
+	if logger != nil {
+		logger.Log(storage.LogEntry{
+			Notification: notification,
+			Status:       storage.StatusDigested,
+			ErrorMessage: fmt.Sprintf("held for digest %s, sent by %s", digest.ID, digest.FlushAt.UTC().Format(time.RFC3339)),
+			ParentID:     parentID,
+			DigestID:     digest.ID,
+			ProviderType: provider.GetType(),
+		})
+	}
+	return digest
+}
+
+// DigestSender returns the function the digest flusher calls when a digest is due.
+// The summary is sent with the digest's ID as its notification ID, so history rows of
+// the notifications it holds link to it through digest_id. Held notifications that have
+// expired are recorded as expired and left out. Mutes, quiet hours and
+// provider changes are checked when the summary is sent; repo stores summaries deferred
+// for quiet hours and may be nil. done is called once the summary is delivered, recorded
+// as failed or deferred, or there is nothing left to send.
+func DigestSender(registry *providers.Registry, logger *storage.NotificationLogger, repo *storage.Repository, store *templates.Store) scheduler.DigestSender {
+	return func(digest *storage.Digest, done func()) {
+		// Notifications that expired while they were held are left out of the summary
+		now := time.Now()
+		items := make([]*providers.Notification, 0, len(digest.Items))
+		for _, item := range digest.Items {
+			if item.Expired(now) {
+				logExpired(logger, digest.ProviderType, item, "", 0, nil)
+				continue
+			}
+			items = append(items, item)
+		}
+		digest.Items = items
+		if len(digest.Items) == 0 {
+			done()
+			return
+		}
+
+		provider, err := resolveMember(registry, digest.ProviderID)
+		providerType := digest.ProviderType
+		if provider != nil {
+			providerType = provider.GetType()
+		}
+
+		notification := digestNotification(store, digest, providerType)
+		if err == nil && !deferForQuietHours(repo, logger, provider, nil, notification, "").IsZero() {
+			done()
+			return
+		}
+		dispatchChild(registry, logger, nil, provider, err, digest.ProviderType, notification, "", nil, done)
+	}
+}
+
+// digestNotification builds the summary of a digest for a provider type. The digest's
+// template renders it when it is set and loaded; otherwise it lists the notifications.
+func digestNotification(store *templates.Store, digest *storage.Digest, providerType string) *providers.Notification {
+	items := make([]interface{}, len(digest.Items))
+	priority := providers.PriorityLow
+	for i, item := range digest.Items {
+		items[i] = map[string]interface{}{
+			"id":         item.ID,
+			"subject":    item.Subject,
+			"message":    item.Message,
+			"priority":   item.Priority,
+			"created_at": item.Timestamp.UTC().Format(time.RFC3339),
+			"metadata":   item.Metadata,
+		}
+		if item.Priority != providers.PriorityLow {
+			priority = providers.PriorityNormal
+		}
+	}
+
+	notification := &providers.Notification{
+		ID:         digest.ID,
+		ProviderID: digest.ProviderID,
+		Recipient:  digest.Recipient,
+		Subject:    fmt.Sprintf("Digest: %d notifications", len(digest.Items)),
+		Message:    digestText(digest.Items),
+		Metadata:   map[string]interface{}{"digest_count": len(digest.Items)},
+		Priority:   priority,
+		Timestamp:  time.Now(),
+	}
+
+	if digest.Template == "" {
+		return notification
+	}
+	tmpl, ok := store.Resolve(digest.Template, "")
+	if !ok {
+		fmt.Printf("Digest template %s not found, sending digest %s as a list\n", digest.Template, digest.ID)
+		return notification
+	}
+	rendered, err := tmpl.Render(providerType, map[string]interface{}{
+		"count":       float64(len(digest.Items)),
+		"items":       items,
+		"provider_id": digest.ProviderID,
+		"recipient":   digest.Recipient,
+		"first_at":    digest.Items[0].Timestamp.UTC().Format(time.RFC3339),
+		"last_at":     digest.Items[len(digest.Items)-1].Timestamp.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		fmt.Printf("Failed to render digest template %s, sending digest %s as a list: %v\n", digest.Template, digest.ID, err)
+		return notification
+	}
+
+	if rendered.Subject != "" {
+		notification.Subject = rendered.Subject
+	}
+	notification.Message = rendered.Text
+	notification.HTML = rendered.HTML
+	return notification
+}
+
+// digestText lists the notifications of a digest, one per line
+func digestText(items []*providers.Notification) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d notifications:\n", len(items))
+	for _, item := range items {
+		b.WriteString("\n- ")
+		if item.Subject != "" {
+			b.WriteString(item.Subject)
+			b.WriteString(": ")
+		}
+		b.WriteString(item.Message)
+	}
+	return b.String()
+}
+
+// HandleListDigests handles GET /api/v1/digests
+func HandleListDigests(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		digests, err := repo.ListDigests()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"digests": digests,
+			"count":   len(digests),
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// maxTTL is the longest ttl_seconds a notification can have
+const maxTTL = maxScheduleAhead
+
+// resolveExpiry validates expires_at and ttl_seconds and records the resulting expiry on
+// the request. ttl_seconds counts from the send time of scheduled requests and from now
+// otherwise, so it must run after resolveSchedule. Requests without either never expire.
+func resolveExpiry(req *NotificationRequest, now time.Time) []ValidationError {
+	if req.ExpiresAt == "" && req.TTLSeconds == 0 {
+		return nil
+	}
+
+	start := now
+	if !req.sendAt.IsZero() {
+		start = req.sendAt
+	}
+
+	var expiresAt time.Time
+	switch {
+	case req.ExpiresAt != "" && req.TTLSeconds != 0:
+		return []ValidationError{{
+			Field:   "expires_at",
+			Message: "expires_at and ttl_seconds cannot both be set",
+		}}
+	case req.TTLSeconds < 0:
+		return []ValidationError{{
+			Field:   "ttl_seconds",
+			Message: "ttl_seconds must be positive",
+		}}
+	case req.TTLSeconds > int(maxTTL/time.Second):
+		return []ValidationError{{
+			Field:   "ttl_seconds",
+			Message: fmt.Sprintf("ttl_seconds must not exceed %d", int(maxTTL/time.Second)),
+		}}
+	case req.TTLSeconds > 0:
+		expiresAt = start.Add(time.Duration(req.TTLSeconds) * time.Second)
+	default:
+		var err error
+		expiresAt, err = time.Parse(time.RFC3339, req.ExpiresAt)
+		switch {
+		case err != nil:
+			return []ValidationError{{
+				Field:   "expires_at",
+				Message: "expires_at must be an RFC 3339 timestamp, e.g. 2025-01-02T15:04:05Z",
+			}}
+		case !expiresAt.After(now):
+			return []ValidationError{{
+				Field:   "expires_at",
+				Message: "expires_at must be in the future",
+			}}
+		case !expiresAt.After(start):
+			return []ValidationError{{
+				Field:   "expires_at",
+				Message: "expires_at must be after send_at",
+			}}
+		}
+	}
+
+	req.expiresAt = &expiresAt
+	return nil
+}
+
+// deliveryDeadline bounds a delivery by the provider's delivery timeout and the
+// notification's expiry
+func deliveryDeadline(registry *providers.Registry, provider providers.Provider, notification *providers.Notification) time.Time {
+	deadline := time.Now().Add(registry.DeliveryTimeout(provider))
+	if notification.ExpiresAt != nil && notification.ExpiresAt.Before(deadline) {
+		return *notification.ExpiresAt
+	}
+	return deadline
+}
+
+// logExpired records a notification that was not delivered before its expiry.
+// err is the last delivery error, if any attempt was made.
+func logExpired(logger *storage.NotificationLogger, providerType string, notification *providers.Notification, parentID string, attempts int, err error) {
+	if logger == nil {
+		return
+	}
+
+	message := fmt.Sprintf("expired at %s before delivery", notification.ExpiresAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		message = fmt.Sprintf("expired at %s before delivery succeeded: %v", notification.ExpiresAt.UTC().Format(time.RFC3339), err)
+	}
+	logger.Log(storage.LogEntry{
+		Notification:  notification,
+		Status:        storage.StatusExpired,
+		ErrorMessage:  message,
+		ErrorCategory: providers.ErrorCategory(err),
+		ParentID:      parentID,
+		ProviderType:  providerType,
+		Attempts:      attempts,
+	})
+}
+
+// releaseBeforeExpiry returns the earlier of a hold's release time and the notification's
+// expiry, so held notifications that would be stale when released are expired on time
+func releaseBeforeExpiry(notification *providers.Notification, release time.Time) time.Time {
+	if notification.ExpiresAt != nil && notification.ExpiresAt.Before(release) {
+		return *notification.ExpiresAt
+	}
+	return release
+}
+
+// HandleGetNotificationStats handles GET /api/v1/notifications/stats.
+// It counts history rows by status, with the provider and date filters of the history endpoint.
+func HandleGetNotificationStats(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stats, err := repo.GetNotificationStats(storage.StatsFilters{
+			ProviderID:   c.Query("provider_id"),
+			ProviderType: c.Query("provider_type"),
+			DateFrom:     c.Query("date_from"),
+			DateTo:       c.Query("date_to"),
+			IncludeTests: c.Query("include_tests") != "false", // Default true, as for history
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to retrieve notification stats",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, stats)
+	}
+}
@@ -0,0 +1,364 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ChildNotification describes the delivery to one member of a group send
+type ChildNotification struct {
+	ID          string     `json:"id"`
+	ProviderID  string     `json:"provider_id"`
+	Recipient   string     `json:"recipient,omitempty"`
+	Status      string     `json:"status"` // "queued", "scheduled", "muted", "suppressed", "digested", "deferred", "expired" or "failed"
+	Error       string     `json:"error,omitempty"`
+	DuplicateOf string     `json:"duplicate_of,omitempty"` // First notification of a suppressed duplicate
+	DigestID    string     `json:"digest_id,omitempty"`    // Digest that will deliver a digested notification
+	SendAt      *time.Time `json:"send_at,omitempty"`      // When a deferred notification will be sent
+}
+
+// fanOut is a send delivered to several providers: a provider group, a topic's
+// subscribers, or a routing rule with more than one target
+type fanOut struct {
+	id           string // Provider ID recorded on the parent history row
+	providerType string
+	members      []providers.GroupMember
+	rule         string                  // Routing rule that selected the members, if any
+	digest       *providers.DigestConfig // Digest mode of that rule, if any
+}
+
+// memberDelivery is a validated request for one group member
+type memberDelivery struct {
+	member   providers.GroupMember
+	provider providers.Provider // nil if the member cannot be delivered to
+	request  NotificationRequest
+	err      error
+}
+
+// handleGroupSend fans a notification out to every member of a group.
+// rule is the routing rule that selected the group, if any.
+func handleGroupSend(registry *providers.Registry, logger *storage.NotificationLogger, repo *storage.Repository, group *providers.GroupProvider, req *NotificationRequest, rule string) (int, interface{}) {
+	return handleFanOut(registry, logger, repo, fanOut{
+		id:           group.GetID(),
+		providerType: group.GetType(),
+		members:      group.Members(),
+		rule:         rule,
+	}, req)
+}
+
+// handleFanOut delivers a notification to every member of a fan-out.
+// A parent history row is recorded for the fan-out and one child row per member.
+// Scheduled requests are stored in repo and fanned out when they are due.
+// It returns the status code and body of the response.
+func handleFanOut(registry *providers.Registry, logger *storage.NotificationLogger, repo *storage.Repository, target fanOut, req *NotificationRequest) (int, interface{}) {
+	// The parent row records the plain-text rendering of a template
+	validationErrors := applyTemplate(req, "")
+	deliveries, memberErrors := planGroupDeliveries(registry, target, req)
+	validationErrors = append(validationErrors, memberErrors...)
+	if len(validationErrors) > 0 {
+		return http.StatusBadRequest, gin.H{
+			"error":   "validation failed",
+			"details": validationErrors,
+		}
+	}
+
+	parentID := uuid.New().String()
+	timestamp := time.Now()
+	parent := &providers.Notification{
+		ID:         parentID,
+		ProviderID: target.id,
+		Message:    req.Message,
+		Subject:    req.Subject,
+		Metadata:   req.Metadata,
+		Priority:   req.Priority,
+		Timestamp:  timestamp,
+		JobID:      req.jobID,
+		ExpiresAt:  req.expiresAt,
+	}
+
+	notifications := make([]*providers.Notification, len(deliveries))
+	for i, d := range deliveries {
+		notifications[i] = &providers.Notification{
+			ID:         uuid.New().String(),
+			ProviderID: d.member.ProviderID,
+			Recipient:  d.request.Recipient,
+			Message:    d.request.Message,
+			Subject:    d.request.Subject,
+			Metadata:   req.Metadata,
+			Priority:   req.Priority,
+			Timestamp:  timestamp,
+			HTML:       d.request.html,
+			Format:     d.request.Format,
+			JobID:      req.jobID,
+			ExpiresAt:  req.expiresAt,
+		}
+	}
+
+	var message string
+	switch target.providerType {
+	case providers.ProviderTypeGroup:
+		message = fmt.Sprintf("notification fanned out to %d group members", len(deliveries))
+	case TopicProviderType:
+		message = fmt.Sprintf("notification published to %d subscribers", len(deliveries))
+	default:
+		message = fmt.Sprintf("notification fanned out to %d providers", len(deliveries))
+	}
+
+	// Scheduled fan-outs are stored whole; members are resolved again when they are due
+	if !req.sendAt.IsZero() {
+		children := make([]ChildNotification, len(deliveries))
+		for i, d := range deliveries {
+			children[i] = childNotification(notifications[i], StatusScheduled, d.err)
+		}
+		return scheduledResponse(repo, &storage.ScheduledNotification{
+			ID:           parentID,
+			ProviderID:   target.id,
+			ProviderType: target.providerType,
+			SendAt:       req.sendAt,
+			Notification: parent,
+			Members:      notifications,
+		}, NotificationResponse{
+			Timestamp: timestamp,
+			Message:   fmt.Sprintf("%s at %s", message, req.sendAt.UTC().Format(time.RFC3339)),
+			Children:  children,
+			Rule:      target.rule,
+		})
+	}
+
+	// A muted group records the notification without fanning out
+	if until, muted := registry.MutedUntil(target.id); muted && target.providerType == providers.ProviderTypeGroup {
+		mutedUntil := logMuted(logger, target.providerType, parent, until, "")
+
+		return http.StatusCreated, NotificationResponse{
+			ID:        parentID,
+			Status:    storage.StatusMuted,
+			Timestamp: timestamp,
+			Message:   fmt.Sprintf("group muted until %s, notification not delivered", mutedUntil),
+			Rule:      target.rule,
+		}
+	}
+
+	if logger != nil {
+		logger.Log(storage.LogEntry{
+			Notification: parent,
+			Status:       storage.StatusFannedOut,
+			ProviderType: target.providerType,
+		})
+	}
+
+	children := make([]ChildNotification, 0, len(deliveries))
+	for i, d := range deliveries {
+		children = append(children, dispatchChild(registry, logger, repo, d.provider, d.err, "", notifications[i], parentID, target.digest, nil))
+	}
+
+	return http.StatusCreated, NotificationResponse{
+		ID:        parentID,
+		Status:    "queued",
+		Timestamp: timestamp,
+		Message:   message,
+		Children:  children,
+		Rule:      target.rule,
+	}
+}
+
+// childNotification describes a member delivery in a fan-out response.
+// Members that could not be resolved are reported as failed with their error.
+func childNotification(notification *providers.Notification, status string, err error) ChildNotification {
+	child := ChildNotification{
+		ID:         notification.ID,
+		ProviderID: notification.ProviderID,
+		Recipient:  notification.Recipient,
+		Status:     status,
+	}
+	if err != nil {
+		child.Status = storage.StatusFailed
+		child.Error = err.Error()
+	}
+	return child
+}
+
+// dispatchChild delivers a notification to a resolved provider in the background.
+// A resolution error is recorded as a failed delivery, an expired notification as
+// expired and a muted provider as muted, without sending. When repo is set, providers
+// in digest mode, or routed with a digest, hold the notification for their digest, quiet
+// hours of the provider, or of the contact the recipient is an identity of, defer it
+// until they end, and providers with deduplication suppress repeats of a queued
+// delivery unless it turns out undelivered. A delivery the
+// delivery queue has no room for is recorded as failed. providerType is recorded when
+// provider is nil. done, if set, is called once the
+// outcome is recorded, after the delivery for queued notifications. It returns the
+// delivery for the response.
+func dispatchChild(registry *providers.Registry, logger *storage.NotificationLogger, repo *storage.Repository, provider providers.Provider, err error, providerType string, notification *providers.Notification, parentID string, digest *providers.DigestConfig, done func()) ChildNotification {
+	if provider != nil {
+		providerType = provider.GetType()
+	}
+	queued := false
+	defer func() {
+		if done != nil && !queued {
+			done()
+		}
+	}()
+
+	if err != nil {
+		if logger != nil {
+			logger.Log(storage.LogEntry{
+				Notification:  notification,
+				Status:        storage.StatusFailed,
+				ErrorMessage:  err.Error(),
+				ErrorCategory: providers.ErrorCategory(err),
+				ParentID:      parentID,
+				ProviderType:  providerType,
+			})
+		}
+		return childNotification(notification, storage.StatusFailed, err)
+	}
+
+	if notification.Expired(time.Now()) {
+		logExpired(logger, providerType, notification, parentID, 0, nil)
+		return childNotification(notification, storage.StatusExpired, nil)
+	}
+
+	if until, muted := registry.MutedUntil(notification.ProviderID); muted {
+		logMuted(logger, providerType, notification, until, parentID)
+		return childNotification(notification, storage.StatusMuted, nil)
+	}
+
+	if held := holdForDigest(repo, logger, provider, notification, parentID, digest); held != nil {
+		child := childNotification(notification, storage.StatusDigested, nil)
+		child.DigestID = held.ID
+		return child
+	}
+
+	if until := deferForQuietHours(repo, logger, provider, memberContact(repo, notification), notification, parentID); !until.IsZero() {
+		child := childNotification(notification, storage.StatusDeferred, nil)
+		child.SendAt = &until
+		return child
+	}
+
+	if window := suppressDuplicate(repo, logger, provider, notification, parentID); window != nil {
+		child := childNotification(notification, storage.StatusSuppressed, nil)
+		child.DuplicateOf = window.ID
+		return child
+	}
+
+	err = registry.Enqueue(notification.Priority, func(ctx context.Context) {
+		if !deliver(ctx, registry, logger, provider, notification, parentID) {
+			dropDedupWindow(repo, provider, notification)
+		}
+		if done != nil {
+			done()
+		}
+	})
+	if err != nil {
+		// The delivery queue is full
+		dropDedupWindow(repo, provider, notification)
+		if logger != nil {
+			logger.Log(storage.LogEntry{
+				Notification:  notification,
+				Status:        storage.StatusFailed,
+				ErrorMessage:  err.Error(),
+				ErrorCategory: providers.ErrorCategory(err),
+				ParentID:      parentID,
+				ProviderType:  providerType,
+			})
+		}
+		return childNotification(notification, storage.StatusFailed, err)
+	}
+	queued = true
+	return childNotification(notification, "queued", nil)
+}
+
+// memberContact returns the contact whose identity a delivery is addressed to, so
+// its quiet hours apply as they do to sends to "contact:<id>". It returns nil when
+// there is no database or no such contact.
+func memberContact(repo *storage.Repository, notification *providers.Notification) *storage.Contact {
+	if repo == nil || notification.Recipient == "" {
+		return nil
+	}
+	contact, err := repo.FindContactByIdentity(notification.ProviderID, notification.Recipient)
+	if err != nil {
+		if !errors.Is(err, storage.ErrContactNotFound) {
+			fmt.Printf("Error looking up contact of notification %s, ignoring contact quiet hours: %v\n", notification.ID, err)
+		}
+		return nil
+	}
+	return contact
+}
+
+// planGroupDeliveries resolves the provider and recipient of every fan-out member and
+// validates the request against each member's capabilities. Members that are not
+// registered are planned as failed deliveries rather than rejecting the request,
+// so one missing provider does not stop the others.
+func planGroupDeliveries(registry *providers.Registry, target fanOut, req *NotificationRequest) ([]memberDelivery, []ValidationError) {
+	var validationErrors []ValidationError
+
+	members := target.members
+	known := make(map[string]bool, len(members))
+	for _, member := range members {
+		known[member.ProviderID] = true
+	}
+	for providerID := range req.Recipients {
+		if !known[providerID] {
+			validationErrors = append(validationErrors, ValidationError{
+				Field:   "recipients." + providerID,
+				Message: fmt.Sprintf("%s is not a member of %s %s", providerID, target.providerType, target.id),
+			})
+		}
+	}
+
+	deliveries := make([]memberDelivery, 0, len(members))
+	for _, member := range members {
+		d := memberDelivery{member: member, request: *req}
+		d.request.ProviderID = member.ProviderID
+		d.request.Recipient = memberRecipient(member, req)
+
+		d.provider, d.err = resolveMember(registry, member.ProviderID)
+		if d.err == nil {
+			caps := registry.CapabilitiesOf(d.provider)
+			memberErrors := applyTemplate(&d.request, d.provider.GetType())
+			adaptToCapabilities(&d.request, &caps)
+			memberErrors = append(memberErrors, ValidateNotificationRequest(&d.request, &caps)...)
+			for _, verr := range memberErrors {
+				verr.Field = fmt.Sprintf("members.%s.%s", member.ProviderID, verr.Field)
+				validationErrors = append(validationErrors, verr)
+			}
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, validationErrors
+}
+
+// resolveMember looks up the provider of a fan-out member. Missing providers and nested
+// groups are permanent errors; the provider is returned with the error when it exists.
+func resolveMember(registry *providers.Registry, providerID string) (providers.Provider, error) {
+	provider, err := registry.Get(providerID)
+	switch {
+	case err != nil:
+		return nil, providers.NewPermanentError(fmt.Errorf("provider not found: %s", providerID))
+	case provider.GetType() == providers.ProviderTypeGroup:
+		return provider, providers.NewPermanentError(fmt.Errorf("nested group %s is not supported", providerID))
+	}
+	return provider, nil
+}
+
+// memberRecipient picks the recipient for a group member: a per-member recipient in the
+// request wins over the one configured for the member, which wins over the request's recipient
+func memberRecipient(member providers.GroupMember, req *NotificationRequest) string {
+	if recipient := req.Recipients[member.ProviderID]; recipient != "" {
+		return recipient
+	}
+	if member.Recipient != "" {
+		return member.Recipient
+	}
+	return req.Recipient
+}
@@ -2,25 +2,62 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/developertyrone/notimulti/internal/logging"
 	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/routing"
 	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/internal/templates"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 // NotificationRequest represents the incoming notification request
 type NotificationRequest struct {
-	ProviderID string                 `json:"provider_id" binding:"required"`
-	Recipient  string                 `json:"recipient" binding:"required"`
-	Message    string                 `json:"message" binding:"required"`
+	ProviderID string                 `json:"provider_id"`      // Chosen by the routing rules when empty
+	Recipient  string                 `json:"recipient"`        // Required unless provider_id is a group
+	Message    string                 `json:"message"`          // Required unless template is set
+	Format     string                 `json:"format,omitempty"` // "text" (default) or "markdown"
 	Subject    string                 `json:"subject,omitempty"`
 	Metadata   map[string]interface{} `json:"metadata,omitempty"`
 	Priority   string                 `json:"priority,omitempty"`
+
+	// Recipients overrides the configured recipient of group members, keyed by provider ID
+	Recipients map[string]string `json:"recipients,omitempty"`
+
+	// Template renders the subject and message from a named template and Data,
+	// separately for each target provider type
+	Template string                 `json:"template,omitempty"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+	// Locale selects the template variant and, with Timezone, how numbers and dates are
+	// formatted. Both default to the contact's settings for "contact:<id>" recipients.
+	Locale   string `json:"locale,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+
+	// SendAt (RFC 3339) or DelaySeconds holds the notification until a later time
+	SendAt       string `json:"send_at,omitempty"`
+	DelaySeconds int    `json:"delay_seconds,omitempty"`
+
+	// ExpiresAt (RFC 3339) or TTLSeconds is when the notification becomes stale; it is
+	// recorded as expired instead of being sent late. TTLSeconds counts from the send time.
+	ExpiresAt  string `json:"expires_at,omitempty"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty"`
+
+	// IdempotencyKey makes retries safe: a repeated key returns the original response.
+	// It can also be sent in the Idempotency-Key header.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	template  *templates.Template // Resolved Template variant
+	format    templates.Format    // Resolved Locale and Timezone
+	html      string              // Template rendering in the target provider's HTML dialect
+	sendAt    time.Time           // Resolved SendAt or DelaySeconds; zero to send now
+	expiresAt *time.Time          // Resolved ExpiresAt or TTLSeconds; nil if it never expires
+	jobID     string              // Recurring job sending the request, if any
 }
 
 // NotificationResponse represents the response after sending a notification
@@ -29,6 +66,19 @@ type NotificationResponse struct {
 	Status    string    `json:"status"`
 	Timestamp time.Time `json:"timestamp"`
 	Message   string    `json:"message,omitempty"`
+
+	// Children lists the member deliveries of a group send
+	Children []ChildNotification `json:"children,omitempty"`
+	// Rule is the routing rule that selected the provider, for requests without provider_id
+	Rule string `json:"rule,omitempty"`
+	// Contact reports the identity a "contact:<id>" recipient resolved to
+	Contact *ContactResolution `json:"contact,omitempty"`
+	// SendAt is when a scheduled or deferred notification will be sent
+	SendAt *time.Time `json:"send_at,omitempty"`
+	// DigestID is the digest that will deliver a notification held in digest mode
+	DigestID string `json:"digest_id,omitempty"`
+	// DuplicateOf is the first notification of a suppressed duplicate
+	DuplicateOf string `json:"duplicate_of,omitempty"`
 }
 
 // HealthResponse represents the health check response
@@ -38,8 +88,16 @@ type HealthResponse struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
-// HandleSendNotification handles POST /api/v1/notifications
-func HandleSendNotification(registry *providers.Registry, logger *storage.NotificationLogger) gin.HandlerFunc {
+// HandleSendNotification handles POST /api/v1/notifications.
+// repo resolves "contact:<id>" recipients and stores scheduled notifications and
+// idempotency keys, engine routes requests without a provider_id and store holds the
+// templates requests can name; any may be nil. Idempotency keys are remembered for
+// idempotencyTTL, or DefaultIdempotencyTTL when it is not positive.
+func HandleSendNotification(registry *providers.Registry, logger *storage.NotificationLogger, repo *storage.Repository, engine *routing.Engine, store *templates.Store, idempotencyTTL time.Duration) gin.HandlerFunc {
+	if idempotencyTTL <= 0 {
+		idempotencyTTL = DefaultIdempotencyTTL
+	}
+	s := &sender{registry: registry, logger: logger, repo: repo, engine: engine, store: store, idempotencyTTL: idempotencyTTL}
 	return func(c *gin.Context) {
 		var req NotificationRequest
 
@@ -52,9 +110,7 @@ func HandleSendNotification(registry *providers.Registry, logger *storage.Notifi
 			return
 		}
 
-		// Validate request
-		validationErrors := ValidateNotificationRequest(&req)
-		if len(validationErrors) > 0 {
+		if validationErrors := resolveIdempotencyKey(c.GetHeader(IdempotencyKeyHeader), &req, repo != nil); len(validationErrors) > 0 {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":   "validation failed",
 				"details": validationErrors,
@@ -62,79 +118,362 @@ func HandleSendNotification(registry *providers.Registry, logger *storage.Notifi
 			return
 		}
 
-		// Get provider from registry
-		provider, err := registry.Get(req.ProviderID)
-		if err != nil || provider == nil {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": fmt.Sprintf("provider not found: %s", req.ProviderID),
-			})
+		if req.IdempotencyKey != "" {
+			s.sendOnce(c, &req, c.GetHeader(APIKeyHeader))
 			return
 		}
+		c.JSON(s.send(&req, c.GetHeader(APIKeyHeader)))
+	}
+}
+
+// sender runs the send pipeline shared by the send endpoint and recurring jobs
+type sender struct {
+	registry *providers.Registry
+	logger   *storage.NotificationLogger
+	repo     *storage.Repository
+	engine   *routing.Engine
+	store    *templates.Store
+
+	idempotencyTTL time.Duration // How long idempotency keys of sends are remembered
+}
+
+// send validates, routes and delivers a notification request, and returns the status
+// code and body of the response. apiKey is the caller's API key, used by routing rules.
+func (s *sender) send(req *NotificationRequest, apiKey string) (int, interface{}) {
+	registry, logger, repo, engine, store := s.registry, s.logger, s.repo, s.engine, s.store
+
+	// Scheduled requests are validated now and stored until their send time
+	now := time.Now()
+	if validationErrors := resolveSchedule(repo, req, now); len(validationErrors) > 0 {
+		return http.StatusBadRequest, gin.H{
+			"error":   "validation failed",
+			"details": validationErrors,
+		}
+	}
+
+	// Requests with an expiry are recorded as expired instead of being sent late
+	if validationErrors := resolveExpiry(req, now); len(validationErrors) > 0 {
+		return http.StatusBadRequest, gin.H{
+			"error":   "validation failed",
+			"details": validationErrors,
+		}
+	}
+
+	// Contact recipients pick the provider and address from the contact directory
+	var contact *storage.Contact
+	var resolution *ContactResolution
+	if strings.HasPrefix(req.Recipient, ContactPrefix) {
+		var validationErrors []ValidationError
+		var err error
+		contact, validationErrors, err = resolveContact(registry, repo, req)
+		if err != nil {
+			return http.StatusInternalServerError, gin.H{"error": err.Error()}
+		}
+		if len(validationErrors) > 0 {
+			return http.StatusBadRequest, gin.H{
+				"error":   "validation failed",
+				"details": validationErrors,
+			}
+		}
+		resolution = &ContactResolution{ID: contact.ID, ProviderID: req.ProviderID, Recipient: req.Recipient}
+		if req.Locale == "" {
+			req.Locale = contact.Locale
+		}
+		if req.Timezone == "" {
+			req.Timezone = contact.Timezone
+		}
+	}
+
+	// Templated requests are checked once and rendered per target provider
+	if req.Template != "" {
+		if validationErrors := resolveTemplate(store, req); len(validationErrors) > 0 {
+			return http.StatusBadRequest, gin.H{
+				"error":   "validation failed",
+				"details": validationErrors,
+			}
+		}
+	}
+
+	// Requests without a provider are routed by the routing rules, if any are loaded
+	var rule string
+	var digest *providers.DigestConfig
+	if req.ProviderID == "" && len(engine.Rules()) > 0 {
+		subject, validationErrors := templateSubject(req)
+		if len(validationErrors) > 0 {
+			return http.StatusBadRequest, gin.H{
+				"error":   "validation failed",
+				"details": validationErrors,
+			}
+		}
 
-		// Generate notification ID
-		notificationID := uuid.New().String()
-		timestamp := time.Now()
-
-		// Create notification object
-		notification := &providers.Notification{
-			ID:         notificationID,
-			ProviderID: req.ProviderID,
-			Recipient:  req.Recipient,
-			Message:    req.Message,
-			Subject:    req.Subject,
-			Metadata:   req.Metadata,
-			Priority:   req.Priority,
-			Timestamp:  timestamp,
-		}
-
-		// Send notification asynchronously
-		go func() {
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			defer cancel()
-
-			// Track attempts for logging
-			attempts := 1
-			err := provider.Send(ctx, notification)
-
-			// Log to database
-			if logger != nil {
-				status := storage.StatusSent
-				errorMsg := ""
-				deliveredAt := time.Now().Format(time.RFC3339)
-				if err != nil {
-					status = storage.StatusFailed
-					errorMsg = err.Error()
-					deliveredAt = ""
-				}
-
-				logEntry := storage.LogEntry{
-					Notification: notification,
-					Status:       status,
-					ErrorMessage: errorMsg,
-					ProviderType: provider.GetType(),
-					Attempts:     attempts,
-					DeliveredAt:  deliveredAt,
-					IsTest:       false,
-				}
-				logger.Log(logEntry)
+		decision := engine.Route(routingInput(apiKey, req, subject))
+		if !decision.Matched {
+			return http.StatusUnprocessableEntity, gin.H{
+				"error":   "no routing rule matched",
+				"details": decision.Evaluations,
 			}
+		}
 
-			// Log to console for debugging
-			if err != nil {
-				fmt.Printf("Error sending notification %s: %v\n", notificationID, err)
-			} else {
-				fmt.Printf("Notification %s sent successfully\n", notificationID)
+		rule = decision.Rule
+		digest = decision.Digest
+		if len(decision.Targets) > 1 {
+			members := make([]providers.GroupMember, len(decision.Targets))
+			for i, target := range decision.Targets {
+				members[i] = targetMember(target)
 			}
-		}()
+			return handleFanOut(registry, logger, repo, fanOut{
+				id:           decision.Rule,
+				providerType: RoutedProviderType,
+				members:      members,
+				rule:         rule,
+				digest:       digest,
+			}, req)
+		}
+
+		target := targetMember(decision.Targets[0])
+		req.Recipient = memberRecipient(target, req)
+		req.ProviderID = target.ProviderID
+	}
+
+	// Groups fan out to their members, each validated against its own capabilities
+	provider, err := registry.Get(req.ProviderID)
+	if group, ok := provider.(*providers.GroupProvider); ok && err == nil {
+		return handleGroupSend(registry, logger, repo, group, req, rule)
+	}
+
+	// Validate request against the target provider's capabilities.
+	// Unknown providers are validated with the defaults so malformed requests still get a 400.
+	var caps *providers.Capabilities
+	providerType := ""
+	if err == nil && provider != nil {
+		providerCaps := registry.CapabilitiesOf(provider)
+		caps = &providerCaps
+		providerType = provider.GetType()
+	}
+
+	validationErrors := applyTemplate(req, providerType)
+	adaptToCapabilities(req, caps)
+	validationErrors = append(validationErrors, ValidateNotificationRequest(req, caps)...)
+	if len(validationErrors) > 0 {
+		return http.StatusBadRequest, gin.H{
+			"error":   "validation failed",
+			"details": validationErrors,
+		}
+	}
+
+	if caps == nil {
+		return http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("provider not found: %s", req.ProviderID),
+		}
+	}
+
+	// Generate notification ID
+	notificationID := uuid.New().String()
+	timestamp := time.Now()
+
+	// Create notification object
+	notification := &providers.Notification{
+		ID:         notificationID,
+		ProviderID: req.ProviderID,
+		Recipient:  req.Recipient,
+		Message:    req.Message,
+		Subject:    req.Subject,
+		Metadata:   req.Metadata,
+		Priority:   req.Priority,
+		Timestamp:  timestamp,
+		HTML:       req.html,
+		Format:     req.Format,
+		JobID:      req.jobID,
+		ExpiresAt:  req.expiresAt,
+	}
+
+	// Muted providers record the notification without delivering it.
+	// Scheduled notifications are checked when they are sent.
+	scheduled := !req.sendAt.IsZero()
+	if until, muted := registry.MutedUntil(req.ProviderID); muted && !scheduled {
+		mutedUntil := logMuted(logger, provider.GetType(), notification, until, "")
 
-		// Return 201 with notification ID
-		c.JSON(http.StatusCreated, NotificationResponse{
+		return http.StatusCreated, NotificationResponse{
 			ID:        notificationID,
-			Status:    "queued",
+			Status:    storage.StatusMuted,
+			Timestamp: timestamp,
+			Message:   fmt.Sprintf("provider muted until %s, notification not delivered", mutedUntil),
+			Rule:      rule,
+			Contact:   resolution,
+		}
+	}
+
+	if scheduled {
+		// A notification due in its contact's quiet hours is sent when they end, or
+		// expired if that is too late; provider quiet hours are checked when it is sent
+		if until, _ := quietUntil(nil, contact, notification, req.sendAt); !until.IsZero() {
+			req.sendAt = releaseBeforeExpiry(notification, until)
+		}
+		return scheduledResponse(repo, &storage.ScheduledNotification{
+			ID:           notificationID,
+			ProviderID:   req.ProviderID,
+			ProviderType: provider.GetType(),
+			SendAt:       req.sendAt,
+			Notification: notification,
+		}, NotificationResponse{
 			Timestamp: timestamp,
-			Message:   "notification queued for delivery",
+			Message:   fmt.Sprintf("notification scheduled for %s", req.sendAt.UTC().Format(time.RFC3339)),
+			Rule:      rule,
+			Contact:   resolution,
 		})
 	}
+
+	// Providers and routing rules in digest mode hold low and normal priority notifications
+	if held := holdForDigest(repo, logger, provider, notification, "", digest); held != nil {
+		return http.StatusCreated, NotificationResponse{
+			ID:        notificationID,
+			Status:    storage.StatusDigested,
+			Timestamp: timestamp,
+			Message:   fmt.Sprintf("notification added to a digest of %d, sent by %s", held.Count, held.FlushAt.UTC().Format(time.RFC3339)),
+			Rule:      rule,
+			Contact:   resolution,
+			DigestID:  held.ID,
+		}
+	}
+
+	// Quiet hours of the contact, or the provider's for the recipient, defer low and normal priority notifications
+	if until := deferForQuietHours(repo, logger, provider, contact, notification, ""); !until.IsZero() {
+		sendAt := until.UTC()
+		return http.StatusCreated, NotificationResponse{
+			ID:        notificationID,
+			Status:    storage.StatusDeferred,
+			Timestamp: timestamp,
+			Message:   fmt.Sprintf("recipient in quiet hours, notification deferred until %s", sendAt.Format(time.RFC3339)),
+			Rule:      rule,
+			Contact:   resolution,
+			SendAt:    &sendAt,
+		}
+	}
+
+	// Providers with deduplication suppress repeats of a queued notification within their
+	// window; the window is removed if that notification is not delivered
+	if window := suppressDuplicate(repo, logger, provider, notification, ""); window != nil {
+		return http.StatusCreated, NotificationResponse{
+			ID:          notificationID,
+			Status:      storage.StatusSuppressed,
+			Timestamp:   timestamp,
+			Message:     fmt.Sprintf("duplicate notification suppressed until %s (%d so far)", window.ExpiresAt.UTC().Format(time.RFC3339), window.Suppressed),
+			Rule:        rule,
+			Contact:     resolution,
+			DuplicateOf: window.ID,
+		}
+	}
+
+	// Send notification asynchronously, ahead of queued lower priority deliveries
+	err = registry.Enqueue(notification.Priority, func(ctx context.Context) {
+		if !deliver(ctx, registry, logger, provider, notification, "") {
+			dropDedupWindow(repo, provider, notification)
+		}
+	})
+	if err != nil {
+		dropDedupWindow(repo, provider, notification)
+		return http.StatusServiceUnavailable, gin.H{"error": err.Error()}
+	}
+
+	// Return 201 with notification ID
+	return http.StatusCreated, NotificationResponse{
+		ID:        notificationID,
+		Status:    "queued",
+		Timestamp: timestamp,
+		Message:   "notification queued for delivery",
+		Rule:      rule,
+		Contact:   resolution,
+	}
+}
+
+// deliver sends a notification through the registry and records the outcome in history.
+// parentID links the delivery to a group send, if any. Deliveries through a failover
+// chain record the hop that delivered. A notification that expires while it waits in
+// the delivery queue or is retried is recorded as expired. ctx is the context the
+// delivery queue runs it with. It reports whether the notification was sent.
+func deliver(ctx context.Context, registry *providers.Registry, logger *storage.NotificationLogger, provider providers.Provider, notification *providers.Notification, parentID string) bool {
+	if notification.Expired(time.Now()) {
+		logExpired(logger, provider.GetType(), notification, parentID, 0, nil)
+		fmt.Printf("Notification %s expired before delivery\n", notification.ID)
+		return false
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, deliveryDeadline(registry, provider, notification))
+	defer cancel()
+
+	// Track attempts for logging
+	ctx, counter := providers.WithAttemptCounter(ctx)
+	var err error
+	var deliveredVia string
+	if chain, ok := provider.(*providers.FailoverProvider); ok {
+		var result *providers.FailoverResult
+		result, err = registry.SendFailover(ctx, chain, notification)
+		deliveredVia = result.ProviderID
+	} else {
+		err = registry.Send(ctx, provider, notification)
+	}
+	attempts := counter.Count()
+	if attempts == 0 && !errors.Is(err, providers.ErrCircuitOpen) {
+		// Providers without a retry policy make a single attempt
+		attempts = 1
+	}
+
+	if providers.ExpiredDuring(ctx, notification, err) {
+		logExpired(logger, provider.GetType(), notification, parentID, attempts, err)
+		fmt.Printf("Notification %s expired before delivery succeeded: %v\n", notification.ID, err)
+		return false
+	}
+
+	// Log to database
+	if logger != nil {
+		status := storage.StatusSent
+		errorMsg := ""
+		deliveredAt := time.Now().Format(time.RFC3339)
+		if err != nil {
+			status = storage.StatusFailed
+			errorMsg = err.Error()
+			deliveredAt = ""
+		}
+
+		logEntry := storage.LogEntry{
+			Notification:  notification,
+			Status:        status,
+			ErrorMessage:  errorMsg,
+			ErrorCategory: providers.ErrorCategory(err),
+			ParentID:      parentID,
+			DeliveredVia:  deliveredVia,
+			ProviderType:  provider.GetType(),
+			Attempts:      attempts,
+			DeliveredAt:   deliveredAt,
+			IsTest:        false,
+		}
+		logger.Log(logEntry)
+	}
+
+	// Log to console for debugging
+	if err != nil {
+		fmt.Printf("Error sending notification %s (%s): %v\n", notification.ID, providers.ErrorCategory(err), err)
+	} else {
+		fmt.Printf("Notification %s sent successfully\n", notification.ID)
+	}
+	return err == nil
+}
+
+// logMuted records a notification that was not delivered because its provider is muted.
+// It returns the end of the mute formatted for responses.
+func logMuted(logger *storage.NotificationLogger, providerType string, notification *providers.Notification, until time.Time, parentID string) string {
+	mutedUntil := until.UTC().Format(time.RFC3339)
+	if logger != nil {
+		logger.Log(storage.LogEntry{
+			Notification: notification,
+			Status:       storage.StatusMuted,
+			ErrorMessage: fmt.Sprintf("provider muted until %s", mutedUntil),
+			ParentID:     parentID,
+			ProviderType: providerType,
+			Attempts:     0,
+		})
+	}
+	return mutedUntil
 }
 
 // HandleHealthCheck handles GET /api/v1/health
@@ -155,7 +494,7 @@ func HandleGetProviders(registry *providers.Registry) gin.HandlerFunc {
 		response := make([]gin.H, len(providersList))
 
 		for i, p := range providersList {
-			status := p.GetStatus()
+			status := registry.StatusOf(p)
 			summary := gin.H{
 				"id":           p.GetID(),
 				"type":         p.GetType(),
@@ -176,6 +515,16 @@ func HandleGetProviders(registry *providers.Registry) gin.HandlerFunc {
 				summary["last_test_status"] = status.LastTestStatus
 			}
 
+			addHealthCheckFields(summary, status)
+
+			if status.CircuitState != "" {
+				summary["circuit_state"] = status.CircuitState
+			}
+
+			if until, muted := registry.MutedUntil(p.GetID()); muted {
+				summary["muted_until"] = until.UTC().Format(time.RFC3339)
+			}
+
 			response[i] = summary
 		}
 
@@ -186,6 +535,16 @@ func HandleGetProviders(registry *providers.Registry) gin.HandlerFunc {
 	}
 }
 
+// addHealthCheckFields adds the cached background health check results to a provider response
+func addHealthCheckFields(response gin.H, status *providers.ProviderStatus) {
+	if status.LastCheckedAt == nil {
+		return
+	}
+	response["last_checked_at"] = status.LastCheckedAt.Format(time.RFC3339)
+	response["latency_ms"] = status.LatencyMs
+	response["consecutive_failures"] = status.ConsecutiveFailures
+}
+
 // HandleGetProvider handles GET /api/v1/providers/:id
 func HandleGetProvider(registry *providers.Registry) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -199,7 +558,7 @@ func HandleGetProvider(registry *providers.Registry) gin.HandlerFunc {
 			return
 		}
 
-		status := provider.GetStatus()
+		status := registry.StatusOf(provider)
 
 		response := gin.H{
 			"id":              provider.GetID(),
@@ -214,6 +573,28 @@ func HandleGetProvider(registry *providers.Registry) gin.HandlerFunc {
 			response["error_message"] = status.ErrorMessage
 		}
 
+		addHealthCheckFields(response, status)
+
+		if status.CircuitState != "" {
+			response["circuit_state"] = status.CircuitState
+		}
+
+		if until, muted := registry.MutedUntil(id); muted {
+			response["muted_until"] = until.UTC().Format(time.RFC3339)
+		}
+
+		response["capabilities"] = registry.CapabilitiesOf(provider)
+
+		if chain, ok := provider.(*providers.FailoverProvider); ok {
+			response["hops"] = chain.Hops()
+		}
+
+		if rl, ok := provider.(providers.RateLimitedProvider); ok {
+			if status := rl.RateLimiter().Status(); status != nil {
+				response["rate_limit"] = status
+			}
+		}
+
 		c.JSON(http.StatusOK, response)
 	}
 }
@@ -223,15 +604,26 @@ func HandleGetNotificationHistory(repo *storage.Repository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Parse query parameters
 		filters := storage.HistoryFilters{
-			ProviderID:   c.Query("provider_id"),
-			ProviderType: c.Query("provider_type"),
-			Status:       c.Query("status"),
-			DateFrom:     c.Query("date_from"),
-			DateTo:       c.Query("date_to"),
-			IncludeTests: c.Query("include_tests") != "false", // Default true
-			Cursor:       0,
-			PageSize:     50, // Default page size
-			SortOrder:    "DESC",
+			ProviderID:    c.Query("provider_id"),
+			ProviderType:  c.Query("provider_type"),
+			Status:        c.Query("status"),
+			ErrorCategory: c.Query("error_category"),
+			ParentID:      c.Query("parent_id"),
+			JobID:         c.Query("job_id"),
+			DigestID:      c.Query("digest_id"),
+			DateFrom:      c.Query("date_from"),
+			DateTo:        c.Query("date_to"),
+			IncludeTests:  c.Query("include_tests") != "false", // Default true
+			Cursor:        0,
+			PageSize:      50, // Default page size
+			SortOrder:     "DESC",
+		}
+
+		if filters.ErrorCategory != "" && !providers.IsValidErrorCategory(filters.ErrorCategory) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("error_category must be one of: %s", strings.Join(providers.ErrorCategories, ", ")),
+			})
+			return
 		}
 
 		// Parse cursor if provided
@@ -252,12 +644,14 @@ func HandleGetNotificationHistory(repo *storage.Repository) gin.HandlerFunc {
 				})
 				return
 			}
-			if filters.PageSize < 1 || filters.PageSize > 100 {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error": "page_size must be between 1 and 100",
-				})
-				return
-			}
+		}
+
+		if validationErrors := ValidateHistoryQueryParams(filters.ProviderType, filters.Status, filters.DateFrom, filters.DateTo, filters.PageSize); len(validationErrors) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "validation failed",
+				"details": validationErrors,
+			})
+			return
 		}
 
 		// Get notification history
@@ -372,6 +766,22 @@ func HandleTestProvider(registry *providers.Registry, logger *storage.Notificati
 			return
 		}
 
+		if provider.GetType() == providers.ProviderTypeGroup {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    "GROUP_NOT_TESTABLE",
+				"message": fmt.Sprintf("Provider '%s' is a group. Test its member providers instead.", id),
+			})
+			return
+		}
+
+		if provider.GetType() == providers.ProviderTypeFailover {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    "FAILOVER_NOT_TESTABLE",
+				"message": fmt.Sprintf("Provider '%s' is a failover chain. Test its hop providers instead.", id),
+			})
+			return
+		}
+
 		// T054: Check rate limit (10 seconds between tests)
 		status := provider.GetStatus()
 		if status.LastTestAt != nil {
@@ -398,9 +808,15 @@ func HandleTestProvider(registry *providers.Registry, logger *storage.Notificati
 		testCtx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 		defer cancel()
 
+		testCtx, counter := providers.WithAttemptCounter(testCtx)
 		testErr := provider.Test(testCtx)
 		testedAt := time.Now()
 
+		testAttempts := counter.Count()
+		if testAttempts == 0 {
+			testAttempts = 1
+		}
+
 		// Prepare response
 		response := gin.H{
 			"tested_at": testedAt.Format(time.RFC3339),
@@ -433,12 +849,13 @@ func HandleTestProvider(registry *providers.Registry, logger *storage.Notificati
 					Priority:   providers.PriorityNormal,
 					Timestamp:  testedAt,
 				},
-				Status:       logStatus,
-				ErrorMessage: logError,
-				ProviderType: provider.GetType(),
-				Attempts:     1,
-				DeliveredAt:  deliveredAt,
-				IsTest:       true,
+				Status:        logStatus,
+				ErrorMessage:  logError,
+				ErrorCategory: providers.ErrorCategory(testErr),
+				ProviderType:  provider.GetType(),
+				Attempts:      testAttempts,
+				DeliveredAt:   deliveredAt,
+				IsTest:        true,
 			})
 		}
 
@@ -447,6 +864,7 @@ func HandleTestProvider(registry *providers.Registry, logger *storage.Notificati
 			response["result"] = "failed"
 			response["message"] = "Test notification failed"
 			response["error_details"] = testErr.Error()
+			response["error_category"] = providers.ErrorCategory(testErr)
 
 			// T055: Log test failure
 			logging.LogWithContext(c.Request.Context()).Error("Provider test failed",
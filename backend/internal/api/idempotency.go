@@ -0,0 +1,132 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// IdempotencyKeyHeader carries a key chosen by the client that makes retried sends safe.
+// It can also be sent as the idempotency_key field of the request.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on responses replayed for a repeated idempotency key
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// DefaultIdempotencyTTL is how long an idempotency key is remembered
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyLease is how long a key is held for a request that is still being handled.
+// Sends are handled well within it, as deliveries run in the background; a key whose
+// request never completed, e.g. because the server stopped, is free again after it.
+const idempotencyLease = 30 * time.Second
+
+// maxIdempotencyKeyLength limits the length of idempotency keys
+const maxIdempotencyKeyLength = 255
+
+// resolveIdempotencyKey validates the idempotency key of a request, given in header or in
+// the request body, and records it on the request. Keys are stored in the database, so
+// they are rejected when there is none.
+func resolveIdempotencyKey(header string, req *NotificationRequest, available bool) []ValidationError {
+	switch {
+	case header != "" && req.IdempotencyKey != "" && header != req.IdempotencyKey:
+		return []ValidationError{{
+			Field:   "idempotency_key",
+			Message: fmt.Sprintf("idempotency_key does not match the %s header", IdempotencyKeyHeader),
+		}}
+	case header != "":
+		req.IdempotencyKey = header
+	}
+
+	switch {
+	case req.IdempotencyKey == "":
+		return nil
+	case len(req.IdempotencyKey) > maxIdempotencyKeyLength:
+		return []ValidationError{{
+			Field:   "idempotency_key",
+			Message: fmt.Sprintf("idempotency_key must not exceed %d characters", maxIdempotencyKeyLength),
+		}}
+	case !available:
+		return []ValidationError{{
+			Field:   "idempotency_key",
+			Message: "idempotency keys are not available without a database",
+		}}
+	}
+	return nil
+}
+
+// requestHash fingerprints a request, so a key reused for a different request is detected
+func requestHash(req *NotificationRequest) string {
+	body, _ := json.Marshal(req)
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotencyScope returns the scope of a caller's idempotency keys: a hash of their API
+// key, so API keys are not stored in the database, or empty without one
+func idempotencyScope(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// sendOnce sends a request with an idempotency key and records the response under the key,
+// scoped by the caller's API key. A retry with the same key gets the recorded response
+// instead of sending again. Rejected requests are not recorded, so they can be retried;
+// the key of a request that panics is held until its reservation lapses.
+func (s *sender) sendOnce(c *gin.Context, req *NotificationRequest, apiKey string) {
+	scope := idempotencyScope(apiKey)
+	hash := requestHash(req)
+	token := uuid.New().String()
+	existing, err := s.repo.ReserveIdempotencyKey(scope, req.IdempotencyKey, hash, token, time.Now().Add(idempotencyLease))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if existing != nil {
+		switch {
+		case existing.RequestHash != hash:
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": "idempotency key was already used for a different request",
+			})
+		case existing.StatusCode == 0:
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "a request with this idempotency key is still in progress",
+			})
+		default:
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(existing.StatusCode, "application/json; charset=utf-8", existing.Response)
+		}
+		return
+	}
+
+	code, body := s.send(req, apiKey)
+	if code >= http.StatusBadRequest {
+		// Nothing was sent or queued, so the request can be retried with the key
+		if err := s.repo.ReleaseIdempotencyKey(scope, req.IdempotencyKey, token); err != nil {
+			fmt.Printf("Error releasing idempotency key %s: %v\n", req.IdempotencyKey, err)
+		}
+		c.JSON(code, body)
+		return
+	}
+
+	// The notification was accepted, so the key is never released: if the response
+	// cannot be recorded, retries get 409 until the reservation lapses
+	response, err := json.Marshal(body)
+	if err == nil {
+		err = s.repo.CompleteIdempotencyKey(scope, req.IdempotencyKey, token, code, response, time.Now().Add(s.idempotencyTTL))
+	}
+	if err != nil {
+		fmt.Printf("Error recording idempotency key %s: %v\n", req.IdempotencyKey, err)
+	}
+	c.JSON(code, body)
+}
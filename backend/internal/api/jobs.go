@@ -0,0 +1,331 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/routing"
+	"github.com/developertyrone/notimulti/internal/scheduler"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/internal/templates"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// JobProviderType is recorded on the history row of a job run that could not be sent
+const JobProviderType = "job"
+
+// jobError writes the response for an error returned by the job store
+func jobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, storage.ErrJobExists), errors.Is(err, storage.ErrJobConfigManaged):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// HandleListJobs handles GET /api/v1/jobs
+func HandleListJobs(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jobs, err := repo.ListJobs()
+		if err != nil {
+			jobError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"jobs":  jobs,
+			"count": len(jobs),
+		})
+	}
+}
+
+// HandleGetJob handles GET /api/v1/jobs/:id
+func HandleGetJob(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := repo.GetJob(c.Param("id"))
+		if err != nil {
+			jobError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// HandleCreateJob handles POST /api/v1/jobs
+func HandleCreateJob(repo *storage.Repository, store *templates.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var job storage.Job
+		if err := c.ShouldBindJSON(&job); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if validationErrors := validateJob(&job, store, time.Now()); len(validationErrors) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "validation failed",
+				"details": validationErrors,
+			})
+			return
+		}
+
+		created, err := repo.CreateJob(&job)
+		if err != nil {
+			jobError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, created)
+	}
+}
+
+// HandleUpdateJob handles PUT /api/v1/jobs/:id.
+// The body replaces the job's definition and its next run is recalculated.
+func HandleUpdateJob(repo *storage.Repository, store *templates.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var job storage.Job
+		if err := c.ShouldBindJSON(&job); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+		job.ID = c.Param("id")
+
+		if validationErrors := validateJob(&job, store, time.Now()); len(validationErrors) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "validation failed",
+				"details": validationErrors,
+			})
+			return
+		}
+
+		updated, err := repo.UpdateJob(&job)
+		if err != nil {
+			jobError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// HandleDeleteJob handles DELETE /api/v1/jobs/:id. The job's history is kept.
+func HandleDeleteJob(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := repo.DeleteJob(c.Param("id")); err != nil {
+			jobError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// HandlePauseJob handles POST /api/v1/jobs/:id/pause
+func HandlePauseJob(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := repo.SetJobPaused(c.Param("id"), true, nil)
+		if err != nil {
+			jobError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// HandleResumeJob handles POST /api/v1/jobs/:id/resume.
+// The job next runs at its next scheduled time; runs missed while paused are skipped.
+func HandleResumeJob(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := repo.GetJob(c.Param("id"))
+		if err != nil {
+			jobError(c, err)
+			return
+		}
+
+		next, err := scheduler.NextRun(job, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		job, err = repo.SetJobPaused(job.ID, false, &next)
+		if err != nil {
+			jobError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// validateJob checks a job before it is stored and sets its next run.
+// The notification is checked as far as possible without knowing the providers
+// it will be sent to, which may change before the job runs.
+func validateJob(job *storage.Job, store *templates.Store, now time.Time) []ValidationError {
+	var validationErrors []ValidationError
+
+	if !topicNamePattern.MatchString(job.ID) {
+		validationErrors = append(validationErrors, ValidationError{
+			Field:   "id",
+			Message: "id must contain only lowercase letters, numbers, and hyphens",
+		})
+	}
+
+	if job.JitterSeconds < 0 || time.Duration(job.JitterSeconds)*time.Second > scheduler.MaxJitter {
+		validationErrors = append(validationErrors, ValidationError{
+			Field:   "jitter_seconds",
+			Message: fmt.Sprintf("jitter_seconds must be between 0 and %d", int(scheduler.MaxJitter/time.Second)),
+		})
+	}
+
+	if job.Schedule == "" {
+		validationErrors = append(validationErrors, ValidationError{
+			Field:   "schedule",
+			Message: "schedule is required",
+		})
+	} else if _, err := scheduler.ParseSchedule(job.Schedule, job.Timezone); err != nil {
+		field := "schedule"
+		if _, tzErr := time.LoadLocation(job.Timezone); tzErr != nil {
+			field = "timezone"
+		}
+		validationErrors = append(validationErrors, ValidationError{Field: field, Message: err.Error()})
+	}
+
+	req, err := jobRequest(job)
+	if err != nil {
+		validationErrors = append(validationErrors, ValidationError{Field: "notification", Message: err.Error()})
+	} else {
+		var notificationErrors []ValidationError
+		if req.Message == "" && req.Template == "" {
+			notificationErrors = append(notificationErrors, ValidationError{
+				Field:   "message",
+				Message: "message or template is required",
+			})
+		}
+		if req.SendAt != "" || req.DelaySeconds != 0 {
+			notificationErrors = append(notificationErrors, ValidationError{
+				Field:   "send_at",
+				Message: "jobs send on their schedule; send_at and delay_seconds are not allowed",
+			})
+		}
+		if req.ExpiresAt != "" {
+			notificationErrors = append(notificationErrors, ValidationError{
+				Field:   "expires_at",
+				Message: "jobs send on their schedule; use ttl_seconds instead of expires_at",
+			})
+		}
+		if req.IdempotencyKey != "" {
+			notificationErrors = append(notificationErrors, ValidationError{
+				Field:   "idempotency_key",
+				Message: "jobs send on every run; idempotency_key is not allowed",
+			})
+		}
+		if req.Template != "" {
+			notificationErrors = append(notificationErrors, resolveTemplate(store, req)...)
+		}
+		for _, verr := range notificationErrors {
+			verr.Field = "notification." + verr.Field
+			validationErrors = append(validationErrors, verr)
+		}
+	}
+
+	if len(validationErrors) > 0 {
+		return validationErrors
+	}
+
+	job.NextRunAt = nil
+	if !job.Paused {
+		next, err := scheduler.NextRun(job, now)
+		if err != nil {
+			return []ValidationError{{Field: "schedule", Message: err.Error()}}
+		}
+		job.NextRunAt = &next
+	}
+	return nil
+}
+
+// jobRequest decodes the notification a job sends. Template dates are formatted in
+// the job's timezone unless the notification sets its own.
+func jobRequest(job *storage.Job) (*NotificationRequest, error) {
+	if len(bytes.TrimSpace(job.Notification)) == 0 {
+		return nil, errors.New("notification is required")
+	}
+
+	var req NotificationRequest
+	decoder := json.NewDecoder(bytes.NewReader(job.Notification))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return nil, fmt.Errorf("notification must be a notification request: %v", err)
+	}
+	if req.Timezone == "" {
+		req.Timezone = job.Timezone
+	}
+	req.jobID = job.ID
+	return &req, nil
+}
+
+// JobSender returns the function the job runner calls to send a job's notification.
+// The notification goes through the same pipeline as POST /api/v1/notifications, so jobs
+// can use templates, contacts, groups and routing rules. A run that cannot be sent is
+// recorded in history as failed, linked to the job like every delivery it makes.
+func JobSender(registry *providers.Registry, logger *storage.NotificationLogger, repo *storage.Repository, engine *routing.Engine, store *templates.Store) scheduler.JobRun {
+	s := &sender{registry: registry, logger: logger, repo: repo, engine: engine, store: store}
+	return func(job *storage.Job) (string, error) {
+		req, err := jobRequest(job)
+		if err == nil {
+			status, body := s.send(req, "")
+			if response, ok := body.(NotificationResponse); ok && status < http.StatusBadRequest {
+				return response.ID, nil
+			}
+			err = responseError(body)
+		}
+
+		notification := &providers.Notification{
+			ID:        uuid.New().String(),
+			Timestamp: time.Now(),
+			JobID:     job.ID,
+		}
+		if req != nil {
+			notification.ProviderID = req.ProviderID
+			notification.Recipient = req.Recipient
+			notification.Message = req.Message
+			notification.Subject = req.Subject
+			notification.Priority = req.Priority
+		}
+		if logger != nil {
+			logger.Log(storage.LogEntry{
+				Notification:  notification,
+				Status:        storage.StatusFailed,
+				ErrorMessage:  err.Error(),
+				ErrorCategory: providers.ErrorCategoryPermanent,
+				ProviderType:  JobProviderType,
+			})
+		}
+		return notification.ID, err
+	}
+}
+
+// responseError describes an error response body returned by the send pipeline
+func responseError(body interface{}) error {
+	h, ok := body.(gin.H)
+	if !ok {
+		return fmt.Errorf("unexpected response %v", body)
+	}
+	message := fmt.Sprint(h["error"])
+	if details, ok := h["details"]; ok {
+		if encoded, err := json.Marshal(details); err == nil {
+			message += ": " + string(encoded)
+		}
+	}
+	return errors.New(message)
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleGetDeliveryQueue handles GET /api/v1/queue.
+// It reports the workers and backlog of each priority lane; lanes is empty when
+// deliveries are not queued.
+func HandleGetDeliveryQueue(registry *providers.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lanes := []providers.QueueLaneStatus{}
+		if queue := registry.DeliveryQueue(); queue != nil {
+			lanes = queue.Status()
+		}
+
+		queued := 0
+		for _, lane := range lanes {
+			queued += lane.Queued
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"lanes":  lanes,
+			"queued": queued,
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+)
+
+// quietUntil reports when the quiet hours a notification falls in at t end: those of
+// its contact, if any, and those its provider has for the recipient. It returns the
+// later end of the active windows and whose they are, or the zero time when the
+// notification can be delivered at t. High priority notifications are never held.
+func quietUntil(provider providers.Provider, contact *storage.Contact, notification *providers.Notification, t time.Time) (time.Time, string) {
+	if notification.Priority == providers.PriorityHigh {
+		return time.Time{}, ""
+	}
+
+	var until time.Time
+	var owner string
+	if contact != nil {
+		if quiet, end := contact.QuietHours.Active(t, contact.Location()); quiet {
+			until, owner = end, "contact "+contact.ID
+		}
+	}
+	if provider != nil {
+		if quiet, end := providers.QuietHoursOf(provider, notification.Recipient).Active(t, time.UTC); quiet && end.After(until) {
+			until, owner = end, "provider "+provider.GetID()
+		}
+	}
+	return until, owner
+}
+
+// deferForQuietHours holds a notification in quiet hours in the scheduled store until
+// they end, and records it in history as deferred with its release time. A notification
+// that expires first is released at its expiry, when it is recorded as expired. It
+// returns the end of the quiet hours, or the zero time when the notification should be
+// delivered now: it is not in quiet hours, there is no database or it could not be stored.
+func deferForQuietHours(repo *storage.Repository, logger *storage.NotificationLogger, provider providers.Provider, contact *storage.Contact, notification *providers.Notification, parentID string) time.Time {
+	if repo == nil {
+		return time.Time{}
+	}
+	until, owner := quietUntil(provider, contact, notification, notification.Timestamp)
+	if until.IsZero() {
+		return until
+	}
+
+	err := repo.ScheduleNotification(&storage.ScheduledNotification{
+		ID:           notification.ID,
+		ProviderID:   notification.ProviderID,
+		ProviderType: provider.GetType(),
+		SendAt:       releaseBeforeExpiry(notification, until),
+		Notification: notification,
+		ParentID:     parentID,
+		Deferred:     true,
+	})
+	if err != nil {
+		fmt.Printf("Error deferring notification %s for quiet hours, sending it now: %v\n", notification.ID, err)
+		return time.Time{}
+	}
+
+	if logger != nil {
+		logger.Log(storage.LogEntry{
+			Notification: notification,
+			Status:       storage.StatusDeferred,
+			ErrorMessage: fmt.Sprintf("held for quiet hours of %s until %s", owner, until.UTC().Format(time.RFC3339)),
+			ParentID:     parentID,
+			ProviderType: provider.GetType(),
+		})
+	}
+	return until
+}
@@ -7,14 +7,52 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/routing"
 	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/internal/templates"
 	"github.com/gin-gonic/gin"
 )
 
+// RouterOption configures optional services of the API router
+type RouterOption func(*routerOptions)
+
+type routerOptions struct {
+	routing        *routing.Engine
+	templates      *templates.Store
+	idempotencyTTL time.Duration
+}
+
+// WithRoutingEngine routes notifications sent without a provider_id through engine
+func WithRoutingEngine(engine *routing.Engine) RouterOption {
+	return func(o *routerOptions) {
+		o.routing = engine
+	}
+}
+
+// WithTemplates lets notifications name a template from store instead of sending a raw message
+func WithTemplates(store *templates.Store) RouterOption {
+	return func(o *routerOptions) {
+		o.templates = store
+	}
+}
+
+// WithIdempotencyTTL sets how long idempotency keys of sends are remembered (default DefaultIdempotencyTTL)
+func WithIdempotencyTTL(ttl time.Duration) RouterOption {
+	return func(o *routerOptions) {
+		o.idempotencyTTL = ttl
+	}
+}
+
 // SetupRouter initializes and configures the Gin router
-func SetupRouter(registry *providers.Registry, logger *storage.NotificationLogger, repo *storage.Repository) *gin.Engine {
+func SetupRouter(registry *providers.Registry, logger *storage.NotificationLogger, repo *storage.Repository, opts ...RouterOption) *gin.Engine {
+	options := routerOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	// Set Gin to release mode for production
 	gin.SetMode(gin.ReleaseMode)
 
@@ -33,9 +71,15 @@ func SetupRouter(registry *providers.Registry, logger *storage.NotificationLogge
 	v1 := router.Group("/api/v1")
 	{
 		// Notification endpoints
-		v1.POST("/notifications", HandleSendNotification(registry, logger))
+		v1.POST("/notifications", HandleSendNotification(registry, logger, repo, options.routing, options.templates, options.idempotencyTTL))
 		v1.GET("/notifications/history", HandleGetNotificationHistory(repo))
+		v1.GET("/notifications/stats", HandleGetNotificationStats(repo))
+		v1.GET("/notifications/scheduled", HandleListScheduledNotifications(repo))
 		v1.GET("/notifications/:id", HandleGetNotificationDetail(repo))
+		v1.DELETE("/notifications/:id", HandleCancelNotification(repo, logger))
+		v1.GET("/digests", HandleListDigests(repo))
+		v1.GET("/dedup-windows", HandleListDedupWindows(repo))
+		v1.GET("/queue", HandleGetDeliveryQueue(registry))
 
 		// API documentation (Swagger UI + OpenAPI spec)
 		v1.GET("/docs", HandleSwaggerDocs())
@@ -49,6 +93,39 @@ func SetupRouter(registry *providers.Registry, logger *storage.NotificationLogge
 		v1.GET("/providers", HandleGetProviders(registry))
 		v1.GET("/providers/:id", HandleGetProvider(registry))
 		v1.POST("/providers/:id/test", HandleTestProvider(registry, logger))
+
+		// Topics (publish/subscribe)
+		v1.GET("/topics", HandleListTopics(repo))
+		v1.POST("/topics", HandleCreateTopic(repo))
+		v1.GET("/topics/:name", HandleGetTopic(repo))
+		v1.DELETE("/topics/:name", HandleDeleteTopic(repo))
+		v1.POST("/topics/:name/subscribers", HandleAddSubscriber(registry, repo))
+		v1.DELETE("/topics/:name/subscribers/:id", HandleRemoveSubscriber(repo))
+		v1.POST("/topics/:name/publish", HandlePublishTopic(registry, logger, repo, options.templates))
+
+		// Contact directory
+		v1.GET("/contacts", HandleListContacts(repo))
+		v1.POST("/contacts", HandleCreateContact(registry, repo))
+		v1.GET("/contacts/:id", HandleGetContact(repo))
+		v1.PUT("/contacts/:id", HandleUpdateContact(registry, repo))
+		v1.DELETE("/contacts/:id", HandleDeleteContact(repo))
+
+		// Recurring jobs
+		v1.GET("/jobs", HandleListJobs(repo))
+		v1.POST("/jobs", HandleCreateJob(repo, options.templates))
+		v1.GET("/jobs/:id", HandleGetJob(repo))
+		v1.PUT("/jobs/:id", HandleUpdateJob(repo, options.templates))
+		v1.DELETE("/jobs/:id", HandleDeleteJob(repo))
+		v1.POST("/jobs/:id/pause", HandlePauseJob(repo))
+		v1.POST("/jobs/:id/resume", HandleResumeJob(repo))
+
+		// Message templates
+		v1.GET("/templates", HandleListTemplates(options.templates))
+		v1.GET("/templates/:name", HandleGetTemplate(options.templates))
+		v1.POST("/templates/:name/render", HandleRenderTemplate(registry, options.templates))
+
+		// Routing rules
+		v1.POST("/routing/dry-run", HandleRoutingDryRun(options.routing, options.templates))
 	}
 
 	return router
@@ -179,7 +256,7 @@ func CORSMiddleware() gin.HandlerFunc {
 		h.Set("Access-Control-Allow-Origin", allowOrigin)
 		h.Set("Vary", "Origin")
 		h.Set("Access-Control-Allow-Credentials", "true")
-		h.Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		h.Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, accept, origin, Cache-Control, X-Requested-With")
 		h.Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
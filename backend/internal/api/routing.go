@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/routing"
+	"github.com/developertyrone/notimulti/internal/templates"
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader identifies the sending client to routing rules. It is not used for authentication.
+const APIKeyHeader = "X-API-Key"
+
+// RoutedProviderType is recorded on the parent history row of a routed send with several targets
+const RoutedProviderType = "route"
+
+// RoutingDryRunResponse explains how a notification would be routed
+type RoutingDryRunResponse struct {
+	*routing.Decision
+	Recipients map[string]string `json:"recipients,omitempty"` // Resolved recipient per target
+}
+
+// routingInput returns the parts of a request routing rules match on.
+// subject is the request's subject, rendered from its template if it has one.
+func routingInput(apiKey string, req *NotificationRequest, subject string) routing.Input {
+	return routing.Input{
+		Priority: req.Priority,
+		Subject:  subject,
+		Metadata: req.Metadata,
+		APIKey:   apiKey,
+	}
+}
+
+// targetMember converts a routing target to the group member it is delivered as
+func targetMember(target routing.Target) providers.GroupMember {
+	return providers.GroupMember{ProviderID: target.ProviderID, Recipient: target.Recipient}
+}
+
+// HandleRoutingDryRun handles POST /api/v1/routing/dry-run.
+// It accepts a notification request and reports which rule would route it, without sending.
+// Templated requests are matched on the template's rendered subject, as when sent.
+func HandleRoutingDryRun(engine *routing.Engine, store *templates.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req NotificationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if req.Template != "" {
+			if validationErrors := resolveTemplate(store, &req); len(validationErrors) > 0 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "validation failed",
+					"details": validationErrors,
+				})
+				return
+			}
+		}
+		subject, validationErrors := templateSubject(&req)
+		if len(validationErrors) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "validation failed",
+				"details": validationErrors,
+			})
+			return
+		}
+
+		response := RoutingDryRunResponse{Decision: engine.Route(routingInput(c.GetHeader(APIKeyHeader), &req, subject))}
+		for _, target := range response.Targets {
+			if recipient := memberRecipient(targetMember(target), &req); recipient != "" {
+				if response.Recipients == nil {
+					response.Recipients = make(map[string]string)
+				}
+				response.Recipients[target.ProviderID] = recipient
+			}
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
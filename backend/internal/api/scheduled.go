@@ -0,0 +1,205 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// StatusScheduled is reported for notifications held until their send time
+const StatusScheduled = "scheduled"
+
+// maxScheduleAhead is how far in the future a notification can be scheduled
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+// resolveSchedule validates send_at and delay_seconds and records the resulting send time
+// on the request. Requests without either are delivered immediately. Scheduling needs the
+// database, so it is rejected when repo is nil.
+func resolveSchedule(repo *storage.Repository, req *NotificationRequest, now time.Time) []ValidationError {
+	if req.SendAt == "" && req.DelaySeconds == 0 {
+		return nil
+	}
+
+	var validationErrors []ValidationError
+	switch {
+	case req.SendAt != "" && req.DelaySeconds != 0:
+		validationErrors = append(validationErrors, ValidationError{
+			Field:   "send_at",
+			Message: "send_at and delay_seconds cannot both be set",
+		})
+	case req.DelaySeconds < 0:
+		validationErrors = append(validationErrors, ValidationError{
+			Field:   "delay_seconds",
+			Message: "delay_seconds must be positive",
+		})
+	case req.DelaySeconds > int(maxScheduleAhead/time.Second):
+		validationErrors = append(validationErrors, ValidationError{
+			Field:   "delay_seconds",
+			Message: fmt.Sprintf("delay_seconds must not exceed %d", int(maxScheduleAhead/time.Second)),
+		})
+	case req.DelaySeconds > 0:
+		req.sendAt = now.Add(time.Duration(req.DelaySeconds) * time.Second)
+	default:
+		sendAt, err := time.Parse(time.RFC3339, req.SendAt)
+		switch {
+		case err != nil:
+			validationErrors = append(validationErrors, ValidationError{
+				Field:   "send_at",
+				Message: "send_at must be an RFC 3339 timestamp, e.g. 2025-01-02T15:04:05Z",
+			})
+		case !sendAt.After(now):
+			validationErrors = append(validationErrors, ValidationError{
+				Field:   "send_at",
+				Message: "send_at must be in the future",
+			})
+		case sendAt.Sub(now) > maxScheduleAhead:
+			validationErrors = append(validationErrors, ValidationError{
+				Field:   "send_at",
+				Message: "send_at must be within 365 days",
+			})
+		default:
+			req.sendAt = sendAt
+		}
+	}
+
+	if repo == nil && len(validationErrors) == 0 {
+		validationErrors = append(validationErrors, ValidationError{
+			Field:   "send_at",
+			Message: "scheduling is not available without a database",
+		})
+	}
+	return validationErrors
+}
+
+// scheduledResponse stores a notification for later delivery and returns the response
+func scheduledResponse(repo *storage.Repository, scheduled *storage.ScheduledNotification, response NotificationResponse) (int, interface{}) {
+	if err := repo.ScheduleNotification(scheduled); err != nil {
+		return http.StatusInternalServerError, gin.H{"error": err.Error()}
+	}
+
+	sendAt := scheduled.SendAt.UTC()
+	response.ID = scheduled.ID
+	response.Status = StatusScheduled
+	response.SendAt = &sendAt
+	return http.StatusCreated, response
+}
+
+// ScheduledDispatcher returns the function the scheduler calls when a notification is due.
+// Mutes, digest mode, quiet hours and provider changes are checked at send time, not when
+// the notification was scheduled; repo stores held notifications and may be nil.
+// Notifications deferred for quiet hours are only checked for mutes and provider changes.
+// done is called once every member's outcome is recorded.
+func ScheduledDispatcher(registry *providers.Registry, logger *storage.NotificationLogger, repo *storage.Repository) func(*storage.ScheduledNotification, func()) {
+	return func(scheduled *storage.ScheduledNotification, done func()) {
+		if scheduled.Notification == nil {
+			done()
+			return
+		}
+		now := time.Now()
+		notification := scheduled.Notification
+		notification.Timestamp = now
+
+		if scheduled.Deferred {
+			provider, err := resolveMember(registry, scheduled.ProviderID)
+			dispatchChild(registry, logger, nil, provider, err, scheduled.ProviderType, notification, scheduled.ParentID, nil, done)
+			return
+		}
+
+		if len(scheduled.Members) == 0 && scheduled.ProviderType != providers.ProviderTypeGroup {
+			provider, err := resolveMember(registry, scheduled.ProviderID)
+			dispatchChild(registry, logger, repo, provider, err, scheduled.ProviderType, notification, "", nil, done)
+			return
+		}
+
+		// A muted group records the notification without fanning out
+		if until, muted := registry.MutedUntil(scheduled.ProviderID); muted && scheduled.ProviderType == providers.ProviderTypeGroup {
+			logMuted(logger, scheduled.ProviderType, notification, until, "")
+			done()
+			return
+		}
+
+		if logger != nil {
+			logger.Log(storage.LogEntry{
+				Notification: notification,
+				Status:       storage.StatusFannedOut,
+				ProviderType: scheduled.ProviderType,
+			})
+		}
+		memberDone := afterAll(len(scheduled.Members), done)
+		for _, member := range scheduled.Members {
+			member.Timestamp = now
+			provider, err := resolveMember(registry, member.ProviderID)
+			dispatchChild(registry, logger, repo, provider, err, "", member, notification.ID, nil, memberDone)
+		}
+	}
+}
+
+// afterAll returns a function that calls done on its nth call, or calls done right away
+// when n is zero
+func afterAll(n int, done func()) func() {
+	if n == 0 {
+		done()
+		return func() {}
+	}
+	var remaining atomic.Int32
+	remaining.Store(int32(n))
+	return func() {
+		if remaining.Add(-1) == 0 {
+			done()
+		}
+	}
+}
+
+// HandleListScheduledNotifications handles GET /api/v1/notifications/scheduled
+func HandleListScheduledNotifications(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scheduled, err := repo.ListScheduledNotifications()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"notifications": scheduled,
+			"count":         len(scheduled),
+		})
+	}
+}
+
+// HandleCancelNotification handles DELETE /api/v1/notifications/:id.
+// Only notifications still waiting for their send time can be cancelled; the
+// cancellation is recorded in history with status "cancelled".
+func HandleCancelNotification(repo *storage.Repository, logger *storage.NotificationLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		scheduled, err := repo.CancelScheduledNotification(id)
+		if errors.Is(err, storage.ErrScheduledNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": fmt.Sprintf("no scheduled notification with id %s", id),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if logger != nil && scheduled.Notification != nil {
+			scheduled.Notification.Timestamp = time.Now()
+			logger.Log(storage.LogEntry{
+				Notification: scheduled.Notification,
+				Status:       storage.StatusCancelled,
+				ErrorMessage: fmt.Sprintf("cancelled before scheduled send time %s", scheduled.SendAt.UTC().Format(time.RFC3339)),
+				ProviderType: scheduled.ProviderType,
+			})
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/templates"
+	"github.com/gin-gonic/gin"
+)
+
+// resolveTemplate looks up the variant of the template named by a request for the request's
+// locale and checks the request's data against the variant's variables. The template is
+// rendered later, once per target provider.
+func resolveTemplate(store *templates.Store, req *NotificationRequest) []ValidationError {
+	if req.Message != "" || req.Subject != "" {
+		return []ValidationError{{Field: "template", Message: "message and subject come from the template and cannot be set with it"}}
+	}
+	if req.Format != "" {
+		return []ValidationError{{Field: "format", Message: "templates set their own markup in their html and telegram parts"}}
+	}
+
+	format, validationErrors := templateFormat(req.Locale, req.Timezone)
+	if len(validationErrors) > 0 {
+		return validationErrors
+	}
+
+	tmpl, validationErrors := lookupTemplate(store, req.Template, format.Locale)
+	if len(validationErrors) > 0 {
+		return validationErrors
+	}
+
+	for _, problem := range tmpl.CheckData(req.Data) {
+		validationErrors = append(validationErrors, ValidationError{
+			Field:   "data." + problem.Variable,
+			Message: problem.Message,
+		})
+	}
+	if len(validationErrors) > 0 {
+		return validationErrors
+	}
+
+	req.template = tmpl
+	req.format = format
+	return nil
+}
+
+// templateFormat validates a requested locale and timezone and returns the format templates render with
+func templateFormat(locale, timezone string) (templates.Format, []ValidationError) {
+	var format templates.Format
+	var validationErrors []ValidationError
+	if locale != "" {
+		canonical, err := templates.ParseLocale(locale)
+		if err != nil {
+			validationErrors = append(validationErrors, ValidationError{Field: "locale", Message: err.Error()})
+		}
+		format.Locale = canonical
+	}
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			validationErrors = append(validationErrors, ValidationError{Field: "timezone", Message: fmt.Sprintf("unknown timezone %q", timezone)})
+		}
+		format.Location = loc
+	}
+	return format, validationErrors
+}
+
+// lookupTemplate returns the variant of a template for a locale
+func lookupTemplate(store *templates.Store, name, locale string) (*templates.Template, []ValidationError) {
+	tmpl, ok := store.Resolve(name, locale)
+	if ok {
+		return tmpl, nil
+	}
+	if len(store.Locales(name)) == 0 {
+		return nil, []ValidationError{{Field: "template", Message: fmt.Sprintf("template not found: %s", name)}}
+	}
+	return nil, []ValidationError{{Field: "locale", Message: fmt.Sprintf("template %s has no variant for locale %q or its fallbacks", name, locale)}}
+}
+
+// applyTemplate renders the request's template, if any, for a provider type into the
+// request's subject, message and HTML
+func applyTemplate(req *NotificationRequest, providerType string) []ValidationError {
+	if req.template == nil {
+		return nil
+	}
+
+	rendered, err := req.template.RenderWith(providerType, req.Data, req.format)
+	if err != nil {
+		return []ValidationError{{Field: "template", Message: fmt.Sprintf("failed to render template: %v", err)}}
+	}
+
+	req.Subject = rendered.Subject
+	req.Message = rendered.Text
+	req.html = rendered.HTML
+	return nil
+}
+
+// templateSubject returns the subject routing rules match a request on. Templated requests
+// have no subject until rendered, so the template's subject is rendered for it; subjects
+// are the same for every provider, so this can happen before the target is known.
+func templateSubject(req *NotificationRequest) (string, []ValidationError) {
+	if req.template == nil {
+		return req.Subject, nil
+	}
+
+	rendered, err := req.template.RenderWith("", req.Data, req.format)
+	if err != nil {
+		return "", []ValidationError{{Field: "template", Message: fmt.Sprintf("failed to render template: %v", err)}}
+	}
+	return rendered.Subject, nil
+}
+
+// RenderRequest is the body of POST /api/v1/templates/:name/render.
+// One of ProviderID and ProviderType is required.
+type RenderRequest struct {
+	ProviderID   string                 `json:"provider_id,omitempty"`   // Render for this provider
+	ProviderType string                 `json:"provider_type,omitempty"` // Or for the first loaded provider of this type
+	Recipient    string                 `json:"recipient,omitempty"`     // Shown in the payload only
+	Locale       string                 `json:"locale,omitempty"`        // Selects the variant and formats numbers and dates
+	Timezone     string                 `json:"timezone,omitempty"`      // Timezone dates are formatted in; UTC when empty
+	Data         map[string]interface{} `json:"data"`
+}
+
+// RenderResponse shows what a template produces for a provider without sending anything
+type RenderResponse struct {
+	Template     string `json:"template"`
+	Locale       string `json:"locale,omitempty"` // Variant rendered, empty for the unlocalized template
+	ProviderType string `json:"provider_type"`
+	ProviderID   string `json:"provider_id,omitempty"` // Provider whose limits and payload were used
+
+	// Subject and Message are as delivered, after adapting to the provider's capabilities
+	Subject string      `json:"subject,omitempty"`
+	Message string      `json:"message"`
+	HTML    string      `json:"html,omitempty"`
+	Payload interface{} `json:"payload,omitempty"` // What the provider would send, if it can tell
+
+	VariableErrors []templates.VariableError `json:"variable_errors"` // Missing, mistyped or undeclared data
+	SizeErrors     []ValidationError         `json:"size_errors"`     // Limits of the provider the result exceeds
+	RenderError    string                    `json:"render_error,omitempty"`
+	Sendable       bool                      `json:"sendable"` // Whether a send with this data would be accepted
+}
+
+// HandleListTemplates handles GET /api/v1/templates
+func HandleListTemplates(store *templates.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list := store.List()
+		if list == nil {
+			list = []*templates.Template{}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"templates": list,
+			"count":     len(list),
+		})
+	}
+}
+
+// HandleGetTemplate handles GET /api/v1/templates/:name
+func HandleGetTemplate(store *templates.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tmpl, ok := store.Get(c.Param("name"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("template not found: %s", c.Param("name"))})
+			return
+		}
+		c.JSON(http.StatusOK, tmpl)
+	}
+}
+
+// HandleRenderTemplate handles POST /api/v1/templates/:name/render.
+// It renders a template with sample data for a provider and reports problems
+// with the data and the provider's limits, without sending anything.
+func HandleRenderTemplate(registry *providers.Registry, store *templates.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RenderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		format, validationErrors := templateFormat(req.Locale, req.Timezone)
+		if len(validationErrors) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "validation failed",
+				"details": validationErrors,
+			})
+			return
+		}
+
+		tmpl, validationErrors := lookupTemplate(store, c.Param("name"), format.Locale)
+		if len(validationErrors) > 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": validationErrors[0].Message})
+			return
+		}
+
+		var provider providers.Provider
+		switch {
+		case req.ProviderID != "":
+			var err error
+			if provider, err = registry.Get(req.ProviderID); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("provider not found: %s", req.ProviderID)})
+				return
+			}
+			req.ProviderType = provider.GetType()
+		case req.ProviderType != "":
+			provider = firstProviderOfType(registry, req.ProviderType)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "validation failed",
+				"details": []ValidationError{{Field: "provider_id", Message: "provider_id or provider_type is required"}},
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, renderPreview(registry, tmpl, format, provider, &req))
+	}
+}
+
+// renderPreview renders a template the way a send to provider would. provider may be nil,
+// in which case the default limits apply and there is no payload.
+func renderPreview(registry *providers.Registry, tmpl *templates.Template, format templates.Format, provider providers.Provider, req *RenderRequest) *RenderResponse {
+	response := &RenderResponse{
+		Template:       tmpl.Name,
+		Locale:         tmpl.Locale,
+		ProviderType:   req.ProviderType,
+		VariableErrors: tmpl.CheckData(req.Data),
+		SizeErrors:     []ValidationError{},
+	}
+	if response.VariableErrors == nil {
+		response.VariableErrors = []templates.VariableError{}
+	}
+
+	rendered, err := tmpl.RenderWith(req.ProviderType, req.Data, format)
+	if err != nil {
+		response.RenderError = err.Error()
+		return response
+	}
+
+	// Apply the same validation and adaptation as a send; recipients are not part of the preview
+	var caps *providers.Capabilities
+	if provider != nil {
+		response.ProviderID = provider.GetID()
+		providerCaps := registry.CapabilitiesOf(provider)
+		caps = &providerCaps
+	}
+	notification := NotificationRequest{ProviderID: req.ProviderID, Subject: rendered.Subject, Message: rendered.Text}
+	adaptToCapabilities(&notification, caps)
+	for _, verr := range ValidateNotificationRequest(&notification, caps) {
+		if verr.Field == "subject" || verr.Field == "message" {
+			response.SizeErrors = append(response.SizeErrors, verr)
+		}
+	}
+
+	response.Subject = notification.Subject
+	response.Message = notification.Message
+	response.HTML = rendered.HTML
+	if provider != nil {
+		response.Payload, _ = providers.PreviewOf(provider, &providers.Notification{
+			ProviderID: provider.GetID(),
+			Recipient:  req.Recipient,
+			Subject:    notification.Subject,
+			Message:    notification.Message,
+			HTML:       rendered.HTML,
+		})
+	}
+
+	response.Sendable = len(response.VariableErrors) == 0 && len(response.SizeErrors) == 0
+	return response
+}
+
+// firstProviderOfType returns the loaded provider of a type with the lowest ID, or nil
+func firstProviderOfType(registry *providers.Registry, providerType string) providers.Provider {
+	var first providers.Provider
+	for _, provider := range registry.List() {
+		if provider.GetType() == providerType && (first == nil || provider.GetID() < first.GetID()) {
+			first = provider
+		}
+	}
+	return first
+}
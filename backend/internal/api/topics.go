@@ -0,0 +1,276 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/internal/templates"
+	"github.com/gin-gonic/gin"
+)
+
+// TopicProviderType is recorded on the parent history row of a topic publish
+const TopicProviderType = "topic"
+
+// topicNamePattern matches valid topic names, which follow the provider ID format
+var topicNamePattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// CreateTopicRequest is the body of POST /api/v1/topics
+type CreateTopicRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// SubscribeRequest is the body of POST /api/v1/topics/:name/subscribers
+type SubscribeRequest struct {
+	ProviderID string `json:"provider_id" binding:"required"`
+	Recipient  string `json:"recipient"`
+}
+
+// PublishRequest is the body of POST /api/v1/topics/:name/publish
+type PublishRequest struct {
+	Message  string                 `json:"message"` // Required unless template is set
+	Format   string                 `json:"format,omitempty"`
+	Subject  string                 `json:"subject,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Priority string                 `json:"priority,omitempty"`
+	Template string                 `json:"template,omitempty"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+	Locale   string                 `json:"locale,omitempty"`
+	Timezone string                 `json:"timezone,omitempty"`
+
+	// ExpiresAt (RFC 3339) or TTLSeconds is when the notification becomes stale
+	ExpiresAt  string `json:"expires_at,omitempty"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty"`
+}
+
+// TopicResponse is a topic with its subscribers
+type TopicResponse struct {
+	*storage.Topic
+	Subscribers []storage.TopicSubscriber `json:"subscribers"`
+}
+
+// topicError writes the response for an error returned by the topic store
+func topicError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrTopicNotFound), errors.Is(err, storage.ErrSubscriberNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, storage.ErrTopicExists), errors.Is(err, storage.ErrSubscriberExists), errors.Is(err, storage.ErrConfigManaged):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// HandleListTopics handles GET /api/v1/topics
+func HandleListTopics(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		topics, err := repo.ListTopics()
+		if err != nil {
+			topicError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"topics": topics,
+			"count":  len(topics),
+		})
+	}
+}
+
+// HandleCreateTopic handles POST /api/v1/topics
+func HandleCreateTopic(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateTopicRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if !topicNamePattern.MatchString(req.Name) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "validation failed",
+				"details": []ValidationError{{
+					Field:   "name",
+					Message: "name must contain only lowercase letters, numbers, and hyphens",
+				}},
+			})
+			return
+		}
+
+		topic, err := repo.CreateTopic(req.Name, req.Description)
+		if err != nil {
+			topicError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, TopicResponse{Topic: topic, Subscribers: []storage.TopicSubscriber{}})
+	}
+}
+
+// HandleGetTopic handles GET /api/v1/topics/:name
+func HandleGetTopic(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		topic, err := repo.GetTopic(c.Param("name"))
+		if err != nil {
+			topicError(c, err)
+			return
+		}
+
+		subscribers, err := repo.ListSubscribers(topic.Name)
+		if err != nil {
+			topicError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, TopicResponse{Topic: topic, Subscribers: subscribers})
+	}
+}
+
+// HandleDeleteTopic handles DELETE /api/v1/topics/:name
+func HandleDeleteTopic(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := repo.DeleteTopic(c.Param("name")); err != nil {
+			topicError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// HandleAddSubscriber handles POST /api/v1/topics/:name/subscribers.
+// The provider must be loaded and the recipient must suit it.
+func HandleAddSubscriber(registry *providers.Registry, repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SubscribeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if validationErrors := validateSubscriber(registry, &req); len(validationErrors) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "validation failed",
+				"details": validationErrors,
+			})
+			return
+		}
+
+		subscriber, err := repo.AddSubscriber(c.Param("name"), req.ProviderID, req.Recipient)
+		if err != nil {
+			topicError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, subscriber)
+	}
+}
+
+// validateSubscriber checks that a subscription can be delivered by its provider
+func validateSubscriber(registry *providers.Registry, req *SubscribeRequest) []ValidationError {
+	provider, err := registry.Get(req.ProviderID)
+	if err != nil {
+		return []ValidationError{{Field: "provider_id", Message: fmt.Sprintf("provider not found: %s", req.ProviderID)}}
+	}
+	if provider.GetType() == providers.ProviderTypeGroup {
+		return []ValidationError{{Field: "provider_id", Message: "groups cannot subscribe to topics, subscribe their members instead"}}
+	}
+	return validateRecipient(registry, provider, req.Recipient)
+}
+
+// HandleRemoveSubscriber handles DELETE /api/v1/topics/:name/subscribers/:id
+func HandleRemoveSubscriber(repo *storage.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid subscriber ID"})
+			return
+		}
+
+		if err := repo.RemoveSubscriber(c.Param("name"), id); err != nil {
+			topicError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// HandlePublishTopic handles POST /api/v1/topics/:name/publish.
+// The notification fans out to every subscriber like a group send.
+func HandlePublishTopic(registry *providers.Registry, logger *storage.NotificationLogger, repo *storage.Repository, store *templates.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PublishRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		notification := &NotificationRequest{
+			Message:    req.Message,
+			Format:     req.Format,
+			Subject:    req.Subject,
+			Metadata:   req.Metadata,
+			Priority:   req.Priority,
+			Template:   req.Template,
+			Data:       req.Data,
+			Locale:     req.Locale,
+			Timezone:   req.Timezone,
+			ExpiresAt:  req.ExpiresAt,
+			TTLSeconds: req.TTLSeconds,
+		}
+		if validationErrors := resolveExpiry(notification, time.Now()); len(validationErrors) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "validation failed",
+				"details": validationErrors,
+			})
+			return
+		}
+		if req.Template != "" {
+			if validationErrors := resolveTemplate(store, notification); len(validationErrors) > 0 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "validation failed",
+					"details": validationErrors,
+				})
+				return
+			}
+		}
+
+		name := c.Param("name")
+		subscribers, err := repo.ListSubscribers(name)
+		if err != nil {
+			topicError(c, err)
+			return
+		}
+		if len(subscribers) == 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": fmt.Sprintf("topic %s has no subscribers", name),
+			})
+			return
+		}
+
+		members := make([]providers.GroupMember, len(subscribers))
+		for i, s := range subscribers {
+			members[i] = providers.GroupMember{ProviderID: s.ProviderID, Recipient: s.Recipient}
+		}
+
+		c.JSON(handleFanOut(registry, logger, repo, fanOut{
+			id:           name,
+			providerType: TopicProviderType,
+			members:      members,
+		}, notification))
+	}
+}
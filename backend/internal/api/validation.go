@@ -3,7 +3,13 @@ package api
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
+	"unicode/utf8"
+
+	"github.com/developertyrone/notimulti/internal/markdown"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
 )
 
 // ValidationError represents a field validation error
@@ -12,10 +18,39 @@ type ValidationError struct {
 	Message string `json:"message"`
 }
 
-// ValidateNotificationRequest validates a notification request
-func ValidateNotificationRequest(req *NotificationRequest) []ValidationError {
+// adaptToCapabilities adapts a request to what the target provider can deliver:
+// Markdown sent to a provider that cannot convert it is reduced to plain text, and a
+// subject sent to a provider without subject support is folded into the message.
+// Sends adapt a request before validating it, so limits apply to what is delivered.
+// Pass nil capabilities when the provider is unknown to apply the defaults.
+func adaptToCapabilities(req *NotificationRequest, caps *providers.Capabilities) {
+	if caps == nil {
+		defaults := providers.DefaultCapabilities()
+		caps = &defaults
+	}
+
+	if req.Format == providers.FormatMarkdown && !caps.SupportsMarkdown {
+		req.Message = markdown.Text(req.Message)
+		req.Format = ""
+	}
+
+	if req.Subject != "" && !caps.SupportsSubject && req.Message != "" {
+		req.Message = req.Subject + "\n\n" + req.Message
+		req.Subject = ""
+	}
+}
+
+// ValidateNotificationRequest validates a notification request against the
+// capabilities of the target provider without modifying it.
+// Pass nil capabilities when the provider is unknown to apply the default limits.
+func ValidateNotificationRequest(req *NotificationRequest, caps *providers.Capabilities) []ValidationError {
 	var errors []ValidationError
 
+	if caps == nil {
+		defaults := providers.DefaultCapabilities()
+		caps = &defaults
+	}
+
 	// Validate ProviderID
 	if req.ProviderID == "" {
 		errors = append(errors, ValidationError{
@@ -26,9 +61,28 @@ func ValidateNotificationRequest(req *NotificationRequest) []ValidationError {
 
 	// Validate Recipient
 	if req.Recipient == "" {
+		if !caps.RecipientOptional {
+			errors = append(errors, ValidationError{
+				Field:   "recipient",
+				Message: "recipient is required",
+			})
+		}
+	} else if caps.RecipientPattern != "" {
+		if matched, err := regexp.MatchString(caps.RecipientPattern, req.Recipient); err == nil && !matched {
+			errors = append(errors, ValidationError{
+				Field:   "recipient",
+				Message: fmt.Sprintf("recipient '%s' does not match the format required by the provider (%s)", req.Recipient, caps.RecipientPattern),
+			})
+		}
+	}
+
+	// Validate Format
+	switch req.Format {
+	case "", providers.FormatText, providers.FormatMarkdown:
+	default:
 		errors = append(errors, ValidationError{
-			Field:   "recipient",
-			Message: "recipient is required",
+			Field:   "format",
+			Message: fmt.Sprintf("format must be %s or %s (got %q)", providers.FormatText, providers.FormatMarkdown, req.Format),
 		})
 	}
 
@@ -38,18 +92,18 @@ func ValidateNotificationRequest(req *NotificationRequest) []ValidationError {
 			Field:   "message",
 			Message: "message is required",
 		})
-	} else if len(req.Message) > 4096 {
+	} else if length := utf8.RuneCountInString(req.Message); caps.MaxMessageLength > 0 && length > caps.MaxMessageLength {
 		errors = append(errors, ValidationError{
 			Field:   "message",
-			Message: fmt.Sprintf("message must be ≤4096 characters (got %d)", len(req.Message)),
+			Message: fmt.Sprintf("message must be ≤%d characters (got %d)", caps.MaxMessageLength, length),
 		})
 	}
 
 	// Validate Subject (optional but bounded)
-	if len(req.Subject) > 200 {
+	if length := utf8.RuneCountInString(req.Subject); caps.MaxSubjectLength > 0 && length > caps.MaxSubjectLength {
 		errors = append(errors, ValidationError{
 			Field:   "subject",
-			Message: fmt.Sprintf("subject must be ≤200 characters (got %d)", len(req.Subject)),
+			Message: fmt.Sprintf("subject must be ≤%d characters (got %d)", caps.MaxSubjectLength, length),
 		})
 	}
 
@@ -186,30 +240,37 @@ func isNumeric(s string) bool {
 	return true
 }
 
+// historyProviderTypes are the provider types recorded in notification history
+var historyProviderTypes = []string{
+	"telegram", "email", providers.ProviderTypeGroup, providers.ProviderTypeFailover,
+	RoutedProviderType, TopicProviderType, JobProviderType,
+}
+
+// historyStatuses are the statuses of notification history rows
+var historyStatuses = []string{
+	storage.StatusPending, storage.StatusSent, storage.StatusFailed, storage.StatusRetrying,
+	storage.StatusMuted, storage.StatusFannedOut, storage.StatusCancelled, storage.StatusDigested,
+	storage.StatusDeferred, storage.StatusSuppressed, storage.StatusExpired,
+}
+
 // ValidateHistoryQueryParams validates notification history query parameters
 func ValidateHistoryQueryParams(providerType, status, dateFrom, dateTo string, pageSize int) []ValidationError {
 	var errors []ValidationError
 
 	// Validate provider_type if provided
-	if providerType != "" {
-		validTypes := map[string]bool{"telegram": true, "email": true}
-		if !validTypes[providerType] {
-			errors = append(errors, ValidationError{
-				Field:   "provider_type",
-				Message: fmt.Sprintf("provider_type must be one of: telegram, email (got '%s')", providerType),
-			})
-		}
+	if providerType != "" && !slices.Contains(historyProviderTypes, providerType) {
+		errors = append(errors, ValidationError{
+			Field:   "provider_type",
+			Message: fmt.Sprintf("provider_type must be one of: %s (got '%s')", strings.Join(historyProviderTypes, ", "), providerType),
+		})
 	}
 
 	// Validate status if provided
-	if status != "" {
-		validStatuses := map[string]bool{"pending": true, "sent": true, "failed": true, "retrying": true}
-		if !validStatuses[status] {
-			errors = append(errors, ValidationError{
-				Field:   "status",
-				Message: fmt.Sprintf("status must be one of: pending, sent, failed, retrying (got '%s')", status),
-			})
-		}
+	if status != "" && !slices.Contains(historyStatuses, status) {
+		errors = append(errors, ValidationError{
+			Field:   "status",
+			Message: fmt.Sprintf("status must be one of: %s (got '%s')", strings.Join(historyStatuses, ", "), status),
+		})
 	}
 
 	// Validate date_from format (ISO8601) if provided
@@ -262,8 +323,9 @@ func isValidISO8601Date(dateStr string) bool {
 		return false
 	}
 
-	// Simple pattern check for ISO8601: YYYY-MM-DDTHH:MM:SSZ or with timezone offset
-	pattern := `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})$`
+	// Simple pattern check for ISO8601: YYYY-MM-DDTHH:MM:SS, optional fractional seconds
+	// as sent by JavaScript's toISOString, then Z or a timezone offset
+	pattern := `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$`
 	matched, _ := regexp.MatchString(pattern, dateStr)
 	return matched
 }
@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+)
+
+// Handler executes chat commands against the provider registry and notification history
+type Handler struct {
+	registry *providers.Registry
+	repo     *storage.Repository
+	logger   *slog.Logger
+}
+
+// NewHandler creates a new command handler
+func NewHandler(registry *providers.Registry, repo *storage.Repository, logger *slog.Logger) *Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Handler{
+		registry: registry,
+		repo:     repo,
+		logger:   logger,
+	}
+}
+
+// Acknowledge marks a notification as acknowledged in the history
+func (h *Handler) Acknowledge(ctx context.Context, notificationID, actor string) error {
+	if h.repo == nil {
+		return fmt.Errorf("notification history is not available")
+	}
+
+	found, err := h.repo.AcknowledgeNotification(notificationID, actor)
+	if err != nil {
+		return fmt.Errorf("failed to update notification: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("notification not found (it may not be recorded yet, retry shortly)")
+	}
+
+	h.logger.InfoContext(ctx, "Notification acknowledged",
+		"notification_id", notificationID,
+		"actor", actor)
+	return nil
+}
+
+// Mute suppresses deliveries through a provider for the given duration
+func (h *Handler) Mute(ctx context.Context, providerID string, duration time.Duration, actor string) error {
+	until := time.Now().Add(duration)
+	if err := h.registry.Mute(providerID, until); err != nil {
+		return err
+	}
+
+	h.logger.InfoContext(ctx, "Provider muted by command",
+		"provider_id", providerID,
+		"until", until.UTC().Format(time.RFC3339),
+		"actor", actor)
+	return nil
+}
+
+// Unmute lifts an active mute on a provider
+func (h *Handler) Unmute(ctx context.Context, providerID, actor string) error {
+	if !h.registry.Unmute(providerID) {
+		return fmt.Errorf("provider %s is not muted", providerID)
+	}
+
+	h.logger.InfoContext(ctx, "Provider unmuted by command",
+		"provider_id", providerID,
+		"actor", actor)
+	return nil
+}
+
+// Status returns one line per provider with its status and mute state
+func (h *Handler) Status(ctx context.Context) (string, error) {
+	list := h.registry.List()
+	if len(list) == 0 {
+		return "No providers loaded", nil
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].GetID() < list[j].GetID()
+	})
+
+	var b strings.Builder
+	for _, p := range list {
+		status := h.registry.StatusOf(p)
+		state := status.Status
+		if state == "" {
+			state = "unknown"
+		}
+
+		fmt.Fprintf(&b, "%s (%s): %s", p.GetID(), p.GetType(), state)
+		if status.CircuitState != "" && status.CircuitState != providers.CircuitClosed {
+			fmt.Fprintf(&b, ", circuit %s", status.CircuitState)
+		}
+		if until, muted := h.registry.MutedUntil(p.GetID()); muted {
+			fmt.Fprintf(&b, ", muted until %s", until.UTC().Format("2006-01-02 15:04 MST"))
+		}
+		b.WriteString("\n")
+	}
+
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
@@ -0,0 +1,375 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+)
+
+// BuildProviderConfig converts a loaded configuration file into the provider
+// configuration used by the factory
+func BuildProviderConfig(config *ProviderConfig) (*providers.ProviderConfig, error) {
+	providerConfig := &providers.ProviderConfig{
+		ID:   config.ID,
+		Type: config.Type,
+	}
+
+	switch config.Type {
+	case "telegram":
+		tgConfig, err := parseTelegramConfig(config.Config)
+		if err != nil {
+			return nil, err
+		}
+		providerConfig.Telegram = tgConfig
+	case "email":
+		emailConfig, err := parseEmailConfig(config.Config)
+		if err != nil {
+			return nil, err
+		}
+		providerConfig.Email = emailConfig
+	case providers.ProviderTypeGroup:
+		providerConfig.Group = parseGroupConfig(config.Config)
+	case providers.ProviderTypeFailover:
+		providerConfig.Failover = parseFailoverConfig(config.Config)
+	default:
+		return nil, fmt.Errorf("unknown provider type: %s", config.Type)
+	}
+
+	return providerConfig, nil
+}
+
+// Helper functions to parse type-specific configs
+func parseTelegramConfig(config map[string]interface{}) (*providers.TelegramConfig, error) {
+	tgConfig := &providers.TelegramConfig{}
+
+	if botToken, ok := config["bot_token"].(string); ok {
+		tgConfig.BotToken = botToken
+	} else {
+		return nil, fmt.Errorf("missing or invalid bot_token")
+	}
+
+	// default_chat_id may be given as a string or a JSON number
+	switch chatID := config["default_chat_id"].(type) {
+	case string:
+		tgConfig.DefaultChatID = chatID
+	case float64:
+		tgConfig.DefaultChatID = strconv.FormatInt(int64(chatID), 10)
+	}
+
+	if parseMode, ok := config["parse_mode"].(string); ok {
+		tgConfig.ParseMode = parseMode
+	}
+
+	if timeout, ok := config["timeout_seconds"].(float64); ok {
+		tgConfig.TimeoutSeconds = int(timeout)
+	}
+
+	if commands, ok := config["commands"].(map[string]interface{}); ok {
+		tgConfig.Commands = parseTelegramCommandsConfig(commands)
+	}
+
+	if retry, ok := config["retry"].(map[string]interface{}); ok {
+		tgConfig.Retry = parseRetryConfig(retry)
+	}
+
+	if rateLimit, ok := config["rate_limit"].(map[string]interface{}); ok {
+		tgConfig.RateLimit = parseRateLimitConfig(rateLimit)
+	}
+
+	if digest, ok := config["digest"].(map[string]interface{}); ok {
+		tgConfig.Digest = parseDigestConfig(digest)
+	}
+
+	if quietHours, ok := config["quiet_hours"].(map[string]interface{}); ok {
+		tgConfig.QuietHours = parseQuietHoursConfig(quietHours)
+	}
+
+	if dedup, ok := config["dedup"].(map[string]interface{}); ok {
+		tgConfig.Dedup = parseDedupConfig(dedup)
+	}
+
+	return tgConfig, nil
+}
+
+func parseTelegramCommandsConfig(config map[string]interface{}) *providers.TelegramCommandsConfig {
+	commands := &providers.TelegramCommandsConfig{}
+
+	if enabled, ok := config["enabled"].(bool); ok {
+		commands.Enabled = enabled
+	}
+
+	if chatIDs, ok := config["allowed_chat_ids"].([]interface{}); ok {
+		for _, chatID := range chatIDs {
+			switch v := chatID.(type) {
+			case string:
+				commands.AllowedChatIDs = append(commands.AllowedChatIDs, v)
+			case float64:
+				commands.AllowedChatIDs = append(commands.AllowedChatIDs, strconv.FormatInt(int64(v), 10))
+			}
+		}
+	}
+
+	if timeout, ok := config["poll_timeout_seconds"].(float64); ok {
+		commands.PollTimeoutSeconds = int(timeout)
+	}
+
+	return commands
+}
+
+func parseEmailConfig(config map[string]interface{}) (*providers.EmailConfig, error) {
+	emailConfig := &providers.EmailConfig{}
+
+	// Accept both legacy keys (host/port/from) and newer smtp_* keys.
+	if host, ok := config["smtp_host"].(string); ok && host != "" {
+		emailConfig.Host = host
+	} else if host, ok := config["host"].(string); ok && host != "" {
+		emailConfig.Host = host
+	} else {
+		return nil, fmt.Errorf("missing or invalid smtp_host/host")
+	}
+
+	if port, ok := config["smtp_port"].(float64); ok && port > 0 {
+		emailConfig.Port = int(port)
+	} else if port, ok := config["port"].(float64); ok && port > 0 {
+		emailConfig.Port = int(port)
+	} else {
+		return nil, fmt.Errorf("missing or invalid smtp_port/port")
+	}
+
+	if username, ok := config["username"].(string); ok {
+		emailConfig.Username = username
+	}
+
+	if password, ok := config["password"].(string); ok {
+		emailConfig.Password = password
+	}
+
+	if from, ok := config["from_address"].(string); ok && from != "" {
+		emailConfig.From = from
+	} else if from, ok := config["from"].(string); ok && from != "" {
+		emailConfig.From = from
+	} else {
+		return nil, fmt.Errorf("missing or invalid from_address/from")
+	}
+
+	if useTLS, ok := config["use_tls"].(bool); ok {
+		emailConfig.UseTLS = useTLS
+	}
+
+	if timeout, ok := config["timeout_seconds"].(float64); ok {
+		emailConfig.TimeoutSeconds = int(timeout)
+	}
+
+	if retry, ok := config["retry"].(map[string]interface{}); ok {
+		emailConfig.Retry = parseRetryConfig(retry)
+	}
+
+	if rateLimit, ok := config["rate_limit"].(map[string]interface{}); ok {
+		emailConfig.RateLimit = parseRateLimitConfig(rateLimit)
+	}
+
+	if digest, ok := config["digest"].(map[string]interface{}); ok {
+		emailConfig.Digest = parseDigestConfig(digest)
+	}
+
+	if quietHours, ok := config["quiet_hours"].(map[string]interface{}); ok {
+		emailConfig.QuietHours = parseQuietHoursConfig(quietHours)
+	}
+
+	if dedup, ok := config["dedup"].(map[string]interface{}); ok {
+		emailConfig.Dedup = parseDedupConfig(dedup)
+	}
+
+	return emailConfig, nil
+}
+
+func parseRetryConfig(config map[string]interface{}) *providers.RetryConfig {
+	retry := &providers.RetryConfig{}
+
+	if maxAttempts, ok := config["max_attempts"].(float64); ok {
+		retry.MaxAttempts = int(maxAttempts)
+	}
+
+	if delay, ok := config["initial_delay_ms"].(float64); ok {
+		retry.InitialDelayMs = int(delay)
+	}
+
+	if delay, ok := config["max_delay_ms"].(float64); ok {
+		retry.MaxDelayMs = int(delay)
+	}
+
+	if multiplier, ok := config["multiplier"].(float64); ok {
+		retry.Multiplier = multiplier
+	}
+
+	if jitter, ok := config["jitter"].(float64); ok {
+		retry.Jitter = jitter
+	}
+
+	if patterns, ok := config["retryable_errors"].([]interface{}); ok {
+		for _, pattern := range patterns {
+			if v, ok := pattern.(string); ok && v != "" {
+				retry.RetryableErrors = append(retry.RetryableErrors, v)
+			}
+		}
+	}
+
+	return retry
+}
+
+func parseRateLimitConfig(config map[string]interface{}) *providers.RateLimitConfig {
+	rateLimit := &providers.RateLimitConfig{}
+
+	if global, ok := config["global"].(map[string]interface{}); ok {
+		rateLimit.Global = parseRateLimitRule(global)
+	}
+
+	if perRecipient, ok := config["per_recipient"].(map[string]interface{}); ok {
+		rateLimit.PerRecipient = parseRateLimitRule(perRecipient)
+	}
+
+	if maxWait, ok := config["max_wait_ms"].(float64); ok {
+		rateLimit.MaxWaitMs = int(maxWait)
+	}
+
+	return rateLimit
+}
+
+func parseRateLimitRule(config map[string]interface{}) *providers.RateLimitRule {
+	rule := &providers.RateLimitRule{}
+
+	if limit, ok := config["limit"].(float64); ok {
+		rule.Limit = int(limit)
+	}
+
+	if interval, ok := config["interval_ms"].(float64); ok {
+		rule.IntervalMs = int(interval)
+	}
+
+	if burst, ok := config["burst"].(float64); ok {
+		rule.Burst = int(burst)
+	}
+
+	return rule
+}
+
+func parseDigestConfig(config map[string]interface{}) *providers.DigestConfig {
+	digest := &providers.DigestConfig{}
+
+	if window, ok := config["window_seconds"].(float64); ok {
+		digest.WindowSeconds = int(window)
+	}
+
+	if maxItems, ok := config["max_items"].(float64); ok {
+		digest.MaxItems = int(maxItems)
+	}
+
+	if template, ok := config["template"].(string); ok {
+		digest.Template = template
+	}
+
+	return digest
+}
+
+func parseDedupConfig(config map[string]interface{}) *providers.DedupConfig {
+	dedup := &providers.DedupConfig{}
+
+	if window, ok := config["window_seconds"].(float64); ok {
+		dedup.WindowSeconds = int(window)
+	}
+
+	if keys, ok := config["metadata_keys"].([]interface{}); ok {
+		for _, key := range keys {
+			if k, ok := key.(string); ok {
+				dedup.MetadataKeys = append(dedup.MetadataKeys, k)
+			}
+		}
+	}
+
+	if notify, ok := config["notify_repeats"].(bool); ok {
+		dedup.NotifyRepeats = notify
+	}
+
+	return dedup
+}
+
+func parseQuietHoursConfig(config map[string]interface{}) *providers.QuietHoursConfig {
+	quietHours := &providers.QuietHoursConfig{QuietHours: *parseQuietHours(config)}
+
+	if recipients, ok := config["recipients"].(map[string]interface{}); ok {
+		quietHours.Recipients = make(map[string]*providers.QuietHours, len(recipients))
+		for recipient, value := range recipients {
+			if window, ok := value.(map[string]interface{}); ok {
+				quietHours.Recipients[recipient] = parseQuietHours(window)
+			}
+		}
+	}
+
+	return quietHours
+}
+
+func parseQuietHours(config map[string]interface{}) *providers.QuietHours {
+	window := &providers.QuietHours{}
+	window.Start, _ = config["start"].(string)
+	window.End, _ = config["end"].(string)
+	window.Timezone, _ = config["timezone"].(string)
+	return window
+}
+
+func parseGroupConfig(config map[string]interface{}) *providers.GroupConfig {
+	group := &providers.GroupConfig{}
+
+	members, _ := config["members"].([]interface{})
+	for _, value := range members {
+		member, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		providerID, _ := member["provider_id"].(string)
+		groupMember := providers.GroupMember{ProviderID: providerID}
+
+		// Recipients may be given as a string or a JSON number (Telegram chat IDs)
+		switch recipient := member["recipient"].(type) {
+		case string:
+			groupMember.Recipient = recipient
+		case float64:
+			groupMember.Recipient = strconv.FormatInt(int64(recipient), 10)
+		}
+
+		group.Members = append(group.Members, groupMember)
+	}
+
+	return group
+}
+
+func parseFailoverConfig(config map[string]interface{}) *providers.FailoverConfig {
+	failover := &providers.FailoverConfig{}
+
+	hops, _ := config["hops"].([]interface{})
+	for _, value := range hops {
+		hop, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		providerID, _ := hop["provider_id"].(string)
+		failoverHop := providers.FailoverHop{ProviderID: providerID}
+
+		switch recipient := hop["recipient"].(type) {
+		case string:
+			failoverHop.Recipient = recipient
+		case float64:
+			failoverHop.Recipient = strconv.FormatInt(int64(recipient), 10)
+		}
+
+		if timeout, ok := hop["timeout_seconds"].(float64); ok {
+			failoverHop.TimeoutSeconds = int(timeout)
+		}
+
+		failover.Hops = append(failover.Hops, failoverHop)
+	}
+
+	return failover
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/scheduler"
+	"github.com/developertyrone/notimulti/internal/storage"
+)
+
+// JobsFileName is the file in the config directory that defines recurring jobs
+const JobsFileName = "jobs.json"
+
+// SyncJobsFile syncs the jobs defined in jobs.json to the store and returns how many
+// there are. A missing file removes all config-managed jobs; an invalid file changes nothing.
+func SyncJobsFile(store *storage.Repository, path string) (int, error) {
+	var jobs []storage.Job
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		jobs, err = LoadJobsFile(path, time.Now())
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := store.SyncConfigJobs(jobs); err != nil {
+		return 0, err
+	}
+	return len(jobs), nil
+}
+
+// LoadJobsFile reads and validates the jobs defined in jobs.json.
+// Each job's NextRunAt is its first run after now.
+func LoadJobsFile(path string, now time.Time) ([]storage.Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var file struct {
+		Jobs []json.RawMessage `json:"jobs"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	jobs := make([]storage.Job, 0, len(file.Jobs))
+	seen := make(map[string]bool, len(file.Jobs))
+	for i, raw := range file.Jobs {
+		job, err := parseJob(raw, now)
+		if err != nil {
+			if verr, ok := err.(*ValidationError); ok {
+				field := fmt.Sprintf("jobs[%d]", i)
+				if verr.Field != "" {
+					field += "." + verr.Field
+				}
+				verr.Field = field
+			}
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+		if seen[job.ID] {
+			return nil, fmt.Errorf("validation failed: %w", &ValidationError{
+				Field:   fmt.Sprintf("jobs[%d].id", i),
+				Message: fmt.Sprintf("duplicate job: %s", job.ID),
+			})
+		}
+		seen[job.ID] = true
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+func parseJob(raw json.RawMessage, now time.Time) (storage.Job, error) {
+	var job storage.Job
+	if err := json.Unmarshal(raw, &job); err != nil {
+		return job, &ValidationError{Message: fmt.Sprintf("invalid job: %v", err)}
+	}
+
+	if !idPattern.MatchString(job.ID) {
+		return job, &ValidationError{Field: "id", Message: "id must contain only lowercase letters, numbers, and hyphens"}
+	}
+	if job.JitterSeconds < 0 || time.Duration(job.JitterSeconds)*time.Second > scheduler.MaxJitter {
+		return job, &ValidationError{
+			Field:   "jitter_seconds",
+			Message: fmt.Sprintf("jitter_seconds must be between 0 and %d", int(scheduler.MaxJitter/time.Second)),
+		}
+	}
+	if _, err := scheduler.ParseSchedule(job.Schedule, job.Timezone); err != nil {
+		return job, &ValidationError{Field: "schedule", Message: err.Error()}
+	}
+
+	// The notification is validated against the providers when the job runs
+	var notification map[string]interface{}
+	if err := json.Unmarshal(job.Notification, &notification); err != nil || notification == nil {
+		return job, &ValidationError{Field: "notification", Message: "notification must be an object"}
+	}
+	if notification["message"] == nil && notification["template"] == nil {
+		return job, &ValidationError{Field: "notification.message", Message: "message or template is required"}
+	}
+	if notification["send_at"] != nil || notification["delay_seconds"] != nil {
+		return job, &ValidationError{Field: "notification.send_at", Message: "send_at and delay_seconds are not allowed in jobs"}
+	}
+	if notification["expires_at"] != nil {
+		return job, &ValidationError{Field: "notification.expires_at", Message: "expires_at is not allowed in jobs; use ttl_seconds"}
+	}
+	if notification["idempotency_key"] != nil {
+		return job, &ValidationError{Field: "notification.idempotency_key", Message: "idempotency_key is not allowed in jobs, which send on every run"}
+	}
+
+	next, err := scheduler.NextRun(&job, now)
+	if err != nil {
+		return job, &ValidationError{Field: "schedule", Message: err.Error()}
+	}
+	job.NextRunAt = &next
+	return job, nil
+}
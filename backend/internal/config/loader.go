@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/developertyrone/notimulti/internal/routing"
 )
 
 // Loader handles loading provider configurations from files
@@ -36,6 +38,11 @@ func (l *Loader) LoadAll() ([]*ProviderConfig, error) {
 	var errors []error
 
 	for _, file := range files {
+		// Routing rules and topics are not provider configurations
+		if isReservedFile(filepath.Base(file)) {
+			continue
+		}
+
 		config, err := l.LoadFile(file)
 		if err != nil {
 			errors = append(errors, fmt.Errorf("file %s: %w", filepath.Base(file), err))
@@ -80,6 +87,11 @@ func (l *Loader) LoadFile(filename string) (*ProviderConfig, error) {
 	return &config, nil
 }
 
+// isReservedFile reports whether a file in the config directory holds something other than a provider
+func isReservedFile(name string) bool {
+	return name == routing.FileName || name == TopicsFileName || name == JobsFileName
+}
+
 // GetConfigPath returns the absolute path for a config file
 func (l *Loader) GetConfigPath(filename string) string {
 	return filepath.Join(l.configDir, filename)
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/developertyrone/notimulti/internal/storage"
+)
+
+// TopicsFileName is the file in the config directory that defines topics
+const TopicsFileName = "topics.json"
+
+// SyncTopicsFile syncs the topics defined in topics.json to the store and returns
+// how many there are. A missing file removes all config-managed topics; an invalid
+// file is rejected and the store is left unchanged.
+func SyncTopicsFile(store *storage.Repository, path string) (int, error) {
+	var definitions []storage.TopicDefinition
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		definitions, err = LoadTopicsFile(path)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := store.SyncConfigTopics(definitions); err != nil {
+		return 0, err
+	}
+	return len(definitions), nil
+}
+
+// LoadTopicsFile reads and validates the topics defined in topics.json
+func LoadTopicsFile(path string) ([]storage.TopicDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var file struct {
+		Topics []map[string]interface{} `json:"topics"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	definitions := make([]storage.TopicDefinition, 0, len(file.Topics))
+	seen := make(map[string]bool, len(file.Topics))
+	for i, topic := range file.Topics {
+		def, err := parseTopic(topic)
+		if err != nil {
+			if verr, ok := err.(*ValidationError); ok {
+				verr.Field = fmt.Sprintf("topics[%d].%s", i, verr.Field)
+			}
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+		if seen[def.Name] {
+			return nil, fmt.Errorf("validation failed: %w", &ValidationError{
+				Field:   fmt.Sprintf("topics[%d].name", i),
+				Message: fmt.Sprintf("duplicate topic: %s", def.Name),
+			})
+		}
+		seen[def.Name] = true
+		definitions = append(definitions, def)
+	}
+
+	return definitions, nil
+}
+
+func parseTopic(topic map[string]interface{}) (storage.TopicDefinition, error) {
+	var def storage.TopicDefinition
+
+	name, ok := topic["name"].(string)
+	if !ok || !idPattern.MatchString(name) {
+		return def, &ValidationError{Field: "name", Message: "name must contain only lowercase letters, numbers, and hyphens"}
+	}
+	def.Name = name
+
+	if description, exists := topic["description"]; exists {
+		if def.Description, ok = description.(string); !ok {
+			return def, &ValidationError{Field: "description", Message: "description must be a string"}
+		}
+	}
+
+	subscribers, ok := topic["subscribers"].([]interface{})
+	if !ok {
+		return def, &ValidationError{Field: "subscribers", Message: "subscribers must be an array"}
+	}
+
+	seen := make(map[string]bool, len(subscribers))
+	for i, value := range subscribers {
+		field := fmt.Sprintf("subscribers[%d]", i)
+
+		subscriber, ok := value.(map[string]interface{})
+		if !ok {
+			return def, &ValidationError{Field: field, Message: "subscriber must be an object with provider_id"}
+		}
+
+		providerID, ok := subscriber["provider_id"].(string)
+		if !ok || !idPattern.MatchString(providerID) {
+			return def, &ValidationError{Field: field + ".provider_id", Message: "provider_id must be a valid provider ID"}
+		}
+
+		// Recipients may be given as a string or a JSON number (Telegram chat IDs)
+		var recipient string
+		switch v := subscriber["recipient"].(type) {
+		case nil:
+		case string:
+			recipient = v
+		case float64:
+			recipient = strconv.FormatInt(int64(v), 10)
+		default:
+			return def, &ValidationError{Field: field + ".recipient", Message: "recipient must be a string or number"}
+		}
+
+		key := providerID + "\x00" + recipient
+		if seen[key] {
+			return def, &ValidationError{Field: field, Message: fmt.Sprintf("duplicate subscriber: %s %s", providerID, recipient)}
+		}
+		seen[key] = true
+
+		def.Subscribers = append(def.Subscribers, storage.TopicSubscriber{ProviderID: providerID, Recipient: recipient})
+	}
+
+	return def, nil
+}
@@ -5,6 +5,9 @@ import (
 	"net/mail"
 	"regexp"
 	"strconv"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
 )
 
 // ValidationError represents a configuration validation error
@@ -44,6 +47,10 @@ func ValidateConfig(config *ProviderConfig) error {
 		return validateTelegramConfig(config.Config)
 	case "email":
 		return validateEmailConfig(config.Config)
+	case "group":
+		return validateGroupConfig(config.ID, config.Config)
+	case "failover":
+		return validateFailoverConfig(config.ID, config.Config)
 	default:
 		return &ValidationError{
 			Field:   "type",
@@ -106,6 +113,85 @@ func validateTelegramConfig(config map[string]interface{}) error {
 		return &ValidationError{Field: "default_chat_id", Message: "default_chat_id must be a string or number"}
 	}
 
+	// Validate optional inbound commands block
+	if commands, exists := config["commands"]; exists {
+		if err := validateTelegramCommands(commands); err != nil {
+			return err
+		}
+	}
+
+	// Validate optional retry policy
+	if retry, exists := config["retry"]; exists {
+		if err := validateRetryConfig(retry); err != nil {
+			return err
+		}
+	}
+
+	// Validate optional outbound rate limits
+	if rateLimit, exists := config["rate_limit"]; exists {
+		if err := validateRateLimitConfig(rateLimit); err != nil {
+			return err
+		}
+	}
+
+	// Validate optional digest mode
+	if digest, exists := config["digest"]; exists {
+		if err := validateDigestConfig(digest); err != nil {
+			return err
+		}
+	}
+
+	// Validate optional quiet hours
+	if quietHours, exists := config["quiet_hours"]; exists {
+		if err := validateQuietHoursConfig(quietHours); err != nil {
+			return err
+		}
+	}
+
+	// Validate optional deduplication
+	if dedup, exists := config["dedup"]; exists {
+		return validateDedupConfig(dedup)
+	}
+
+	return nil
+}
+
+func validateTelegramCommands(value interface{}) error {
+	commands, ok := value.(map[string]interface{})
+	if !ok {
+		return &ValidationError{Field: "commands", Message: "commands must be an object"}
+	}
+
+	if enabled, exists := commands["enabled"]; exists {
+		if _, ok := enabled.(bool); !ok {
+			return &ValidationError{Field: "commands.enabled", Message: "enabled must be a boolean"}
+		}
+	}
+
+	if chatIDs, exists := commands["allowed_chat_ids"]; exists {
+		list, ok := chatIDs.([]interface{})
+		if !ok {
+			return &ValidationError{Field: "commands.allowed_chat_ids", Message: "allowed_chat_ids must be an array"}
+		}
+		for _, chatID := range list {
+			switch v := chatID.(type) {
+			case string:
+				if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+					return &ValidationError{Field: "commands.allowed_chat_ids", Message: fmt.Sprintf("invalid chat ID: %s", v)}
+				}
+			case float64:
+			default:
+				return &ValidationError{Field: "commands.allowed_chat_ids", Message: "chat IDs must be strings or numbers"}
+			}
+		}
+	}
+
+	if timeout, exists := commands["poll_timeout_seconds"]; exists {
+		if v, ok := timeout.(float64); !ok || v < 1 || v > 60 {
+			return &ValidationError{Field: "commands.poll_timeout_seconds", Message: "poll_timeout_seconds must be between 1 and 60"}
+		}
+	}
+
 	return nil
 }
 
@@ -173,5 +259,358 @@ func validateEmailConfig(config map[string]interface{}) error {
 		return &ValidationError{Field: "from", Message: "from must be a valid email address"}
 	}
 
+	// Validate optional retry policy
+	if retry, exists := config["retry"]; exists {
+		if err := validateRetryConfig(retry); err != nil {
+			return err
+		}
+	}
+
+	// Validate optional outbound rate limits
+	if rateLimit, exists := config["rate_limit"]; exists {
+		if err := validateRateLimitConfig(rateLimit); err != nil {
+			return err
+		}
+	}
+
+	// Validate optional digest mode
+	if digest, exists := config["digest"]; exists {
+		if err := validateDigestConfig(digest); err != nil {
+			return err
+		}
+	}
+
+	// Validate optional quiet hours
+	if quietHours, exists := config["quiet_hours"]; exists {
+		if err := validateQuietHoursConfig(quietHours); err != nil {
+			return err
+		}
+	}
+
+	// Validate optional deduplication
+	if dedup, exists := config["dedup"]; exists {
+		return validateDedupConfig(dedup)
+	}
+
+	return nil
+}
+
+// maxRetryDelayMs caps retry delays at one hour
+const maxRetryDelayMs = 3600000
+
+func validateRetryConfig(value interface{}) error {
+	retry, ok := value.(map[string]interface{})
+	if !ok {
+		return &ValidationError{Field: "retry", Message: "retry must be an object"}
+	}
+
+	if maxAttempts, exists := retry["max_attempts"]; exists {
+		if v, ok := maxAttempts.(float64); !ok || v < 1 || v > 20 || v != float64(int(v)) {
+			return &ValidationError{Field: "retry.max_attempts", Message: "max_attempts must be an integer between 1 and 20"}
+		}
+	}
+
+	for _, field := range []string{"initial_delay_ms", "max_delay_ms"} {
+		if delay, exists := retry[field]; exists {
+			if v, ok := delay.(float64); !ok || v < 0 || v > maxRetryDelayMs {
+				return &ValidationError{Field: "retry." + field, Message: fmt.Sprintf("%s must be between 0 and %d", field, maxRetryDelayMs)}
+			}
+		}
+	}
+
+	initial, hasInitial := retry["initial_delay_ms"].(float64)
+	maxDelay, hasMax := retry["max_delay_ms"].(float64)
+	if hasInitial && hasMax && maxDelay < initial {
+		return &ValidationError{Field: "retry.max_delay_ms", Message: "max_delay_ms must not be less than initial_delay_ms"}
+	}
+
+	if multiplier, exists := retry["multiplier"]; exists {
+		if v, ok := multiplier.(float64); !ok || v < 1 || v > 10 {
+			return &ValidationError{Field: "retry.multiplier", Message: "multiplier must be between 1 and 10"}
+		}
+	}
+
+	if jitter, exists := retry["jitter"]; exists {
+		if v, ok := jitter.(float64); !ok || v < 0 || v > 1 {
+			return &ValidationError{Field: "retry.jitter", Message: "jitter must be between 0 and 1"}
+		}
+	}
+
+	if patterns, exists := retry["retryable_errors"]; exists {
+		list, ok := patterns.([]interface{})
+		if !ok {
+			return &ValidationError{Field: "retry.retryable_errors", Message: "retryable_errors must be an array of strings"}
+		}
+		for _, pattern := range list {
+			if v, ok := pattern.(string); !ok || v == "" {
+				return &ValidationError{Field: "retry.retryable_errors", Message: "retryable_errors must be an array of non-empty strings"}
+			}
+		}
+	}
+
+	return nil
+}
+
+// maxRateLimitIntervalMs caps rate limit intervals at one day
+const maxRateLimitIntervalMs = 86400000
+
+func validateRateLimitConfig(value interface{}) error {
+	rateLimit, ok := value.(map[string]interface{})
+	if !ok {
+		return &ValidationError{Field: "rate_limit", Message: "rate_limit must be an object"}
+	}
+
+	_, hasGlobal := rateLimit["global"]
+	_, hasPerRecipient := rateLimit["per_recipient"]
+	if !hasGlobal && !hasPerRecipient {
+		return &ValidationError{Field: "rate_limit", Message: "rate_limit requires global and/or per_recipient"}
+	}
+
+	for _, field := range []string{"global", "per_recipient"} {
+		if rule, exists := rateLimit[field]; exists {
+			if err := validateRateLimitRule("rate_limit."+field, rule); err != nil {
+				return err
+			}
+		}
+	}
+
+	if maxWait, exists := rateLimit["max_wait_ms"]; exists {
+		if v, ok := maxWait.(float64); !ok || v < 0 || v > maxRetryDelayMs {
+			return &ValidationError{Field: "rate_limit.max_wait_ms", Message: fmt.Sprintf("max_wait_ms must be between 0 and %d", maxRetryDelayMs)}
+		}
+	}
+
+	return nil
+}
+
+func validateRateLimitRule(field string, value interface{}) error {
+	rule, ok := value.(map[string]interface{})
+	if !ok {
+		return &ValidationError{Field: field, Message: field + " must be an object"}
+	}
+
+	limit, ok := rule["limit"].(float64)
+	if !ok || limit < 1 || limit != float64(int(limit)) {
+		return &ValidationError{Field: field + ".limit", Message: "limit must be a positive integer"}
+	}
+
+	interval, ok := rule["interval_ms"].(float64)
+	if !ok || interval < 1 || interval > maxRateLimitIntervalMs || interval != float64(int(interval)) {
+		return &ValidationError{Field: field + ".interval_ms", Message: fmt.Sprintf("interval_ms must be an integer between 1 and %d", maxRateLimitIntervalMs)}
+	}
+
+	if burst, exists := rule["burst"]; exists {
+		if v, ok := burst.(float64); !ok || v < 1 || v != float64(int(v)) {
+			return &ValidationError{Field: field + ".burst", Message: "burst must be a positive integer"}
+		}
+	}
+
+	return nil
+}
+
+func validateDigestConfig(value interface{}) error {
+	digest, ok := value.(map[string]interface{})
+	if !ok {
+		return &ValidationError{Field: "digest", Message: "digest must be an object"}
+	}
+
+	maxWindow := int(providers.MaxDigestWindow / time.Second)
+	window, ok := digest["window_seconds"].(float64)
+	if !ok || window < 1 || window > float64(maxWindow) || window != float64(int(window)) {
+		return &ValidationError{Field: "digest.window_seconds", Message: fmt.Sprintf("window_seconds must be an integer between 1 and %d", maxWindow)}
+	}
+
+	if maxItems, exists := digest["max_items"]; exists {
+		if v, ok := maxItems.(float64); !ok || v < 1 || v > providers.MaxDigestItems || v != float64(int(v)) {
+			return &ValidationError{Field: "digest.max_items", Message: fmt.Sprintf("max_items must be an integer between 1 and %d", providers.MaxDigestItems)}
+		}
+	}
+
+	if template, exists := digest["template"]; exists {
+		if v, ok := template.(string); !ok || v == "" {
+			return &ValidationError{Field: "digest.template", Message: "template must be a non-empty string"}
+		}
+	}
+
+	return nil
+}
+
+func validateDedupConfig(value interface{}) error {
+	dedup, ok := value.(map[string]interface{})
+	if !ok {
+		return &ValidationError{Field: "dedup", Message: "dedup must be an object"}
+	}
+
+	maxWindow := int(providers.MaxDedupWindow / time.Second)
+	window, ok := dedup["window_seconds"].(float64)
+	if !ok || window < 1 || window > float64(maxWindow) || window != float64(int(window)) {
+		return &ValidationError{Field: "dedup.window_seconds", Message: fmt.Sprintf("window_seconds must be an integer between 1 and %d", maxWindow)}
+	}
+
+	if keys, exists := dedup["metadata_keys"]; exists {
+		list, ok := keys.([]interface{})
+		if !ok {
+			return &ValidationError{Field: "dedup.metadata_keys", Message: "metadata_keys must be an array of strings"}
+		}
+		for _, key := range list {
+			if k, ok := key.(string); !ok || k == "" {
+				return &ValidationError{Field: "dedup.metadata_keys", Message: "metadata_keys must be an array of non-empty strings"}
+			}
+		}
+	}
+
+	if notify, exists := dedup["notify_repeats"]; exists {
+		if _, ok := notify.(bool); !ok {
+			return &ValidationError{Field: "dedup.notify_repeats", Message: "notify_repeats must be a boolean"}
+		}
+	}
+
+	return nil
+}
+
+func validateQuietHoursConfig(value interface{}) error {
+	quietHours, ok := value.(map[string]interface{})
+	if !ok {
+		return &ValidationError{Field: "quiet_hours", Message: "quiet_hours must be an object"}
+	}
+
+	recipients, hasRecipients := quietHours["recipients"]
+	if _, hasStart := quietHours["start"]; hasStart || !hasRecipients {
+		if err := validateQuietHoursWindow("quiet_hours", quietHours); err != nil {
+			return err
+		}
+	} else if _, hasEnd := quietHours["end"]; hasEnd {
+		return &ValidationError{Field: "quiet_hours.start", Message: "start is required with end"}
+	}
+
+	if hasRecipients {
+		windows, ok := recipients.(map[string]interface{})
+		if !ok || len(windows) == 0 {
+			return &ValidationError{Field: "quiet_hours.recipients", Message: "recipients must be a non-empty object keyed by recipient"}
+		}
+		for recipient, value := range windows {
+			field := "quiet_hours.recipients." + recipient
+			window, ok := value.(map[string]interface{})
+			if !ok {
+				return &ValidationError{Field: field, Message: "quiet hours must be an object"}
+			}
+			if err := validateQuietHoursWindow(field, window); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateQuietHoursWindow(field string, window map[string]interface{}) error {
+	for _, key := range []string{"start", "end", "timezone"} {
+		if v, exists := window[key]; exists {
+			if _, ok := v.(string); !ok {
+				return &ValidationError{Field: field + "." + key, Message: key + " must be a string"}
+			}
+		}
+	}
+	if err := parseQuietHours(window).Validate(); err != nil {
+		return &ValidationError{Field: field, Message: err.Error()}
+	}
+	return nil
+}
+
+func validateGroupConfig(groupID string, config map[string]interface{}) error {
+	members, ok := config["members"].([]interface{})
+	if !ok || len(members) == 0 {
+		return &ValidationError{Field: "members", Message: "members must be a non-empty array"}
+	}
+
+	seen := make(map[string]bool, len(members))
+	for i, value := range members {
+		field := fmt.Sprintf("members[%d]", i)
+
+		member, ok := value.(map[string]interface{})
+		if !ok {
+			return &ValidationError{Field: field, Message: "member must be an object with provider_id"}
+		}
+
+		providerID, ok := member["provider_id"].(string)
+		if !ok || !idPattern.MatchString(providerID) {
+			return &ValidationError{Field: field + ".provider_id", Message: "provider_id must be a valid provider ID"}
+		}
+		if providerID == groupID {
+			return &ValidationError{Field: field + ".provider_id", Message: "a group cannot contain itself"}
+		}
+		if seen[providerID] {
+			return &ValidationError{Field: field + ".provider_id", Message: fmt.Sprintf("duplicate member: %s", providerID)}
+		}
+		seen[providerID] = true
+
+		if recipient, exists := member["recipient"]; exists {
+			switch v := recipient.(type) {
+			case string:
+				if v == "" {
+					return &ValidationError{Field: field + ".recipient", Message: "recipient cannot be empty"}
+				}
+			case float64:
+			default:
+				return &ValidationError{Field: field + ".recipient", Message: "recipient must be a string or number"}
+			}
+		}
+	}
+
+	return nil
+}
+
+// maxFailoverHopTimeoutSeconds bounds how long a failover chain waits on one hop
+const maxFailoverHopTimeoutSeconds = 3600
+
+func validateFailoverConfig(chainID string, config map[string]interface{}) error {
+	hops, ok := config["hops"].([]interface{})
+	if !ok || len(hops) == 0 {
+		return &ValidationError{Field: "hops", Message: "hops must be a non-empty array"}
+	}
+
+	seen := make(map[string]bool, len(hops))
+	for i, value := range hops {
+		field := fmt.Sprintf("hops[%d]", i)
+
+		hop, ok := value.(map[string]interface{})
+		if !ok {
+			return &ValidationError{Field: field, Message: "hop must be an object with provider_id"}
+		}
+
+		providerID, ok := hop["provider_id"].(string)
+		if !ok || !idPattern.MatchString(providerID) {
+			return &ValidationError{Field: field + ".provider_id", Message: "provider_id must be a valid provider ID"}
+		}
+		if providerID == chainID {
+			return &ValidationError{Field: field + ".provider_id", Message: "a failover chain cannot contain itself"}
+		}
+		if seen[providerID] {
+			return &ValidationError{Field: field + ".provider_id", Message: fmt.Sprintf("duplicate hop: %s", providerID)}
+		}
+		seen[providerID] = true
+
+		if recipient, exists := hop["recipient"]; exists {
+			switch v := recipient.(type) {
+			case string:
+				if v == "" {
+					return &ValidationError{Field: field + ".recipient", Message: "recipient cannot be empty"}
+				}
+			case float64:
+			default:
+				return &ValidationError{Field: field + ".recipient", Message: "recipient must be a string or number"}
+			}
+		}
+
+		if timeout, exists := hop["timeout_seconds"]; exists {
+			if v, ok := timeout.(float64); !ok || v < 1 || v > maxFailoverHopTimeoutSeconds || v != float64(int(v)) {
+				return &ValidationError{
+					Field:   field + ".timeout_seconds",
+					Message: fmt.Sprintf("timeout_seconds must be an integer between 1 and %d", maxFailoverHopTimeoutSeconds),
+				}
+			}
+		}
+	}
+
 	return nil
 }
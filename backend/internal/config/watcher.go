@@ -11,6 +11,9 @@ import (
 	"time"
 
 	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/routing"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/internal/templates"
 	"github.com/fsnotify/fsnotify"
 )
 
@@ -18,6 +21,10 @@ import (
 type Watcher struct {
 	configDir string
 	registry  *providers.Registry
+	routing   *routing.Engine
+	topics    *storage.Repository
+	jobs      *storage.Repository
+	templates *templates.Store
 	loader    *Loader
 	factory   *providers.Factory
 	watcher   *fsnotify.Watcher
@@ -80,6 +87,43 @@ func NewWatcher(configDir string, registry *providers.Registry, logger *slog.Log
 	return w, nil
 }
 
+// SetRoutingEngine makes the watcher reload routing rules when the rules file changes
+func (w *Watcher) SetRoutingEngine(engine *routing.Engine) {
+	w.routing = engine
+}
+
+// SetTopicStore makes the watcher sync topics to the store when topics.json changes
+func (w *Watcher) SetTopicStore(store *storage.Repository) {
+	w.topics = store
+}
+
+// SetJobStore makes the watcher sync recurring jobs to the store when jobs.json changes
+func (w *Watcher) SetJobStore(store *storage.Repository) {
+	w.jobs = store
+}
+
+// SetTemplateStore makes the watcher reload templates when files in the templates
+// directory change. The directory is watched once it exists.
+func (w *Watcher) SetTemplateStore(store *templates.Store) {
+	w.templates = store
+	w.watchTemplatesDir()
+}
+
+// templatesDir returns the templates directory inside the config directory
+func (w *Watcher) templatesDir() string {
+	return filepath.Join(w.configDir, templates.Dir)
+}
+
+// watchTemplatesDir adds the templates directory to the watch list if it exists
+func (w *Watcher) watchTemplatesDir() {
+	if info, err := os.Stat(w.templatesDir()); err != nil || !info.IsDir() {
+		return
+	}
+	if err := w.watcher.Add(w.templatesDir()); err != nil {
+		w.logger.Error("Failed to watch templates directory", "directory", w.templatesDir(), "error", err)
+	}
+}
+
 // Start begins watching for configuration changes
 func (w *Watcher) Start() {
 	w.wg.Add(1)
@@ -103,6 +147,13 @@ func (w *Watcher) run() {
 				return
 			}
 
+			// A templates directory created after startup is watched and loaded
+			if w.templates != nil && event.Name == w.templatesDir() && event.Has(fsnotify.Create) {
+				w.watchTemplatesDir()
+				w.reloadTemplates()
+				continue
+			}
+
 			// Only process JSON files
 			if !strings.HasSuffix(event.Name, ".json") {
 				continue
@@ -148,7 +199,24 @@ func (d *debouncer) debounce(path string) {
 
 // handleFileChange processes a file change after debouncing
 func (w *Watcher) handleFileChange(path string) {
+	if filepath.Dir(path) == w.templatesDir() {
+		w.handleTemplateChange(path)
+		return
+	}
+
 	filename := filepath.Base(path)
+	switch filename {
+	case routing.FileName:
+		w.handleRoutingChange(path)
+		return
+	case TopicsFileName:
+		w.handleTopicsChange(path)
+		return
+	case JobsFileName:
+		w.handleJobsChange(path)
+		return
+	}
+
 	configID := strings.TrimSuffix(filename, ".json")
 
 	w.logger.Info("Processing configuration change",
@@ -215,37 +283,12 @@ func (w *Watcher) handleCreate(config *ProviderConfig) {
 		"checksum", config.Checksum)
 
 	// Convert config.Config to providers.ProviderConfig
-	providerConfig := &providers.ProviderConfig{
-		ID:   config.ID,
-		Type: config.Type,
-	}
-
-	// Set type-specific config
-	var parseErr error
-	switch config.Type {
-	case "telegram":
-		if tgConfig, err := parseTelegramConfig(config.Config); err == nil {
-			providerConfig.Telegram = tgConfig
-		} else {
-			parseErr = err
-			w.logger.Error("Failed to parse Telegram config",
-				"id", config.ID,
-				"error", err)
-		}
-	case "email":
-		if emailConfig, err := parseEmailConfig(config.Config); err == nil {
-			providerConfig.Email = emailConfig
-		} else {
-			parseErr = err
-			w.logger.Error("Failed to parse Email config",
-				"id", config.ID,
-				"error", err)
-		}
-	default:
-		parseErr = fmt.Errorf("unknown provider type: %s", config.Type)
-		w.logger.Error("Unknown provider type",
+	providerConfig, parseErr := BuildProviderConfig(config)
+	if parseErr != nil {
+		w.logger.Error("Failed to parse provider config",
 			"id", config.ID,
-			"type", config.Type)
+			"type", config.Type,
+			"error", parseErr)
 	}
 
 	// Create provider (or failed provider if parsing failed)
@@ -300,37 +343,12 @@ func (w *Watcher) handleWrite(config *ProviderConfig) {
 		"checksum", config.Checksum)
 
 	// Convert config.Config to providers.ProviderConfig
-	providerConfig := &providers.ProviderConfig{
-		ID:   config.ID,
-		Type: config.Type,
-	}
-
-	// Set type-specific config
-	var parseErr error
-	switch config.Type {
-	case "telegram":
-		if tgConfig, err := parseTelegramConfig(config.Config); err == nil {
-			providerConfig.Telegram = tgConfig
-		} else {
-			parseErr = err
-			w.logger.Error("Failed to parse Telegram config",
-				"id", config.ID,
-				"error", err)
-		}
-	case "email":
-		if emailConfig, err := parseEmailConfig(config.Config); err == nil {
-			providerConfig.Email = emailConfig
-		} else {
-			parseErr = err
-			w.logger.Error("Failed to parse Email config",
-				"id", config.ID,
-				"error", err)
-		}
-	default:
-		parseErr = fmt.Errorf("unknown provider type: %s", config.Type)
-		w.logger.Error("Unknown provider type",
+	providerConfig, parseErr := BuildProviderConfig(config)
+	if parseErr != nil {
+		w.logger.Error("Failed to parse provider config",
 			"id", config.ID,
-			"type", config.Type)
+			"type", config.Type,
+			"error", parseErr)
 	}
 
 	// Create new provider (or failed provider if parsing failed)
@@ -393,76 +411,96 @@ func (w *Watcher) handleRemove(configID string) {
 		"id", configID)
 }
 
-// Helper functions to parse type-specific configs
-func parseTelegramConfig(config map[string]interface{}) (*providers.TelegramConfig, error) {
-	tgConfig := &providers.TelegramConfig{}
+// handleRoutingChange reloads the routing rules. Invalid rules files are
+// rejected and the previous rules stay in effect.
+func (w *Watcher) handleRoutingChange(path string) {
+	if w.routing == nil {
+		return
+	}
 
-	if botToken, ok := config["bot_token"].(string); ok {
-		tgConfig.BotToken = botToken
-	} else {
-		return nil, fmt.Errorf("missing or invalid bot_token")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		w.routing.Clear()
+		w.logger.Info("Routing rules removed")
+		return
 	}
 
-	if chatID, ok := config["default_chat_id"].(string); ok {
-		tgConfig.DefaultChatID = chatID
+	if err := w.routing.LoadFile(path); err != nil {
+		w.logger.Error("Failed to load routing rules, keeping previous rules",
+			"file", filepath.Base(path),
+			"error", err)
+		return
 	}
 
-	if parseMode, ok := config["parse_mode"].(string); ok {
-		tgConfig.ParseMode = parseMode
+	w.logger.Info("Routing rules reloaded", "rules", len(w.routing.Rules()))
+}
+
+// handleTopicsChange syncs the topics defined in topics.json to the store.
+// Removing the file removes the topics it defined.
+func (w *Watcher) handleTopicsChange(path string) {
+	if w.topics == nil {
+		return
 	}
 
-	if timeout, ok := config["timeout_seconds"].(float64); ok {
-		tgConfig.TimeoutSeconds = int(timeout)
+	count, err := SyncTopicsFile(w.topics, path)
+	if err != nil {
+		w.logger.Error("Failed to load topics, keeping previous topics",
+			"file", filepath.Base(path),
+			"error", err)
+		return
 	}
 
-	return tgConfig, nil
+	w.logger.Info("Topics reloaded", "topics", count)
 }
 
-func parseEmailConfig(config map[string]interface{}) (*providers.EmailConfig, error) {
-	emailConfig := &providers.EmailConfig{}
-
-	// Accept both legacy keys (host/port/from) and newer smtp_* keys.
-	if host, ok := config["smtp_host"].(string); ok && host != "" {
-		emailConfig.Host = host
-	} else if host, ok := config["host"].(string); ok && host != "" {
-		emailConfig.Host = host
-	} else {
-		return nil, fmt.Errorf("missing or invalid smtp_host/host")
+// handleJobsChange syncs the jobs defined in jobs.json to the store.
+// Removing the file removes the jobs it defined.
+func (w *Watcher) handleJobsChange(path string) {
+	if w.jobs == nil {
+		return
 	}
 
-	if port, ok := config["smtp_port"].(float64); ok && port > 0 {
-		emailConfig.Port = int(port)
-	} else if port, ok := config["port"].(float64); ok && port > 0 {
-		emailConfig.Port = int(port)
-	} else {
-		return nil, fmt.Errorf("missing or invalid smtp_port/port")
+	count, err := SyncJobsFile(w.jobs, path)
+	if err != nil {
+		w.logger.Error("Failed to load jobs, keeping previous jobs",
+			"file", filepath.Base(path),
+			"error", err)
+		return
 	}
 
-	if username, ok := config["username"].(string); ok {
-		emailConfig.Username = username
-	}
+	w.logger.Info("Jobs reloaded", "jobs", count)
+}
 
-	if password, ok := config["password"].(string); ok {
-		emailConfig.Password = password
+// handleTemplateChange reloads a template. Invalid templates are rejected
+// and the previous version stays in use.
+func (w *Watcher) handleTemplateChange(path string) {
+	if w.templates == nil {
+		return
 	}
 
-	if from, ok := config["from_address"].(string); ok && from != "" {
-		emailConfig.From = from
-	} else if from, ok := config["from"].(string); ok && from != "" {
-		emailConfig.From = from
-	} else {
-		return nil, fmt.Errorf("missing or invalid from_address/from")
+	name, locale := templates.NameFromPath(path)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		w.templates.Remove(name, locale)
+		w.logger.Info("Template removed", "template", name, "locale", locale)
+		return
 	}
 
-	if useTLS, ok := config["use_tls"].(bool); ok {
-		emailConfig.UseTLS = useTLS
+	if _, err := w.templates.LoadFile(path); err != nil {
+		w.logger.Error("Failed to load template, keeping previous version",
+			"template", name,
+			"locale", locale,
+			"error", err)
+		return
 	}
 
-	if timeout, ok := config["timeout_seconds"].(float64); ok {
-		emailConfig.TimeoutSeconds = int(timeout)
-	}
+	w.logger.Info("Template reloaded", "template", name, "locale", locale)
+}
 
-	return emailConfig, nil
+// reloadTemplates loads every template in the templates directory
+func (w *Watcher) reloadTemplates() {
+	for _, err := range w.templates.LoadDir(w.templatesDir()) {
+		w.logger.Error("Failed to load template", "error", err)
+	}
+	w.logger.Info("Templates reloaded", "templates", len(w.templates.List()))
 }
 
 // Stop stops the watcher and waits for cleanup
@@ -0,0 +1,232 @@
+// Package markdown converts CommonMark messages into the formats providers deliver:
+// HTML for email, the HTML subset Telegram accepts, and plain text.
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// parser is safe for concurrent use; raw HTML in messages is dropped, not passed through
+var parser = goldmark.New()
+
+// HTML converts a message to an HTML fragment
+func HTML(source string) string {
+	var out bytes.Buffer
+	if err := parser.Convert([]byte(source), &out); err != nil {
+		// Writing to a buffer cannot fail; fall back to the escaped source all the same
+		return "<p>" + escape(source) + "</p>"
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// TelegramHTML converts a message to Telegram's HTML parse mode. Emphasis, code, links and
+// quotes keep their markup; headings become bold lines and lists use bullet or number prefixes.
+func TelegramHTML(source string) string {
+	return render(source, true)
+}
+
+// Text converts a message to plain text for channels without formatting, such as
+// the text part of an email. Markup is removed and link targets follow their text.
+func Text(source string) string {
+	return render(source, false)
+}
+
+func render(source string, html bool) string {
+	src := []byte(source)
+	doc := parser.Parser().Parse(text.NewReader(src))
+	r := &renderer{source: src, html: html}
+	return r.blocks(doc, "\n\n")
+}
+
+// renderer writes a document as Telegram HTML or, when html is false, plain text
+type renderer struct {
+	source []byte
+	html   bool
+}
+
+// blocks renders the block children of a node, skipping empty ones
+func (r *renderer) blocks(parent ast.Node, sep string) string {
+	var parts []string
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		if part := r.block(n); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+func (r *renderer) block(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Paragraph, *ast.TextBlock:
+		return strings.TrimRight(r.inlines(n), "\n")
+	case *ast.Heading:
+		return r.wrap("b", strings.TrimRight(r.inlines(n), "\n"))
+	case *ast.ThematicBreak:
+		return "---"
+	case *ast.CodeBlock:
+		return r.code(n, "")
+	case *ast.FencedCodeBlock:
+		return r.code(n, string(n.Language(r.source)))
+	case *ast.Blockquote:
+		inner := r.blocks(n, "\n\n")
+		if r.html {
+			return "<blockquote>" + inner + "</blockquote>"
+		}
+		return prefixLines(inner, "> ", "> ")
+	case *ast.List:
+		return r.list(n)
+	case *ast.HTMLBlock:
+		return ""
+	}
+	return r.blocks(n, "\n\n")
+}
+
+func (r *renderer) code(n ast.Node, language string) string {
+	var content strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		content.Write(segment.Value(r.source))
+	}
+	code := strings.TrimRight(content.String(), "\n")
+	if !r.html {
+		return code
+	}
+	if language != "" {
+		return fmt.Sprintf(`<pre><code class="language-%s">%s</code></pre>`, escapeAttr(language), escape(code))
+	}
+	return "<pre>" + escape(code) + "</pre>"
+}
+
+// list renders items with a marker, indenting their continuation lines under the marker
+func (r *renderer) list(list *ast.List) string {
+	itemSep, blockSep := "\n", "\n"
+	if !list.IsTight {
+		itemSep, blockSep = "\n\n", "\n\n"
+	}
+
+	var items []string
+	number := list.Start
+	for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+		marker := "•"
+		if !r.html {
+			marker = "-"
+		}
+		if list.IsOrdered() {
+			marker = fmt.Sprintf("%d.", number)
+			number++
+		}
+		content := r.blocks(item, blockSep)
+		items = append(items, prefixLines(content, marker+" ", strings.Repeat(" ", len([]rune(marker))+1)))
+	}
+	return strings.Join(items, itemSep)
+}
+
+// inlines renders the inline children of a node
+func (r *renderer) inlines(parent ast.Node) string {
+	var out strings.Builder
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		out.WriteString(r.inline(n))
+	}
+	return out.String()
+}
+
+func (r *renderer) inline(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Text:
+		value := r.text(string(n.Segment.Value(r.source)))
+		if n.HardLineBreak() || n.SoftLineBreak() {
+			value += "\n"
+		}
+		return value
+	case *ast.String:
+		return r.text(string(n.Value))
+	case *ast.CodeSpan:
+		var content strings.Builder
+		for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+			if t, ok := child.(*ast.Text); ok {
+				content.Write(t.Segment.Value(r.source))
+			}
+		}
+		return r.wrap("code", r.text(content.String()))
+	case *ast.Emphasis:
+		if n.Level >= 2 {
+			return r.wrap("b", r.inlines(n))
+		}
+		return r.wrap("i", r.inlines(n))
+	case *ast.Link:
+		return r.link(string(n.Destination), r.inlines(n))
+	case *ast.Image:
+		return r.link(string(n.Destination), r.inlines(n))
+	case *ast.AutoLink:
+		url := string(n.URL(r.source))
+		destination := url
+		if n.AutoLinkType == ast.AutoLinkEmail && !strings.HasPrefix(strings.ToLower(url), "mailto:") {
+			destination = "mailto:" + url
+		}
+		return r.link(destination, r.text(url))
+	case *ast.RawHTML:
+		return ""
+	}
+	return r.inlines(n)
+}
+
+func (r *renderer) link(destination, content string) string {
+	if r.html {
+		return fmt.Sprintf(`<a href="%s">%s</a>`, escapeAttr(destination), content)
+	}
+	if content == "" || content == destination || "mailto:"+content == destination {
+		return destination
+	}
+	return content + " (" + destination + ")"
+}
+
+// wrap encloses content in a tag in HTML output
+func (r *renderer) wrap(tag, content string) string {
+	if !r.html || content == "" {
+		return content
+	}
+	return "<" + tag + ">" + content + "</" + tag + ">"
+}
+
+func (r *renderer) text(s string) string {
+	if r.html {
+		return escape(s)
+	}
+	return s
+}
+
+// prefixLines prefixes the first line of s with first and every other line with rest
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		switch {
+		case i == 0:
+			lines[i] = first + line
+		case line == "":
+			lines[i] = strings.TrimRight(rest, " ")
+		default:
+			lines[i] = rest + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+func escape(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func escapeAttr(s string) string {
+	return attrEscaper.Replace(s)
+}
@@ -0,0 +1,39 @@
+package providers
+
+// Capabilities describes what a provider can deliver so clients and request
+// validation can adapt to the target provider instead of relying on global limits
+type Capabilities struct {
+	SupportsSubject     bool   `json:"supports_subject"`
+	SupportsHTML        bool   `json:"supports_html"`
+	SupportsMarkdown    bool   `json:"supports_markdown"` // Converts FormatMarkdown messages itself
+	SupportsAttachments bool   `json:"supports_attachments"`
+	SupportsButtons     bool   `json:"supports_buttons"`
+	SupportsBatching    bool   `json:"supports_batching"`
+	MaxMessageLength    int    `json:"max_message_length"`           // In characters, 0 means unlimited
+	MaxSubjectLength    int    `json:"max_subject_length"`           // In characters, 0 means unlimited
+	RecipientPattern    string `json:"recipient_pattern,omitempty"`  // Regular expression recipients must match
+	RecipientOptional   bool   `json:"recipient_optional,omitempty"` // Provider supplies its own recipients
+}
+
+// CapabilityProvider is implemented by providers that advertise their capabilities
+type CapabilityProvider interface {
+	Capabilities() Capabilities
+}
+
+// DefaultCapabilities returns the conservative limits applied to providers
+// that do not advertise their own capabilities
+func DefaultCapabilities() Capabilities {
+	return Capabilities{
+		SupportsSubject:  true,
+		MaxMessageLength: 4096,
+		MaxSubjectLength: 200,
+	}
+}
+
+// CapabilitiesOf returns the capabilities of a provider, falling back to the defaults
+func CapabilitiesOf(provider Provider) Capabilities {
+	if cp, ok := provider.(CapabilityProvider); ok {
+		return cp.Capabilities()
+	}
+	return DefaultCapabilities()
+}
@@ -0,0 +1,118 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// Default circuit breaker settings
+const (
+	DefaultCircuitFailureThreshold = 5
+	DefaultCircuitCooldown         = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned when a send is rejected because the provider's circuit is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitBreaker stops sending through a provider after consecutive failures.
+// After the cooldown a single probe send is let through (half-open): success closes
+// the circuit, failure opens it again for another cooldown.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     string
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+// NewCircuitBreaker creates a closed circuit breaker.
+// Non-positive settings fall back to the defaults.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = DefaultCircuitFailureThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultCircuitCooldown
+	}
+
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     CircuitClosed,
+	}
+}
+
+// Allow reports whether a send may proceed. Every allowed send must be followed by
+// Record, or by Skip when its outcome says nothing about the provider.
+func (cb *CircuitBreaker) Allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if remaining := cb.cooldown - time.Since(cb.openedAt); remaining > 0 {
+			return fmt.Errorf("%w, retry in %s", ErrCircuitOpen, remaining.Round(time.Second))
+		}
+		cb.state = CircuitHalfOpen
+		cb.probing = true
+		return nil
+
+	case CircuitHalfOpen:
+		if cb.probing {
+			return fmt.Errorf("%w, probe send in progress", ErrCircuitOpen)
+		}
+		cb.probing = true
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+// Record updates the breaker with the result of an allowed send
+func (cb *CircuitBreaker) Record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.probing = false
+
+	// Recipient and request errors prove the provider is reachable
+	if err == nil || ErrorCategory(err) == ErrorCategoryRecipient || ErrorCategory(err) == ErrorCategoryPermanent {
+		cb.state = CircuitClosed
+		cb.failures = 0
+		return
+	}
+
+	cb.failures++
+	if cb.state == CircuitHalfOpen || cb.failures >= cb.threshold {
+		cb.state = CircuitOpen
+		cb.openedAt = time.Now()
+	}
+}
+
+// Skip ends an allowed send without counting it, such as one stopped because its
+// notification expired. The failure count is kept, and a half-open breaker lets
+// another probe through.
+func (cb *CircuitBreaker) Skip() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.probing = false
+}
+
+// State returns the current breaker state
+func (cb *CircuitBreaker) State() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
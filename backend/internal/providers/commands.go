@@ -0,0 +1,27 @@
+package providers
+
+import (
+	"context"
+	"time"
+)
+
+// CommandHandler executes chat commands received by providers that support inbound messages
+type CommandHandler interface {
+	// Acknowledge marks a notification as acknowledged by the given actor
+	Acknowledge(ctx context.Context, notificationID, actor string) error
+
+	// Mute suppresses deliveries through a provider for the given duration
+	Mute(ctx context.Context, providerID string, duration time.Duration, actor string) error
+
+	// Unmute lifts an active mute on a provider
+	Unmute(ctx context.Context, providerID, actor string) error
+
+	// Status returns a human-readable summary of all providers
+	Status(ctx context.Context) (string, error)
+}
+
+// CommandReceiver is implemented by providers that can receive chat commands.
+// Receiving stops when the provider is closed.
+type CommandReceiver interface {
+	StartReceiving(handler CommandHandler) error
+}
@@ -0,0 +1,43 @@
+package providers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MaxDedupWindow is the longest a deduplication window can last
+const MaxDedupWindow = 24 * time.Hour
+
+// DedupProvider is implemented by providers that can suppress duplicate notifications
+type DedupProvider interface {
+	Dedup() *DedupConfig
+}
+
+// DedupOf returns the deduplication configuration of a provider, or nil when it sends duplicates
+func DedupOf(provider Provider) *DedupConfig {
+	if p, ok := provider.(DedupProvider); ok {
+		return p.Dedup()
+	}
+	return nil
+}
+
+// Window returns how long duplicates of a notification are suppressed
+func (c *DedupConfig) Window() time.Duration {
+	return time.Duration(c.WindowSeconds) * time.Second
+}
+
+// Fingerprint identifies the notifications that count as duplicates of each other
+func (c *DedupConfig) Fingerprint(notification *Notification) string {
+	hash := sha256.New()
+	for _, part := range []string{notification.ProviderID, notification.Recipient, notification.Subject} {
+		fmt.Fprintf(hash, "%s\x00", part)
+	}
+	for _, key := range c.MetadataKeys {
+		value, _ := json.Marshal(notification.Metadata[key])
+		fmt.Fprintf(hash, "%s=%s\x00", key, value)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,51 @@
+package providers
+
+import (
+	"fmt"
+	"time"
+)
+
+// Digest limits
+const (
+	DefaultDigestMaxItems = 100
+	MaxDigestItems        = 1000
+	MaxDigestWindow       = 24 * time.Hour
+)
+
+// DigestProvider is implemented by providers that can be configured in digest mode
+type DigestProvider interface {
+	Digest() *DigestConfig
+}
+
+// DigestOf returns the digest configuration of a provider, or nil when it sends
+// every notification as it arrives
+func DigestOf(provider Provider) *DigestConfig {
+	if p, ok := provider.(DigestProvider); ok {
+		return p.Digest()
+	}
+	return nil
+}
+
+// Window returns how long notifications are buffered before the digest is sent
+func (c *DigestConfig) Window() time.Duration {
+	return time.Duration(c.WindowSeconds) * time.Second
+}
+
+// Limit returns how many notifications a digest holds before it is sent early
+func (c *DigestConfig) Limit() int {
+	if c.MaxItems <= 0 {
+		return DefaultDigestMaxItems
+	}
+	return c.MaxItems
+}
+
+// Validate checks the configuration and returns the name of the invalid field with the error
+func (c *DigestConfig) Validate() (string, error) {
+	if c.WindowSeconds < 1 || c.Window() > MaxDigestWindow {
+		return "window_seconds", fmt.Errorf("window_seconds must be between 1 and %d", int(MaxDigestWindow/time.Second))
+	}
+	if c.MaxItems < 0 || c.MaxItems > MaxDigestItems {
+		return "max_items", fmt.Errorf("max_items must be between 1 and %d", MaxDigestItems)
+	}
+	return "", nil
+}
@@ -2,13 +2,22 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/developertyrone/notimulti/internal/markdown"
 	"gopkg.in/gomail.v2"
 )
 
+// emailMaxMessageLength bounds the plain-text body size accepted for email notifications
+const emailMaxMessageLength = 100000
+
 // EmailProvider implements the Provider interface for SMTP email
 type EmailProvider struct {
 	id             string
@@ -16,6 +25,9 @@ type EmailProvider struct {
 	dailer         *gomail.Dialer
 	lastTestAt     *time.Time
 	lastTestStatus string
+	health         *healthCache
+	retry          RetryPolicy
+	limiter        *RateLimiter
 }
 
 // NewEmailProvider creates a new Email provider instance
@@ -43,102 +55,146 @@ func NewEmailProvider(id string, config *EmailConfig) (*EmailProvider, error) {
 	}
 
 	return &EmailProvider{
-		id:     id,
-		config: config,
-		dailer: dialer,
+		id:      id,
+		config:  config,
+		dailer:  dialer,
+		health:  newHealthCache(StatusInactive, "health check pending"),
+		retry:   NewRetryPolicy(config.Retry),
+		limiter: NewRateLimiter(config.RateLimit),
 	}, nil
 }
 
 // Send sends a notification via SMTP email with retry logic
 func (ep *EmailProvider) Send(ctx context.Context, notification *Notification) error {
 	if notification == nil {
-		return fmt.Errorf("notification cannot be nil")
+		return NewPermanentError(fmt.Errorf("notification cannot be nil"))
 	}
 
 	if notification.Recipient == "" {
-		return fmt.Errorf("recipient email cannot be empty")
+		return recipientErrorf("recipient email cannot be empty")
 	}
 
 	// Validate email format
 	if !isValidEmail(notification.Recipient) {
-		return fmt.Errorf("invalid email format: %s", notification.Recipient)
+		return recipientErrorf("invalid email format: %s", notification.Recipient)
 	}
 
+	payload := ep.payload(notification)
 	message := gomail.NewMessage()
-	message.SetHeader("From", ep.config.From)
-	message.SetHeader("To", notification.Recipient)
+	message.SetHeader("From", payload.From)
+	message.SetHeader("To", payload.To)
+	message.SetHeader("Subject", payload.Subject)
+	message.SetBody("text/plain", payload.Text)
+	if payload.HTML != "" {
+		message.AddAlternative("text/html", payload.HTML)
+	}
 
-	if notification.Subject != "" {
-		message.SetHeader("Subject", notification.Subject)
-	} else {
-		message.SetHeader("Subject", "Notification")
+	err := ep.retry.Do(ctx, func(attempt int) error {
+		// Every attempt counts against the relay's limits
+		if err := ep.limiter.Wait(ctx, notification.Recipient); err != nil {
+			return err
+		}
+		return classifyEmailError(ep.dailer.DialAndSend(message))
+	})
+	if err != nil {
+		return err
 	}
 
-	message.SetBody("text/plain", notification.Message)
+	ep.health.markDelivered()
+	return nil
+}
 
-	// Retry logic with exponential backoff
-	backoffMs := []int{1000, 2000, 4000} // 1s, 2s, 4s
-	var lastErr error
+// EmailPayload is the message the Email provider sends for a notification
+type EmailPayload struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"` // Sent as an alternative to Text
+}
 
-	for attempt := 0; attempt < 3; attempt++ {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("context cancelled: %w", ctx.Err())
-		default:
-		}
+func (ep *EmailProvider) payload(notification *Notification) EmailPayload {
+	subject := notification.Subject
+	if subject == "" {
+		subject = "Notification"
+	}
+	payload := EmailPayload{
+		From:    ep.config.From,
+		To:      notification.Recipient,
+		Subject: subject,
+		Text:    notification.Message,
+		HTML:    notification.HTML,
+	}
+	// Markdown is sent as HTML with the stripped text as the plain-text alternative
+	if notification.Format == FormatMarkdown {
+		payload.Text = markdown.Text(notification.Message)
+		payload.HTML = markdown.HTML(notification.Message)
+	}
+	return payload
+}
 
-		// Send with timeout
-		err := ep.dailer.DialAndSend(message)
-		if err == nil {
-			return nil
-		}
+// Preview returns the message Send would send
+func (ep *EmailProvider) Preview(notification *Notification) interface{} {
+	return ep.payload(notification)
+}
 
-		lastErr = err
+// RetryPolicy returns the retry policy used for sends
+func (ep *EmailProvider) RetryPolicy() RetryPolicy {
+	return ep.retry
+}
 
-		// Check if error is retryable
-		if !isRetryableEmailError(err) {
-			return fmt.Errorf("non-retryable error: %w", err)
-		}
+// RateLimiter returns the outbound rate limiter, or nil when no limits are configured
+func (ep *EmailProvider) RateLimiter() *RateLimiter {
+	return ep.limiter
+}
 
-		// Sleep with exponential backoff if not the last attempt
-		if attempt < 2 {
-			backoff := time.Duration(backoffMs[attempt]) * time.Millisecond
-			select {
-			case <-time.After(backoff):
-			case <-ctx.Done():
-				return fmt.Errorf("context cancelled during retry: %w", ctx.Err())
-			}
-		}
-	}
+// Digest returns the digest configuration, or nil when digest mode is off
+func (ep *EmailProvider) Digest() *DigestConfig {
+	return ep.config.Digest
+}
+
+// QuietHours returns the quiet hours configuration, or nil when there is none
+func (ep *EmailProvider) QuietHours() *QuietHoursConfig {
+	return ep.config.QuietHours
+}
 
-	return fmt.Errorf("failed after 3 retries: %w", lastErr)
+// Dedup returns the deduplication configuration, or nil when duplicates are sent
+func (ep *EmailProvider) Dedup() *DedupConfig {
+	return ep.config.Dedup
 }
 
-// GetStatus returns the current status of the provider
+// GetStatus returns the cached status of the provider without dialing SMTP
 func (ep *EmailProvider) GetStatus() *ProviderStatus {
-	// Try to connect to verify configuration
-	conn, err := ep.dailer.Dial()
-	if err != nil {
-		return &ProviderStatus{
-			Status:         StatusError,
-			LastUpdated:    time.Now(),
-			ErrorMessage:   fmt.Sprintf("SMTP connectivity check failed: %v", err),
-			LastTestAt:     ep.lastTestAt,     // T049
-			LastTestStatus: ep.lastTestStatus, // T049
+	status := ep.health.snapshot()
+	status.LastTestAt = ep.lastTestAt         // T049
+	status.LastTestStatus = ep.lastTestStatus // T049
+	return &status
+}
+
+// CheckHealth dials the SMTP server and caches the result
+func (ep *EmailProvider) CheckHealth(ctx context.Context) error {
+	return ep.health.probe(ctx, func() (string, error) {
+		conn, err := ep.dailer.Dial()
+		if err != nil {
+			return "", fmt.Errorf("SMTP connectivity check failed: %w", err)
 		}
-	}
-	defer func() {
 		if closeErr := conn.Close(); closeErr != nil {
 			fmt.Printf("warning: failed to close SMTP connection for provider %s: %v\n", ep.id, closeErr)
 		}
-	}()
-
-	return &ProviderStatus{
-		Status:         StatusActive,
-		LastUpdated:    time.Now(),
-		ErrorMessage:   fmt.Sprintf("SMTP: %s:%d", ep.config.Host, ep.config.Port),
-		LastTestAt:     ep.lastTestAt,     // T049
-		LastTestStatus: ep.lastTestStatus, // T049
+		return fmt.Sprintf("SMTP: %s:%d", ep.config.Host, ep.config.Port), nil
+	})
+}
+
+// Capabilities returns what the Email provider can deliver (plain-text bodies with
+// an optional HTML alternative rendered by a template or from Markdown)
+func (ep *EmailProvider) Capabilities() Capabilities {
+	return Capabilities{
+		SupportsSubject:  true,
+		SupportsHTML:     true,
+		SupportsMarkdown: true,
+		MaxMessageLength: emailMaxMessageLength,
+		MaxSubjectLength: 200,
+		RecipientPattern: `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`,
 	}
 }
 
@@ -253,43 +309,60 @@ func isValidDomain(domain string) bool {
 	return hasDot
 }
 
-// Helper function to determine if SMTP error is retryable
-func isRetryableEmailError(err error) bool {
+// smtpReplyCodePattern finds an SMTP reply code in error text such as
+// "gomail: could not send email 1: 550 5.1.1 user unknown"
+var smtpReplyCodePattern = regexp.MustCompile(`(?:^|: )([245]\d\d)[ -]`)
+
+// classifyEmailError converts an SMTP error into a typed ProviderError
+func classifyEmailError(err error) error {
 	if err == nil {
-		return false
+		return nil
 	}
 
-	errStr := err.Error()
-
 	// Network errors are typically retryable
-	if _, ok := err.(net.Error); ok {
-		return true
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return NewTransientError(err)
+	}
+
+	code := 0
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		code = protoErr.Code
+	} else if match := smtpReplyCodePattern.FindStringSubmatch(err.Error()); match != nil {
+		code, _ = strconv.Atoi(match[1])
+	}
+
+	switch {
+	case code == 530 || code == 534 || code == 535 || code == 454:
+		// Authentication required, mechanism too weak, credentials invalid, TLS not available
+		return NewAuthError(err)
+	case code >= 400 && code < 500:
+		return NewTransientError(err)
+	case code == 550 || code == 551 || code == 553 || code == 501 || code == 552:
+		return NewRecipientError(err)
+	case code >= 500:
+		return NewPermanentError(err)
 	}
 
-	// Check for SMTP transient failures
-	retryableErrors := []string{
+	errStr := strings.ToLower(err.Error())
+	for _, transient := range []string{
 		"timeout",
 		"connection refused",
 		"connection reset",
 		"temporary failure",
 		"service unavailable",
 		"try again later",
+		"eof",
+	} {
+		if strings.Contains(errStr, transient) {
+			return NewTransientError(err)
+		}
 	}
 
-	for _, retryable := range retryableErrors {
-		if len(errStr) >= len(retryable) {
-			match := true
-			for i := 0; i < len(retryable); i++ {
-				if errStr[i] != retryable[i] {
-					match = false
-					break
-				}
-			}
-			if match {
-				return true
-			}
-		}
+	if strings.Contains(errStr, "auth") || strings.Contains(errStr, "certificate") || strings.Contains(errStr, "tls") {
+		return NewAuthError(err)
 	}
 
-	return false
+	return err
 }
@@ -0,0 +1,138 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Error categories for failed deliveries
+const (
+	ErrorCategoryTransient   = "transient"    // Network or server-side failure, safe to retry
+	ErrorCategoryRateLimited = "rate_limited" // Throttled by the remote side, retry after a delay
+	ErrorCategoryRecipient   = "recipient"    // Recipient is invalid or unreachable, do not retry
+	ErrorCategoryAuth        = "auth"         // Credentials or provider configuration are wrong
+	ErrorCategoryPermanent   = "permanent"    // Request was rejected for another reason, do not retry
+	ErrorCategoryUnknown     = "unknown"      // Error was not classified by the provider
+)
+
+// ErrorCategories lists every error category in display order
+var ErrorCategories = []string{
+	ErrorCategoryTransient,
+	ErrorCategoryRateLimited,
+	ErrorCategoryRecipient,
+	ErrorCategoryAuth,
+	ErrorCategoryPermanent,
+	ErrorCategoryUnknown,
+}
+
+// ErrPartialDelivery is wrapped in the error of a send that failed after part of the
+// notification, such as the first chunks of a long message, was delivered. Such sends
+// are not retried or failed over, which would deliver the same part again.
+var ErrPartialDelivery = errors.New("notification partially delivered")
+
+// ProviderError is a classified delivery error returned by providers
+type ProviderError struct {
+	Category   string
+	RetryAfter time.Duration // Minimum wait requested by the remote side (rate_limited only)
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// NewTransientError marks an error as a temporary failure
+func NewTransientError(err error) error {
+	return &ProviderError{Category: ErrorCategoryTransient, Err: err}
+}
+
+// NewRateLimitedError marks an error as throttling with an optional retry-after delay
+func NewRateLimitedError(err error, retryAfter time.Duration) error {
+	return &ProviderError{Category: ErrorCategoryRateLimited, RetryAfter: retryAfter, Err: err}
+}
+
+// NewRecipientError marks an error as caused by the recipient
+func NewRecipientError(err error) error {
+	return &ProviderError{Category: ErrorCategoryRecipient, Err: err}
+}
+
+// NewAuthError marks an error as an authentication or configuration problem
+func NewAuthError(err error) error {
+	return &ProviderError{Category: ErrorCategoryAuth, Err: err}
+}
+
+// NewPermanentError marks an error as a non-retryable rejection
+func NewPermanentError(err error) error {
+	return &ProviderError{Category: ErrorCategoryPermanent, Err: err}
+}
+
+// recipientErrorf creates a recipient error with a formatted message
+func recipientErrorf(format string, args ...any) error {
+	return NewRecipientError(fmt.Errorf(format, args...))
+}
+
+// ErrorCategory returns the category of a delivery error, or "" for nil
+func ErrorCategory(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.Category
+	}
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return ErrorCategoryTransient
+	}
+	return ErrorCategoryUnknown
+}
+
+// ExpiredDuring reports whether a failed send ran out of time because its notification
+// expired: it has expired by now, or the send stopped at the deadline of ctx and that
+// deadline was the expiry
+func ExpiredDuring(ctx context.Context, notification *Notification, err error) bool {
+	if err == nil || notification.ExpiresAt == nil {
+		return false
+	}
+	if notification.Expired(time.Now()) {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && !deadline.Before(*notification.ExpiresAt) &&
+		(errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrRetryPastDeadline))
+}
+
+// IsRetryable reports whether a delivery error may succeed if retried
+func IsRetryable(err error) bool {
+	switch ErrorCategory(err) {
+	case ErrorCategoryTransient, ErrorCategoryRateLimited:
+		return true
+	default:
+		return false
+	}
+}
+
+// RetryAfter returns the delay requested by a rate-limited error
+func RetryAfter(err error) time.Duration {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.RetryAfter
+	}
+	return 0
+}
+
+// IsValidErrorCategory reports whether category is a known error category
+func IsValidErrorCategory(category string) bool {
+	for _, c := range ErrorCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
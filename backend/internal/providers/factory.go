@@ -39,6 +39,18 @@ func (f *Factory) NewProvider(config *ProviderConfig) (Provider, error) {
 		}
 		return NewEmailProvider(config.ID, config.Email)
 
+	case ProviderTypeGroup:
+		if config.Group == nil {
+			return nil, fmt.Errorf("group config is required for group provider")
+		}
+		return NewGroupProvider(config.ID, config.Group)
+
+	case ProviderTypeFailover:
+		if config.Failover == nil {
+			return nil, fmt.Errorf("failover config is required for failover provider")
+		}
+		return NewFailoverProvider(config.ID, config.Failover)
+
 	default:
 		return nil, fmt.Errorf("unsupported provider type: %s", config.Type)
 	}
@@ -0,0 +1,285 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// ProviderTypeFailover is the type of failover chains
+const ProviderTypeFailover = "failover"
+
+// ErrFailoverSend is returned when a failover chain is asked to send without the registry
+var ErrFailoverSend = NewPermanentError(errors.New("failover chains are delivered through the registry, which resolves their hops"))
+
+// FailoverProvider is an ordered list of providers tried in turn until one delivers.
+// Hops are resolved through the registry at send time, so reloading a hop's
+// configuration takes effect without reloading the chain.
+type FailoverProvider struct {
+	id     string
+	hops   []FailoverHop
+	status *ProviderStatus
+}
+
+// FailoverResult records how a failover delivery went
+type FailoverResult struct {
+	ProviderID string       `json:"provider_id,omitempty"` // Hop that delivered, empty if every hop failed
+	Hop        int          `json:"hop,omitempty"`         // 1-based position of that hop
+	Failures   []HopFailure `json:"failures,omitempty"`    // Hops tried before it, in order
+}
+
+// HopFailure is a hop that did not deliver
+type HopFailure struct {
+	ProviderID string `json:"provider_id"`
+	Category   string `json:"category"`
+	Error      string `json:"error"`
+}
+
+// NewFailoverProvider creates a new failover chain
+func NewFailoverProvider(id string, config *FailoverConfig) (*FailoverProvider, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config cannot be nil")
+	}
+
+	if len(config.Hops) == 0 {
+		return nil, fmt.Errorf("failover chain must have at least one hop")
+	}
+
+	ids := make([]string, len(config.Hops))
+	for i, hop := range config.Hops {
+		if hop.ProviderID == "" {
+			return nil, fmt.Errorf("hop provider_id is required")
+		}
+		if hop.ProviderID == id {
+			return nil, fmt.Errorf("failover chain cannot contain itself")
+		}
+		ids[i] = hop.ProviderID
+	}
+
+	return &FailoverProvider{
+		id:   id,
+		hops: append([]FailoverHop(nil), config.Hops...),
+		status: &ProviderStatus{
+			Status:       StatusActive,
+			LastUpdated:  time.Now(),
+			ErrorMessage: fmt.Sprintf("Chain: %s", strings.Join(ids, " → ")),
+		},
+	}, nil
+}
+
+// Hops returns the hops of the chain in the order they are tried
+func (fp *FailoverProvider) Hops() []FailoverHop {
+	return append([]FailoverHop(nil), fp.hops...)
+}
+
+// Send always fails; use Registry.Send so hops are resolved at send time
+func (fp *FailoverProvider) Send(ctx context.Context, notification *Notification) error {
+	return ErrFailoverSend
+}
+
+// GetStatus returns the chain status
+func (fp *FailoverProvider) GetStatus() *ProviderStatus {
+	return fp.status
+}
+
+// GetID returns the chain ID
+func (fp *FailoverProvider) GetID() string {
+	return fp.id
+}
+
+// GetType returns the provider type
+func (fp *FailoverProvider) GetType() string {
+	return ProviderTypeFailover
+}
+
+// GetTestRecipient returns an error; each hop is tested individually
+func (fp *FailoverProvider) GetTestRecipient() (string, error) {
+	return "", fmt.Errorf("failover chain %s has no test recipient, test its hops instead", fp.id)
+}
+
+// Test returns an error; each hop is tested individually
+func (fp *FailoverProvider) Test(ctx context.Context) error {
+	return fmt.Errorf("failover chain %s cannot be tested directly, test its hops instead", fp.id)
+}
+
+// Close does nothing for failover chains
+func (fp *FailoverProvider) Close() error {
+	return nil
+}
+
+// IsVirtual reports whether a provider only routes to other providers (groups and failover chains)
+func IsVirtual(provider Provider) bool {
+	switch provider.GetType() {
+	case ProviderTypeGroup, ProviderTypeFailover:
+		return true
+	default:
+		return false
+	}
+}
+
+// SendFailover tries each hop of a failover chain in order until one delivers.
+// Hops are looked up when the send starts; missing, muted or virtual hops are
+// skipped. Each hop gets its own timeout and goes through its circuit breaker.
+func (r *Registry) SendFailover(ctx context.Context, chain *FailoverProvider, notification *Notification) (*FailoverResult, error) {
+	result := &FailoverResult{}
+	var lastErr error
+
+	for i, hop := range chain.Hops() {
+		if ctx.Err() != nil {
+			lastErr = fmt.Errorf("failover stopped: %w", ctx.Err())
+			break
+		}
+
+		member, err := r.resolveHop(hop)
+		if err != nil {
+			lastErr = err
+			result.Failures = append(result.Failures, hopFailure(hop, err))
+			continue
+		}
+
+		hopNotification := *notification
+		hopNotification.ProviderID = hop.ProviderID
+		if hop.Recipient != "" {
+			hopNotification.Recipient = hop.Recipient
+		}
+
+		hopCtx, cancel := context.WithTimeout(ctx, r.hopTimeout(hop, member))
+		err = r.Send(hopCtx, member, &hopNotification)
+		cancel()
+
+		if err == nil {
+			result.ProviderID = hop.ProviderID
+			result.Hop = i + 1
+			if i > 0 {
+				r.log(slog.LevelInfo, "Failover chain delivered through fallback hop",
+					"id", chain.GetID(),
+					"hop", hop.ProviderID,
+					"position", i+1)
+			}
+			return result, nil
+		}
+
+		lastErr = err
+		result.Failures = append(result.Failures, hopFailure(hop, err))
+		r.log(slog.LevelWarn, "Failover hop failed",
+			"id", chain.GetID(),
+			"hop", hop.ProviderID,
+			"position", i+1,
+			"category", ErrorCategory(err),
+			"error", err)
+
+		// The recipient already has part of the notification; another hop would repeat it
+		if errors.Is(err, ErrPartialDelivery) {
+			break
+		}
+	}
+
+	summary := make([]string, len(result.Failures))
+	for i, failure := range result.Failures {
+		summary[i] = fmt.Sprintf("%s: %s", failure.ProviderID, failure.Error)
+	}
+	return result, fmt.Errorf("all failover hops failed (%s): %w", strings.Join(summary, "; "), lastErr)
+}
+
+// resolveHop looks up the provider of a hop at send time
+func (r *Registry) resolveHop(hop FailoverHop) (Provider, error) {
+	member, err := r.Get(hop.ProviderID)
+	if err != nil {
+		return nil, NewPermanentError(err)
+	}
+	if IsVirtual(member) {
+		return nil, NewPermanentError(fmt.Errorf("%s provider %s cannot be a failover hop", member.GetType(), hop.ProviderID))
+	}
+	if until, muted := r.MutedUntil(hop.ProviderID); muted {
+		return nil, NewPermanentError(fmt.Errorf("provider %s muted until %s", hop.ProviderID, until.UTC().Format(time.RFC3339)))
+	}
+	return member, nil
+}
+
+// hopTimeout returns the configured timeout of a hop, or the member's delivery timeout
+func (r *Registry) hopTimeout(hop FailoverHop, member Provider) time.Duration {
+	if hop.TimeoutSeconds > 0 {
+		return time.Duration(hop.TimeoutSeconds) * time.Second
+	}
+	return DeliveryTimeout(member)
+}
+
+// DeliveryTimeout returns how long a delivery through the provider may take.
+// A failover chain may use the timeouts of all of its hops.
+func (r *Registry) DeliveryTimeout(provider Provider) time.Duration {
+	chain, ok := provider.(*FailoverProvider)
+	if !ok {
+		return DeliveryTimeout(provider)
+	}
+
+	var total time.Duration
+	for _, hop := range chain.Hops() {
+		member, err := r.Get(hop.ProviderID)
+		if err != nil || IsVirtual(member) {
+			continue
+		}
+		total += r.hopTimeout(hop, member)
+	}
+	if total == 0 {
+		return DefaultDeliveryTimeout
+	}
+	return total
+}
+
+// CapabilitiesOf returns the capabilities of a provider. A failover chain only
+// promises what every one of its current hops supports, and needs no recipient
+// when every hop has its own.
+func (r *Registry) CapabilitiesOf(provider Provider) Capabilities {
+	chain, ok := provider.(*FailoverProvider)
+	if !ok {
+		return CapabilitiesOf(provider)
+	}
+
+	merged := Capabilities{SupportsSubject: true, SupportsHTML: true, SupportsMarkdown: true, SupportsButtons: true, RecipientOptional: true}
+	resolved := 0
+	for _, hop := range chain.Hops() {
+		if hop.Recipient == "" {
+			merged.RecipientOptional = false
+		}
+
+		member, err := r.Get(hop.ProviderID)
+		if err != nil || IsVirtual(member) {
+			continue
+		}
+		resolved++
+
+		caps := CapabilitiesOf(member)
+		merged.SupportsSubject = merged.SupportsSubject && caps.SupportsSubject
+		merged.SupportsHTML = merged.SupportsHTML && caps.SupportsHTML
+		merged.SupportsMarkdown = merged.SupportsMarkdown && caps.SupportsMarkdown
+		merged.SupportsButtons = merged.SupportsButtons && caps.SupportsButtons
+		merged.MaxMessageLength = minLimit(merged.MaxMessageLength, caps.MaxMessageLength)
+		merged.MaxSubjectLength = minLimit(merged.MaxSubjectLength, caps.MaxSubjectLength)
+	}
+
+	if resolved == 0 {
+		defaults := DefaultCapabilities()
+		defaults.RecipientOptional = merged.RecipientOptional
+		return defaults
+	}
+	return merged
+}
+
+// minLimit returns the stricter of two limits where 0 means unlimited
+func minLimit(a, b int) int {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+func hopFailure(hop FailoverHop, err error) HopFailure {
+	return HopFailure{
+		ProviderID: hop.ProviderID,
+		Category:   ErrorCategory(err),
+		Error:      err.Error(),
+	}
+}
@@ -0,0 +1,103 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ProviderTypeGroup is the type of provider groups
+const ProviderTypeGroup = "group"
+
+// ErrGroupSend is returned when a group is used as a delivery target directly
+var ErrGroupSend = NewPermanentError(fmt.Errorf("provider groups cannot send directly, deliveries fan out to their members"))
+
+// GroupProvider names a set of member providers that a notification fans out to.
+// It implements Provider so groups are loaded, listed and muted like any other
+// provider, but delivery is done per member by the caller.
+type GroupProvider struct {
+	id      string
+	members []GroupMember
+	status  *ProviderStatus
+}
+
+// NewGroupProvider creates a new provider group
+func NewGroupProvider(id string, config *GroupConfig) (*GroupProvider, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config cannot be nil")
+	}
+
+	if len(config.Members) == 0 {
+		return nil, fmt.Errorf("group must have at least one member")
+	}
+
+	seen := make(map[string]bool, len(config.Members))
+	for _, member := range config.Members {
+		if member.ProviderID == "" {
+			return nil, fmt.Errorf("member provider_id is required")
+		}
+		if member.ProviderID == id {
+			return nil, fmt.Errorf("group cannot contain itself")
+		}
+		if seen[member.ProviderID] {
+			return nil, fmt.Errorf("duplicate member: %s", member.ProviderID)
+		}
+		seen[member.ProviderID] = true
+	}
+
+	ids := make([]string, len(config.Members))
+	for i, member := range config.Members {
+		ids[i] = member.ProviderID
+	}
+
+	return &GroupProvider{
+		id:      id,
+		members: append([]GroupMember(nil), config.Members...),
+		status: &ProviderStatus{
+			Status:       StatusActive,
+			LastUpdated:  time.Now(),
+			ErrorMessage: fmt.Sprintf("Members: %s", strings.Join(ids, ", ")),
+		},
+	}, nil
+}
+
+// Members returns the members of the group in configuration order
+func (gp *GroupProvider) Members() []GroupMember {
+	return append([]GroupMember(nil), gp.members...)
+}
+
+// Send always fails; group sends are fanned out to the members
+func (gp *GroupProvider) Send(ctx context.Context, notification *Notification) error {
+	return ErrGroupSend
+}
+
+// GetStatus returns the group status
+func (gp *GroupProvider) GetStatus() *ProviderStatus {
+	return gp.status
+}
+
+// GetID returns the group ID
+func (gp *GroupProvider) GetID() string {
+	return gp.id
+}
+
+// GetType returns the provider type
+func (gp *GroupProvider) GetType() string {
+	return ProviderTypeGroup
+}
+
+// GetTestRecipient returns an error; groups have no recipient of their own
+func (gp *GroupProvider) GetTestRecipient() (string, error) {
+	return "", fmt.Errorf("provider group %s has no test recipient, test its members instead", gp.id)
+}
+
+// Test returns an error; each member provider is tested individually
+func (gp *GroupProvider) Test(ctx context.Context) error {
+	return fmt.Errorf("provider group %s cannot be tested directly, test its members instead", gp.id)
+}
+
+// Close does nothing for groups
+func (gp *GroupProvider) Close() error {
+	return nil
+}
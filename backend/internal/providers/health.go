@@ -0,0 +1,121 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// HealthChecker is implemented by providers that can actively probe their backend.
+// Probes run in the background so GetStatus can return the cached result instantly.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// healthCache holds the last known health of a provider
+type healthCache struct {
+	mu      sync.RWMutex
+	status  ProviderStatus
+	probing bool
+}
+
+// newHealthCache creates a cache with an initial status and detail message
+func newHealthCache(status, detail string) *healthCache {
+	return &healthCache{
+		status: ProviderStatus{
+			Status:       status,
+			LastUpdated:  time.Now(),
+			ErrorMessage: detail,
+		},
+	}
+}
+
+// snapshot returns a copy of the cached status
+func (h *healthCache) snapshot() ProviderStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	status := h.status
+	if h.status.LastCheckedAt != nil {
+		checkedAt := *h.status.LastCheckedAt
+		status.LastCheckedAt = &checkedAt
+	}
+	return status
+}
+
+// probe runs check with the context deadline and records the outcome.
+// A check that outlives its deadline keeps running, but no new check starts until it returns.
+func (h *healthCache) probe(ctx context.Context, check func() (string, error)) error {
+	h.mu.Lock()
+	if h.probing {
+		h.mu.Unlock()
+		err := fmt.Errorf("previous health check still running")
+		h.record("", 0, err)
+		return err
+	}
+	h.probing = true
+	h.mu.Unlock()
+
+	type result struct {
+		detail string
+		err    error
+	}
+
+	start := time.Now()
+	done := make(chan result, 1)
+	go func() {
+		detail, err := check()
+
+		h.mu.Lock()
+		h.probing = false
+		h.mu.Unlock()
+
+		done <- result{detail: detail, err: err}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		res.err = fmt.Errorf("health check timed out: %w", ctx.Err())
+	}
+
+	h.record(res.detail, time.Since(start), res.err)
+	return res.err
+}
+
+// record stores the outcome of a health check
+func (h *healthCache) record(detail string, latency time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	h.status.LastUpdated = now
+	h.status.LastCheckedAt = &now
+	h.status.LatencyMs = latency.Milliseconds()
+
+	if err != nil {
+		h.status.Status = StatusError
+		h.status.ErrorMessage = err.Error()
+		h.status.ConsecutiveFailures++
+		return
+	}
+
+	h.status.Status = StatusActive
+	h.status.ErrorMessage = detail
+	h.status.ConsecutiveFailures = 0
+}
+
+// markDelivered records a successful delivery, which proves the provider is reachable
+func (h *healthCache) markDelivered() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.status.Status != StatusActive {
+		h.status.ErrorMessage = ""
+	}
+	h.status.Status = StatusActive
+	h.status.LastUpdated = time.Now()
+	h.status.ConsecutiveFailures = 0
+}
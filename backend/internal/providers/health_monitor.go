@@ -0,0 +1,164 @@
+package providers
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Default health check settings
+const (
+	DefaultHealthCheckInterval = 60 * time.Second
+	DefaultHealthCheckTimeout  = 5 * time.Second
+)
+
+// HealthMonitor periodically probes registered providers in the background
+type HealthMonitor struct {
+	registry *Registry
+	interval time.Duration
+	timeout  time.Duration
+	logger   *slog.Logger
+
+	mu      sync.Mutex
+	records map[string]*healthRecord
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// healthRecord tracks when a provider instance was last probed
+type healthRecord struct {
+	provider  Provider
+	lastCheck time.Time
+	running   bool
+	failing   bool
+}
+
+// NewHealthMonitor creates a health monitor for the registry.
+// Non-positive interval or timeout values fall back to the defaults.
+func NewHealthMonitor(registry *Registry, interval, timeout time.Duration, logger *slog.Logger) *HealthMonitor {
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+	if timeout <= 0 {
+		timeout = DefaultHealthCheckTimeout
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &HealthMonitor{
+		registry: registry,
+		interval: interval,
+		timeout:  timeout,
+		logger:   logger,
+		records:  make(map[string]*healthRecord),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start begins probing providers. New or replaced providers are probed within a second.
+func (m *HealthMonitor) Start() {
+	m.wg.Add(1)
+	go m.run()
+}
+
+// Stop cancels in-flight probes and waits for the monitor to exit
+func (m *HealthMonitor) Stop() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// run checks for due providers until stopped
+func (m *HealthMonitor) run() {
+	defer m.wg.Done()
+
+	tick := time.Second
+	if m.interval < tick {
+		tick = m.interval
+	}
+
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	m.checkDue()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.checkDue()
+		}
+	}
+}
+
+// checkDue starts a probe for every provider whose last check is older than the interval
+func (m *HealthMonitor) checkDue() {
+	now := time.Now()
+	seen := make(map[string]bool)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, provider := range m.registry.List() {
+		checker, ok := provider.(HealthChecker)
+		if !ok {
+			continue
+		}
+
+		id := provider.GetID()
+		seen[id] = true
+
+		record, exists := m.records[id]
+		if !exists || record.provider != provider {
+			// New or hot-reloaded provider instance - probe immediately
+			record = &healthRecord{provider: provider}
+			m.records[id] = record
+		}
+
+		if record.running || now.Sub(record.lastCheck) < m.interval {
+			continue
+		}
+
+		record.running = true
+		record.lastCheck = now
+		m.wg.Add(1)
+		go m.probe(id, record, checker)
+	}
+
+	for id := range m.records {
+		if !seen[id] {
+			delete(m.records, id)
+		}
+	}
+}
+
+// probe runs a single health check and logs state transitions
+func (m *HealthMonitor) probe(id string, record *healthRecord, checker HealthChecker) {
+	defer m.wg.Done()
+
+	ctx, cancel := context.WithTimeout(m.ctx, m.timeout)
+	defer cancel()
+
+	err := checker.CheckHealth(ctx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record.running = false
+	if m.ctx.Err() != nil {
+		return
+	}
+
+	if err != nil && !record.failing {
+		m.logger.Warn("Provider health check failed", "id", id, "error", err)
+	} else if err == nil && record.failing {
+		m.logger.Info("Provider health check recovered", "id", id)
+	}
+	record.failing = err != nil
+}
@@ -0,0 +1,15 @@
+package providers
+
+// PreviewProvider is implemented by providers that can show what they would send for a
+// notification without sending it, e.g. the escaped and split Telegram messages
+type PreviewProvider interface {
+	Preview(notification *Notification) interface{}
+}
+
+// PreviewOf returns what a provider would send for a notification, if the provider can tell
+func PreviewOf(provider Provider, notification *Notification) (interface{}, bool) {
+	if pp, ok := provider.(PreviewProvider); ok {
+		return pp.Preview(notification), true
+	}
+	return nil, false
+}
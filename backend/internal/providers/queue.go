@@ -0,0 +1,355 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Default delivery queue settings
+const (
+	DefaultHighPriorityWorkers   = 4
+	DefaultNormalPriorityWorkers = 4
+	DefaultLowPriorityWorkers    = 2
+	DefaultStarvationAge         = 30 * time.Second
+	DefaultQueueCapacity         = 10000
+)
+
+// ErrQueueFull is wrapped in the error of a delivery submitted while the queue holds
+// its capacity of waiting deliveries
+var ErrQueueFull = errors.New("delivery queue is full")
+
+// deliveryLanes are the lanes of the delivery queue, highest priority first
+var deliveryLanes = []string{PriorityHigh, PriorityNormal, PriorityLow}
+
+// DeliveryQueue runs deliveries on a worker pool per priority lane. An idle worker takes
+// the highest priority delivery it serves, so high priority notifications go ahead of
+// queued normal and low ones. Workers of a lane also serve the lanes above it but never
+// those below, which keeps the high lane's workers free for pages during a storm of
+// informational messages. A delivery that has waited longer than the starvation age is
+// taken before newer higher priority work, so low priority notifications are delayed but
+// never held forever.
+//
+// A delivery that waits for a retry backoff or a rate limit gives its worker back for
+// the wait, and rejoins its lane, ahead of newer deliveries, once the wait is over. The
+// queue holds at most its capacity of deliveries waiting for a worker; Submit fails
+// with ErrQueueFull beyond that rather than buffering without bound.
+type DeliveryQueue struct {
+	workers       map[string]int
+	starvationAge time.Duration
+	capacity      int
+	logger        *slog.Logger
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	lanes   map[string][]queuedDelivery
+	busy    map[string]int
+	waiting map[string]int
+	started bool
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// queuedDelivery is a delivery waiting for a worker: a new one, or one resuming after a wait
+type queuedDelivery struct {
+	run      func(context.Context)
+	lane     string
+	queuedAt time.Time
+	resumed  *activeDelivery
+}
+
+// activeDelivery is a delivery that a worker has started. It runs in its own goroutine,
+// so its worker can run other deliveries while it waits.
+type activeDelivery struct {
+	queue    *DeliveryQueue
+	lane     string
+	queuedAt time.Time
+	parked   bool
+	yield    chan struct{} // Signalled when the delivery starts waiting or finishes
+	resume   chan struct{} // Signalled when a worker takes the delivery back
+}
+
+type activeDeliveryKey struct{}
+
+// QueueLaneStatus reports the workers and backlog of a delivery queue lane
+type QueueLaneStatus struct {
+	Lane           string     `json:"lane"`
+	Workers        int        `json:"workers"`
+	Busy           int        `json:"busy"`
+	Queued         int        `json:"queued"`
+	Waiting        int        `json:"waiting"` // Started deliveries waiting for a retry or rate limit, without a worker
+	OldestQueuedAt *time.Time `json:"oldest_queued_at,omitempty"`
+}
+
+// NewDeliveryQueue creates a delivery queue with the given number of workers per lane.
+// The high and normal lanes may have no workers of their own and are then served by the
+// workers of the lanes below; negative counts, a non-positive low lane count and a
+// non-positive starvation age and capacity fall back to the defaults.
+func NewDeliveryQueue(highWorkers, normalWorkers, lowWorkers int, starvationAge time.Duration, capacity int, logger *slog.Logger) *DeliveryQueue {
+	if highWorkers < 0 {
+		highWorkers = DefaultHighPriorityWorkers
+	}
+	if normalWorkers < 0 {
+		normalWorkers = DefaultNormalPriorityWorkers
+	}
+	if lowWorkers <= 0 {
+		lowWorkers = DefaultLowPriorityWorkers
+	}
+	if starvationAge <= 0 {
+		starvationAge = DefaultStarvationAge
+	}
+	if capacity <= 0 {
+		capacity = DefaultQueueCapacity
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	q := &DeliveryQueue{
+		workers: map[string]int{
+			PriorityHigh:   highWorkers,
+			PriorityNormal: normalWorkers,
+			PriorityLow:    lowWorkers,
+		},
+		starvationAge: starvationAge,
+		capacity:      capacity,
+		logger:        logger,
+		lanes:         make(map[string][]queuedDelivery),
+		busy:          make(map[string]int),
+		waiting:       make(map[string]int),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// Start launches the workers. Deliveries submitted before Start wait in the queue.
+func (q *DeliveryQueue) Start() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.started {
+		return
+	}
+	q.started = true
+
+	for i, lane := range deliveryLanes {
+		for n := 0; n < q.workers[lane]; n++ {
+			q.wg.Add(1)
+			go q.work(deliveryLanes[:i+1])
+		}
+	}
+}
+
+// Stop stops accepting deliveries and waits for the workers to finish the queued ones,
+// including those waiting for a retry or rate limit. Deliveries submitted afterwards
+// run in their own goroutine.
+func (q *DeliveryQueue) Stop() {
+	q.mu.Lock()
+	q.stopped = true
+	pending := q.queuedLocked()
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	if pending > 0 {
+		q.logger.Info("Draining delivery queue", "queued", pending)
+	}
+	q.wg.Wait()
+}
+
+// Submit queues a delivery in the lane of its priority; unknown priorities use the normal
+// lane. run is passed a context that lets retry backoff and rate limit waits give the
+// worker back. Submit fails with a transient ErrQueueFull error when the queue is full.
+func (q *DeliveryQueue) Submit(priority string, run func(ctx context.Context)) error {
+	lane := laneOf(priority)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stopped {
+		go run(context.Background())
+		return nil
+	}
+	if queued := q.queuedLocked(); queued >= q.capacity {
+		return NewTransientError(fmt.Errorf("%w: %d deliveries waiting", ErrQueueFull, queued))
+	}
+	q.lanes[lane] = append(q.lanes[lane], queuedDelivery{run: run, lane: lane, queuedAt: time.Now()})
+	q.cond.Broadcast()
+	return nil
+}
+
+// Status reports every lane, highest priority first
+func (q *DeliveryQueue) Status() []QueueLaneStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	status := make([]QueueLaneStatus, len(deliveryLanes))
+	for i, lane := range deliveryLanes {
+		status[i] = QueueLaneStatus{
+			Lane:    lane,
+			Workers: q.workers[lane],
+			Busy:    q.busy[lane],
+			Queued:  len(q.lanes[lane]),
+			Waiting: q.waiting[lane],
+		}
+		if queued := q.lanes[lane]; len(queued) > 0 {
+			oldest := queued[0].queuedAt.UTC()
+			status[i].OldestQueuedAt = &oldest
+		}
+	}
+	return status
+}
+
+// work runs deliveries from the given lanes until the queue is stopped and drained
+func (q *DeliveryQueue) work(lanes []string) {
+	defer q.wg.Done()
+	own := lanes[len(lanes)-1]
+
+	for {
+		q.mu.Lock()
+		delivery, ok := q.nextLocked(lanes, time.Now())
+		for !ok && (!q.stopped || q.waitingLocked() > 0) {
+			q.cond.Wait()
+			delivery, ok = q.nextLocked(lanes, time.Now())
+		}
+		if !ok {
+			q.mu.Unlock()
+			return
+		}
+		q.busy[own]++
+		q.mu.Unlock()
+
+		// Hold the worker until the delivery finishes or starts waiting
+		active := delivery.resumed
+		if active == nil {
+			active = &activeDelivery{
+				queue:    q,
+				lane:     delivery.lane,
+				queuedAt: delivery.queuedAt,
+				yield:    make(chan struct{}, 1),
+				resume:   make(chan struct{}),
+			}
+			go func() {
+				delivery.run(context.WithValue(context.Background(), activeDeliveryKey{}, active))
+				active.yield <- struct{}{}
+			}()
+		} else {
+			active.resume <- struct{}{}
+		}
+		<-active.yield
+
+		q.mu.Lock()
+		q.busy[own]--
+		q.mu.Unlock()
+	}
+}
+
+// nextLocked removes the delivery a worker serving lanes should run next: the oldest
+// starved delivery if any has waited longer than the starvation age, otherwise the
+// first delivery of the highest priority lane with work. Must be called with q.mu held.
+func (q *DeliveryQueue) nextLocked(lanes []string, now time.Time) (queuedDelivery, bool) {
+	pick := ""
+	for _, lane := range lanes {
+		queued := q.lanes[lane]
+		if len(queued) == 0 || now.Sub(queued[0].queuedAt) < q.starvationAge {
+			continue
+		}
+		if pick == "" || queued[0].queuedAt.Before(q.lanes[pick][0].queuedAt) {
+			pick = lane
+		}
+	}
+	if pick == "" {
+		for _, lane := range lanes {
+			if len(q.lanes[lane]) > 0 {
+				pick = lane
+				break
+			}
+		}
+	}
+	if pick == "" {
+		return queuedDelivery{}, false
+	}
+
+	delivery := q.lanes[pick][0]
+	q.lanes[pick][0] = queuedDelivery{}
+	q.lanes[pick] = q.lanes[pick][1:]
+	return delivery, true
+}
+
+// waitingLocked counts the started deliveries waiting without a worker. Must be called with q.mu held.
+func (q *DeliveryQueue) waitingLocked() int {
+	total := 0
+	for _, waiting := range q.waiting {
+		total += waiting
+	}
+	return total
+}
+
+// queuedLocked counts the deliveries waiting in all lanes. Must be called with q.mu held.
+func (q *DeliveryQueue) queuedLocked() int {
+	total := 0
+	for _, queued := range q.lanes {
+		total += len(queued)
+	}
+	return total
+}
+
+// laneOf maps a notification priority to its delivery lane
+func laneOf(priority string) string {
+	switch priority {
+	case PriorityHigh, PriorityLow:
+		return priority
+	default:
+		return PriorityNormal
+	}
+}
+
+// park gives the delivery's worker back while it waits. It returns false when the
+// delivery is already waiting.
+func (a *activeDelivery) park() bool {
+	q := a.queue
+	q.mu.Lock()
+	if a.parked {
+		q.mu.Unlock()
+		return false
+	}
+	a.parked = true
+	q.waiting[a.lane]++
+	q.mu.Unlock()
+
+	a.yield <- struct{}{}
+	return true
+}
+
+// unpark queues the delivery ahead of those submitted after it and blocks until a
+// worker takes it back
+func (a *activeDelivery) unpark() {
+	q := a.queue
+	q.mu.Lock()
+	a.parked = false
+	q.waiting[a.lane]--
+	queued := q.lanes[a.lane]
+	i := len(queued)
+	for i > 0 && queued[i-1].queuedAt.After(a.queuedAt) {
+		i--
+	}
+	queued = append(queued, queuedDelivery{})
+	copy(queued[i+1:], queued[i:])
+	queued[i] = queuedDelivery{lane: a.lane, queuedAt: a.queuedAt, resumed: a}
+	q.lanes[a.lane] = queued
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	<-a.resume
+}
+
+// releaseWorker gives the worker running the delivery of ctx back to the delivery queue
+// for a wait. It returns a function that blocks until a worker takes the delivery back;
+// for deliveries not run by a queue both do nothing.
+func releaseWorker(ctx context.Context) (reacquire func()) {
+	active, ok := ctx.Value(activeDeliveryKey{}).(*activeDelivery)
+	if !ok || !active.park() {
+		return func() {}
+	}
+	return active.unpark
+}
@@ -0,0 +1,92 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// timeOfDayPattern matches quiet hours boundaries (HH:MM, 24-hour clock)
+var timeOfDayPattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// QuietHoursProvider is implemented by providers that can be configured with quiet hours
+type QuietHoursProvider interface {
+	QuietHours() *QuietHoursConfig
+}
+
+// QuietHoursOf returns the quiet hours that apply to a recipient of a provider, or nil
+// when the provider delivers to the recipient at any time
+func QuietHoursOf(provider Provider, recipient string) *QuietHours {
+	p, ok := provider.(QuietHoursProvider)
+	if !ok {
+		return nil
+	}
+	config := p.QuietHours()
+	if config == nil {
+		return nil
+	}
+	if window := config.Recipients[recipient]; window != nil {
+		return window
+	}
+	if config.Start == "" {
+		return nil
+	}
+	return &config.QuietHours
+}
+
+// Active reports whether t falls inside the window and, if so, when the window ends.
+// The window is evaluated in its Timezone, or in loc when it has none.
+func (q *QuietHours) Active(t time.Time, loc *time.Location) (bool, time.Time) {
+	if q == nil {
+		return false, time.Time{}
+	}
+	start, errStart := time.Parse("15:04", q.Start)
+	end, errEnd := time.Parse("15:04", q.End)
+	if errStart != nil || errEnd != nil {
+		return false, time.Time{}
+	}
+	if q.Timezone != "" {
+		if tz, err := time.LoadLocation(q.Timezone); err == nil {
+			loc = tz
+		}
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	var active bool
+	switch {
+	case startMinute < endMinute:
+		active = minute >= startMinute && minute < endMinute
+	case startMinute > endMinute: // Spans midnight
+		active = minute >= startMinute || minute < endMinute
+	}
+	if !active {
+		return false, time.Time{}
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+	if minute >= endMinute {
+		until = until.AddDate(0, 0, 1)
+	}
+	return true, until
+}
+
+// Validate checks the window's times and timezone
+func (q *QuietHours) Validate() error {
+	if !timeOfDayPattern.MatchString(q.Start) || !timeOfDayPattern.MatchString(q.End) {
+		return errors.New("start and end must be times of day in HH:MM format")
+	}
+	if q.Start == q.End {
+		return errors.New("start and end must differ")
+	}
+	if q.Timezone != "" {
+		if _, err := time.LoadLocation(q.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %q", q.Timezone)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultRateLimitMaxWait bounds how long a send may be delayed by rate limits
+const DefaultRateLimitMaxWait = 30 * time.Second
+
+// maxTrackedRecipients is the number of per-recipient buckets kept before idle ones are pruned
+const maxTrackedRecipients = 1000
+
+// ErrRateLimitExceeded is returned when a send would have to wait longer than allowed
+var ErrRateLimitExceeded = errors.New("outbound rate limit exceeded")
+
+// RateLimitedProvider is implemented by providers with outbound rate limits
+type RateLimitedProvider interface {
+	RateLimiter() *RateLimiter
+}
+
+// RateLimiter delays sends so a provider stays within its configured global and
+// per-recipient token buckets instead of being throttled upstream.
+// A nil *RateLimiter allows every send immediately.
+type RateLimiter struct {
+	mu           sync.Mutex
+	global       *tokenBucket
+	perRecipient *RateLimitRule
+	recipients   map[string]*tokenBucket
+	maxWait      time.Duration
+	delayed      int64
+	rejected     int64
+}
+
+// RateLimitStatus is a snapshot of a provider's rate limiter
+type RateLimitStatus struct {
+	Global        *TokenBucketStatus        `json:"global,omitempty"`
+	PerRecipient  *RecipientRateLimitStatus `json:"per_recipient,omitempty"`
+	MaxWaitMs     int64                     `json:"max_wait_ms"`
+	DelayedSends  int64                     `json:"delayed_sends"`  // Sends that had to wait for a token
+	RejectedSends int64                     `json:"rejected_sends"` // Sends that failed because the wait was too long
+}
+
+// TokenBucketStatus describes the current state of a token bucket
+type TokenBucketStatus struct {
+	Limit      int   `json:"limit"`
+	IntervalMs int   `json:"interval_ms"`
+	Burst      int   `json:"burst"`
+	Available  int   `json:"available"` // Tokens that can be taken without waiting
+	WaitMs     int64 `json:"wait_ms"`   // Delay a new send would get
+}
+
+// RecipientRateLimitStatus describes the per-recipient buckets
+type RecipientRateLimitStatus struct {
+	Limit             int              `json:"limit"`
+	IntervalMs        int              `json:"interval_ms"`
+	Burst             int              `json:"burst"`
+	TrackedRecipients int              `json:"tracked_recipients"`
+	Throttled         map[string]int64 `json:"throttled,omitempty"` // Wait in ms by recipient, for recipients out of tokens
+}
+
+// NewRateLimiter creates a rate limiter from a provider's configuration.
+// It returns nil when no limits are configured.
+func NewRateLimiter(config *RateLimitConfig) *RateLimiter {
+	if config == nil || (!config.Global.valid() && !config.PerRecipient.valid()) {
+		return nil
+	}
+
+	rl := &RateLimiter{
+		recipients: make(map[string]*tokenBucket),
+		maxWait:    DefaultRateLimitMaxWait,
+	}
+	if config.MaxWaitMs > 0 {
+		rl.maxWait = time.Duration(config.MaxWaitMs) * time.Millisecond
+	}
+	if config.Global.valid() {
+		rl.global = newTokenBucket(*config.Global, time.Now())
+	}
+	if config.PerRecipient.valid() {
+		rule := *config.PerRecipient
+		rl.perRecipient = &rule
+	}
+	return rl
+}
+
+// MaxWait returns the longest a send may be delayed, or 0 for a nil limiter
+func (rl *RateLimiter) MaxWait() time.Duration {
+	if rl == nil {
+		return 0
+	}
+	return rl.maxWait
+}
+
+// Wait blocks until a send to recipient is allowed by every bucket.
+// Sends that would wait longer than the max wait or the context deadline fail
+// immediately with a rate_limited error and do not consume tokens.
+func (rl *RateLimiter) Wait(ctx context.Context, recipient string) error {
+	if rl == nil {
+		return nil
+	}
+
+	rl.mu.Lock()
+	now := time.Now()
+
+	var reserved []*tokenBucket
+	var wait time.Duration
+	if rl.global != nil {
+		wait = rl.global.reserve(now)
+		reserved = append(reserved, rl.global)
+	}
+	if rl.perRecipient != nil {
+		bucket := rl.recipientBucket(recipient, now)
+		if d := bucket.reserve(now); d > wait {
+			wait = d
+		}
+		reserved = append(reserved, bucket)
+	}
+
+	limit := rl.maxWait
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < limit {
+		limit = time.Until(deadline)
+	}
+	if wait > limit {
+		for _, bucket := range reserved {
+			bucket.release()
+		}
+		rl.rejected++
+		rl.mu.Unlock()
+		return NewRateLimitedError(fmt.Errorf("%w: next slot in %s", ErrRateLimitExceeded, wait.Round(time.Millisecond)), wait)
+	}
+	if wait > 0 {
+		rl.delayed++
+	}
+	rl.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	// The delivery queue worker runs other deliveries during the wait
+	reacquire := releaseWorker(ctx)
+	defer reacquire()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		rl.mu.Lock()
+		for _, bucket := range reserved {
+			bucket.release()
+		}
+		rl.mu.Unlock()
+		return fmt.Errorf("rate limit wait cancelled: %w", ctx.Err())
+	}
+}
+
+// Status returns a snapshot of the limiter, or nil for a nil limiter
+func (rl *RateLimiter) Status() *RateLimitStatus {
+	if rl == nil {
+		return nil
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	status := &RateLimitStatus{
+		MaxWaitMs:     rl.maxWait.Milliseconds(),
+		DelayedSends:  rl.delayed,
+		RejectedSends: rl.rejected,
+	}
+
+	if rl.global != nil {
+		status.Global = rl.global.status(now)
+	}
+
+	if rl.perRecipient != nil {
+		recipients := &RecipientRateLimitStatus{
+			Limit:             rl.perRecipient.Limit,
+			IntervalMs:        rl.perRecipient.IntervalMs,
+			Burst:             rl.perRecipient.burst(),
+			TrackedRecipients: len(rl.recipients),
+		}
+		for recipient, bucket := range rl.recipients {
+			if wait := bucket.status(now).WaitMs; wait > 0 {
+				if recipients.Throttled == nil {
+					recipients.Throttled = make(map[string]int64)
+				}
+				recipients.Throttled[recipient] = wait
+			}
+		}
+		status.PerRecipient = recipients
+	}
+
+	return status
+}
+
+// recipientBucket returns the bucket of a recipient, pruning full buckets when too many are tracked.
+// The caller must hold rl.mu.
+func (rl *RateLimiter) recipientBucket(recipient string, now time.Time) *tokenBucket {
+	if bucket, ok := rl.recipients[recipient]; ok {
+		return bucket
+	}
+
+	if len(rl.recipients) >= maxTrackedRecipients {
+		for key, bucket := range rl.recipients {
+			bucket.refill(now)
+			if bucket.tokens >= bucket.capacity {
+				delete(rl.recipients, key)
+			}
+		}
+	}
+
+	bucket := newTokenBucket(*rl.perRecipient, now)
+	rl.recipients[recipient] = bucket
+	return bucket
+}
+
+func (r *RateLimitRule) valid() bool {
+	return r != nil && r.Limit > 0 && r.IntervalMs > 0
+}
+
+// burst returns the bucket capacity, defaulting to the limit
+func (r *RateLimitRule) burst() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Limit
+}
+
+// tokenBucket refills limit tokens per interval up to its capacity.
+// Reservations may take the balance below zero; the deficit is the queue of waiting sends.
+type tokenBucket struct {
+	rule     RateLimitRule
+	capacity float64
+	perToken time.Duration
+	tokens   float64
+	updated  time.Time
+}
+
+func newTokenBucket(rule RateLimitRule, now time.Time) *tokenBucket {
+	capacity := float64(rule.burst())
+	return &tokenBucket{
+		rule:     rule,
+		capacity: capacity,
+		perToken: time.Duration(rule.IntervalMs) * time.Millisecond / time.Duration(rule.Limit),
+		tokens:   capacity,
+		updated:  now,
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated)
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens = math.Min(b.capacity, b.tokens+float64(elapsed)/float64(b.perToken))
+	b.updated = now
+}
+
+// reserve takes a token and returns how long the caller must wait before using it
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.refill(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens * float64(b.perToken))
+}
+
+// release returns a reserved token that was not used
+func (b *tokenBucket) release() {
+	b.tokens = math.Min(b.capacity, b.tokens+1)
+}
+
+func (b *tokenBucket) status(now time.Time) *TokenBucketStatus {
+	b.refill(now)
+
+	status := &TokenBucketStatus{
+		Limit:      b.rule.Limit,
+		IntervalMs: b.rule.IntervalMs,
+		Burst:      int(b.capacity),
+	}
+	if b.tokens >= 1 {
+		status.Available = int(b.tokens)
+	} else {
+		status.WaitMs = time.Duration((1 - b.tokens) * float64(b.perToken)).Milliseconds()
+	}
+	return status
+}
@@ -2,22 +2,107 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
+	"time"
 )
 
 // Registry manages a thread-safe collection of notification providers
 type Registry struct {
-	mu        sync.RWMutex
-	providers map[string]Provider
-	logger    *slog.Logger
+	mu             sync.RWMutex
+	providers      map[string]Provider
+	mutes          map[string]time.Time
+	breakers       map[string]*CircuitBreaker
+	commandHandler CommandHandler
+	queue          *DeliveryQueue
+	logger         *slog.Logger
+
+	breakerThreshold int
+	breakerCooldown  time.Duration
 }
 
 // NewRegistry creates a new provider registry
 func NewRegistry() *Registry {
 	return &Registry{
-		providers: make(map[string]Provider),
+		providers:        make(map[string]Provider),
+		mutes:            make(map[string]time.Time),
+		breakers:         make(map[string]*CircuitBreaker),
+		breakerThreshold: DefaultCircuitFailureThreshold,
+		breakerCooldown:  DefaultCircuitCooldown,
+	}
+}
+
+// SetCircuitBreakerSettings configures the breakers created for providers registered afterwards
+func (r *Registry) SetCircuitBreakerSettings(threshold int, cooldown time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.breakerThreshold = threshold
+	r.breakerCooldown = cooldown
+}
+
+// SetCommandHandler sets the handler for inbound chat commands.
+// Providers implementing CommandReceiver start receiving when they are registered.
+func (r *Registry) SetCommandHandler(handler CommandHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commandHandler = handler
+}
+
+// SetDeliveryQueue runs deliveries passed to Enqueue on queue's priority lanes
+func (r *Registry) SetDeliveryQueue(queue *DeliveryQueue) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queue = queue
+}
+
+// DeliveryQueue returns the delivery queue, or nil if none is set
+func (r *Registry) DeliveryQueue() *DeliveryQueue {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.queue
+}
+
+// Enqueue runs a delivery of the given notification priority on the delivery queue,
+// or in its own goroutine when no queue is set. deliver should derive the context of
+// its sends from the one it is passed. It fails when the queue is full.
+func (r *Registry) Enqueue(priority string, deliver func(ctx context.Context)) error {
+	queue := r.DeliveryQueue()
+	if queue == nil {
+		go deliver(context.Background())
+		return nil
+	}
+	return queue.Submit(priority, deliver)
+}
+
+// startReceiver starts inbound command handling for a provider if supported.
+// Must be called with r.mu held.
+func (r *Registry) startReceiver(provider Provider) {
+	if r.commandHandler == nil {
+		return
+	}
+
+	receiver, ok := provider.(CommandReceiver)
+	if !ok {
+		return
+	}
+
+	if err := receiver.StartReceiving(r.commandHandler); err != nil {
+		r.log(slog.LevelWarn, "Failed to start command receiver",
+			"id", provider.GetID(),
+			"error", err)
+	}
+}
+
+// startReceiverIfCurrent starts a provider's command receiver unless it has been
+// replaced or removed in the meantime
+func (r *Registry) startReceiverIfCurrent(provider Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if current, exists := r.providers[provider.GetID()]; exists && current == provider {
+		r.startReceiver(provider)
 	}
 }
 
@@ -45,17 +130,20 @@ func (r *Registry) Register(provider Provider) error {
 	}
 
 	r.mu.Lock()
-	defer r.mu.Unlock()
+	existing, exists := r.providers[id]
+	r.providers[id] = provider
+	r.breakers[id] = NewCircuitBreaker(r.breakerThreshold, r.breakerCooldown)
+	r.mu.Unlock()
 
 	// Close existing provider if being replaced
-	if existing, exists := r.providers[id]; exists {
+	if exists {
 		if err := existing.Close(); err != nil {
 			// Log error but continue with replacement
 			fmt.Printf("Warning: error closing existing provider %s: %v\n", id, err)
 		}
 	}
 
-	r.providers[id] = provider
+	r.startReceiverIfCurrent(provider)
 	return nil
 }
 
@@ -88,25 +176,28 @@ func (r *Registry) List() []Provider {
 // Remove removes a provider from the registry and closes it
 func (r *Registry) Remove(id string) error {
 	r.mu.Lock()
-	defer r.mu.Unlock()
-
 	provider, exists := r.providers[id]
 	if !exists {
+		r.mu.Unlock()
 		return fmt.Errorf("provider not found: %s", id)
 	}
 
-	// Close the provider
+	delete(r.providers, id)
+	delete(r.breakers, id)
+	r.mu.Unlock()
+
+	// Close the provider outside the lock, as in Replace
 	if err := provider.Close(); err != nil {
 		return fmt.Errorf("error closing provider %s: %w", id, err)
 	}
 
-	delete(r.providers, id)
 	r.log(slog.LevelInfo, "Provider removed", "id", id, "type", provider.GetType())
 	return nil
 }
 
 // Replace atomically replaces a provider with a new one
-// The old provider is closed after the swap
+// The old provider is closed after the swap, and the new one starts receiving commands
+// only once the old one has stopped, as Telegram allows a single poller per bot
 func (r *Registry) Replace(id string, newProvider Provider) error {
 	if newProvider == nil {
 		return fmt.Errorf("cannot replace with nil provider")
@@ -117,8 +208,6 @@ func (r *Registry) Replace(id string, newProvider Provider) error {
 	}
 
 	r.mu.Lock()
-	defer r.mu.Unlock()
-
 	oldProvider, exists := r.providers[id]
 
 	// Get checksums for logging
@@ -136,6 +225,8 @@ func (r *Registry) Replace(id string, newProvider Provider) error {
 	// Atomic swap - this is the critical section
 	// Once we update the map, new requests will use the new provider
 	r.providers[id] = newProvider
+	r.breakers[id] = NewCircuitBreaker(r.breakerThreshold, r.breakerCooldown)
+	r.mu.Unlock()
 
 	r.log(slog.LevelInfo, "Provider replaced",
 		"id", id,
@@ -143,8 +234,8 @@ func (r *Registry) Replace(id string, newProvider Provider) error {
 		"old_checksum", oldChecksum,
 		"new_checksum", newChecksum)
 
-	// Close old provider after swap (outside critical section would be ideal,
-	// but we're already in the lock, so do it here)
+	// Close old provider after swap, outside the critical section: Close waits for
+	// the command receiver, which may be handling a command that calls the registry
 	if exists {
 		if err := oldProvider.Close(); err != nil {
 			// Log error but don't fail the replacement
@@ -154,6 +245,7 @@ func (r *Registry) Replace(id string, newProvider Provider) error {
 		}
 	}
 
+	r.startReceiverIfCurrent(newProvider)
 	return nil
 }
 
@@ -167,20 +259,129 @@ func (r *Registry) Count() int {
 // Clear removes all providers from the registry
 func (r *Registry) Clear() error {
 	r.mu.Lock()
-	defer r.mu.Unlock()
+	providers := r.providers
+	r.providers = make(map[string]Provider)
+	r.breakers = make(map[string]*CircuitBreaker)
+	r.mu.Unlock()
 
+	// Close outside the lock, as in Replace
 	var errors []error
-	for id, provider := range r.providers {
+	for id, provider := range providers {
 		if err := provider.Close(); err != nil {
 			errors = append(errors, fmt.Errorf("error closing provider %s: %w", id, err))
 		}
 	}
 
-	r.providers = make(map[string]Provider)
-
 	if len(errors) > 0 {
 		return fmt.Errorf("errors occurred while clearing registry: %v", errors)
 	}
 
 	return nil
 }
+
+// Mute suppresses deliveries through a provider until the given time.
+// Mutes are kept by provider ID so they survive configuration reloads, but only in
+// memory: a restart lifts them.
+func (r *Registry) Mute(id string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.providers[id]; !exists {
+		return fmt.Errorf("provider not found: %s", id)
+	}
+
+	r.mutes[id] = until
+	r.log(slog.LevelInfo, "Provider muted", "id", id, "until", until)
+	return nil
+}
+
+// Unmute lifts a mute on a provider. It returns false if the provider was not muted.
+func (r *Registry) Unmute(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	until, exists := r.mutes[id]
+	delete(r.mutes, id)
+	if !exists || time.Now().After(until) {
+		return false
+	}
+
+	r.log(slog.LevelInfo, "Provider unmuted", "id", id)
+	return true
+}
+
+// MutedUntil returns the end of an active mute on a provider
+func (r *Registry) MutedUntil(id string) (time.Time, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	until, exists := r.mutes[id]
+	if !exists || time.Now().After(until) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// Send delivers a notification through the provider's circuit breaker.
+// While the circuit is open the send fails fast with ErrCircuitOpen.
+// Sends that fail because the notification expired, or that the provider's own rate
+// limiter turned away, do not count against the breaker: neither says anything about the
+// provider. A rate limit reported by the provider itself does count, so a provider that
+// keeps throttling gets the cooldown to recover.
+// Failover chains are delivered hop by hop, see SendFailover.
+func (r *Registry) Send(ctx context.Context, provider Provider, notification *Notification) error {
+	if chain, ok := provider.(*FailoverProvider); ok {
+		_, err := r.SendFailover(ctx, chain, notification)
+		return err
+	}
+
+	breaker := r.breaker(provider.GetID())
+	if breaker == nil {
+		return provider.Send(ctx, notification)
+	}
+
+	if err := breaker.Allow(); err != nil {
+		return fmt.Errorf("provider %s: %w", provider.GetID(), err)
+	}
+
+	before := breaker.State()
+	err := provider.Send(ctx, notification)
+	if ExpiredDuring(ctx, notification, err) || errors.Is(err, ErrRateLimitExceeded) {
+		breaker.Skip()
+	} else {
+		breaker.Record(err)
+	}
+
+	if after := breaker.State(); after != before {
+		level := slog.LevelInfo
+		if after == CircuitOpen {
+			level = slog.LevelWarn
+		}
+		r.log(level, "Provider circuit breaker state changed",
+			"id", provider.GetID(),
+			"from", before,
+			"to", after)
+	}
+
+	return err
+}
+
+// StatusOf returns the provider status annotated with its circuit breaker state
+func (r *Registry) StatusOf(provider Provider) *ProviderStatus {
+	status := ProviderStatus{}
+	if current := provider.GetStatus(); current != nil {
+		status = *current
+	}
+
+	if breaker := r.breaker(provider.GetID()); breaker != nil {
+		status.CircuitState = breaker.State()
+	}
+	return &status
+}
+
+// breaker returns the circuit breaker of a registered provider
+func (r *Registry) breaker(id string) *CircuitBreaker {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.breakers[id]
+}
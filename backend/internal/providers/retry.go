@@ -0,0 +1,226 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDeliveryTimeout bounds a delivery through a provider without a retry policy
+const DefaultDeliveryTimeout = 30 * time.Second
+
+// ErrRetryPastDeadline is wrapped in the error of a send given up because the next retry
+// would start after the context's deadline
+var ErrRetryPastDeadline = errors.New("next retry is past the deadline")
+
+// RetryPolicy controls how often and how quickly a failed send is retried
+type RetryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	Jitter       float64 // Fraction of the delay randomly added or removed (0-1)
+
+	// Retryable classifies errors; nil retries every error
+	Retryable func(error) bool
+}
+
+// RetryPolicyProvider is implemented by providers with a configurable retry policy
+type RetryPolicyProvider interface {
+	RetryPolicy() RetryPolicy
+}
+
+// DefaultRetryPolicy returns 3 attempts with 1s, 2s backoff, retrying
+// transient and rate-limited errors
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: time.Second,
+		MaxDelay:     4 * time.Second,
+		Multiplier:   2,
+		Retryable:    IsRetryable,
+	}
+}
+
+// NewRetryPolicy applies a provider's retry configuration on top of the defaults.
+// Configured retryable_errors extend the error classification.
+func NewRetryPolicy(config *RetryConfig) RetryPolicy {
+	policy := DefaultRetryPolicy()
+	if config == nil {
+		return policy
+	}
+
+	if config.MaxAttempts > 0 {
+		policy.MaxAttempts = config.MaxAttempts
+	}
+	if config.InitialDelayMs > 0 {
+		policy.InitialDelay = time.Duration(config.InitialDelayMs) * time.Millisecond
+	}
+	if config.MaxDelayMs > 0 {
+		policy.MaxDelay = time.Duration(config.MaxDelayMs) * time.Millisecond
+	}
+	if policy.MaxDelay < policy.InitialDelay {
+		policy.MaxDelay = policy.InitialDelay
+	}
+	if config.Multiplier >= 1 {
+		policy.Multiplier = config.Multiplier
+	}
+	if config.Jitter > 0 && config.Jitter <= 1 {
+		policy.Jitter = config.Jitter
+	}
+
+	if len(config.RetryableErrors) > 0 {
+		patterns := config.RetryableErrors
+		policy.Retryable = func(err error) bool {
+			for _, pattern := range patterns {
+				if strings.Contains(strings.ToLower(err.Error()), strings.ToLower(pattern)) {
+					return true
+				}
+			}
+			return IsRetryable(err)
+		}
+	}
+
+	return policy
+}
+
+// Delay returns the backoff before the attempt following the given (1-based) attempt
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := float64(p.InitialDelay)
+	for i := 1; i < attempt; i++ {
+		delay *= p.Multiplier
+		if delay >= float64(p.MaxDelay) {
+			break
+		}
+	}
+	if delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// TotalDelay returns the longest time the policy may spend waiting between attempts
+func (p RetryPolicy) TotalDelay() time.Duration {
+	var total time.Duration
+	for attempt := 1; attempt < p.MaxAttempts; attempt++ {
+		noJitter := p
+		noJitter.Jitter = 0
+		delay := noJitter.Delay(attempt)
+		total += delay + time.Duration(float64(delay)*p.Jitter)
+	}
+	return total
+}
+
+// Do runs op until it succeeds, returns a non-retryable error or attempts run out.
+// Every attempt is counted on the context's AttemptCounter, if any.
+func (p RetryPolicy) Do(ctx context.Context, op func(attempt int) error) error {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context cancelled: %w", ctx.Err())
+		default:
+		}
+
+		countAttempt(ctx)
+		err := op(attempt)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		if p.Retryable != nil && !p.Retryable(err) {
+			slog.Warn("Send attempt failed with non-retryable error",
+				"attempt", attempt,
+				"category", ErrorCategory(err),
+				"error", err)
+			return fmt.Errorf("non-retryable error: %w", err)
+		}
+
+		if attempt == maxAttempts {
+			break
+		}
+
+		// Rate limits wait at least as long as the remote side asked
+		delay := p.Delay(attempt)
+		if retryAfter := RetryAfter(err); retryAfter > delay {
+			delay = retryAfter
+		}
+
+		// Waiting past the deadline cannot succeed - report the last error instead
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return fmt.Errorf("failed after %d attempts, next retry in %s: %w: %w", attempt, delay, ErrRetryPastDeadline, err)
+		}
+
+		slog.Warn("Send attempt failed, retrying",
+			"attempt", attempt,
+			"category", ErrorCategory(err),
+			"max_attempts", maxAttempts,
+			"delay", delay,
+			"error", err)
+
+		// The delivery queue worker runs other deliveries during the backoff
+		reacquire := releaseWorker(ctx)
+		select {
+		case <-time.After(delay):
+			reacquire()
+		case <-ctx.Done():
+			reacquire()
+			return fmt.Errorf("context cancelled during retry: %w", ctx.Err())
+		}
+	}
+
+	return fmt.Errorf("failed after %d attempts: %w", maxAttempts, lastErr)
+}
+
+// DeliveryTimeout returns how long a single delivery through the provider may take,
+// including the waits of its retry policy and rate limits
+func DeliveryTimeout(provider Provider) time.Duration {
+	timeout := DefaultDeliveryTimeout
+	if rp, ok := provider.(RetryPolicyProvider); ok {
+		timeout += rp.RetryPolicy().TotalDelay()
+	}
+	if rl, ok := provider.(RateLimitedProvider); ok {
+		timeout += rl.RateLimiter().MaxWait()
+	}
+	return timeout
+}
+
+type attemptCounterKey struct{}
+
+// AttemptCounter counts the send attempts made for a delivery
+type AttemptCounter struct {
+	n atomic.Int32
+}
+
+// WithAttemptCounter returns a context that counts send attempts made with it
+func WithAttemptCounter(ctx context.Context) (context.Context, *AttemptCounter) {
+	counter := &AttemptCounter{}
+	return context.WithValue(ctx, attemptCounterKey{}, counter), counter
+}
+
+// Count returns the number of attempts made so far
+func (c *AttemptCounter) Count() int {
+	return int(c.n.Load())
+}
+
+// countAttempt records an attempt on the context's counter
+func countAttempt(ctx context.Context) {
+	if counter, ok := ctx.Value(attemptCounterKey{}).(*AttemptCounter); ok {
+		counter.n.Add(1)
+	}
+}
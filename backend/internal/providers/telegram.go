@@ -2,7 +2,12 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -15,6 +20,15 @@ type TelegramProvider struct {
 	config         *TelegramConfig
 	lastTestAt     *time.Time
 	lastTestStatus string
+	health         *healthCache
+	retry          RetryPolicy
+	limiter        *RateLimiter
+
+	receiving   bool
+	receiveDone chan struct{}
+	stopCh      chan struct{}
+	closeOnce   sync.Once
+	mu          sync.Mutex
 }
 
 // NewTelegramProvider creates a new Telegram provider instance
@@ -41,112 +55,165 @@ func NewTelegramProvider(id string, config *TelegramConfig) (*TelegramProvider,
 		return nil, fmt.Errorf("failed to create bot API: %w", err)
 	}
 
+	// Creating the bot API already verified the token with getMe
 	return &TelegramProvider{
-		id:     id,
-		bot:    bot,
-		config: config,
+		id:      id,
+		bot:     bot,
+		config:  config,
+		health:  newHealthCache(StatusActive, fmt.Sprintf("Bot: @%s (%s)", bot.Self.UserName, bot.Self.FirstName)),
+		retry:   NewRetryPolicy(config.Retry),
+		limiter: NewRateLimiter(config.RateLimit),
+		stopCh:  make(chan struct{}),
 	}, nil
 }
 
-// Send sends a notification via Telegram with retry logic
+// Send sends a notification via Telegram with retry logic. Each chunk of a long message
+// is retried on its own; once a chunk has been delivered, a failure of a later one is
+// permanent, so the notification is not resent from the start.
 func (tp *TelegramProvider) Send(ctx context.Context, notification *Notification) error {
 	if notification == nil {
-		return fmt.Errorf("notification cannot be nil")
+		return NewPermanentError(fmt.Errorf("notification cannot be nil"))
 	}
 
 	if notification.Recipient == "" {
-		return fmt.Errorf("recipient (chat_id) cannot be empty")
+		return recipientErrorf("recipient (chat_id) cannot be empty")
 	}
 
 	chatID, err := parseChatID(notification.Recipient)
 	if err != nil {
-		return fmt.Errorf("invalid chat_id: %w", err)
+		return NewRecipientError(fmt.Errorf("invalid chat_id: %w", err))
 	}
 
-	// Prepare message with optional parse mode
-	parseMode := tp.config.ParseMode
-	if parseMode == "" {
-		parseMode = "HTML"
-	}
+	// Escape user content for the configured parse mode and split long messages
+	parseMode, chunks := telegramMessages(notification, normalizeParseMode(tp.config.ParseMode))
 
-	message := tgbotapi.NewMessage(chatID, notification.Message)
-	message.ParseMode = parseMode
+	for i, chunk := range chunks {
+		message := tgbotapi.NewMessage(chatID, chunk)
+		message.ParseMode = telegramAPIParseMode(parseMode)
 
-	// Add optional subject as reply_markup or in message
-	if notification.Subject != "" {
-		message.Text = fmt.Sprintf("<b>%s</b>\n\n%s", notification.Subject, notification.Message)
+		// Offer acknowledge/mute buttons below the last chunk when commands are enabled
+		if i == len(chunks)-1 && tp.commandsEnabled() && notification.ID != "" {
+			if keyboard, ok := tp.commandKeyboard(notification.ID); ok {
+				message.ReplyMarkup = keyboard
+			}
+		}
+
+		err := tp.sendWithRetry(ctx, message)
+		if err != nil && message.ParseMode != "" && isEntityParseError(err) {
+			// Telegram rejected the markup - resend this chunk as plain text
+			message.Text = stripTelegramMarkup(chunk, parseMode)
+			message.ParseMode = ""
+			err = tp.sendWithRetry(ctx, message)
+		}
+		if err != nil {
+			if i > 0 {
+				// Resending would repeat the chunks the recipient already has
+				return NewPermanentError(fmt.Errorf("chunk %d/%d: %w: %w", i+1, len(chunks), ErrPartialDelivery, err))
+			}
+			if len(chunks) > 1 {
+				return fmt.Errorf("chunk %d/%d: %w", i+1, len(chunks), err)
+			}
+			return err
+		}
 	}
 
-	// Apply timeout from config (default 5s)
+	tp.health.markDelivered()
+	return nil
+}
+
+// sendWithRetry sends a single message using the provider's retry policy
+func (tp *TelegramProvider) sendWithRetry(ctx context.Context, message tgbotapi.MessageConfig) error {
+	// One deadline covers every attempt, the backoff between them and the rate limiter
+	// waits: the configured timeout (default 5s) per attempt plus the longest waits
 	timeout := 5 * time.Second
 	if tp.config.TimeoutSeconds > 0 {
 		timeout = time.Duration(tp.config.TimeoutSeconds) * time.Second
 	}
 
-	// Create context with timeout for retries
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout*time.Duration(tp.retry.MaxAttempts)+tp.retry.TotalDelay()+tp.limiter.MaxWait())
 	defer cancel()
 
-	// Retry logic with exponential backoff
-	backoffMs := []int{1000, 2000, 4000} // 1s, 2s, 4s
-	var lastErr error
-
-	for attempt := 0; attempt < 3; attempt++ {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("context cancelled: %w", ctx.Err())
-		default:
+	recipient := strconv.FormatInt(message.ChatID, 10)
+	return tp.retry.Do(ctx, func(attempt int) error {
+		// Every attempt counts against the Bot API limits
+		if err := tp.limiter.Wait(ctx, recipient); err != nil {
+			return err
 		}
-
 		_, err := tp.bot.Send(message)
-		if err == nil {
-			return nil
-		}
+		return classifyTelegramError(err)
+	})
+}
 
-		lastErr = err
+// RetryPolicy returns the retry policy used for sends
+func (tp *TelegramProvider) RetryPolicy() RetryPolicy {
+	return tp.retry
+}
 
-		// Check if error is retryable
-		if !isRetryableError(err) {
-			return fmt.Errorf("non-retryable error: %w", err)
-		}
+// RateLimiter returns the outbound rate limiter, or nil when no limits are configured
+func (tp *TelegramProvider) RateLimiter() *RateLimiter {
+	return tp.limiter
+}
 
-		// Sleep with exponential backoff if not the last attempt
-		if attempt < 2 {
-			backoff := time.Duration(backoffMs[attempt]) * time.Millisecond
-			select {
-			case <-time.After(backoff):
-			case <-ctx.Done():
-				return fmt.Errorf("context cancelled during retry: %w", ctx.Err())
-			}
-		}
-	}
+// Digest returns the digest configuration, or nil when digest mode is off
+func (tp *TelegramProvider) Digest() *DigestConfig {
+	return tp.config.Digest
+}
 
-	return fmt.Errorf("failed after 3 retries: %w", lastErr)
+// QuietHours returns the quiet hours configuration, or nil when there is none
+func (tp *TelegramProvider) QuietHours() *QuietHoursConfig {
+	return tp.config.QuietHours
 }
 
-// GetStatus returns the current status of the provider
+// Dedup returns the deduplication configuration, or nil when duplicates are sent
+func (tp *TelegramProvider) Dedup() *DedupConfig {
+	return tp.config.Dedup
+}
+
+// GetStatus returns the cached status of the provider without contacting Telegram
 func (tp *TelegramProvider) GetStatus() *ProviderStatus {
-	_, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	status := tp.health.snapshot()
+	status.LastTestAt = tp.lastTestAt         // T049
+	status.LastTestStatus = tp.lastTestStatus // T049
+	return &status
+}
 
-	// Try to get bot info to verify connectivity
-	user, err := tp.bot.GetMe()
-	if err != nil {
-		return &ProviderStatus{
-			Status:         StatusError,
-			LastUpdated:    time.Now(),
-			ErrorMessage:   fmt.Sprintf("bot connectivity check failed: %v", err),
-			LastTestAt:     tp.lastTestAt,     // T049
-			LastTestStatus: tp.lastTestStatus, // T049
+// CheckHealth verifies bot connectivity with getMe and caches the result
+func (tp *TelegramProvider) CheckHealth(ctx context.Context) error {
+	return tp.health.probe(ctx, func() (string, error) {
+		user, err := tp.bot.GetMe()
+		if err != nil {
+			return "", fmt.Errorf("bot connectivity check failed: %w", err)
 		}
+		return fmt.Sprintf("Bot: @%s (%s)", user.UserName, user.FirstName), nil
+	})
+}
+
+// TelegramPayload is the messages the Telegram provider sends for a notification
+type TelegramPayload struct {
+	ParseMode string   `json:"parse_mode,omitempty"`
+	Messages  []string `json:"messages"`
+}
+
+// Preview returns the messages Send would send, before any plain-text fallback
+func (tp *TelegramProvider) Preview(notification *Notification) interface{} {
+	parseMode, chunks := telegramMessages(notification, normalizeParseMode(tp.config.ParseMode))
+	return TelegramPayload{
+		ParseMode: telegramAPIParseMode(parseMode),
+		Messages:  chunks,
 	}
+}
 
-	return &ProviderStatus{
-		Status:         StatusActive,
-		LastUpdated:    time.Now(),
-		ErrorMessage:   fmt.Sprintf("Bot: @%s (%s)", user.UserName, user.FirstName),
-		LastTestAt:     tp.lastTestAt,     // T049
-		LastTestStatus: tp.lastTestStatus, // T049
+// Capabilities returns what the Telegram provider can deliver.
+// Long messages are split into chunks, up to telegramMaxChunks messages.
+func (tp *TelegramProvider) Capabilities() Capabilities {
+	return Capabilities{
+		SupportsSubject:  true,
+		SupportsHTML:     normalizeParseMode(tp.config.ParseMode) == ParseModeHTML,
+		SupportsMarkdown: true,
+		SupportsButtons:  true,
+		MaxMessageLength: telegramMaxMessageLength * telegramMaxChunks,
+		MaxSubjectLength: 200,
+		RecipientPattern: `^-?[0-9]+$`,
 	}
 }
 
@@ -203,8 +270,19 @@ func (tp *TelegramProvider) Test(ctx context.Context) error {
 	return nil
 }
 
-// Close performs cleanup (Telegram doesn't require explicit cleanup)
+// Close stops the command receiver if it is running and waits for it to exit,
+// interrupting a pending getUpdates poll
 func (tp *TelegramProvider) Close() error {
+	tp.closeOnce.Do(func() {
+		close(tp.stopCh)
+	})
+
+	tp.mu.Lock()
+	done := tp.receiveDone
+	tp.mu.Unlock()
+	if done != nil {
+		<-done
+	}
 	return nil
 }
 
@@ -218,41 +296,40 @@ func parseChatID(chatIDStr string) (int64, error) {
 	return chatID, nil
 }
 
-// Helper function to determine if error is retryable
-func isRetryableError(err error) bool {
+// classifyTelegramError converts a Bot API error into a typed ProviderError
+func classifyTelegramError(err error) error {
 	if err == nil {
-		return false
+		return nil
 	}
 
-	errStr := err.Error()
-
-	// Rate limit (429) or other API errors
-	if apiErr, ok := err.(tgbotapi.Error); ok {
-		// RetryAfter indicates rate limiting - this is retryable
-		if apiErr.RetryAfter > 0 {
-			return true
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		var valueErr tgbotapi.Error
+		if !errors.As(err, &valueErr) {
+			// Not an API response - the request never completed
+			return NewTransientError(err)
 		}
+		apiErr = &valueErr
 	}
 
-	// Timeout or connection errors
-	retryableErrors := []string{
-		"timeout",
-		"connection refused",
-		"connection reset",
-		"EOF",
-		"temporary failure",
-	}
-
-	for _, retryable := range retryableErrors {
-		if contains(errStr, retryable) {
-			return true
-		}
+	description := strings.ToLower(apiErr.Message)
+
+	switch {
+	case apiErr.RetryAfter > 0 || apiErr.Code == http.StatusTooManyRequests:
+		return NewRateLimitedError(err, time.Duration(apiErr.RetryAfter)*time.Second)
+	case apiErr.Code == http.StatusUnauthorized:
+		return NewAuthError(err)
+	case apiErr.Code == http.StatusForbidden,
+		strings.Contains(description, "chat not found"),
+		strings.Contains(description, "user not found"),
+		strings.Contains(description, "peer_id_invalid"):
+		return NewRecipientError(err)
+	case apiErr.Code == http.StatusNotFound:
+		// The Bot API answers 404 for an invalid bot token
+		return NewAuthError(err)
+	case apiErr.Code >= 500:
+		return NewTransientError(err)
+	default:
+		return NewPermanentError(err)
 	}
-
-	return false
-}
-
-// Helper function to check if string contains substring
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(substr) == 0 || (len(substr) <= len(s)))
 }
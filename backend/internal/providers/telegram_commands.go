@@ -0,0 +1,297 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// defaultMuteDuration is used when /mute is called without a duration
+const defaultMuteDuration = time.Hour
+
+// telegramCallbackDataLimit is the Bot API limit for inline button callback data
+const telegramCallbackDataLimit = 64
+
+const telegramCommandsHelp = `Available commands:
+/ack <notification-id> - acknowledge a notification
+/mute <provider-id> [duration] - mute a provider (default 1h, e.g. 30m, 4h, 1d)
+/unmute <provider-id> - lift a mute
+/status - show provider status`
+
+// StartReceiving starts long-polling for bot commands if enabled in the config.
+// Close stops the receiver and waits for it, so a replacement provider can take over
+// without two pollers conflicting.
+func (tp *TelegramProvider) StartReceiving(handler CommandHandler) error {
+	if !tp.commandsEnabled() {
+		return nil
+	}
+
+	if handler == nil {
+		return fmt.Errorf("command handler cannot be nil")
+	}
+
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	select {
+	case <-tp.stopCh:
+		return fmt.Errorf("provider %s is closed", tp.id)
+	default:
+	}
+
+	if tp.receiving {
+		return nil
+	}
+
+	tp.receiving = true
+	tp.receiveDone = make(chan struct{})
+	go tp.receiveLoop(handler, tp.receiveDone)
+
+	slog.Info("Telegram command receiver started", "provider_id", tp.id)
+	return nil
+}
+
+// commandsEnabled reports whether inbound commands are configured
+func (tp *TelegramProvider) commandsEnabled() bool {
+	return tp.config.Commands != nil && tp.config.Commands.Enabled
+}
+
+// cancelableClient aborts requests when ctx is cancelled, so closing the provider
+// interrupts a getUpdates long poll instead of waiting for it to time out
+type cancelableClient struct {
+	ctx    context.Context
+	client tgbotapi.HTTPClient
+}
+
+func (c cancelableClient) Do(req *http.Request) (*http.Response, error) {
+	return c.client.Do(req.WithContext(c.ctx))
+}
+
+// receiveLoop long-polls getUpdates until the provider is closed, then closes done
+func (tp *TelegramProvider) receiveLoop(handler CommandHandler, done chan struct{}) {
+	defer close(done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-tp.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	poller := *tp.bot
+	poller.Client = cancelableClient{ctx: ctx, client: tp.bot.Client}
+
+	updateConfig := tgbotapi.NewUpdate(0)
+	updateConfig.Timeout = 30
+	if tp.config.Commands.PollTimeoutSeconds > 0 {
+		updateConfig.Timeout = tp.config.Commands.PollTimeoutSeconds
+	}
+	updateConfig.AllowedUpdates = []string{"message", "callback_query"}
+
+	for {
+		select {
+		case <-tp.stopCh:
+			slog.Info("Telegram command receiver stopped", "provider_id", tp.id)
+			return
+		default:
+		}
+
+		updates, err := poller.GetUpdates(updateConfig)
+
+		// Leave updates unconfirmed if closed meanwhile so a replacement provider receives them
+		select {
+		case <-tp.stopCh:
+			slog.Info("Telegram command receiver stopped", "provider_id", tp.id)
+			return
+		default:
+		}
+
+		if err != nil {
+			slog.Warn("Failed to get Telegram updates", "provider_id", tp.id, "error", err)
+			select {
+			case <-time.After(3 * time.Second):
+			case <-tp.stopCh:
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			if update.UpdateID >= updateConfig.Offset {
+				updateConfig.Offset = update.UpdateID + 1
+			}
+			tp.handleUpdate(handler, update)
+		}
+	}
+}
+
+// handleUpdate dispatches a single update to the command handler
+func (tp *TelegramProvider) handleUpdate(handler CommandHandler, update tgbotapi.Update) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	switch {
+	case update.CallbackQuery != nil:
+		query := update.CallbackQuery
+		if query.Message == nil || !tp.isAllowedChat(query.Message.Chat.ID) {
+			return
+		}
+
+		parts := strings.Split(query.Data, ":")
+		reply := tp.executeCommand(ctx, handler, parts[0], parts[1:], telegramActor(query.From))
+
+		if _, err := tp.bot.Request(tgbotapi.NewCallback(query.ID, reply)); err != nil {
+			slog.Warn("Failed to answer Telegram callback", "provider_id", tp.id, "error", err)
+		}
+		tp.reply(query.Message.Chat.ID, 0, reply)
+
+	case update.Message != nil && update.Message.IsCommand():
+		msg := update.Message
+		if !tp.isAllowedChat(msg.Chat.ID) {
+			slog.Warn("Ignoring Telegram command from unauthorized chat",
+				"provider_id", tp.id,
+				"chat_id", msg.Chat.ID)
+			return
+		}
+
+		reply := tp.executeCommand(ctx, handler, msg.Command(), strings.Fields(msg.CommandArguments()), telegramActor(msg.From))
+		tp.reply(msg.Chat.ID, msg.MessageID, reply)
+	}
+}
+
+// executeCommand runs a command and returns the reply text
+func (tp *TelegramProvider) executeCommand(ctx context.Context, handler CommandHandler, command string, args []string, actor string) string {
+	slog.Info("Telegram command received",
+		"provider_id", tp.id,
+		"command", command,
+		"actor", actor)
+
+	switch command {
+	case "ack":
+		if len(args) < 1 {
+			return "Usage: /ack <notification-id>"
+		}
+		if err := handler.Acknowledge(ctx, args[0], actor); err != nil {
+			return fmt.Sprintf("Failed to acknowledge %s: %v", args[0], err)
+		}
+		return fmt.Sprintf("Notification %s acknowledged by %s", args[0], actor)
+
+	case "mute":
+		if len(args) < 1 {
+			return "Usage: /mute <provider-id> [duration]"
+		}
+		duration := defaultMuteDuration
+		if len(args) > 1 {
+			d, err := parseMuteDuration(args[1])
+			if err != nil {
+				return fmt.Sprintf("Invalid duration %q: %v", args[1], err)
+			}
+			duration = d
+		}
+		if err := handler.Mute(ctx, args[0], duration, actor); err != nil {
+			return fmt.Sprintf("Failed to mute %s: %v", args[0], err)
+		}
+		return fmt.Sprintf("Provider %s muted for %s by %s", args[0], duration, actor)
+
+	case "unmute":
+		if len(args) < 1 {
+			return "Usage: /unmute <provider-id>"
+		}
+		if err := handler.Unmute(ctx, args[0], actor); err != nil {
+			return fmt.Sprintf("Failed to unmute %s: %v", args[0], err)
+		}
+		return fmt.Sprintf("Provider %s unmuted by %s", args[0], actor)
+
+	case "status":
+		status, err := handler.Status(ctx)
+		if err != nil {
+			return fmt.Sprintf("Failed to get status: %v", err)
+		}
+		return status
+
+	default:
+		return telegramCommandsHelp
+	}
+}
+
+// reply sends a plain-text response to a chat
+func (tp *TelegramProvider) reply(chatID int64, replyTo int, text string) {
+	message := tgbotapi.NewMessage(chatID, text)
+	message.ReplyToMessageID = replyTo
+	if _, err := tp.bot.Send(message); err != nil {
+		slog.Warn("Failed to send Telegram command reply", "provider_id", tp.id, "error", err)
+	}
+}
+
+// isAllowedChat reports whether commands from a chat should be executed
+func (tp *TelegramProvider) isAllowedChat(chatID int64) bool {
+	allowed := tp.config.Commands.AllowedChatIDs
+	if len(allowed) == 0 && tp.config.DefaultChatID != "" {
+		allowed = []string{tp.config.DefaultChatID}
+	}
+
+	for _, id := range allowed {
+		if parsed, err := parseChatID(id); err == nil && parsed == chatID {
+			return true
+		}
+	}
+	return false
+}
+
+// commandKeyboard builds the inline buttons attached to outgoing notifications.
+// It returns false if no button fits within the callback data limit.
+func (tp *TelegramProvider) commandKeyboard(notificationID string) (tgbotapi.InlineKeyboardMarkup, bool) {
+	buttons := []tgbotapi.InlineKeyboardButton{}
+
+	if ack := "ack:" + notificationID; len(ack) <= telegramCallbackDataLimit {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("✅ Acknowledge", ack))
+	}
+	if mute := "mute:" + tp.id + ":1h"; len(mute) <= telegramCallbackDataLimit {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("🔕 Mute 1h", mute))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(buttons), len(buttons) > 0
+}
+
+// telegramActor formats the user issuing a command for audit purposes
+func telegramActor(user *tgbotapi.User) string {
+	if user == nil {
+		return "telegram"
+	}
+	if user.UserName != "" {
+		return "@" + user.UserName
+	}
+	return fmt.Sprintf("%s (%d)", strings.TrimSpace(user.FirstName+" "+user.LastName), user.ID)
+}
+
+// parseMuteDuration parses Go durations plus a "d" suffix for days
+func parseMuteDuration(value string) (time.Duration, error) {
+	var (
+		duration time.Duration
+		err      error
+	)
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		duration = time.Duration(n) * 24 * time.Hour
+	} else {
+		duration, err = time.ParseDuration(value)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("expected a duration such as 30m, 4h or 1d")
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("duration must be positive")
+	}
+	return duration, nil
+}
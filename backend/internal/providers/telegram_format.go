@@ -0,0 +1,318 @@
+package providers
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/developertyrone/notimulti/internal/markdown"
+)
+
+// Telegram parse modes supported by the provider
+const (
+	ParseModeHTML       = "HTML"
+	ParseModeMarkdownV2 = "MarkdownV2"
+	ParseModeMarkdown   = "Markdown"
+	ParseModeNone       = "None"
+)
+
+var (
+	htmlTagPattern            = regexp.MustCompile(`</?[a-zA-Z][^<>]*>`)
+	markdownBoldHeaderPattern = regexp.MustCompile(`^\*(.*)\*\n\n`)
+	markdownEscapePattern     = regexp.MustCompile(`\\(.)`)
+)
+
+// telegramMaxMessageLength is the Bot API limit for a single sendMessage text
+const telegramMaxMessageLength = 4096
+
+// telegramMaxChunks bounds how many messages a single notification may be split into
+const telegramMaxChunks = 10
+
+// normalizeParseMode maps the configured parse mode onto a supported Telegram value.
+// An empty value keeps the historical HTML default.
+func normalizeParseMode(mode string) string {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", "html":
+		return ParseModeHTML
+	case "markdownv2":
+		return ParseModeMarkdownV2
+	case "markdown":
+		return ParseModeMarkdown
+	default:
+		return ParseModeNone
+	}
+}
+
+// telegramAPIParseMode returns the value sent as parse_mode (empty for plain text)
+func telegramAPIParseMode(mode string) string {
+	if mode == ParseModeNone {
+		return ""
+	}
+	return mode
+}
+
+// escapeTelegramText escapes user content so Telegram renders it literally in the given parse mode
+func escapeTelegramText(text, mode string) string {
+	switch mode {
+	case ParseModeHTML:
+		// Telegram only understands &lt; &gt; &amp; &quot; and numeric entities
+		return html.EscapeString(text)
+	case ParseModeMarkdownV2:
+		return escapeWithBackslash(text, "_*[]()~`>#+-=|{}.!\\")
+	case ParseModeMarkdown:
+		return escapeWithBackslash(text, "_*`[")
+	default:
+		return text
+	}
+}
+
+func escapeWithBackslash(text, special string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	for _, r := range text {
+		if strings.ContainsRune(special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// formatTelegramSubject renders the subject as a bold header in the given parse mode
+func formatTelegramSubject(subject, mode string) string {
+	if subject == "" {
+		return ""
+	}
+
+	escaped := escapeTelegramText(subject, mode)
+	switch mode {
+	case ParseModeHTML:
+		return "<b>" + escaped + "</b>\n\n"
+	case ParseModeMarkdownV2, ParseModeMarkdown:
+		return "*" + escaped + "*\n\n"
+	default:
+		return escaped + "\n\n"
+	}
+}
+
+// telegramMessages returns the parse mode and chunks a notification is sent with. Markdown
+// notifications are converted to Telegram HTML whatever parse mode is configured.
+// Escaping can make a message that passed validation longer, so the chunks are capped
+// at telegramMaxChunks.
+func telegramMessages(notification *Notification, mode string) (string, []string) {
+	if notification.Format == FormatMarkdown {
+		header := formatTelegramSubject(notification.Subject, ParseModeHTML)
+		return ParseModeHTML, capTelegramChunks(chunkTelegramBody(header, markdown.TelegramHTML(notification.Message), ParseModeHTML), ParseModeHTML)
+	}
+	return mode, capTelegramChunks(telegramChunks(notification, mode), mode)
+}
+
+// telegramTruncatedNotice ends the last chunk of a message cut at telegramMaxChunks
+const telegramTruncatedNotice = "\n… (message truncated)"
+
+// capTelegramChunks keeps the first telegramMaxChunks chunks and marks the last one as truncated
+func capTelegramChunks(chunks []string, mode string) []string {
+	if len(chunks) <= telegramMaxChunks {
+		return chunks
+	}
+
+	notice := escapeTelegramText(telegramTruncatedNotice, mode)
+	chunks = chunks[:telegramMaxChunks]
+	last, _, _ := fitTelegramChunk("", chunks[telegramMaxChunks-1], telegramMaxMessageLength-utf8.RuneCountInString(notice), mode)
+	chunks[telegramMaxChunks-1] = last + notice
+	return chunks
+}
+
+// telegramChunks returns the messages a notification is sent as. Template output
+// is already Telegram HTML and is sent as is.
+func telegramChunks(notification *Notification, mode string) []string {
+	if notification.HTML != "" && mode == ParseModeHTML {
+		return chunkTelegramBody(formatTelegramSubject(notification.Subject, mode), notification.HTML, mode)
+	}
+	return buildTelegramChunks(notification.Subject, notification.Message, mode)
+}
+
+// buildTelegramChunks escapes the notification content for the parse mode and splits
+// it into ordered chunks that each fit within Telegram's message length limit.
+// The subject header is only rendered on the first chunk.
+func buildTelegramChunks(subject, message, mode string) []string {
+	return chunkTelegramBody(formatTelegramSubject(subject, mode), escapeTelegramText(message, mode), mode)
+}
+
+// chunkTelegramBody splits a formatted header and body into chunks that fit Telegram's limit.
+// HTML tags open at the end of a chunk are closed there and reopened at the start of the
+// next, so every chunk is valid markup on its own.
+func chunkTelegramBody(header, body, mode string) []string {
+	headerLen := utf8.RuneCountInString(header)
+	if headerLen+utf8.RuneCountInString(body) <= telegramMaxMessageLength {
+		return []string{header + body}
+	}
+
+	// Keep the header with the first chunk unless it leaves no room for content
+	firstLimit := telegramMaxMessageLength - headerLen
+	if firstLimit < telegramMaxMessageLength/2 {
+		header = ""
+		firstLimit = telegramMaxMessageLength
+	}
+
+	var (
+		chunks []string
+		open   []string // Opening tags of the elements the previous chunk left open
+	)
+	limit := firstLimit
+	for body != "" {
+		chunk, rest, stillOpen := fitTelegramChunk(strings.Join(open, ""), body, limit, mode)
+		chunks = append(chunks, chunk)
+		body = rest
+		open = stillOpen
+		limit = telegramMaxMessageLength
+	}
+
+	if header != "" && len(chunks) > 0 {
+		chunks[0] = header + chunks[0]
+	}
+
+	return chunks
+}
+
+// fitTelegramChunk returns the next chunk of text, starting with reopen, that fits in
+// limit runes, and the rest of text. In HTML mode the tags the chunk leaves open are
+// closed at its end and returned, to be reopened by the next chunk; tags too long to
+// reopen within a chunk are dropped with their formatting.
+func fitTelegramChunk(reopen, text string, limit int, mode string) (string, string, []string) {
+	if utf8.RuneCountInString(reopen) > limit/4 {
+		reopen = ""
+	}
+
+	room := limit - utf8.RuneCountInString(reopen)
+	for {
+		chunk, rest := splitTelegramText(text, room, mode)
+		chunk = reopen + chunk
+		if mode != ParseModeHTML {
+			return chunk, rest, nil
+		}
+
+		// Make room for the closing tags and split again if they do not fit
+		open := openTelegramTags(chunk)
+		chunk += closeTelegramTags(open)
+		over := utf8.RuneCountInString(chunk) - limit
+		if over <= 0 || room <= 1 {
+			return chunk, rest, open
+		}
+		room = max(room-over, 1)
+	}
+}
+
+// openTelegramTags returns the opening tags of the elements text leaves open, outermost first
+func openTelegramTags(text string) []string {
+	var open []string
+	for _, tag := range htmlTagPattern.FindAllString(text, -1) {
+		switch {
+		case strings.HasPrefix(tag, "</"):
+			name := telegramTagName(tag)
+			for i := len(open) - 1; i >= 0; i-- {
+				if telegramTagName(open[i]) == name {
+					open = open[:i]
+					break
+				}
+			}
+		case !strings.HasSuffix(tag, "/>"):
+			open = append(open, tag)
+		}
+	}
+	return open
+}
+
+// closeTelegramTags returns the closing tags for open, innermost first
+func closeTelegramTags(open []string) string {
+	var b strings.Builder
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + telegramTagName(open[i]) + ">")
+	}
+	return b.String()
+}
+
+// telegramTagName returns the lower case element name of an opening or closing tag
+func telegramTagName(tag string) string {
+	name := strings.TrimPrefix(strings.TrimPrefix(tag, "<"), "/")
+	if i := strings.IndexAny(name, " \t\n/>"); i >= 0 {
+		name = name[:i]
+	}
+	return strings.ToLower(name)
+}
+
+// splitTelegramText returns the longest prefix of text that fits in limit runes,
+// preferring line and word boundaries and never cutting an escape sequence in half.
+func splitTelegramText(text string, limit int, mode string) (string, string) {
+	if utf8.RuneCountInString(text) <= limit {
+		return text, ""
+	}
+
+	// Byte offset of the hard cut at limit runes
+	cut := 0
+	for i := 0; i < limit; i++ {
+		_, size := utf8.DecodeRuneInString(text[cut:])
+		cut += size
+	}
+
+	// Prefer a newline, then a space, within the last quarter of the window
+	minCut := cut * 3 / 4
+	if idx := strings.LastIndexByte(text[:cut], '\n'); idx >= minCut && idx > 0 {
+		cut = idx + 1
+	} else if idx := strings.LastIndexByte(text[:cut], ' '); idx >= minCut && idx > 0 {
+		cut = idx + 1
+	}
+
+	cut = avoidSplittingEscape(text, cut, mode)
+
+	return text[:cut], text[cut:]
+}
+
+// avoidSplittingEscape moves cut back so it does not land inside an HTML entity or tag
+// or directly after a dangling Markdown escape character.
+func avoidSplittingEscape(text string, cut int, mode string) int {
+	switch mode {
+	case ParseModeHTML:
+		amp := strings.LastIndexByte(text[:cut], '&')
+		if amp > 0 && !strings.Contains(text[amp:cut], ";") {
+			return amp
+		}
+		// Template markup can contain tags
+		lt := strings.LastIndexByte(text[:cut], '<')
+		if lt > 0 && !strings.Contains(text[lt:cut], ">") {
+			return lt
+		}
+	case ParseModeMarkdownV2, ParseModeMarkdown:
+		backslashes := 0
+		for i := cut - 1; i >= 0 && text[i] == '\\'; i-- {
+			backslashes++
+		}
+		if backslashes%2 == 1 && cut > 1 {
+			return cut - 1
+		}
+	}
+	return cut
+}
+
+// stripTelegramMarkup converts a formatted chunk back to plain text, used when
+// Telegram rejects the markup so the content is still delivered.
+func stripTelegramMarkup(text, mode string) string {
+	switch mode {
+	case ParseModeHTML:
+		return html.UnescapeString(htmlTagPattern.ReplaceAllString(text, ""))
+	case ParseModeMarkdownV2, ParseModeMarkdown:
+		text = markdownBoldHeaderPattern.ReplaceAllString(text, "$1\n\n")
+		return markdownEscapePattern.ReplaceAllString(text, "$1")
+	default:
+		return text
+	}
+}
+
+// isEntityParseError reports whether Telegram rejected the message markup
+func isEntityParseError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "can't parse entities")
+}
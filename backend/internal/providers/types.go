@@ -12,14 +12,37 @@ type Notification struct {
 	Metadata   map[string]interface{} `json:"metadata,omitempty"`
 	Priority   string                 `json:"priority,omitempty"`
 	Timestamp  time.Time              `json:"timestamp"`
+
+	// HTML is Message rendered by a template in the provider's HTML dialect: an HTML
+	// alternative for email, or ready-to-send markup for Telegram in HTML parse mode
+	HTML string `json:"html,omitempty"`
+	// Format is the markup Message is written in: FormatText (the default) or FormatMarkdown
+	Format string `json:"format,omitempty"`
+	// JobID is the recurring job that sent the notification, if any; it is recorded in history
+	JobID string `json:"job_id,omitempty"`
+	// ExpiresAt is when the notification becomes stale; it is not sent after this time
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Expired reports whether the notification has an expiry that has passed at t
+func (n *Notification) Expired(t time.Time) bool {
+	return n.ExpiresAt != nil && !t.Before(*n.ExpiresAt)
 }
 
+// Message formats
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown" // CommonMark, converted by each provider to its own markup
+)
+
 // ProviderConfig represents the configuration for a provider
 type ProviderConfig struct {
 	ID       string          `json:"id"`
-	Type     string          `json:"type"` // "telegram" or "email"
+	Type     string          `json:"type"` // "telegram", "email", "group" or "failover"
 	Telegram *TelegramConfig `json:"telegram,omitempty"`
 	Email    *EmailConfig    `json:"email,omitempty"`
+	Group    *GroupConfig    `json:"group,omitempty"`
+	Failover *FailoverConfig `json:"failover,omitempty"`
 }
 
 // ProviderStatus represents the current status of a provider
@@ -30,6 +53,13 @@ type ProviderStatus struct {
 	ConfigChecksum string     `json:"config_checksum,omitempty"`
 	LastTestAt     *time.Time `json:"last_test_at,omitempty"`     // T049: When provider was last tested
 	LastTestStatus string     `json:"last_test_status,omitempty"` // T049: "success" or "failed"
+
+	// Background health check results
+	LastCheckedAt       *time.Time `json:"last_checked_at,omitempty"`
+	LatencyMs           int64      `json:"latency_ms,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures,omitempty"`
+
+	CircuitState string `json:"circuit_state,omitempty"` // "closed", "open" or "half_open"
 }
 
 // Priority constants for notifications
@@ -51,9 +81,23 @@ const (
 type TelegramConfig struct {
 	BotToken       string `json:"bot_token"`
 	DefaultChatID  string `json:"default_chat_id"`
-	ParseMode      string `json:"parse_mode,omitempty"` // HTML or Markdown
+	ParseMode      string `json:"parse_mode,omitempty"` // HTML (default), MarkdownV2, Markdown or None
 	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
 	APIEndpoint    string `json:"api_endpoint,omitempty"`
+
+	Commands   *TelegramCommandsConfig `json:"commands,omitempty"`
+	Retry      *RetryConfig            `json:"retry,omitempty"`
+	RateLimit  *RateLimitConfig        `json:"rate_limit,omitempty"`
+	Digest     *DigestConfig           `json:"digest,omitempty"`
+	QuietHours *QuietHoursConfig       `json:"quiet_hours,omitempty"`
+	Dedup      *DedupConfig            `json:"dedup,omitempty"`
+}
+
+// TelegramCommandsConfig enables inbound bot commands (/ack, /mute, /unmute, /status)
+type TelegramCommandsConfig struct {
+	Enabled            bool     `json:"enabled"`
+	AllowedChatIDs     []string `json:"allowed_chat_ids,omitempty"`     // Defaults to default_chat_id
+	PollTimeoutSeconds int      `json:"poll_timeout_seconds,omitempty"` // Long-polling timeout (default 30s)
 }
 
 // EmailConfig contains Email-specific configuration
@@ -66,4 +110,89 @@ type EmailConfig struct {
 	UseTLS         bool   `json:"use_tls,omitempty"`
 	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
 	TestRecipient  string `json:"test_recipient,omitempty"` // T050: Email address for test notifications
+
+	Retry      *RetryConfig      `json:"retry,omitempty"`
+	RateLimit  *RateLimitConfig  `json:"rate_limit,omitempty"`
+	Digest     *DigestConfig     `json:"digest,omitempty"`
+	QuietHours *QuietHoursConfig `json:"quiet_hours,omitempty"`
+	Dedup      *DedupConfig      `json:"dedup,omitempty"`
+}
+
+// GroupConfig lists the providers a group send fans out to
+type GroupConfig struct {
+	Members []GroupMember `json:"members"`
+}
+
+// GroupMember is a provider in a group with the recipient used for it
+type GroupMember struct {
+	ProviderID string `json:"provider_id"`
+	Recipient  string `json:"recipient,omitempty"` // Used unless the request names a recipient for this member
+}
+
+// FailoverConfig lists the providers a failover chain tries, in order
+type FailoverConfig struct {
+	Hops []FailoverHop `json:"hops"`
+}
+
+// FailoverHop is one provider in a failover chain
+type FailoverHop struct {
+	ProviderID     string `json:"provider_id"`
+	Recipient      string `json:"recipient,omitempty"`       // Defaults to the request's recipient
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"` // Defaults to the provider's delivery timeout
+}
+
+// RetryConfig overrides the default retry policy of a provider
+type RetryConfig struct {
+	MaxAttempts     int      `json:"max_attempts,omitempty"`
+	InitialDelayMs  int      `json:"initial_delay_ms,omitempty"`
+	MaxDelayMs      int      `json:"max_delay_ms,omitempty"`
+	Multiplier      float64  `json:"multiplier,omitempty"`
+	Jitter          float64  `json:"jitter,omitempty"`           // Fraction of the delay, 0-1
+	RetryableErrors []string `json:"retryable_errors,omitempty"` // Extra error substrings to retry, e.g. "451"
+}
+
+// RateLimitConfig configures outbound token-bucket limits of a provider
+type RateLimitConfig struct {
+	Global       *RateLimitRule `json:"global,omitempty"`        // Shared by every send through the provider
+	PerRecipient *RateLimitRule `json:"per_recipient,omitempty"` // Applied separately to each recipient
+	MaxWaitMs    int            `json:"max_wait_ms,omitempty"`   // Longest a send is delayed before failing (default 30s)
+}
+
+// DigestConfig buffers the notifications for each recipient and delivers them as one
+// summary message when the window ends or MaxItems are waiting
+type DigestConfig struct {
+	WindowSeconds int    `json:"window_seconds"`
+	MaxItems      int    `json:"max_items,omitempty"` // Defaults to DefaultDigestMaxItems
+	Template      string `json:"template,omitempty"`  // Renders the summary; a plain list when empty
+}
+
+// DedupConfig suppresses notifications with the same fingerprint, made of the provider,
+// recipient, subject and MetadataKeys, for WindowSeconds after the first one is sent
+type DedupConfig struct {
+	WindowSeconds int      `json:"window_seconds"`
+	MetadataKeys  []string `json:"metadata_keys,omitempty"`
+	NotifyRepeats bool     `json:"notify_repeats,omitempty"` // Sends "repeated N times" when a window with duplicates ends
+}
+
+// QuietHours is a daily window in which only high priority notifications are delivered;
+// others are held until it ends. Times are HH:MM in Timezone, or in the owner's timezone
+// when Timezone is empty. A window may span midnight.
+type QuietHours struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone,omitempty"` // IANA name, e.g. "Europe/Berlin"
+}
+
+// QuietHoursConfig holds a provider's quiet hours: a window for every recipient, and
+// windows for individual recipients that replace it
+type QuietHoursConfig struct {
+	QuietHours
+	Recipients map[string]*QuietHours `json:"recipients,omitempty"` // Keyed by chat ID or email address
+}
+
+// RateLimitRule allows Limit sends per IntervalMs, with bursts of up to Burst sends
+type RateLimitRule struct {
+	Limit      int `json:"limit"`
+	IntervalMs int `json:"interval_ms"`
+	Burst      int `json:"burst,omitempty"` // Defaults to Limit
 }
@@ -0,0 +1,255 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+)
+
+// FileName is the routing rules file in the config directory
+const FileName = "routing.json"
+
+// providerIDPattern matches valid provider IDs (lowercase letters, numbers and hyphens)
+var providerIDPattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// validPriorities are the notification priorities a rule can match
+var validPriorities = []string{"low", "normal", "high"}
+
+// RuleSet is the content of the routing rules file
+type RuleSet struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule selects the providers of notifications sent without a provider_id.
+// Every condition in Match must hold; a rule without conditions matches everything.
+type Rule struct {
+	Name    string   `json:"name"`
+	Match   Match    `json:"match"`
+	Targets []Target `json:"targets"`
+
+	// Digest buffers the notifications the rule routes and sends each target a summary
+	Digest *providers.DigestConfig `json:"digest,omitempty"`
+
+	subject *regexp.Regexp
+}
+
+// Match lists the conditions of a rule
+type Match struct {
+	Priority []string          `json:"priority,omitempty"` // Any of these priorities ("normal" when unset)
+	Metadata map[string]string `json:"metadata,omitempty"` // Metadata values; "*" only requires the key
+	APIKey   []string          `json:"api_key,omitempty"`  // Any of these X-API-Key header values
+	Subject  string            `json:"subject,omitempty"`  // Regular expression the subject must match
+}
+
+// Target is a provider a matching notification is sent to
+type Target struct {
+	ProviderID string `json:"provider_id"`
+	Recipient  string `json:"recipient,omitempty"` // Defaults to the request's recipient
+}
+
+// Input is the part of a notification rules are matched against
+type Input struct {
+	Priority string
+	Subject  string
+	Metadata map[string]interface{}
+	APIKey   string
+}
+
+// Decision is the outcome of routing a notification
+type Decision struct {
+	Matched     bool                    `json:"matched"`
+	Rule        string                  `json:"rule,omitempty"`
+	Targets     []Target                `json:"targets,omitempty"`
+	Digest      *providers.DigestConfig `json:"digest,omitempty"` // Digest mode of the matched rule
+	Evaluations []RuleEvaluation        `json:"evaluations"`      // Rules in the order they were tried
+}
+
+// RuleEvaluation explains why a rule did or did not match
+type RuleEvaluation struct {
+	Rule    string `json:"rule"`
+	Matched bool   `json:"matched"`
+	Reason  string `json:"reason"`
+}
+
+// Engine holds the current routing rules. Rules are tried in file order and the
+// first match wins. A nil or empty engine matches nothing.
+type Engine struct {
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+// NewEngine creates an engine without rules
+func NewEngine() *Engine {
+	return &Engine{}
+}
+
+// LoadFile replaces the rules with the content of a rules file.
+// Invalid files are rejected and the current rules are kept.
+func (e *Engine) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read routing rules: %w", err)
+	}
+
+	rules, err := Parse(data)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.rules = rules
+	e.mu.Unlock()
+	return nil
+}
+
+// Clear removes all rules
+func (e *Engine) Clear() {
+	e.mu.Lock()
+	e.rules = nil
+	e.mu.Unlock()
+}
+
+// Rules returns the current rules
+func (e *Engine) Rules() []Rule {
+	if e == nil {
+		return nil
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return slices.Clone(e.rules)
+}
+
+// Route returns the first rule matching the input and explains every rule tried
+func (e *Engine) Route(input Input) *Decision {
+	decision := &Decision{Evaluations: []RuleEvaluation{}}
+
+	for _, rule := range e.Rules() {
+		reason, matched := rule.evaluate(input)
+		decision.Evaluations = append(decision.Evaluations, RuleEvaluation{
+			Rule:    rule.Name,
+			Matched: matched,
+			Reason:  reason,
+		})
+		if matched {
+			decision.Matched = true
+			decision.Rule = rule.Name
+			decision.Targets = slices.Clone(rule.Targets)
+			decision.Digest = rule.Digest
+			break
+		}
+	}
+
+	return decision
+}
+
+// Parse reads and validates a rules file
+func Parse(data []byte) ([]Rule, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var set RuleSet
+	if err := decoder.Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to parse routing rules: %w", err)
+	}
+
+	names := make(map[string]bool, len(set.Rules))
+	for i := range set.Rules {
+		rule := &set.Rules[i]
+		field := fmt.Sprintf("rules[%d]", i)
+
+		if rule.Name == "" {
+			return nil, fmt.Errorf("%s.name: name is required", field)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("%s.name: duplicate rule name %q", field, rule.Name)
+		}
+		names[rule.Name] = true
+
+		for _, priority := range rule.Match.Priority {
+			if !slices.Contains(validPriorities, priority) {
+				return nil, fmt.Errorf("%s.match.priority: priority must be one of %s (got %q)", field, strings.Join(validPriorities, ", "), priority)
+			}
+		}
+
+		if rule.Match.Subject != "" {
+			subject, err := regexp.Compile(rule.Match.Subject)
+			if err != nil {
+				return nil, fmt.Errorf("%s.match.subject: invalid regular expression: %w", field, err)
+			}
+			rule.subject = subject
+		}
+
+		if len(rule.Targets) == 0 {
+			return nil, fmt.Errorf("%s.targets: at least one target is required", field)
+		}
+		seen := make(map[string]bool, len(rule.Targets))
+		for j, target := range rule.Targets {
+			if !providerIDPattern.MatchString(target.ProviderID) {
+				return nil, fmt.Errorf("%s.targets[%d].provider_id: provider_id must be a valid provider ID", field, j)
+			}
+			if seen[target.ProviderID] {
+				return nil, fmt.Errorf("%s.targets[%d].provider_id: duplicate target %s", field, j, target.ProviderID)
+			}
+			seen[target.ProviderID] = true
+		}
+
+		if rule.Digest != nil {
+			if name, err := rule.Digest.Validate(); err != nil {
+				return nil, fmt.Errorf("%s.digest.%s: %w", field, name, err)
+			}
+		}
+	}
+
+	return set.Rules, nil
+}
+
+// evaluate checks the rule's conditions and returns why it did or did not match
+func (r *Rule) evaluate(input Input) (string, bool) {
+	if len(r.Match.Priority) > 0 {
+		priority := input.Priority
+		if priority == "" {
+			priority = "normal"
+		}
+		if !slices.Contains(r.Match.Priority, priority) {
+			return fmt.Sprintf("priority %q is not one of [%s]", priority, strings.Join(r.Match.Priority, ", ")), false
+		}
+	}
+
+	// Sorted so the explanation is stable
+	keys := make([]string, 0, len(r.Match.Metadata))
+	for key := range r.Match.Metadata {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		want := r.Match.Metadata[key]
+		value, ok := input.Metadata[key]
+		if !ok {
+			return fmt.Sprintf("metadata.%s is missing", key), false
+		}
+		if got := fmt.Sprint(value); want != "*" && got != want {
+			return fmt.Sprintf("metadata.%s is %q, want %q", key, got, want), false
+		}
+	}
+
+	// Key values are never echoed back
+	if len(r.Match.APIKey) > 0 && !slices.Contains(r.Match.APIKey, input.APIKey) {
+		return "API key does not match", false
+	}
+
+	if r.subject != nil && !r.subject.MatchString(input.Subject) {
+		return fmt.Sprintf("subject does not match /%s/", r.Match.Subject), false
+	}
+
+	if len(r.Match.Priority) == 0 && len(r.Match.Metadata) == 0 && len(r.Match.APIKey) == 0 && r.subject == nil {
+		return "rule has no conditions", true
+	}
+	return "all conditions match", true
+}
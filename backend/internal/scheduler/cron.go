@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/developertyrone/notimulti/internal/storage"
+)
+
+// MaxJitter is the largest random delay a job can add to its runs
+const MaxJitter = time.Hour
+
+// cronParser accepts standard five-field expressions ("0 9 * * MON-FRI") and
+// descriptors such as "@daily", "@hourly" and "@every 15m"
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Schedule is a parsed cron expression evaluated in a timezone
+type Schedule struct {
+	schedule cron.Schedule
+	location *time.Location
+}
+
+// ParseSchedule parses a cron expression evaluated in the named IANA timezone, UTC when empty
+func ParseSchedule(expression, timezone string) (*Schedule, error) {
+	location := time.UTC
+	if timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", timezone)
+		}
+	}
+
+	schedule, err := cronParser.Parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", expression, err)
+	}
+	return &Schedule{schedule: schedule, location: location}, nil
+}
+
+// Next returns the first time after t that the schedule fires, or the zero time if it never does
+func (s *Schedule) Next(t time.Time) time.Time {
+	return s.schedule.Next(t.In(s.location))
+}
+
+// NextRun returns when a job next runs after now: the schedule's next time plus
+// a random delay of up to the job's jitter
+func NextRun(job *storage.Job, now time.Time) (time.Time, error) {
+	schedule, err := ParseSchedule(job.Schedule, job.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := schedule.Next(now)
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("schedule %q never fires", job.Schedule)
+	}
+	if job.JitterSeconds > 0 {
+		next = next.Add(time.Duration(rand.Int64N(int64(job.JitterSeconds) * int64(time.Second))))
+	}
+	return next.UTC(), nil
+}
@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/storage"
+)
+
+// RepeatSender sends the "repeated N times" follow-up of a closed dedup window
+type RepeatSender func(*storage.DedupWindow)
+
+// DedupSweeper closes deduplication windows in the background when they end, sending a
+// follow-up for those that suppressed duplicates and asked for one
+type DedupSweeper struct {
+	repo     *storage.Repository
+	send     RepeatSender
+	interval time.Duration
+	logger   *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDedupSweeper creates a dedup sweeper. A non-positive interval falls back to DefaultInterval.
+func NewDedupSweeper(repo *storage.Repository, send RepeatSender, interval time.Duration, logger *slog.Logger) *DedupSweeper {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &DedupSweeper{
+		repo:     repo,
+		send:     send,
+		interval: interval,
+		logger:   logger,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start begins closing expired windows
+func (s *DedupSweeper) Start() {
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop stops the sweeper and waits for it to exit.
+// Open windows stay in the database and are closed after the next start.
+func (s *DedupSweeper) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// run checks for expired windows until stopped
+func (s *DedupSweeper) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.checkExpired()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkExpired()
+		}
+	}
+}
+
+// checkExpired closes every window that has ended and sends the follow-ups
+func (s *DedupSweeper) checkExpired() {
+	for s.ctx.Err() == nil {
+		closed, err := s.repo.CloseExpiredDedupWindows(time.Now(), batchSize)
+		if err != nil {
+			s.logger.Error("Failed to close dedup windows", "error", err)
+			return
+		}
+
+		for i := range closed {
+			window := &closed[i]
+			if window.Suppressed > 0 && window.NotifyRepeats {
+				s.logger.Info("Sending repeat follow-up",
+					"id", window.ID,
+					"provider_id", window.ProviderID,
+					"suppressed", window.Suppressed,
+				)
+				s.send(window)
+			}
+		}
+
+		if len(closed) < batchSize {
+			return
+		}
+	}
+}
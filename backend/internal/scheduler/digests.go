@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/storage"
+)
+
+// DigestSender sends the summary of a digest once it is due. It calls done once the
+// outcome of the summary is recorded; until then the digest stays in the database and
+// is sent again if the process stops.
+type DigestSender func(digest *storage.Digest, done func())
+
+// DigestFlusher sends digests in the background when their window ends or they fill up
+type DigestFlusher struct {
+	repo     *storage.Repository
+	send     DigestSender
+	interval time.Duration
+	claims   *claims
+	logger   *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDigestFlusher creates a digest flusher. A non-positive interval falls back to DefaultInterval.
+func NewDigestFlusher(repo *storage.Repository, send DigestSender, interval time.Duration, logger *slog.Logger) *DigestFlusher {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &DigestFlusher{
+		repo:     repo,
+		send:     send,
+		interval: interval,
+		claims:   newClaims(leaseFor(ClaimLease, interval)),
+		logger:   logger,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// SetLease sets how long a claim lasts without being renewed; see ClaimLease
+func (f *DigestFlusher) SetLease(lease time.Duration) {
+	f.claims.setLease(leaseFor(lease, f.interval))
+}
+
+// Start begins sending due digests
+func (f *DigestFlusher) Start() {
+	f.wg.Add(1)
+	go f.run()
+}
+
+// Stop stops the flusher and waits for it to exit.
+// Open digests stay in the database and are sent after the next start.
+func (f *DigestFlusher) Stop() {
+	f.cancel()
+	f.wg.Wait()
+}
+
+// run checks for due digests until stopped
+func (f *DigestFlusher) run() {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	f.checkDue()
+	for {
+		select {
+		case <-f.ctx.Done():
+			return
+		case <-ticker.C:
+			f.checkDue()
+		}
+	}
+}
+
+// checkDue claims and sends every digest whose flush time has passed. Claims are
+// renewed while the summary is being sent and the digest is removed once it is done.
+func (f *DigestFlusher) checkDue() {
+	if err := f.claims.renew(time.Now(), f.repo.RenewDigestClaim); err != nil {
+		f.logger.Error("Failed to renew digest claims", "error", err)
+	}
+
+	for f.ctx.Err() == nil {
+		due, err := f.repo.ClaimDueDigests(time.Now(), f.claims.duration(), batchSize)
+		if err != nil {
+			f.logger.Error("Failed to claim digests", "error", err)
+			return
+		}
+
+		for i := range due {
+			digest := &due[i]
+			f.logger.Info("Sending digest",
+				"id", digest.ID,
+				"provider_id", digest.ProviderID,
+				"items", digest.Count,
+			)
+			f.claims.hold(digest.ID, digest.ClaimedAt)
+			f.send(digest, func() {
+				f.claims.release(digest.ID, func() {
+					if err := f.repo.CompleteDigest(digest); err != nil {
+						f.logger.Error("Failed to complete digest", "id", digest.ID, "error", err)
+					}
+				})
+			})
+		}
+
+		if len(due) < batchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/storage"
+)
+
+// JobRun sends one run of a job and returns the ID of the notification it sent
+type JobRun func(*storage.Job) (string, error)
+
+// JobRunner runs recurring jobs in the background when their next run is due.
+// Runs missed while the server was down are made once at startup.
+type JobRunner struct {
+	repo     *storage.Repository
+	run      JobRun
+	interval time.Duration
+	logger   *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewJobRunner creates a job runner. A non-positive interval falls back to DefaultInterval.
+func NewJobRunner(repo *storage.Repository, run JobRun, interval time.Duration, logger *slog.Logger) *JobRunner {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &JobRunner{
+		repo:     repo,
+		run:      run,
+		interval: interval,
+		logger:   logger,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start begins running due jobs
+func (r *JobRunner) Start() {
+	r.wg.Add(1)
+	go r.loop()
+}
+
+// Stop stops the runner and waits for it to exit
+func (r *JobRunner) Stop() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+// loop checks for due jobs until stopped
+func (r *JobRunner) loop() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	r.checkDue()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			r.checkDue()
+		}
+	}
+}
+
+// checkDue runs every job whose next run has passed and schedules its following run
+func (r *JobRunner) checkDue() {
+	now := time.Now()
+	jobs, err := r.repo.DueJobs(now)
+	if err != nil {
+		r.logger.Error("Failed to list due jobs", "error", err)
+		return
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+
+		// A schedule that cannot produce another run leaves the job without one
+		var next *time.Time
+		if t, err := NextRun(job, now); err != nil {
+			r.logger.Error("Failed to schedule next job run", "job_id", job.ID, "error", err)
+		} else {
+			next = &t
+		}
+
+		claimed, err := r.repo.ClaimJobRun(job, now, next)
+		if err != nil {
+			r.logger.Error("Failed to claim job run", "job_id", job.ID, "error", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		notificationID, runErr := r.run(job)
+		errorMessage := ""
+		if runErr != nil {
+			errorMessage = runErr.Error()
+			r.logger.Warn("Job run failed", "job_id", job.ID, "error", runErr)
+		} else {
+			r.logger.Info("Job run", "job_id", job.ID, "notification_id", notificationID, "next_run_at", next)
+		}
+		if err := r.repo.RecordJobRun(job.ID, notificationID, errorMessage); err != nil {
+			r.logger.Error("Failed to record job run", "job_id", job.ID, "error", err)
+		}
+	}
+}
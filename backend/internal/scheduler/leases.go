@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ClaimLease is how long a claim lasts without being renewed. Claims are renewed while
+// their delivery is in flight, however long it takes, so the lease only decides how
+// soon work claimed before a crash is claimed again.
+const ClaimLease = 2 * time.Minute
+
+// claims tracks the claims of deliveries in flight so their leases can be renewed until
+// the deliveries are done
+type claims struct {
+	mu    sync.Mutex
+	lease time.Duration
+	held  map[string]*time.Time // Claim time by ID; renewing updates the claimed row's time
+}
+
+func newClaims(lease time.Duration) *claims {
+	return &claims{lease: lease, held: make(map[string]*time.Time)}
+}
+
+// setLease changes the lease of claims made from now on
+func (c *claims) setLease(lease time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lease = lease
+}
+
+// duration returns the lease
+func (c *claims) duration() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lease
+}
+
+// hold starts renewing a claim. claimedAt is updated in place when it is renewed.
+func (c *claims) hold(id string, claimedAt *time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.held[id] = claimedAt
+}
+
+// release stops renewing a claim and calls complete, which may read the claim time
+func (c *claims) release(id string, complete func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.held, id)
+	complete()
+}
+
+// renew extends the claims that are past half their lease. renew updates one claim in
+// the database and reports whether it is still held; a claim taken over after its lease
+// expired, e.g. while the process was suspended, is no longer renewed.
+func (c *claims) renew(now time.Time, renew func(id string, claimedAt, now time.Time) (bool, error)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs error
+	for id, claimedAt := range c.held {
+		if now.Sub(*claimedAt) < c.lease/2 {
+			continue
+		}
+		held, err := renew(id, *claimedAt, now)
+		switch {
+		case err != nil:
+			errs = errors.Join(errs, err)
+		case !held:
+			delete(c.held, id)
+		default:
+			*claimedAt = now
+		}
+	}
+	return errs
+}
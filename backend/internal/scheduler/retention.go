@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/storage"
+)
+
+// Default retention settings
+const (
+	DefaultRetentionDays     = 90
+	DefaultRetentionInterval = time.Hour
+)
+
+// Retention removes notification history older than the retention period and expired
+// idempotency keys in the background
+type Retention struct {
+	repo     *storage.Repository
+	days     int
+	interval time.Duration
+	logger   *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRetention creates a retention job keeping days of history. Non-positive values fall
+// back to DefaultRetentionDays and DefaultRetentionInterval.
+func NewRetention(repo *storage.Repository, days int, interval time.Duration, logger *slog.Logger) *Retention {
+	if days <= 0 {
+		days = DefaultRetentionDays
+	}
+	if interval <= 0 {
+		interval = DefaultRetentionInterval
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Retention{
+		repo:     repo,
+		days:     days,
+		interval: interval,
+		logger:   logger,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start cleans up now and then every interval
+func (r *Retention) Start() {
+	r.wg.Add(1)
+	go r.run()
+}
+
+// Stop stops the job and waits for it to exit
+func (r *Retention) Stop() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+// run cleans up until stopped
+func (r *Retention) run() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	r.cleanup()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			r.cleanup()
+		}
+	}
+}
+
+// cleanup removes expired history and idempotency keys
+func (r *Retention) cleanup() {
+	if err := r.repo.CleanupOldLogs(r.days); err != nil {
+		r.logger.Error("Failed to clean up notification history", "retention_days", r.days, "error", err)
+	}
+}
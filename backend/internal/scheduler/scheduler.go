@@ -0,0 +1,146 @@
+// Package scheduler dispatches notifications held in the database until their send time,
+// runs recurring jobs on cron schedules and sends digests when their window ends. All are
+// stored in SQLite, so they survive restarts; anything that became due while the server
+// was down runs on the first check after startup. Deduplication windows are closed when
+// they end, and a retention job removes old history.
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/storage"
+)
+
+// Default scheduler settings
+const (
+	DefaultInterval = time.Second
+
+	// batchSize is the number of due notifications claimed per query
+	batchSize = 100
+)
+
+// Dispatcher delivers a scheduled notification once it is due. It calls done once the
+// outcome of every delivery it started is recorded; until then the notification stays
+// in the database and is dispatched again if the process stops.
+type Dispatcher func(scheduled *storage.ScheduledNotification, done func())
+
+// Scheduler polls for due notifications in the background and hands them to a Dispatcher
+type Scheduler struct {
+	repo     *storage.Repository
+	dispatch Dispatcher
+	interval time.Duration
+	claims   *claims
+	logger   *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a scheduler. A non-positive interval falls back to DefaultInterval.
+func New(repo *storage.Repository, dispatch Dispatcher, interval time.Duration, logger *slog.Logger) *Scheduler {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Scheduler{
+		repo:     repo,
+		dispatch: dispatch,
+		interval: interval,
+		claims:   newClaims(leaseFor(ClaimLease, interval)),
+		logger:   logger,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// SetLease sets how long a claim lasts without being renewed; see ClaimLease. It is
+// raised to several check intervals so claims in flight are renewed in time.
+func (s *Scheduler) SetLease(lease time.Duration) {
+	s.claims.setLease(leaseFor(lease, s.interval))
+}
+
+// leaseFor returns a lease that spans enough check intervals to be renewed before it expires
+func leaseFor(lease, interval time.Duration) time.Duration {
+	if lease < 4*interval {
+		return 4 * interval
+	}
+	return lease
+}
+
+// Start begins dispatching due notifications
+func (s *Scheduler) Start() {
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop stops polling and waits for the scheduler to exit.
+// Notifications that are not yet due, or not yet done, stay in the database for the next start.
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// run checks for due notifications until stopped
+func (s *Scheduler) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.checkDue()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkDue()
+		}
+	}
+}
+
+// checkDue claims and dispatches every notification whose send time has passed.
+// Claims are leases, renewed while their dispatch is in flight, so a notification is
+// removed only when its dispatch is done and one whose dispatch was interrupted by a
+// crash is claimed again once its lease expires.
+func (s *Scheduler) checkDue() {
+	if err := s.claims.renew(time.Now(), s.repo.RenewScheduledClaim); err != nil {
+		s.logger.Error("Failed to renew scheduled notification claims", "error", err)
+	}
+
+	for s.ctx.Err() == nil {
+		due, err := s.repo.ClaimDueNotifications(time.Now(), s.claims.duration(), batchSize)
+		if err != nil {
+			s.logger.Error("Failed to claim scheduled notifications", "error", err)
+		}
+
+		for i := range due {
+			scheduled := &due[i]
+			s.logger.Info("Dispatching scheduled notification",
+				"id", scheduled.ID,
+				"provider_id", scheduled.ProviderID,
+				"send_at", scheduled.SendAt,
+			)
+			s.claims.hold(scheduled.ID, scheduled.ClaimedAt)
+			s.dispatch(scheduled, func() {
+				s.claims.release(scheduled.ID, func() {
+					if err := s.repo.CompleteScheduledNotification(scheduled); err != nil {
+						s.logger.Error("Failed to complete scheduled notification", "id", scheduled.ID, "error", err)
+					}
+				})
+			})
+		}
+
+		if err != nil || len(due) < batchSize {
+			return
+		}
+	}
+}
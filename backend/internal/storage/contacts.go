@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+)
+
+var (
+	// ErrContactNotFound is returned when a contact does not exist
+	ErrContactNotFound = errors.New("contact not found")
+	// ErrContactExists is returned when creating a contact that already exists
+	ErrContactExists = errors.New("contact already exists")
+)
+
+// Contact is a person in the contact directory
+type Contact struct {
+	ID                string            `json:"id"`
+	Name              string            `json:"name,omitempty"`
+	Identities        []ContactIdentity `json:"identities"`                   // In order of preference after PreferredProvider
+	PreferredProvider string            `json:"preferred_provider,omitempty"` // Provider tried first
+	Timezone          string            `json:"timezone,omitempty"`           // IANA name, e.g. "Europe/Berlin"; UTC when empty
+	Locale            string            `json:"locale,omitempty"`             // BCP 47 tag selecting template variants, e.g. "zh-TW"
+	QuietHours        *QuietHours       `json:"quiet_hours,omitempty"`
+	CreatedAt         string            `json:"created_at,omitempty"`
+	UpdatedAt         string            `json:"updated_at,omitempty"`
+}
+
+// ContactIdentity is a contact's address on one provider
+type ContactIdentity struct {
+	ProviderID string `json:"provider_id"`
+	Address    string `json:"address"` // Email address, Telegram chat ID, phone number, ...
+}
+
+// QuietHours is a daily window in which only high priority notifications are delivered.
+// A contact's window is in the contact's timezone unless it names its own.
+type QuietHours = providers.QuietHours
+
+// Location returns the contact's timezone, defaulting to UTC
+func (c *Contact) Location() *time.Location {
+	if c.Timezone != "" {
+		if loc, err := time.LoadLocation(c.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// Identity returns the contact's address on a provider
+func (c *Contact) Identity(providerID string) (string, bool) {
+	for _, identity := range c.Identities {
+		if identity.ProviderID == providerID {
+			return identity.Address, true
+		}
+	}
+	return "", false
+}
+
+const contactSelect = `SELECT id, name, preferred_provider, timezone, locale, quiet_hours_start, quiet_hours_end,
+	quiet_hours_timezone, created_at, updated_at FROM contacts`
+
+func scanContact(row rowScanner) (Contact, error) {
+	var (
+		contact              Contact
+		start, end, timezone string
+	)
+	err := row.Scan(&contact.ID, &contact.Name, &contact.PreferredProvider, &contact.Timezone,
+		&contact.Locale, &start, &end, &timezone, &contact.CreatedAt, &contact.UpdatedAt)
+	if start != "" && end != "" {
+		contact.QuietHours = &QuietHours{Start: start, End: end, Timezone: timezone}
+	}
+	return contact, err
+}
+
+// ListContacts returns all contacts ordered by ID
+func (r *Repository) ListContacts() ([]Contact, error) {
+	rows, err := r.db.Query(contactSelect + ` ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list contacts: %w", err)
+	}
+
+	contacts := []Contact{}
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to scan contact: %w", err)
+		}
+		contacts = append(contacts, contact)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	for i := range contacts {
+		if contacts[i].Identities, err = r.contactIdentities(contacts[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return contacts, nil
+}
+
+// GetContact returns a contact with its identities
+func (r *Repository) GetContact(id string) (*Contact, error) {
+	contact, err := scanContact(r.db.QueryRow(contactSelect+` WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrContactNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contact: %w", err)
+	}
+
+	if contact.Identities, err = r.contactIdentities(id); err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
+
+// FindContactByIdentity returns the contact with an address on a provider, so
+// deliveries addressed directly, such as fan-out members, follow the contact's settings.
+// When several contacts share the address the one with the lowest ID is returned.
+func (r *Repository) FindContactByIdentity(providerID, address string) (*Contact, error) {
+	var id string
+	err := r.db.QueryRow(`SELECT contact_id FROM contact_identities
+		WHERE provider_id = ? AND address = ? ORDER BY contact_id LIMIT 1`, providerID, address).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrContactNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find contact: %w", err)
+	}
+	return r.GetContact(id)
+}
+
+// CreateContact adds a contact to the directory
+func (r *Repository) CreateContact(contact *Contact) (*Contact, error) {
+	return r.saveContact(contact, false)
+}
+
+// UpdateContact replaces a contact, including all of its identities
+func (r *Repository) UpdateContact(contact *Contact) (*Contact, error) {
+	return r.saveContact(contact, true)
+}
+
+// DeleteContact removes a contact and its identities
+func (r *Repository) DeleteContact(id string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM contact_identities WHERE contact_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete identities: %w", err)
+	}
+	result, err := tx.Exec(`DELETE FROM contacts WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete contact: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrContactNotFound
+	}
+	return tx.Commit()
+}
+
+func (r *Repository) saveContact(contact *Contact, update bool) (*Contact, error) {
+	var start, end, timezone string
+	if contact.QuietHours != nil {
+		start, end, timezone = contact.QuietHours.Start, contact.QuietHours.End, contact.QuietHours.Timezone
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var result sql.Result
+	if update {
+		result, err = tx.Exec(`UPDATE contacts SET name = ?, preferred_provider = ?, timezone = ?, locale = ?,
+			quiet_hours_start = ?, quiet_hours_end = ?, quiet_hours_timezone = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+			contact.Name, contact.PreferredProvider, contact.Timezone, contact.Locale, start, end, timezone, contact.ID)
+	} else {
+		result, err = tx.Exec(`INSERT INTO contacts (id, name, preferred_provider, timezone, locale, quiet_hours_start, quiet_hours_end,
+			quiet_hours_timezone) VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(id) DO NOTHING`,
+			contact.ID, contact.Name, contact.PreferredProvider, contact.Timezone, contact.Locale, start, end, timezone)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save contact: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		if update {
+			return nil, ErrContactNotFound
+		}
+		return nil, ErrContactExists
+	}
+
+	if _, err := tx.Exec(`DELETE FROM contact_identities WHERE contact_id = ?`, contact.ID); err != nil {
+		return nil, fmt.Errorf("failed to replace identities: %w", err)
+	}
+	for i, identity := range contact.Identities {
+		if _, err := tx.Exec(`INSERT INTO contact_identities (contact_id, provider_id, address, position) VALUES (?, ?, ?, ?)`,
+			contact.ID, identity.ProviderID, identity.Address, i); err != nil {
+			return nil, fmt.Errorf("failed to save identity %s: %w", identity.ProviderID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit contact: %w", err)
+	}
+	return r.GetContact(contact.ID)
+}
+
+func (r *Repository) contactIdentities(contactID string) ([]ContactIdentity, error) {
+	rows, err := r.db.Query(`SELECT provider_id, address FROM contact_identities
+		WHERE contact_id = ? ORDER BY position`, contactID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+	defer rows.Close()
+
+	identities := []ContactIdentity{}
+	for rows.Next() {
+		var identity ContactIdentity
+		if err := rows.Scan(&identity.ProviderID, &identity.Address); err != nil {
+			return nil, fmt.Errorf("failed to scan identity: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+)
+
+// DedupWindow suppresses notifications with the same fingerprint as the first one,
+// which was sent, until ExpiresAt. ID is the first notification's ID.
+type DedupWindow struct {
+	ID            string                  `json:"id"`
+	Fingerprint   string                  `json:"fingerprint"`
+	ProviderID    string                  `json:"provider_id"`
+	ProviderType  string                  `json:"provider_type"`
+	Recipient     string                  `json:"recipient"`
+	Suppressed    int                     `json:"suppressed"`     // Duplicates suppressed so far
+	NotifyRepeats bool                    `json:"notify_repeats"` // Send a follow-up when the window ends
+	ExpiresAt     time.Time               `json:"expires_at"`
+	Notification  *providers.Notification `json:"notification"` // The first notification
+	CreatedAt     string                  `json:"created_at,omitempty"`
+}
+
+const dedupColumns = `id, fingerprint, provider_id, provider_type, recipient, suppressed, notify_repeats,
+	expires_at, payload, created_at`
+
+func scanDedupWindow(row rowScanner) (DedupWindow, error) {
+	var (
+		window             DedupWindow
+		expiresAt, payload string
+	)
+	if err := row.Scan(&window.ID, &window.Fingerprint, &window.ProviderID, &window.ProviderType, &window.Recipient,
+		&window.Suppressed, &window.NotifyRepeats, &expiresAt, &payload, &window.CreatedAt); err != nil {
+		return window, err
+	}
+
+	var err error
+	if window.ExpiresAt, err = time.Parse(timeLayout, expiresAt); err != nil {
+		return window, fmt.Errorf("invalid expires_at %q: %w", expiresAt, err)
+	}
+	if err := json.Unmarshal([]byte(payload), &window.Notification); err != nil {
+		return window, fmt.Errorf("invalid payload: %w", err)
+	}
+	return window, nil
+}
+
+// TrackDuplicate looks for an open window with the fingerprint of open. When there is
+// one, the notification is a duplicate: the window's count of suppressed notifications is
+// increased and it is returned with true. Otherwise open is stored as a new window and
+// returned with false, and the notification should be sent.
+func (r *Repository) TrackDuplicate(open *DedupWindow) (*DedupWindow, bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	window, err := scanDedupWindow(tx.QueryRow(`SELECT `+dedupColumns+` FROM dedup_windows
+		WHERE fingerprint = ? AND expires_at > ? ORDER BY expires_at DESC LIMIT 1`,
+		open.Fingerprint, time.Now().UTC().Format(timeLayout)))
+	switch {
+	case err == nil:
+		if _, err := tx.Exec(`UPDATE dedup_windows SET suppressed = suppressed + 1 WHERE id = ?`, window.ID); err != nil {
+			return nil, false, fmt.Errorf("failed to count duplicate: %w", err)
+		}
+		window.Suppressed++
+		if err := tx.Commit(); err != nil {
+			return nil, false, fmt.Errorf("failed to commit duplicate: %w", err)
+		}
+		return &window, true, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, false, fmt.Errorf("failed to get dedup window: %w", err)
+	}
+
+	payload, err := json.Marshal(open.Notification)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode notification: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO dedup_windows (id, fingerprint, provider_id, provider_type, recipient, notify_repeats, expires_at, payload)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		open.ID, open.Fingerprint, open.ProviderID, open.ProviderType, open.Recipient, open.NotifyRepeats,
+		open.ExpiresAt.UTC().Format(timeLayout), string(payload)); err != nil {
+		return nil, false, fmt.Errorf("failed to create dedup window: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit dedup window: %w", err)
+	}
+	return open, false, nil
+}
+
+// ListDedupWindows returns the deduplication windows that have not been closed, soonest to end first
+func (r *Repository) ListDedupWindows() ([]DedupWindow, error) {
+	rows, err := r.db.Query(`SELECT ` + dedupColumns + ` FROM dedup_windows ORDER BY expires_at, created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dedup windows: %w", err)
+	}
+	defer rows.Close()
+
+	windows := []DedupWindow{}
+	for rows.Next() {
+		window, err := scanDedupWindow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dedup window: %w", err)
+		}
+		windows = append(windows, window)
+	}
+	return windows, rows.Err()
+}
+
+// DeleteDedupWindow removes a window without a follow-up, e.g. because its first
+// notification was not delivered
+func (r *Repository) DeleteDedupWindow(id string) error {
+	if _, err := r.db.Exec(`DELETE FROM dedup_windows WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete dedup window: %w", err)
+	}
+	return nil
+}
+
+// CloseExpiredDedupWindows removes and returns up to limit windows that ended at or
+// before now. Each window is closed once.
+func (r *Repository) CloseExpiredDedupWindows(now time.Time, limit int) ([]DedupWindow, error) {
+	rows, err := r.db.Query(`DELETE FROM dedup_windows WHERE id IN (
+			SELECT id FROM dedup_windows WHERE expires_at <= ? ORDER BY expires_at LIMIT ?
+		) RETURNING `+dedupColumns,
+		now.UTC().Format(timeLayout), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to close dedup windows: %w", err)
+	}
+	defer rows.Close()
+
+	var closed []DedupWindow
+	for rows.Next() {
+		window, err := scanDedupWindow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dedup window: %w", err)
+		}
+		closed = append(closed, window)
+	}
+	return closed, rows.Err()
+}
@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+)
+
+// Digest collects the notifications for one provider and recipient until FlushAt,
+// when they are sent as a single summary. ID becomes the summary's notification ID.
+type Digest struct {
+	ID           string                    `json:"id"`
+	ProviderID   string                    `json:"provider_id"`
+	ProviderType string                    `json:"provider_type"`
+	Recipient    string                    `json:"recipient"`
+	Template     string                    `json:"template,omitempty"` // Renders the summary; empty for the default list
+	FlushAt      time.Time                 `json:"flush_at"`
+	Items        []*providers.Notification `json:"items,omitempty"` // Set when the digest is claimed
+	Count        int                       `json:"count"`           // Number of notifications held
+	CreatedAt    string                    `json:"created_at,omitempty"`
+	ClaimedAt    *time.Time                `json:"claimed_at,omitempty"` // Set while the summary is being sent
+}
+
+const digestColumns = `id, provider_id, provider_type, recipient, template, flush_at, created_at`
+
+func scanDigest(row rowScanner) (Digest, error) {
+	var (
+		digest  Digest
+		flushAt string
+	)
+	if err := row.Scan(&digest.ID, &digest.ProviderID, &digest.ProviderType, &digest.Recipient, &digest.Template, &flushAt, &digest.CreatedAt); err != nil {
+		return digest, err
+	}
+
+	var err error
+	if digest.FlushAt, err = time.Parse(timeLayout, flushAt); err != nil {
+		return digest, fmt.Errorf("invalid flush_at %q: %w", flushAt, err)
+	}
+	return digest, nil
+}
+
+// AddToDigest adds a notification to the open digest for the provider and recipient of
+// open, creating it from open when there is none. A digest holding maxItems or more
+// notifications is due immediately. It returns the digest the notification was added to.
+func (r *Repository) AddToDigest(open *Digest, notification *providers.Notification, maxItems int) (*Digest, error) {
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode digest item: %w", err)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`INSERT INTO digests (id, provider_id, provider_type, recipient, template, flush_at)
+		VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (provider_id, recipient) DO NOTHING`,
+		open.ID, open.ProviderID, open.ProviderType, open.Recipient, open.Template, open.FlushAt.UTC().Format(timeLayout)); err != nil {
+		return nil, fmt.Errorf("failed to create digest: %w", err)
+	}
+
+	digest, err := scanDigest(tx.QueryRow(`SELECT `+digestColumns+` FROM digests WHERE provider_id = ? AND recipient = ?`,
+		open.ProviderID, open.Recipient))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get digest: %w", err)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO digest_items (notification_id, digest_id, payload) VALUES (?, ?, ?)`,
+		notification.ID, digest.ID, string(payload)); err != nil {
+		return nil, fmt.Errorf("failed to add digest item: %w", err)
+	}
+
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM digest_items WHERE digest_id = ?`, digest.ID).Scan(&digest.Count); err != nil {
+		return nil, fmt.Errorf("failed to count digest items: %w", err)
+	}
+
+	if maxItems > 0 && digest.Count >= maxItems {
+		now := time.Now().UTC()
+		if now.Before(digest.FlushAt) {
+			if _, err := tx.Exec(`UPDATE digests SET flush_at = ? WHERE id = ?`, now.Format(timeLayout), digest.ID); err != nil {
+				return nil, fmt.Errorf("failed to update digest: %w", err)
+			}
+			digest.FlushAt = now
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit digest: %w", err)
+	}
+	return &digest, nil
+}
+
+// ListDigests returns the open digests with the number of notifications each holds, soonest first
+func (r *Repository) ListDigests() ([]Digest, error) {
+	rows, err := r.db.Query(`SELECT ` + digestColumns + `,
+		(SELECT COUNT(*) FROM digest_items WHERE digest_id = digests.id)
+		FROM digests ORDER BY flush_at, created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list digests: %w", err)
+	}
+	defer rows.Close()
+
+	digests := []Digest{}
+	for rows.Next() {
+		var (
+			digest  Digest
+			flushAt string
+		)
+		if err := rows.Scan(&digest.ID, &digest.ProviderID, &digest.ProviderType, &digest.Recipient, &digest.Template, &flushAt, &digest.CreatedAt, &digest.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan digest: %w", err)
+		}
+		if digest.FlushAt, err = time.Parse(timeLayout, flushAt); err != nil {
+			return nil, fmt.Errorf("invalid flush_at %q: %w", flushAt, err)
+		}
+		digests = append(digests, digest)
+	}
+	return digests, rows.Err()
+}
+
+// ClaimDueDigests claims and returns up to limit digests whose flush time is at or
+// before now, with their notifications in the order they were added. Claiming moves a
+// digest to claimed_digests, so notifications added afterwards start a new digest. A
+// claim is a lease: the digest stays stored until CompleteDigest, and one whose claim is
+// older than lease, e.g. because the process stopped while sending it, is claimed again.
+func (r *Repository) ClaimDueDigests(now time.Time, lease time.Duration, limit int) ([]Digest, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	claimedAt := now.UTC().Format(timeLayout)
+	rows, err := tx.Query(`UPDATE claimed_digests SET claimed_at = ? WHERE id IN (
+			SELECT id FROM claimed_digests WHERE claimed_at <= ? ORDER BY claimed_at LIMIT ?
+		) RETURNING `+digestColumns,
+		claimedAt, now.Add(-lease).UTC().Format(timeLayout), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim digests: %w", err)
+	}
+	due, err := scanDigests(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if remaining := limit - len(due); remaining > 0 {
+		rows, err := tx.Query(`DELETE FROM digests WHERE id IN (
+				SELECT id FROM digests WHERE flush_at <= ? ORDER BY flush_at LIMIT ?
+			) RETURNING `+digestColumns,
+			claimedAt, remaining)
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim digests: %w", err)
+		}
+		open, err := scanDigests(rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, digest := range open {
+			if _, err := tx.Exec(`INSERT INTO claimed_digests (id, provider_id, provider_type, recipient, template, flush_at, created_at, claimed_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				digest.ID, digest.ProviderID, digest.ProviderType, digest.Recipient, digest.Template,
+				digest.FlushAt.UTC().Format(timeLayout), digest.CreatedAt, claimedAt); err != nil {
+				return nil, fmt.Errorf("failed to claim digest %s: %w", digest.ID, err)
+			}
+		}
+		due = append(due, open...)
+	}
+
+	claimTime, _ := time.Parse(timeLayout, claimedAt)
+	for i := range due {
+		if due[i].Items, err = digestItems(tx, due[i].ID); err != nil {
+			return nil, err
+		}
+		due[i].Count = len(due[i].Items)
+		due[i].ClaimedAt = &claimTime
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit digest claim: %w", err)
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].FlushAt.Before(due[j].FlushAt) })
+	return due, nil
+}
+
+// RenewDigestClaim extends the lease of a claimed digest to now. It reports false when
+// the digest is no longer held under claimedAt.
+func (r *Repository) RenewDigestClaim(id string, claimedAt, now time.Time) (bool, error) {
+	result, err := r.db.Exec(`UPDATE claimed_digests SET claimed_at = ? WHERE id = ? AND claimed_at = ?`,
+		now.UTC().Format(timeLayout), id, claimedAt.UTC().Format(timeLayout))
+	if err != nil {
+		return false, fmt.Errorf("failed to renew claim of digest %s: %w", id, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to renew claim of digest %s: %w", id, err)
+	}
+	return n > 0, nil
+}
+
+// CompleteDigest removes a claimed digest and its notifications once the outcome of its
+// summary is recorded. A digest claimed again after its lease expired is left in place.
+func (r *Repository) CompleteDigest(digest *Digest) error {
+	if digest.ClaimedAt == nil {
+		return fmt.Errorf("digest %s is not claimed", digest.ID)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.Exec(`DELETE FROM claimed_digests WHERE id = ? AND claimed_at = ?`,
+		digest.ID, digest.ClaimedAt.UTC().Format(timeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to complete digest %s: %w", digest.ID, err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM digest_items WHERE digest_id = ?`, digest.ID); err != nil {
+		return fmt.Errorf("failed to remove items of digest %s: %w", digest.ID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit digest completion: %w", err)
+	}
+	return nil
+}
+
+// scanDigests reads and closes rows of digestColumns
+func scanDigests(rows *sql.Rows) ([]Digest, error) {
+	defer rows.Close()
+
+	var digests []Digest
+	for rows.Next() {
+		digest, err := scanDigest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan digest: %w", err)
+		}
+		digests = append(digests, digest)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim digests: %w", err)
+	}
+	return digests, nil
+}
+
+// digestItems returns the notifications of a digest in the order they were added
+func digestItems(tx *sql.Tx, digestID string) ([]*providers.Notification, error) {
+	rows, err := tx.Query(`SELECT payload FROM digest_items WHERE digest_id = ? ORDER BY rowid`, digestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read digest items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []*providers.Notification
+	for rows.Next() {
+		var payload string
+		if err := rows.Scan(&payload); err != nil {
+			return nil, fmt.Errorf("failed to scan digest item: %w", err)
+		}
+		var notification providers.Notification
+		if err := json.Unmarshal([]byte(payload), &notification); err != nil {
+			return nil, fmt.Errorf("invalid digest item: %w", err)
+		}
+		items = append(items, &notification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read digest items: %w", err)
+	}
+	return items, nil
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// IdempotencyKey records a request sent with an idempotency key and its response, so a
+// retry with the same key gets the original response instead of sending again. Keys are
+// scoped by the caller's API key and forgotten after ExpiresAt.
+type IdempotencyKey struct {
+	Scope       string    `json:"scope"` // SHA-256 of the caller's API key; empty without one
+	Key         string    `json:"key"`
+	RequestHash string    `json:"request_hash"`
+	StatusCode  int       `json:"status_code"` // 0 while the original request is in progress
+	Response    []byte    `json:"response,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// ReserveIdempotencyKey claims a key for a request until expiresAt. It returns nil when
+// the key was free or had expired, and the caller should handle the request and record
+// its response; otherwise it returns the existing key. expiresAt is a short lease: a
+// reservation whose request never completed, e.g. because the process stopped, can be
+// taken over once it passes. CompleteIdempotencyKey extends it to the key's full TTL.
+// token identifies the reservation, so a request whose reservation was taken over
+// cannot complete or release the new one.
+func (r *Repository) ReserveIdempotencyKey(scope, key, requestHash, token string, expiresAt time.Time) (*IdempotencyKey, error) {
+	now := time.Now().UTC().Format(timeLayout)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.Exec(`INSERT INTO idempotency_keys (scope, key, request_hash, token, expires_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (scope, key) DO UPDATE SET request_hash = excluded.request_hash, token = excluded.token,
+			status_code = 0, response = '', expires_at = excluded.expires_at, created_at = CURRENT_TIMESTAMP
+		WHERE idempotency_keys.expires_at <= ?`,
+		scope, key, requestHash, token, expiresAt.UTC().Format(timeLayout), now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 1 {
+		return nil, tx.Commit()
+	}
+
+	existing := IdempotencyKey{Scope: scope, Key: key}
+	var response, expires string
+	err = tx.QueryRow(`SELECT request_hash, status_code, response, expires_at FROM idempotency_keys WHERE scope = ? AND key = ?`,
+		scope, key).Scan(&existing.RequestHash, &existing.StatusCode, &response, &expires)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	existing.Response = []byte(response)
+	if existing.ExpiresAt, err = time.Parse(timeLayout, expires); err != nil {
+		return nil, fmt.Errorf("invalid expires_at %q: %w", expires, err)
+	}
+	return &existing, tx.Commit()
+}
+
+// CompleteIdempotencyKey records the response to the request that reserved a key with
+// token and keeps the key until expiresAt
+func (r *Repository) CompleteIdempotencyKey(scope, key, token string, statusCode int, response []byte, expiresAt time.Time) error {
+	result, err := r.db.Exec(`UPDATE idempotency_keys SET status_code = ?, response = ?, expires_at = ?
+		WHERE scope = ? AND key = ? AND token = ? AND status_code = 0`,
+		statusCode, string(response), expiresAt.UTC().Format(timeLayout), scope, key, token)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return errors.New("idempotency key reservation not found; it was completed or taken over")
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a key reserved with token, so the request can be retried
+// with it. Keys whose response was recorded, or that were reserved again, are kept.
+func (r *Repository) ReleaseIdempotencyKey(scope, key, token string) error {
+	if _, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE scope = ? AND key = ? AND token = ? AND status_code = 0`,
+		scope, key, token); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// pruneIdempotencyKeys removes the keys that expired at or before now
+func pruneIdempotencyKeys(db *sql.DB, now time.Time) error {
+	_, err := db.Exec(`DELETE FROM idempotency_keys WHERE expires_at <= ?`, now.UTC().Format(timeLayout))
+	return err
+}
@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Job sources
+const (
+	JobSourceAPI    = "api"    // Managed through the REST API
+	JobSourceConfig = "config" // Defined in jobs.json
+)
+
+var (
+	// ErrJobNotFound is returned when a job does not exist
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobExists is returned when creating a job whose ID is taken
+	ErrJobExists = errors.New("job already exists")
+	// ErrJobConfigManaged is returned when changing or deleting a job defined in jobs.json
+	ErrJobConfigManaged = errors.New("defined in jobs.json, edit the file instead")
+)
+
+// Job sends a notification on a cron schedule
+type Job struct {
+	ID            string `json:"id"`
+	Schedule      string `json:"schedule"`                 // Cron expression or descriptor such as "@daily"
+	Timezone      string `json:"timezone,omitempty"`       // IANA timezone the schedule runs in; UTC when empty
+	JitterSeconds int    `json:"jitter_seconds,omitempty"` // Random delay of up to this many seconds added to each run
+	// Notification is the request sent on every run, as accepted by POST /api/v1/notifications
+	Notification json.RawMessage `json:"notification"`
+	Paused       bool            `json:"paused"`
+	Source       string          `json:"source"`
+
+	NextRunAt          *time.Time `json:"next_run_at,omitempty"` // Nil while paused
+	LastRunAt          *time.Time `json:"last_run_at,omitempty"`
+	LastNotificationID string     `json:"last_notification_id,omitempty"`
+	LastError          string     `json:"last_error,omitempty"`
+	CreatedAt          string     `json:"created_at"`
+	UpdatedAt          string     `json:"updated_at"`
+}
+
+const jobColumns = `id, schedule, timezone, jitter_seconds, notification, paused, source,
+	next_run_at, last_run_at, last_notification_id, last_error, created_at, updated_at`
+
+func scanJob(row rowScanner) (Job, error) {
+	var (
+		job                  Job
+		notification         string
+		nextRunAt, lastRunAt sql.NullString
+	)
+	err := row.Scan(&job.ID, &job.Schedule, &job.Timezone, &job.JitterSeconds, &notification, &job.Paused, &job.Source,
+		&nextRunAt, &lastRunAt, &job.LastNotificationID, &job.LastError, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return job, err
+	}
+	job.Notification = json.RawMessage(notification)
+	if job.NextRunAt, err = parseTimeColumn(nextRunAt); err != nil {
+		return job, err
+	}
+	if job.LastRunAt, err = parseTimeColumn(lastRunAt); err != nil {
+		return job, err
+	}
+	return job, nil
+}
+
+// parseTimeColumn reads a nullable time stored with timeLayout
+func parseTimeColumn(value sql.NullString) (*time.Time, error) {
+	if !value.Valid || value.String == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(timeLayout, value.String)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time %q: %w", value.String, err)
+	}
+	return &t, nil
+}
+
+// timeColumn formats a nullable time for storage with timeLayout
+func timeColumn(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(timeLayout)
+}
+
+// ListJobs returns all jobs ordered by ID
+func (r *Repository) ListJobs() ([]Job, error) {
+	rows, err := r.db.Query(`SELECT ` + jobColumns + ` FROM jobs ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// GetJob returns a job by ID
+func (r *Repository) GetJob(id string) (*Job, error) {
+	job, err := scanJob(r.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return &job, nil
+}
+
+// CreateJob stores a job managed through the API. job.NextRunAt is its first run.
+func (r *Repository) CreateJob(job *Job) (*Job, error) {
+	result, err := r.db.Exec(`INSERT INTO jobs (id, schedule, timezone, jitter_seconds, notification, paused, source, next_run_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(id) DO NOTHING`,
+		job.ID, job.Schedule, job.Timezone, job.JitterSeconds, string(job.Notification), job.Paused, JobSourceAPI, timeColumn(job.NextRunAt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrJobExists
+	}
+	return r.GetJob(job.ID)
+}
+
+// UpdateJob replaces the definition of a job managed through the API.
+// job.NextRunAt replaces the next run; the run history is kept.
+func (r *Repository) UpdateJob(job *Job) (*Job, error) {
+	if err := r.checkJobEditable(job.ID); err != nil {
+		return nil, err
+	}
+
+	_, err := r.db.Exec(`UPDATE jobs SET schedule = ?, timezone = ?, jitter_seconds = ?, notification = ?,
+		paused = ?, next_run_at = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		job.Schedule, job.Timezone, job.JitterSeconds, string(job.Notification), job.Paused, timeColumn(job.NextRunAt), job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update job: %w", err)
+	}
+	return r.GetJob(job.ID)
+}
+
+// DeleteJob removes a job managed through the API. Its history is kept.
+func (r *Repository) DeleteJob(id string) error {
+	if err := r.checkJobEditable(id); err != nil {
+		return err
+	}
+	if _, err := r.db.Exec(`DELETE FROM jobs WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete job: %w", err)
+	}
+	return nil
+}
+
+// checkJobEditable reports whether a job exists and is managed through the API
+func (r *Repository) checkJobEditable(id string) error {
+	var source string
+	err := r.db.QueryRow(`SELECT source FROM jobs WHERE id = ?`, id).Scan(&source)
+	if err == sql.ErrNoRows {
+		return ErrJobNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get job: %w", err)
+	}
+	if source == JobSourceConfig {
+		return ErrJobConfigManaged
+	}
+	return nil
+}
+
+// SetJobPaused pauses or resumes a job, including jobs defined in jobs.json.
+// nextRunAt is the next run of a resumed job and is ignored when pausing.
+func (r *Repository) SetJobPaused(id string, paused bool, nextRunAt *time.Time) (*Job, error) {
+	if paused {
+		nextRunAt = nil
+	}
+	result, err := r.db.Exec(`UPDATE jobs SET paused = ?, next_run_at = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		paused, timeColumn(nextRunAt), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update job: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrJobNotFound
+	}
+	return r.GetJob(id)
+}
+
+// DueJobs returns the jobs that are not paused and whose next run is at or before now
+func (r *Repository) DueJobs(now time.Time) ([]Job, error) {
+	rows, err := r.db.Query(`SELECT `+jobColumns+` FROM jobs
+		WHERE paused = 0 AND next_run_at IS NOT NULL AND next_run_at <= ? ORDER BY next_run_at`,
+		now.UTC().Format(timeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// ClaimJobRun moves a due job on to its next run and reports whether this caller claimed
+// the run. The claim fails if the job was paused, changed or already claimed since it was
+// read, so each run happens once. A nil next leaves the job without a next run.
+func (r *Repository) ClaimJobRun(job *Job, now time.Time, next *time.Time) (bool, error) {
+	result, err := r.db.Exec(`UPDATE jobs SET next_run_at = ?, last_run_at = ?
+		WHERE id = ? AND paused = 0 AND next_run_at = ?`,
+		timeColumn(next), now.UTC().Format(timeLayout), job.ID, timeColumn(job.NextRunAt))
+	if err != nil {
+		return false, fmt.Errorf("failed to claim job run: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// RecordJobRun stores the outcome of a job's latest run
+func (r *Repository) RecordJobRun(id, notificationID, errorMessage string) error {
+	_, err := r.db.Exec(`UPDATE jobs SET last_notification_id = ?, last_error = ? WHERE id = ?`,
+		notificationID, errorMessage, id)
+	if err != nil {
+		return fmt.Errorf("failed to record job run: %w", err)
+	}
+	return nil
+}
+
+// SyncConfigJobs makes the jobs stored with source "config" match jobs.json. Jobs keep
+// their paused state and next run across reloads unless their schedule, timezone or
+// jitter changed, in which case NextRunAt of the definition is used. A job defined in
+// the file replaces an API job with the same ID.
+func (r *Repository) SyncConfigJobs(jobs []Job) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ids := make([]interface{}, 0, len(jobs)+1)
+	ids = append(ids, JobSourceConfig)
+	for _, job := range jobs {
+		_, err := tx.Exec(`INSERT INTO jobs (id, schedule, timezone, jitter_seconds, notification, source, next_run_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET
+				next_run_at = CASE
+					WHEN jobs.paused = 1 THEN NULL
+					WHEN jobs.source = excluded.source AND jobs.schedule = excluded.schedule AND jobs.timezone = excluded.timezone
+						AND jobs.jitter_seconds = excluded.jitter_seconds AND jobs.next_run_at IS NOT NULL THEN jobs.next_run_at
+					ELSE excluded.next_run_at END,
+				schedule = excluded.schedule, timezone = excluded.timezone, jitter_seconds = excluded.jitter_seconds,
+				notification = excluded.notification, source = excluded.source,
+				updated_at = CASE WHEN jobs.notification = excluded.notification AND jobs.schedule = excluded.schedule
+					AND jobs.timezone = excluded.timezone AND jobs.jitter_seconds = excluded.jitter_seconds
+					AND jobs.source = excluded.source THEN jobs.updated_at ELSE CURRENT_TIMESTAMP END`,
+			job.ID, job.Schedule, job.Timezone, job.JitterSeconds, string(job.Notification), JobSourceConfig, timeColumn(job.NextRunAt))
+		if err != nil {
+			return fmt.Errorf("failed to sync job %s: %w", job.ID, err)
+		}
+		ids = append(ids, job.ID)
+	}
+
+	// Jobs no longer in the file
+	removed := `DELETE FROM jobs WHERE source = ?`
+	if len(jobs) > 0 {
+		removed += ` AND id NOT IN (?` + strings.Repeat(`, ?`, len(jobs)-1) + `)`
+	}
+	if _, err := tx.Exec(removed, ids...); err != nil {
+		return fmt.Errorf("failed to remove jobs: %w", err)
+	}
+
+	return tx.Commit()
+}
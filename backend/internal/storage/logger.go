@@ -25,13 +25,17 @@ type NotificationLogger struct {
 
 // LogEntry represents a notification log entry
 type LogEntry struct {
-	Notification *providers.Notification
-	Status       string
-	ErrorMessage string
-	ProviderType string
-	Attempts     int
-	DeliveredAt  string // ISO8601 timestamp
-	IsTest       bool
+	Notification  *providers.Notification
+	Status        string
+	ErrorMessage  string
+	ErrorCategory string // providers.ErrorCategory of the failure, empty on success
+	ParentID      string // Notification ID of the group send this delivery belongs to
+	DeliveredVia  string // Hop of a failover chain that delivered the notification
+	DigestID      string // Notification ID of the digest that delivers this notification
+	ProviderType  string
+	Attempts      int
+	DeliveredAt   string // ISO8601 timestamp
+	IsTest        bool
 }
 
 // NewNotificationLogger creates a new notification logger with buffered channel
@@ -107,8 +111,9 @@ func (nl *NotificationLogger) flushBatch(batch []LogEntry) error {
 	stmt, err := tx.Prepare(`
 		INSERT INTO notification_logs (
 			provider_id, provider_type, recipient, message, subject,
-			metadata, priority, status, error_message, attempts, created_at, delivered_at, is_test
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			metadata, priority, status, error_message, attempts, created_at, delivered_at, is_test,
+			notification_id, error_category, parent_id, delivered_via, job_id, digest_id, expires_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		log.Printf("ERROR: Failed to prepare statement: %v", err)
@@ -163,6 +168,48 @@ func (nl *NotificationLogger) writeEntryWithStmt(stmt *sql.Stmt, entry LogEntry)
 		deliveredAt = nil
 	}
 
+	// Handle nullable notification_id
+	var notificationID interface{}
+	if entry.Notification.ID != "" {
+		notificationID = entry.Notification.ID
+	}
+
+	// Handle nullable error_category
+	var errorCategory interface{}
+	if entry.ErrorCategory != "" {
+		errorCategory = entry.ErrorCategory
+	}
+
+	// Handle nullable parent_id
+	var parentID interface{}
+	if entry.ParentID != "" {
+		parentID = entry.ParentID
+	}
+
+	// Handle nullable delivered_via
+	var deliveredVia interface{}
+	if entry.DeliveredVia != "" {
+		deliveredVia = entry.DeliveredVia
+	}
+
+	// Handle nullable job_id
+	var jobID interface{}
+	if entry.Notification.JobID != "" {
+		jobID = entry.Notification.JobID
+	}
+
+	// Handle nullable digest_id
+	var digestID interface{}
+	if entry.DigestID != "" {
+		digestID = entry.DigestID
+	}
+
+	// Handle nullable expires_at
+	var expiresAt interface{}
+	if entry.Notification.ExpiresAt != nil {
+		expiresAt = entry.Notification.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
 	_, err := stmt.Exec(
 		entry.Notification.ProviderID,
 		entry.ProviderType,
@@ -177,6 +224,13 @@ func (nl *NotificationLogger) writeEntryWithStmt(stmt *sql.Stmt, entry LogEntry)
 		entry.Notification.Timestamp,
 		deliveredAt,
 		isTestInt,
+		notificationID,
+		errorCategory,
+		parentID,
+		deliveredVia,
+		jobID,
+		digestID,
+		expiresAt,
 	)
 
 	return err
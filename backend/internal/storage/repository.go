@@ -2,6 +2,8 @@ package storage
 
 import (
 	"database/sql"
+	"strconv"
+	"time"
 )
 
 // Repository handles database queries for notification history
@@ -24,38 +26,89 @@ func (r *Repository) Ping() error {
 
 // HistoryFilters represents query filters for notification history
 type HistoryFilters struct {
-	ProviderID   string
-	ProviderType string
-	Status       string
-	DateFrom     string
-	DateTo       string
-	IncludeTests bool
-	Cursor       int
-	PageSize     int
-	SortOrder    string
+	ProviderID    string
+	ProviderType  string
+	Status        string
+	ErrorCategory string
+	ParentID      string
+	JobID         string
+	DigestID      string
+	DateFrom      string
+	DateTo        string
+	IncludeTests  bool
+	Cursor        int
+	PageSize      int
+	SortOrder     string
 }
 
 // NotificationLogEntry represents a notification log record from the database
 type NotificationLogEntry struct {
-	ID           int            `json:"id"`
-	ProviderID   string         `json:"provider_id"`
-	ProviderType string         `json:"provider_type"`
-	Recipient    string         `json:"recipient"`
-	Message      string         `json:"message"`
-	Subject      sql.NullString `json:"subject"`
-	Metadata     sql.NullString `json:"metadata"`
-	Priority     string         `json:"priority"`
-	Status       string         `json:"status"`
-	ErrorMessage sql.NullString `json:"error_message"`
-	Attempts     int            `json:"attempts"`
-	CreatedAt    string         `json:"created_at"`
-	DeliveredAt  sql.NullString `json:"delivered_at"`
-	IsTest       bool           `json:"is_test"`
+	ID             int            `json:"id"`
+	ProviderID     string         `json:"provider_id"`
+	ProviderType   string         `json:"provider_type"`
+	Recipient      string         `json:"recipient"`
+	Message        string         `json:"message"`
+	Subject        sql.NullString `json:"subject"`
+	Metadata       sql.NullString `json:"metadata"`
+	Priority       string         `json:"priority"`
+	Status         string         `json:"status"`
+	ErrorMessage   sql.NullString `json:"error_message"`
+	Attempts       int            `json:"attempts"`
+	CreatedAt      string         `json:"created_at"`
+	DeliveredAt    sql.NullString `json:"delivered_at"`
+	IsTest         bool           `json:"is_test"`
+	NotificationID sql.NullString `json:"notification_id"`
+	AcknowledgedAt sql.NullString `json:"acknowledged_at"`
+	AcknowledgedBy sql.NullString `json:"acknowledged_by"`
+	ErrorCategory  sql.NullString `json:"error_category"`
+	ParentID       sql.NullString `json:"parent_id"`
+	DeliveredVia   sql.NullString `json:"delivered_via"`
+	JobID          sql.NullString `json:"job_id"`
+	DigestID       sql.NullString `json:"digest_id"`
+	ExpiresAt      sql.NullString `json:"expires_at"`
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanNotificationLog reads a row selected with notificationLogSelectColumns
+func scanNotificationLog(row rowScanner) (NotificationLogEntry, error) {
+	var entry NotificationLogEntry
+	var isTestInt int
+	err := row.Scan(
+		&entry.ID,
+		&entry.ProviderID,
+		&entry.ProviderType,
+		&entry.Recipient,
+		&entry.Message,
+		&entry.Subject,
+		&entry.Metadata,
+		&entry.Priority,
+		&entry.Status,
+		&entry.ErrorMessage,
+		&entry.Attempts,
+		&entry.CreatedAt,
+		&entry.DeliveredAt,
+		&isTestInt,
+		&entry.NotificationID,
+		&entry.AcknowledgedAt,
+		&entry.AcknowledgedBy,
+		&entry.ErrorCategory,
+		&entry.ParentID,
+		&entry.DeliveredVia,
+		&entry.JobID,
+		&entry.DigestID,
+		&entry.ExpiresAt,
+	)
+	entry.IsTest = isTestInt != 0
+	return entry, err
 }
 
 // buildHistoryQuery constructs the SQL query with filters
 func (r *Repository) buildHistoryQuery(filters HistoryFilters) (string, []interface{}) {
-	query := `SELECT * FROM notification_logs WHERE 1=1`
+	query := `SELECT ` + notificationLogSelectColumns + ` FROM notification_logs WHERE 1=1`
 	args := []interface{}{}
 
 	if filters.ProviderID != "" {
@@ -70,6 +123,22 @@ func (r *Repository) buildHistoryQuery(filters HistoryFilters) (string, []interf
 		query += " AND status = ?"
 		args = append(args, filters.Status)
 	}
+	if filters.ErrorCategory != "" {
+		query += " AND error_category = ?"
+		args = append(args, filters.ErrorCategory)
+	}
+	if filters.ParentID != "" {
+		query += " AND parent_id = ?"
+		args = append(args, filters.ParentID)
+	}
+	if filters.JobID != "" {
+		query += " AND job_id = ?"
+		args = append(args, filters.JobID)
+	}
+	if filters.DigestID != "" {
+		query += " AND digest_id = ?"
+		args = append(args, filters.DigestID)
+	}
 	if filters.DateFrom != "" {
 		query += " AND created_at >= ?"
 		args = append(args, filters.DateFrom)
@@ -108,28 +177,10 @@ func (r *Repository) GetNotificationHistory(filters HistoryFilters) ([]Notificat
 
 	var entries []NotificationLogEntry
 	for rows.Next() {
-		var entry NotificationLogEntry
-		var isTestInt int
-		err := rows.Scan(
-			&entry.ID,
-			&entry.ProviderID,
-			&entry.ProviderType,
-			&entry.Recipient,
-			&entry.Message,
-			&entry.Subject,
-			&entry.Metadata,
-			&entry.Priority,
-			&entry.Status,
-			&entry.ErrorMessage,
-			&entry.Attempts,
-			&entry.CreatedAt,
-			&entry.DeliveredAt,
-			&isTestInt,
-		)
+		entry, err := scanNotificationLog(rows)
 		if err != nil {
 			return nil, nil, err
 		}
-		entry.IsTest = isTestInt != 0
 		entries = append(entries, entry)
 	}
 
@@ -151,26 +202,9 @@ func (r *Repository) GetNotificationHistory(filters HistoryFilters) ([]Notificat
 
 // GetNotificationByID retrieves a specific notification by ID
 func (r *Repository) GetNotificationByID(id int) (*NotificationLogEntry, error) {
-	query := `SELECT * FROM notification_logs WHERE id = ?`
+	query := `SELECT ` + notificationLogSelectColumns + ` FROM notification_logs WHERE id = ?`
 
-	var entry NotificationLogEntry
-	var isTestInt int
-	err := r.db.QueryRow(query, id).Scan(
-		&entry.ID,
-		&entry.ProviderID,
-		&entry.ProviderType,
-		&entry.Recipient,
-		&entry.Message,
-		&entry.Subject,
-		&entry.Metadata,
-		&entry.Priority,
-		&entry.Status,
-		&entry.ErrorMessage,
-		&entry.Attempts,
-		&entry.CreatedAt,
-		&entry.DeliveredAt,
-		&isTestInt,
-	)
+	entry, err := scanNotificationLog(r.db.QueryRow(query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -178,13 +212,40 @@ func (r *Repository) GetNotificationByID(id int) (*NotificationLogEntry, error)
 		return nil, err
 	}
 
-	entry.IsTest = isTestInt != 0
 	return &entry, nil
 }
 
-// CleanupOldLogs removes notifications older than the retention period
+// AcknowledgeNotification marks a notification as acknowledged by the given actor.
+// The reference may be the notification ID returned by the API or the numeric history ID.
+// It returns false if no matching notification exists.
+func (r *Repository) AcknowledgeNotification(ref, acknowledgedBy string) (bool, error) {
+	query := `UPDATE notification_logs SET acknowledged_at = ?, acknowledged_by = ?
+		WHERE notification_id = ?`
+	args := []interface{}{time.Now().UTC().Format(time.RFC3339), acknowledgedBy, ref}
+
+	if id, err := strconv.Atoi(ref); err == nil {
+		query += " OR id = ?"
+		args = append(args, id)
+	}
+
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// CleanupOldLogs removes notifications older than the retention period and expired idempotency keys
 func (r *Repository) CleanupOldLogs(retentionDays int) error {
 	query := `DELETE FROM notification_logs WHERE created_at < datetime('now', '-' || ? || ' days')`
-	_, err := r.db.Exec(query, retentionDays)
-	return err
+	if _, err := r.db.Exec(query, retentionDays); err != nil {
+		return err
+	}
+	return pruneIdempotencyKeys(r.db, time.Now())
 }
@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+)
+
+// ErrScheduledNotFound is returned when no pending scheduled notification has an ID
+var ErrScheduledNotFound = errors.New("scheduled notification not found")
+
+// timeLayout stores send and run times as fixed-width UTC text so they compare in order
+const timeLayout = "2006-01-02T15:04:05.000Z"
+
+// ScheduledNotification is a validated notification held until its send time.
+// A fan-out is stored with its parent as Notification and one entry per member.
+type ScheduledNotification struct {
+	ID           string                    `json:"id"`            // Notification ID returned when it was scheduled
+	ProviderID   string                    `json:"provider_id"`   // Provider, group, failover chain, topic or routing rule
+	ProviderType string                    `json:"provider_type"` // Type of ProviderID, e.g. "group" or "topic" for fan-outs
+	SendAt       time.Time                 `json:"send_at"`
+	Notification *providers.Notification   `json:"notification"`
+	Members      []*providers.Notification `json:"members,omitempty"`   // Member deliveries of a fan-out
+	ParentID     string                    `json:"parent_id,omitempty"` // Fan-out a deferred member delivery belongs to
+	Deferred     bool                      `json:"deferred,omitempty"`  // Held for quiet hours; sent without holding it again
+	CreatedAt    string                    `json:"created_at,omitempty"`
+	ClaimedAt    *time.Time                `json:"claimed_at,omitempty"` // When the scheduler claimed it for dispatch
+}
+
+// scheduledPayload is the JSON stored for a scheduled notification
+type scheduledPayload struct {
+	Notification *providers.Notification   `json:"notification"`
+	Members      []*providers.Notification `json:"members,omitempty"`
+	ParentID     string                    `json:"parent_id,omitempty"`
+	Deferred     bool                      `json:"deferred,omitempty"`
+}
+
+const scheduledColumns = `id, provider_id, provider_type, send_at, payload, created_at, claimed_at`
+
+func scanScheduled(row rowScanner) (ScheduledNotification, error) {
+	var (
+		scheduled       ScheduledNotification
+		sendAt, payload string
+		claimedAt       sql.NullString
+	)
+	if err := row.Scan(&scheduled.ID, &scheduled.ProviderID, &scheduled.ProviderType, &sendAt, &payload, &scheduled.CreatedAt, &claimedAt); err != nil {
+		return scheduled, err
+	}
+
+	var err error
+	if scheduled.SendAt, err = time.Parse(timeLayout, sendAt); err != nil {
+		return scheduled, fmt.Errorf("invalid send_at %q: %w", sendAt, err)
+	}
+	if claimedAt.Valid {
+		claimed, err := time.Parse(timeLayout, claimedAt.String)
+		if err != nil {
+			return scheduled, fmt.Errorf("invalid claimed_at %q: %w", claimedAt.String, err)
+		}
+		scheduled.ClaimedAt = &claimed
+	}
+	var decoded scheduledPayload
+	if err := json.Unmarshal([]byte(payload), &decoded); err != nil {
+		return scheduled, fmt.Errorf("invalid payload: %w", err)
+	}
+	scheduled.Notification = decoded.Notification
+	scheduled.Members = decoded.Members
+	scheduled.ParentID = decoded.ParentID
+	scheduled.Deferred = decoded.Deferred
+	return scheduled, nil
+}
+
+// ScheduleNotification stores a notification to be sent at its SendAt time. A row with
+// the same ID that is claimed for dispatch is replaced, so a due notification can be held
+// again, e.g. for quiet hours, while it is being dispatched; completing the claim then
+// leaves the new row in place.
+func (r *Repository) ScheduleNotification(scheduled *ScheduledNotification) error {
+	payload, err := json.Marshal(scheduledPayload{
+		Notification: scheduled.Notification,
+		Members:      scheduled.Members,
+		ParentID:     scheduled.ParentID,
+		Deferred:     scheduled.Deferred,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode scheduled notification: %w", err)
+	}
+
+	result, err := r.db.Exec(`INSERT INTO scheduled_notifications (id, provider_id, provider_type, send_at, payload)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET provider_id = excluded.provider_id, provider_type = excluded.provider_type,
+			send_at = excluded.send_at, payload = excluded.payload, claimed_at = NULL
+		WHERE claimed_at IS NOT NULL`,
+		scheduled.ID, scheduled.ProviderID, scheduled.ProviderType, scheduled.SendAt.UTC().Format(timeLayout), string(payload))
+	if err != nil {
+		return fmt.Errorf("failed to schedule notification: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("failed to schedule notification: %s is already scheduled", scheduled.ID)
+	}
+	return nil
+}
+
+// ListScheduledNotifications returns the pending scheduled notifications, soonest first
+func (r *Repository) ListScheduledNotifications() ([]ScheduledNotification, error) {
+	rows, err := r.db.Query(`SELECT ` + scheduledColumns + ` FROM scheduled_notifications ORDER BY send_at, created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled notifications: %w", err)
+	}
+	defer rows.Close()
+
+	scheduled := []ScheduledNotification{}
+	for rows.Next() {
+		s, err := scanScheduled(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled notification: %w", err)
+		}
+		scheduled = append(scheduled, s)
+	}
+	return scheduled, rows.Err()
+}
+
+// CancelScheduledNotification removes a pending scheduled notification and returns it.
+// Notifications claimed for dispatch can no longer be cancelled.
+func (r *Repository) CancelScheduledNotification(id string) (*ScheduledNotification, error) {
+	scheduled, err := scanScheduled(r.db.QueryRow(`DELETE FROM scheduled_notifications WHERE id = ? AND claimed_at IS NULL
+		RETURNING `+scheduledColumns, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrScheduledNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel scheduled notification: %w", err)
+	}
+	return &scheduled, nil
+}
+
+// ClaimDueNotifications claims and returns up to limit scheduled notifications whose
+// send time is at or before now, soonest first. A claim is a lease: the rows stay in the
+// database until CompleteScheduledNotification removes them once their outcome is
+// recorded, and rows whose claim is older than lease are claimed again, so a notification
+// claimed by a process that stopped before completing it is dispatched after a restart.
+// Rows that cannot be decoded stay claimed and are reported in the error together with
+// the rows that could.
+func (r *Repository) ClaimDueNotifications(now time.Time, lease time.Duration, limit int) ([]ScheduledNotification, error) {
+	rows, err := r.db.Query(`UPDATE scheduled_notifications SET claimed_at = ? WHERE id IN (
+			SELECT id FROM scheduled_notifications
+			WHERE send_at <= ? AND (claimed_at IS NULL OR claimed_at <= ?)
+			ORDER BY send_at LIMIT ?
+		) RETURNING `+scheduledColumns,
+		now.UTC().Format(timeLayout), now.UTC().Format(timeLayout), now.Add(-lease).UTC().Format(timeLayout), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim scheduled notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		due     []ScheduledNotification
+		scanErr error
+	)
+	for rows.Next() {
+		s, err := scanScheduled(rows)
+		if err != nil {
+			scanErr = errors.Join(scanErr, fmt.Errorf("failed to scan scheduled notification %s: %w", s.ID, err))
+			continue
+		}
+		due = append(due, s)
+	}
+	if err := rows.Err(); err != nil {
+		return due, fmt.Errorf("failed to claim scheduled notifications: %w", err)
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].SendAt.Before(due[j].SendAt) })
+	return due, scanErr
+}
+
+// RenewScheduledClaim extends the lease of a claimed notification to now. It reports
+// false when the notification is no longer held under claimedAt.
+func (r *Repository) RenewScheduledClaim(id string, claimedAt, now time.Time) (bool, error) {
+	result, err := r.db.Exec(`UPDATE scheduled_notifications SET claimed_at = ? WHERE id = ? AND claimed_at = ?`,
+		now.UTC().Format(timeLayout), id, claimedAt.UTC().Format(timeLayout))
+	if err != nil {
+		return false, fmt.Errorf("failed to renew claim of scheduled notification %s: %w", id, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to renew claim of scheduled notification %s: %w", id, err)
+	}
+	return n > 0, nil
+}
+
+// CompleteScheduledNotification removes a claimed notification once its outcome is
+// recorded. A notification scheduled again under the same ID since it was claimed, or
+// claimed again after its lease expired, is left in place.
+func (r *Repository) CompleteScheduledNotification(scheduled *ScheduledNotification) error {
+	if scheduled.ClaimedAt == nil {
+		return fmt.Errorf("scheduled notification %s is not claimed", scheduled.ID)
+	}
+	_, err := r.db.Exec(`DELETE FROM scheduled_notifications WHERE id = ? AND claimed_at = ?`,
+		scheduled.ID, scheduled.ClaimedAt.UTC().Format(timeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to complete scheduled notification %s: %w", scheduled.ID, err)
+	}
+	return nil
+}
@@ -16,7 +16,16 @@ CREATE TABLE IF NOT EXISTS notification_logs (
     attempts INTEGER DEFAULT 0,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     delivered_at DATETIME,
-    is_test INTEGER NOT NULL DEFAULT 0
+    is_test INTEGER NOT NULL DEFAULT 0,
+    notification_id TEXT,
+    acknowledged_at DATETIME,
+    acknowledged_by TEXT,
+    error_category TEXT,
+    parent_id TEXT,
+    delivered_via TEXT,
+    job_id TEXT,
+    digest_id TEXT,
+    expires_at TEXT
 );
 
 -- Composite indexes for common query patterns (Phase 2 optimization)
@@ -31,12 +40,246 @@ CREATE INDEX IF NOT EXISTS idx_type_created
 
 CREATE INDEX IF NOT EXISTS idx_created_id 
     ON notification_logs(created_at DESC, id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_notification_id 
+    ON notification_logs(notification_id);
+
+CREATE INDEX IF NOT EXISTS idx_error_category_created 
+    ON notification_logs(error_category, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_parent_id 
+    ON notification_logs(parent_id);
+
+CREATE INDEX IF NOT EXISTS idx_job_id_created
+    ON notification_logs(job_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_digest_id
+    ON notification_logs(digest_id);
+
+-- Publish/subscribe topics (source 'config' for topics.json, 'api' for REST)
+CREATE TABLE IF NOT EXISTS topics (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL DEFAULT 'api',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS topic_subscribers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    topic TEXT NOT NULL REFERENCES topics(name) ON DELETE CASCADE,
+    provider_id TEXT NOT NULL,
+    recipient TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL DEFAULT 'api',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (topic, provider_id, recipient)
+);
+
+-- Contact directory: people with an address per provider and delivery preferences
+CREATE TABLE IF NOT EXISTS contacts (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT '',
+    preferred_provider TEXT NOT NULL DEFAULT '',
+    timezone TEXT NOT NULL DEFAULT '',
+    locale TEXT NOT NULL DEFAULT '',
+    quiet_hours_start TEXT NOT NULL DEFAULT '',
+    quiet_hours_end TEXT NOT NULL DEFAULT '',
+    quiet_hours_timezone TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS contact_identities (
+    contact_id TEXT NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    provider_id TEXT NOT NULL,
+    address TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (contact_id, provider_id)
+);
+
+-- Notifications held until their send time; rows are claimed for a lease when due and
+-- removed once their delivery outcome is recorded, or when cancelled
+CREATE TABLE IF NOT EXISTS scheduled_notifications (
+    id TEXT PRIMARY KEY,
+    provider_id TEXT NOT NULL,
+    provider_type TEXT NOT NULL DEFAULT '',
+    send_at TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    claimed_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_send_at
+ON scheduled_notifications(send_at);
+
+-- Recurring jobs that send a notification on a cron schedule
+-- (source 'config' for jobs.json, 'api' for REST)
+CREATE TABLE IF NOT EXISTS jobs (
+    id TEXT PRIMARY KEY,
+    schedule TEXT NOT NULL,
+    timezone TEXT NOT NULL DEFAULT '',
+    jitter_seconds INTEGER NOT NULL DEFAULT 0,
+    notification TEXT NOT NULL,
+    paused INTEGER NOT NULL DEFAULT 0,
+    source TEXT NOT NULL DEFAULT 'api',
+    next_run_at TEXT,
+    last_run_at TEXT,
+    last_notification_id TEXT NOT NULL DEFAULT '',
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_next_run
+ON jobs(next_run_at);
+
+-- Digests collecting notifications for a provider and recipient until flush_at;
+-- rows are removed when the summary is sent
+CREATE TABLE IF NOT EXISTS digests (
+    id TEXT PRIMARY KEY,
+    provider_id TEXT NOT NULL,
+    provider_type TEXT NOT NULL DEFAULT '',
+    recipient TEXT NOT NULL DEFAULT '',
+    template TEXT NOT NULL DEFAULT '',
+    flush_at TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider_id, recipient)
+);
+
+CREATE INDEX IF NOT EXISTS idx_digests_flush_at
+ON digests(flush_at);
+
+CREATE TABLE IF NOT EXISTS digest_items (
+    notification_id TEXT PRIMARY KEY,
+    digest_id TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_digest_items_digest
+ON digest_items(digest_id);
+
+-- Digests whose summary is being sent. Claiming moves a due digest here, so new
+-- notifications open a new digest; the row and its items are removed once the summary's
+-- outcome is recorded, and claims older than the lease are claimed again.
+CREATE TABLE IF NOT EXISTS claimed_digests (
+    id TEXT PRIMARY KEY,
+    provider_id TEXT NOT NULL,
+    provider_type TEXT NOT NULL DEFAULT '',
+    recipient TEXT NOT NULL DEFAULT '',
+    template TEXT NOT NULL DEFAULT '',
+    flush_at TEXT NOT NULL,
+    created_at DATETIME,
+    claimed_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_claimed_digests_claimed_at
+ON claimed_digests(claimed_at);
+
+-- Idempotency keys of notification requests with the response to replay on retries;
+-- expired rows are removed by the retention job
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope TEXT NOT NULL DEFAULT '',
+    key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    response TEXT NOT NULL DEFAULT '',
+    expires_at TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    token TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at
+ON idempotency_keys(expires_at);
+
+-- Deduplication windows: notifications with the same fingerprint as the first one are
+-- suppressed until expires_at; rows are removed when the window is closed
+CREATE TABLE IF NOT EXISTS dedup_windows (
+    id TEXT PRIMARY KEY,
+    fingerprint TEXT NOT NULL,
+    provider_id TEXT NOT NULL,
+    provider_type TEXT NOT NULL DEFAULT '',
+    recipient TEXT NOT NULL DEFAULT '',
+    suppressed INTEGER NOT NULL DEFAULT 0,
+    notify_repeats INTEGER NOT NULL DEFAULT 0,
+    expires_at TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_dedup_windows_fingerprint
+ON dedup_windows(fingerprint, expires_at);
+
+CREATE INDEX IF NOT EXISTS idx_dedup_windows_expires_at
+ON dedup_windows(expires_at);
 `
 
+// addedColumn is a column added to a table after the table was introduced
+type addedColumn struct {
+	Name       string
+	Definition string
+}
+
+// notificationLogColumns lists columns added to notification_logs after the initial
+// release. InitDB adds any that are missing from an existing database before applying Schema.
+var notificationLogColumns = []addedColumn{
+	{"is_test", "INTEGER NOT NULL DEFAULT 0"},
+	{"notification_id", "TEXT"},
+	{"acknowledged_at", "DATETIME"},
+	{"acknowledged_by", "TEXT"},
+	{"error_category", "TEXT"},
+	{"parent_id", "TEXT"},
+	{"delivered_via", "TEXT"},
+	{"job_id", "TEXT"},
+	{"digest_id", "TEXT"},
+	{"expires_at", "TEXT"},
+}
+
+// contactColumns lists columns added to contacts after the table was introduced
+var contactColumns = []addedColumn{
+	{"locale", "TEXT NOT NULL DEFAULT ''"},
+	{"quiet_hours_timezone", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// scheduledNotificationColumns lists columns added to scheduled_notifications after the table was introduced
+var scheduledNotificationColumns = []addedColumn{
+	{"claimed_at", "TEXT"},
+}
+
+// idempotencyKeyColumns lists columns added to idempotency_keys after the table was introduced
+var idempotencyKeyColumns = []addedColumn{
+	{"token", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// notificationLogSelectColumns is the column list used when reading notification_logs
+const notificationLogSelectColumns = `id, provider_id, provider_type, recipient, message, subject,
+	metadata, priority, status, error_message, attempts, created_at, delivered_at, is_test,
+	notification_id, acknowledged_at, acknowledged_by, error_category, parent_id, delivered_via, job_id, digest_id,
+	expires_at`
+
 // Status constants for notification logs
 const (
 	StatusPending  = "pending"
 	StatusSent     = "sent"
 	StatusFailed   = "failed"
 	StatusRetrying = "retrying"
+	StatusMuted    = "muted"
+
+	// StatusFannedOut marks the parent row of a group send; each member delivery has its own row
+	StatusFannedOut = "fanned_out"
+
+	// StatusCancelled marks a scheduled notification cancelled before its send time
+	StatusCancelled = "cancelled"
+
+	// StatusDigested marks a notification held for a digest; the digest's own row records the delivery
+	StatusDigested = "digested"
+
+	// StatusDeferred marks a notification held for quiet hours; it gets another row when it is sent
+	StatusDeferred = "deferred"
+
+	// StatusSuppressed marks a duplicate of a notification sent within its dedup window
+	StatusSuppressed = "suppressed"
+
+	// StatusExpired marks a notification not delivered before its expires_at
+	StatusExpired = "expired"
 )
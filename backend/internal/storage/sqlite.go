@@ -55,6 +55,14 @@ func InitDB(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
 	}
 
+	// Bring existing databases up to date before creating indexes on new columns
+	if err := migrateSchema(conn); err != nil {
+		if closeErr := conn.Close(); closeErr != nil {
+			fmt.Printf("warning: failed to close database after migration error: %v\n", closeErr)
+		}
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
 	// Execute schema creation
 	if _, err := conn.Exec(Schema); err != nil {
 		if closeErr := conn.Close(); closeErr != nil {
@@ -66,6 +74,64 @@ func InitDB(dbPath string) (*DB, error) {
 	return &DB{conn: conn}, nil
 }
 
+// migrateSchema adds columns introduced after a table's first release to existing
+// tables. New databases are fully created by Schema instead.
+func migrateSchema(conn *sql.DB) error {
+	if err := addMissingColumns(conn, "notification_logs", notificationLogColumns); err != nil {
+		return err
+	}
+	if err := addMissingColumns(conn, "scheduled_notifications", scheduledNotificationColumns); err != nil {
+		return err
+	}
+	if err := addMissingColumns(conn, "idempotency_keys", idempotencyKeyColumns); err != nil {
+		return err
+	}
+	return addMissingColumns(conn, "contacts", contactColumns)
+}
+
+// addMissingColumns adds the columns an existing table lacks
+func addMissingColumns(conn *sql.DB, table string, columns []addedColumn) error {
+	rows, err := conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read table info: %w", err)
+	}
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid           int
+			name, colType string
+			notNull, pk   int
+			defaultValue  sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to scan table info: %w", err)
+		}
+		existing[name] = true
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("failed to close table info rows: %w", err)
+	}
+
+	// Table does not exist yet - Schema will create it with all columns
+	if len(existing) == 0 {
+		return nil
+	}
+
+	for _, column := range columns {
+		if existing[column.Name] {
+			continue
+		}
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column.Name, column.Definition)
+		if _, err := conn.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", table, column.Name, err)
+		}
+	}
+
+	return nil
+}
+
 // Close closes the database connection
 func (db *DB) Close() error {
 	if db.conn != nil {
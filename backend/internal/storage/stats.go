@@ -0,0 +1,72 @@
+package storage
+
+// StatsFilters narrows the notification history counted by GetNotificationStats
+type StatsFilters struct {
+	ProviderID   string
+	ProviderType string
+	DateFrom     string
+	DateTo       string
+	IncludeTests bool
+}
+
+// NotificationStats counts history rows by status
+type NotificationStats struct {
+	Total    int            `json:"total"`
+	ByStatus map[string]int `json:"by_status"`
+}
+
+// statsStatuses are always reported by GetNotificationStats, even when none match
+var statsStatuses = []string{StatusSent, StatusFailed, StatusExpired}
+
+// GetNotificationStats counts the history rows matching filters by status
+func (r *Repository) GetNotificationStats(filters StatsFilters) (*NotificationStats, error) {
+	query := `SELECT status, COUNT(*) FROM notification_logs WHERE 1=1`
+	args := []interface{}{}
+
+	if filters.ProviderID != "" {
+		query += " AND provider_id = ?"
+		args = append(args, filters.ProviderID)
+	}
+	if filters.ProviderType != "" {
+		query += " AND provider_type = ?"
+		args = append(args, filters.ProviderType)
+	}
+	if filters.DateFrom != "" {
+		query += " AND created_at >= ?"
+		args = append(args, filters.DateFrom)
+	}
+	if filters.DateTo != "" {
+		query += " AND created_at <= ?"
+		args = append(args, filters.DateTo)
+	}
+	if !filters.IncludeTests {
+		query += " AND is_test = 0"
+	}
+	query += " GROUP BY status"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	stats := &NotificationStats{ByStatus: make(map[string]int)}
+	for _, status := range statsStatuses {
+		stats.ByStatus[status] = 0
+	}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		stats.ByStatus[status] = count
+		stats.Total += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,296 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Topic and subscriber sources
+const (
+	TopicSourceAPI    = "api"    // Managed through the REST API
+	TopicSourceConfig = "config" // Defined in topics.json
+)
+
+var (
+	// ErrTopicNotFound is returned when a topic does not exist
+	ErrTopicNotFound = errors.New("topic not found")
+	// ErrTopicExists is returned when creating a topic that already exists
+	ErrTopicExists = errors.New("topic already exists")
+	// ErrSubscriberNotFound is returned when a subscriber does not exist
+	ErrSubscriberNotFound = errors.New("subscriber not found")
+	// ErrSubscriberExists is returned when a subscription already exists
+	ErrSubscriberExists = errors.New("subscriber already exists")
+	// ErrConfigManaged is returned when changing a topic or subscriber defined in topics.json
+	ErrConfigManaged = errors.New("defined in topics.json, edit the file instead")
+)
+
+// Topic is a named list of subscribers notifications can be published to
+type Topic struct {
+	Name            string `json:"name"`
+	Description     string `json:"description,omitempty"`
+	Source          string `json:"source"`
+	CreatedAt       string `json:"created_at"`
+	SubscriberCount int    `json:"subscriber_count"`
+}
+
+// TopicSubscriber is a provider and recipient subscribed to a topic
+type TopicSubscriber struct {
+	ID         int64  `json:"id"`
+	Topic      string `json:"topic"`
+	ProviderID string `json:"provider_id"`
+	Recipient  string `json:"recipient,omitempty"` // Empty when the provider supplies its own
+	Source     string `json:"source"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// TopicDefinition is a topic and its subscribers as defined in topics.json
+type TopicDefinition struct {
+	Name        string
+	Description string
+	Subscribers []TopicSubscriber
+}
+
+const topicSelect = `SELECT t.name, t.description, t.source, t.created_at,
+	(SELECT COUNT(*) FROM topic_subscribers s WHERE s.topic = t.name)
+	FROM topics t`
+
+func scanTopic(row rowScanner) (Topic, error) {
+	var topic Topic
+	err := row.Scan(&topic.Name, &topic.Description, &topic.Source, &topic.CreatedAt, &topic.SubscriberCount)
+	return topic, err
+}
+
+// ListTopics returns all topics ordered by name
+func (r *Repository) ListTopics() ([]Topic, error) {
+	rows, err := r.db.Query(topicSelect + ` ORDER BY t.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list topics: %w", err)
+	}
+	defer rows.Close()
+
+	topics := []Topic{}
+	for rows.Next() {
+		topic, err := scanTopic(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan topic: %w", err)
+		}
+		topics = append(topics, topic)
+	}
+	return topics, rows.Err()
+}
+
+// GetTopic returns a topic by name
+func (r *Repository) GetTopic(name string) (*Topic, error) {
+	topic, err := scanTopic(r.db.QueryRow(topicSelect+` WHERE t.name = ?`, name))
+	if err == sql.ErrNoRows {
+		return nil, ErrTopicNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topic: %w", err)
+	}
+	return &topic, nil
+}
+
+// CreateTopic creates a topic managed through the API
+func (r *Repository) CreateTopic(name, description string) (*Topic, error) {
+	result, err := r.db.Exec(`INSERT INTO topics (name, description, source) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO NOTHING`, name, description, TopicSourceAPI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create topic: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return nil, ErrTopicExists
+	}
+	return r.GetTopic(name)
+}
+
+// DeleteTopic deletes a topic managed through the API and its subscribers
+func (r *Repository) DeleteTopic(name string) error {
+	topic, err := r.GetTopic(name)
+	if err != nil {
+		return err
+	}
+	if topic.Source == TopicSourceConfig {
+		return ErrConfigManaged
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM topic_subscribers WHERE topic = ?`, name); err != nil {
+		return fmt.Errorf("failed to delete subscribers: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM topics WHERE name = ?`, name); err != nil {
+		return fmt.Errorf("failed to delete topic: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ListSubscribers returns the subscribers of a topic in the order they subscribed
+func (r *Repository) ListSubscribers(topic string) ([]TopicSubscriber, error) {
+	if _, err := r.GetTopic(topic); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`SELECT id, topic, provider_id, recipient, source, created_at
+		FROM topic_subscribers WHERE topic = ? ORDER BY id`, topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscribers: %w", err)
+	}
+	defer rows.Close()
+
+	subscribers := []TopicSubscriber{}
+	for rows.Next() {
+		var s TopicSubscriber
+		if err := rows.Scan(&s.ID, &s.Topic, &s.ProviderID, &s.Recipient, &s.Source, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan subscriber: %w", err)
+		}
+		subscribers = append(subscribers, s)
+	}
+	return subscribers, rows.Err()
+}
+
+// AddSubscriber subscribes a provider and recipient to a topic through the API
+func (r *Repository) AddSubscriber(topic, providerID, recipient string) (*TopicSubscriber, error) {
+	if _, err := r.GetTopic(topic); err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.Exec(`INSERT INTO topic_subscribers (topic, provider_id, recipient, source)
+		VALUES (?, ?, ?, ?) ON CONFLICT(topic, provider_id, recipient) DO NOTHING`,
+		topic, providerID, recipient, TopicSourceAPI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add subscriber: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return nil, ErrSubscriberExists
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subscriber ID: %w", err)
+	}
+
+	var s TopicSubscriber
+	err = r.db.QueryRow(`SELECT id, topic, provider_id, recipient, source, created_at
+		FROM topic_subscribers WHERE id = ?`, id).
+		Scan(&s.ID, &s.Topic, &s.ProviderID, &s.Recipient, &s.Source, &s.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subscriber: %w", err)
+	}
+	return &s, nil
+}
+
+// RemoveSubscriber removes a subscriber added through the API
+func (r *Repository) RemoveSubscriber(topic string, id int64) error {
+	var source string
+	err := r.db.QueryRow(`SELECT source FROM topic_subscribers WHERE topic = ? AND id = ?`, topic, id).Scan(&source)
+	if err == sql.ErrNoRows {
+		return ErrSubscriberNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get subscriber: %w", err)
+	}
+	if source == TopicSourceConfig {
+		return ErrConfigManaged
+	}
+
+	if _, err := r.db.Exec(`DELETE FROM topic_subscribers WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to remove subscriber: %w", err)
+	}
+	return nil
+}
+
+// SyncConfigTopics makes the config-managed topics match topics.json.
+// Topics and subscribers added through the API are kept, except that
+// topics removed from the file are deleted with all their subscribers.
+// A topic or subscriber in the file that already exists becomes config-managed.
+func (r *Repository) SyncConfigTopics(definitions []TopicDefinition) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	names := make([]interface{}, 0, len(definitions))
+	for _, def := range definitions {
+		names = append(names, def.Name)
+
+		if _, err := tx.Exec(`INSERT INTO topics (name, description, source) VALUES (?, ?, ?)
+			ON CONFLICT(name) DO UPDATE SET description = excluded.description, source = excluded.source`,
+			def.Name, def.Description, TopicSourceConfig); err != nil {
+			return fmt.Errorf("failed to sync topic %s: %w", def.Name, err)
+		}
+
+		keep := make(map[string]bool, len(def.Subscribers))
+		for _, s := range def.Subscribers {
+			keep[s.ProviderID+"\x00"+s.Recipient] = true
+			if _, err := tx.Exec(`INSERT INTO topic_subscribers (topic, provider_id, recipient, source)
+				VALUES (?, ?, ?, ?) ON CONFLICT(topic, provider_id, recipient) DO UPDATE SET source = excluded.source`,
+				def.Name, s.ProviderID, s.Recipient, TopicSourceConfig); err != nil {
+				return fmt.Errorf("failed to sync subscribers of %s: %w", def.Name, err)
+			}
+		}
+
+		// Subscribers no longer in the file; unchanged ones keep their ID
+		if err := deleteConfigSubscribers(tx, def.Name, keep); err != nil {
+			return fmt.Errorf("failed to sync subscribers of %s: %w", def.Name, err)
+		}
+	}
+
+	// Topics no longer in the file
+	removed := `SELECT name FROM topics WHERE source = ?`
+	args := []interface{}{TopicSourceConfig}
+	if len(names) > 0 {
+		removed += ` AND name NOT IN (?` + strings.Repeat(", ?", len(names)-1) + `)`
+		args = append(args, names...)
+	}
+	if _, err := tx.Exec(`DELETE FROM topic_subscribers WHERE topic IN (`+removed+`)`, args...); err != nil {
+		return fmt.Errorf("failed to remove subscribers of deleted topics: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM topics WHERE name IN (`+removed+`)`, args...); err != nil {
+		return fmt.Errorf("failed to remove deleted topics: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// deleteConfigSubscribers deletes the config-managed subscribers of a topic not in keep,
+// which is keyed by provider ID and recipient separated by a NUL byte
+func deleteConfigSubscribers(tx *sql.Tx, topic string, keep map[string]bool) error {
+	rows, err := tx.Query(`SELECT id, provider_id, recipient FROM topic_subscribers WHERE topic = ? AND source = ?`,
+		topic, TopicSourceConfig)
+	if err != nil {
+		return err
+	}
+
+	var stale []int64
+	for rows.Next() {
+		var (
+			id                    int64
+			providerID, recipient string
+		)
+		if err := rows.Scan(&id, &providerID, &recipient); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		if !keep[providerID+"\x00"+recipient] {
+			stale = append(stale, id)
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	for _, id := range stale {
+		if _, err := tx.Exec(`DELETE FROM topic_subscribers WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
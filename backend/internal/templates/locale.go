@@ -0,0 +1,195 @@
+package templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// DefaultLocale is the last locale tried when selecting a template variant,
+// and the locale the formatting helpers use when none is given
+const DefaultLocale = "en"
+
+// Format is the locale and timezone the formatting helpers render with
+type Format struct {
+	Locale   string         // BCP 47 tag, e.g. "zh-TW"; DefaultLocale when empty
+	Location *time.Location // UTC when nil
+}
+
+// dateLayouts are the date and time layouts of a locale, keyed by language or language-region
+type dateLayouts struct {
+	date, time string
+}
+
+var localeLayouts = map[string]dateLayouts{
+	"en":    {"Jan 2, 2006", "3:04 PM"},
+	"en-GB": {"2 Jan 2006", "15:04"},
+	"en-AU": {"2 Jan 2006", "15:04"},
+	"de":    {"02.01.2006", "15:04"},
+	"fr":    {"02/01/2006", "15:04"},
+	"es":    {"02/01/2006", "15:04"},
+	"it":    {"02/01/2006", "15:04"},
+	"pt":    {"02/01/2006", "15:04"},
+	"nl":    {"02-01-2006", "15:04"},
+	"ja":    {"2006年1月2日", "15:04"},
+	"zh":    {"2006年1月2日", "15:04"},
+	"ko":    {"2006년 1월 2일", "15:04"},
+}
+
+// defaultLayouts are used for locales without an entry in localeLayouts
+var defaultLayouts = dateLayouts{"2006-01-02", "15:04"}
+
+// localePattern matches well-formed locale tags: a language and optional script, region and variant
+// subtags. language.Parse alone is more lenient and accepts "_" separators and single-letter subtags.
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// ParseLocale validates a BCP 47 locale tag and returns it in canonical form, e.g. "zh-tw" becomes "zh-TW"
+func ParseLocale(locale string) (string, error) {
+	if !localePattern.MatchString(locale) {
+		return "", fmt.Errorf("invalid locale %q", locale)
+	}
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return "", fmt.Errorf("invalid locale %q", locale)
+	}
+	return tag.String(), nil
+}
+
+// Fallbacks returns the variants tried for a locale, most specific first: the locale,
+// its parents ("zh-Hant-TW", "zh-Hant", "zh"), the unlocalized template ("") and DefaultLocale
+func Fallbacks(locale string) []string {
+	var chain []string
+	for locale != "" {
+		chain = append(chain, locale)
+		i := strings.LastIndex(locale, "-")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	chain = append(chain, "")
+	if !slices.Contains(chain, DefaultLocale) {
+		chain = append(chain, DefaultLocale)
+	}
+	return chain
+}
+
+// funcs returns the formatting helpers for the format's locale and timezone
+func (f Format) funcs() map[string]interface{} {
+	locale := f.Locale
+	if locale == "" {
+		locale = DefaultLocale
+	}
+	tag := language.Make(locale)
+	printer := message.NewPrinter(tag)
+	layouts := layoutsFor(tag)
+	loc := f.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	formatTime := func(layout string) func(interface{}) (string, error) {
+		return func(value interface{}) (string, error) {
+			t, ok, err := toTime(value)
+			if err != nil || !ok {
+				return "", err
+			}
+			return t.In(loc).Format(layout), nil
+		}
+	}
+
+	return map[string]interface{}{
+		// formatNumber groups digits the locale's way; decimals fixes the number of fraction digits
+		"formatNumber": func(value interface{}, decimals ...int) (string, error) {
+			n, ok, err := toNumber(value)
+			if err != nil || !ok {
+				return "", err
+			}
+			var opts []number.Option
+			if len(decimals) > 0 {
+				opts = append(opts, number.MinFractionDigits(decimals[0]), number.MaxFractionDigits(decimals[0]))
+			}
+			return printer.Sprint(number.Decimal(n, opts...)), nil
+		},
+		"formatDate":     formatTime(layouts.date),
+		"formatTime":     formatTime(layouts.time),
+		"formatDateTime": formatTime(layouts.date + " " + layouts.time + " MST"),
+	}
+}
+
+func layoutsFor(tag language.Tag) dateLayouts {
+	base, _ := tag.Base()
+	if region, confidence := tag.Region(); confidence == language.Exact {
+		if layouts, ok := localeLayouts[base.String()+"-"+region.String()]; ok {
+			return layouts
+		}
+	}
+	if layouts, ok := localeLayouts[base.String()]; ok {
+		return layouts
+	}
+	return defaultLayouts
+}
+
+// toTime reads a timestamp: an RFC 3339 string or Unix seconds. Empty values report ok false.
+func toTime(value interface{}) (time.Time, bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return time.Time{}, false, nil
+	case time.Time:
+		return v, true, nil
+	case string:
+		if v == "" {
+			return time.Time{}, false, nil
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid timestamp %q, want RFC 3339", v)
+		}
+		return t, true, nil
+	}
+
+	seconds, ok, err := toNumber(value)
+	if err != nil || !ok {
+		return time.Time{}, false, fmt.Errorf("invalid timestamp %v", value)
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), true, nil
+}
+
+// toNumber reads a number. Empty values report ok false.
+func toNumber(value interface{}) (float64, bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, false, nil
+	case float64:
+		return v, true, nil
+	case float32:
+		return float64(v), true, nil
+	case int:
+		return float64(v), true, nil
+	case int64:
+		return float64(v), true, nil
+	case int32:
+		return float64(v), true, nil
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil, err
+	case string:
+		if v == "" {
+			return 0, false, nil
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid number %q", v)
+		}
+		return n, true, nil
+	}
+	return 0, false, fmt.Errorf("invalid number %v", value)
+}
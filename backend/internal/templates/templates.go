@@ -0,0 +1,614 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+// Dir is the templates directory inside the config directory
+const Dir = "templates"
+
+// Variable types
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeList    = "list"
+	TypeObject  = "object"
+	TypeTime    = "time" // RFC 3339 string or Unix seconds, for the formatting helpers
+)
+
+// namePattern matches valid template names, which follow the provider ID format
+var namePattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// validTypes are the variable types a template can declare
+var validTypes = []string{TypeString, TypeNumber, TypeBoolean, TypeList, TypeObject, TypeTime}
+
+// telegramTags are the tags Telegram's HTML parse mode supports
+var telegramTags = []string{
+	"a", "b", "blockquote", "code", "del", "em", "i", "ins", "pre",
+	"s", "span", "strike", "strong", "tg-emoji", "tg-spoiler", "u",
+}
+
+// tagPattern matches the name of an opening or closing tag
+var tagPattern = regexp.MustCompile(`</?([a-zA-Z][a-zA-Z0-9-]*)`)
+
+// funcs are the helper functions available in every template, next to the
+// formatting helpers of Format, which are bound to a locale when rendering
+var funcs = map[string]interface{}{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join": func(items []interface{}, sep string) string {
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, sep)
+	},
+	"default": func(fallback, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return fallback
+		}
+		return value
+	},
+}
+
+// Definition is the content of a template file
+type Definition struct {
+	Description string              `json:"description,omitempty"`
+	Variables   map[string]Variable `json:"variables,omitempty"`
+	Subject     string              `json:"subject,omitempty"`  // text/template
+	Text        string              `json:"text"`               // text/template, delivered by every provider
+	HTML        string              `json:"html,omitempty"`     // html/template, sent by email as an HTML alternative
+	Telegram    string              `json:"telegram,omitempty"` // html/template restricted to Telegram's HTML tags
+}
+
+// Variable declares a value templates are rendered with
+type Variable struct {
+	Type        string `json:"type"`
+	Required    bool   `json:"required,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Template is a parsed message template, or one locale's variant of it
+type Template struct {
+	Name   string `json:"name"`
+	Locale string `json:"locale,omitempty"` // Empty for the unlocalized template
+	Definition
+
+	subject  *texttemplate.Template
+	text     *texttemplate.Template
+	html     *htmltemplate.Template
+	telegram *htmltemplate.Template
+}
+
+// Rendered is a template rendered for one provider type
+type Rendered struct {
+	Subject string `json:"subject,omitempty"`
+	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"` // Markup in the provider's HTML dialect, if the template has one
+}
+
+// VariableError reports a problem with the data for one variable
+type VariableError struct {
+	Variable string `json:"variable"`
+	Message  string `json:"message"`
+}
+
+// Parse reads and validates a template file. Syntax errors and references to
+// undeclared variables are reported here rather than when the template is used.
+func Parse(name string, data []byte) (*Template, error) {
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("name: template names must contain only lowercase letters, numbers, and hyphens (got %q)", name)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	t := &Template{Name: name}
+	if err := decoder.Decode(&t.Definition); err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	if t.Text == "" {
+		return nil, errors.New("text: text is required")
+	}
+	for variable, v := range t.Variables {
+		if !slices.Contains(validTypes, v.Type) {
+			return nil, fmt.Errorf("variables.%s.type: type must be one of %s (got %q)", variable, strings.Join(validTypes, ", "), v.Type)
+		}
+	}
+
+	var err error
+	if t.subject, err = t.parseText("subject", t.Subject); err != nil {
+		return nil, err
+	}
+	if t.text, err = t.parseText("text", t.Text); err != nil {
+		return nil, err
+	}
+	if t.html, err = t.parseHTML("html", t.HTML); err != nil {
+		return nil, err
+	}
+	if t.telegram, err = t.parseHTML("telegram", t.Telegram); err != nil {
+		return nil, err
+	}
+	if t.telegram != nil {
+		if err := checkTelegramTags(t.telegram.Tree); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+func (t *Template) parseText(field, source string) (*texttemplate.Template, error) {
+	if source == "" {
+		return nil, nil
+	}
+	tmpl, err := texttemplate.New(field).Funcs(funcs).Funcs(Format{}.funcs()).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", field, err)
+	}
+	if err := t.checkReferences(field, tmpl.Tree); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func (t *Template) parseHTML(field, source string) (*htmltemplate.Template, error) {
+	if source == "" {
+		return nil, nil
+	}
+	tmpl, err := htmltemplate.New(field).Funcs(funcs).Funcs(Format{}.funcs()).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", field, err)
+	}
+	if err := t.checkReferences(field, tmpl.Tree); err != nil {
+		return nil, err
+	}
+
+	// html/template escapes on first use; surface escaping errors now. The trial
+	// runs on a clone because executed templates cannot be cloned for rendering.
+	trial, err := tmpl.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", field, err)
+	}
+	var escapeErr *htmltemplate.Error
+	if err := trial.Execute(io.Discard, t.withDefaults(nil)); errors.As(err, &escapeErr) {
+		return nil, fmt.Errorf("%s: %w", field, err)
+	}
+	return tmpl, nil
+}
+
+// checkReferences rejects templates that use variables they do not declare
+func (t *Template) checkReferences(field string, tree *parse.Tree) error {
+	var undeclared []string
+	walkNode(tree.Root, true, func(variable string) {
+		if _, ok := t.Variables[variable]; !ok && !slices.Contains(undeclared, variable) {
+			undeclared = append(undeclared, variable)
+		}
+	})
+	if len(undeclared) > 0 {
+		return fmt.Errorf("%s: undeclared variables: %s", field, strings.Join(undeclared, ", "))
+	}
+	return nil
+}
+
+// checkTelegramTags rejects markup Telegram cannot parse. Only the template's own markup
+// is checked: html/template escapes variable values.
+func checkTelegramTags(tree *parse.Tree) error {
+	var unsupported []string
+	walkText(tree.Root, func(text string) {
+		for _, match := range tagPattern.FindAllStringSubmatch(text, -1) {
+			tag := strings.ToLower(match[1])
+			if !slices.Contains(telegramTags, tag) && !slices.Contains(unsupported, tag) {
+				unsupported = append(unsupported, tag)
+			}
+		}
+	})
+	if len(unsupported) > 0 {
+		return fmt.Errorf("telegram: unsupported tags: %s (Telegram allows %s)", strings.Join(unsupported, ", "), strings.Join(telegramTags, ", "))
+	}
+	return nil
+}
+
+// walkText reports the literal text of a template, including inside if, range and with blocks
+func walkText(node parse.Node, visit func(string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkText(child, visit)
+		}
+	case *parse.TextNode:
+		visit(string(n.Text))
+	case *parse.IfNode:
+		walkText(n.List, visit)
+		walkText(n.ElseList, visit)
+	case *parse.RangeNode:
+		walkText(n.List, visit)
+		walkText(n.ElseList, visit)
+	case *parse.WithNode:
+		walkText(n.List, visit)
+		walkText(n.ElseList, visit)
+	}
+}
+
+// walkNode reports the top-level variables a template refers to. root is false
+// inside range and with blocks, where dot no longer refers to the data.
+func walkNode(node parse.Node, root bool, visit func(string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkNode(child, root, visit)
+		}
+	case *parse.ActionNode:
+		walkPipe(n.Pipe, root, visit)
+	case *parse.IfNode:
+		walkPipe(n.Pipe, root, visit)
+		walkNode(n.List, root, visit)
+		walkNode(n.ElseList, root, visit)
+	case *parse.RangeNode:
+		walkPipe(n.Pipe, root, visit)
+		walkNode(n.List, false, visit)
+		walkNode(n.ElseList, root, visit)
+	case *parse.WithNode:
+		walkPipe(n.Pipe, root, visit)
+		walkNode(n.List, false, visit)
+		walkNode(n.ElseList, root, visit)
+	case *parse.TemplateNode:
+		walkPipe(n.Pipe, root, visit)
+	}
+}
+
+func walkPipe(pipe *parse.PipeNode, root bool, visit func(string)) {
+	if pipe == nil {
+		return
+	}
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			switch a := arg.(type) {
+			case *parse.FieldNode:
+				if root {
+					visit(a.Ident[0])
+				}
+			case *parse.VariableNode:
+				if len(a.Ident) > 1 && a.Ident[0] == "$" {
+					visit(a.Ident[1])
+				}
+			case *parse.ChainNode:
+				if p, ok := a.Node.(*parse.PipeNode); ok {
+					walkPipe(p, root, visit)
+				}
+			case *parse.PipeNode:
+				walkPipe(a, root, visit)
+			}
+		}
+	}
+}
+
+// CheckData validates data against the declared variables: required variables
+// must be present, values must have the declared type and undeclared keys are rejected.
+// Errors are sorted by variable name.
+func (t *Template) CheckData(data map[string]interface{}) []VariableError {
+	var problems []VariableError
+
+	for name, v := range t.Variables {
+		value, ok := data[name]
+		if !ok || value == nil {
+			if v.Required {
+				problems = append(problems, VariableError{Variable: name, Message: "required variable is missing"})
+			}
+			continue
+		}
+		if !hasType(value, v.Type) {
+			problems = append(problems, VariableError{Variable: name, Message: fmt.Sprintf("must be a %s (got %T)", v.Type, value)})
+		}
+	}
+	for name := range data {
+		if _, ok := t.Variables[name]; !ok {
+			problems = append(problems, VariableError{Variable: name, Message: "variable is not declared by the template"})
+		}
+	}
+
+	slices.SortFunc(problems, func(a, b VariableError) int { return strings.Compare(a.Variable, b.Variable) })
+	return problems
+}
+
+func hasType(value interface{}, typ string) bool {
+	if typ == TypeTime {
+		_, ok, err := toTime(value)
+		return ok && err == nil
+	}
+	switch value.(type) {
+	case string:
+		return typ == TypeString
+	case float64, float32, int, int64, int32, json.Number:
+		return typ == TypeNumber
+	case bool:
+		return typ == TypeBoolean
+	case []interface{}:
+		return typ == TypeList
+	case map[string]interface{}:
+		return typ == TypeObject
+	}
+	return false
+}
+
+// withDefaults returns data with a zero value for every declared variable that is missing
+func (t *Template) withDefaults(data map[string]interface{}) map[string]interface{} {
+	values := make(map[string]interface{}, len(t.Variables))
+	for name, v := range t.Variables {
+		switch v.Type {
+		case TypeString, TypeTime:
+			values[name] = ""
+		case TypeNumber:
+			values[name] = float64(0)
+		case TypeBoolean:
+			values[name] = false
+		case TypeList:
+			values[name] = []interface{}{}
+		case TypeObject:
+			values[name] = map[string]interface{}{}
+		}
+	}
+	for name, value := range data {
+		if value != nil {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// Render renders the template for a provider type, formatting for the template's locale in UTC.
+// See RenderWith.
+func (t *Template) Render(providerType string, data map[string]interface{}) (*Rendered, error) {
+	return t.RenderWith(providerType, data, Format{Locale: t.Locale})
+}
+
+// RenderWith renders the template for a provider type, formatting numbers and
+// timestamps for f. Email gets the html part as an HTML alternative and Telegram
+// the telegram part; every other type, including groups and failover chains whose
+// delivering provider is not known yet, gets text only. Check data with CheckData first.
+func (t *Template) RenderWith(providerType string, data map[string]interface{}, f Format) (*Rendered, error) {
+	values := t.withDefaults(data)
+	helpers := f.funcs()
+	rendered := &Rendered{}
+
+	var err error
+	if rendered.Subject, err = executeText(t.subject, values, helpers); err != nil {
+		return nil, err
+	}
+	if rendered.Text, err = executeText(t.text, values, helpers); err != nil {
+		return nil, err
+	}
+
+	var markup *htmltemplate.Template
+	switch providerType {
+	case "email":
+		markup = t.html
+	case "telegram":
+		markup = t.telegram
+	}
+	if rendered.HTML, err = executeHTML(markup, values, helpers); err != nil {
+		return nil, err
+	}
+
+	return rendered, nil
+}
+
+// executeText runs a clone of tmpl, so concurrent renders can bind their own helpers
+func executeText(tmpl *texttemplate.Template, values, helpers map[string]interface{}) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := clone.Funcs(helpers).Execute(&out, values); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func executeHTML(tmpl *htmltemplate.Template, values, helpers map[string]interface{}) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := clone.Funcs(helpers).Execute(&out, values); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// Store holds the loaded templates and their locale variants. It is safe for concurrent use.
+type Store struct {
+	mu        sync.RWMutex
+	templates map[string]map[string]*Template // Name -> locale ("" when unlocalized) -> template
+}
+
+// NewStore creates an empty template store
+func NewStore() *Store {
+	return &Store{templates: make(map[string]map[string]*Template)}
+}
+
+// LoadDir replaces the templates with the *.json files in dir. Invalid files are
+// skipped and returned as errors; a missing directory leaves the store empty.
+func (s *Store) LoadDir(dir string) []error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return []error{fmt.Errorf("failed to list templates: %w", err)}
+	}
+
+	loaded := make(map[string]map[string]*Template, len(files))
+	var errs []error
+	for _, file := range files {
+		t, err := parseFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if loaded[t.Name] == nil {
+			loaded[t.Name] = make(map[string]*Template)
+		}
+		loaded[t.Name][t.Locale] = t
+	}
+
+	s.mu.Lock()
+	s.templates = loaded
+	s.mu.Unlock()
+	return errs
+}
+
+// LoadFile adds or replaces the template in a file.
+// An invalid file is rejected and the previous version is kept.
+func (s *Store) LoadFile(path string) (*Template, error) {
+	t, err := parseFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if s.templates[t.Name] == nil {
+		s.templates[t.Name] = make(map[string]*Template)
+	}
+	s.templates[t.Name][t.Locale] = t
+	s.mu.Unlock()
+	return t, nil
+}
+
+// Remove removes one variant of a template; locale is empty for the unlocalized template
+func (s *Store) Remove(name, locale string) {
+	s.mu.Lock()
+	delete(s.templates[name], locale)
+	if len(s.templates[name]) == 0 {
+		delete(s.templates, name)
+	}
+	s.mu.Unlock()
+}
+
+// Get returns the variant of a template used when no locale is requested
+func (s *Store) Get(name string) (*Template, bool) {
+	return s.Resolve(name, "")
+}
+
+// Resolve returns the variant of a template for a locale, following the chain
+// from Fallbacks. It reports false if the template does not exist or none of
+// the chain's variants do.
+func (s *Store) Resolve(name, locale string) (*Template, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	variants, ok := s.templates[name]
+	if !ok {
+		return nil, false
+	}
+	for _, candidate := range Fallbacks(locale) {
+		if t, ok := variants[candidate]; ok {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// Locales returns the locales a template has variants for, sorted, with ""
+// for the unlocalized template. It is nil if the template does not exist.
+func (s *Store) Locales(name string) []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var locales []string
+	for locale := range s.templates[name] {
+		locales = append(locales, locale)
+	}
+	slices.Sort(locales)
+	return locales
+}
+
+// List returns all templates and variants ordered by name and locale
+func (s *Store) List() []*Template {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	var list []*Template
+	for _, variants := range s.templates {
+		for _, t := range variants {
+			list = append(list, t)
+		}
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(list, func(a, b *Template) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Locale, b.Locale)
+	})
+	return list
+}
+
+// NameFromPath returns the name and locale of the template stored in a file:
+// "alert.json" holds the unlocalized alert template and "alert.zh-TW.json" its zh-TW variant.
+// Valid locales are returned in canonical form.
+func NameFromPath(path string) (name, locale string) {
+	name = strings.TrimSuffix(filepath.Base(path), ".json")
+	i := strings.Index(name, ".")
+	if i < 0 {
+		return name, ""
+	}
+	name, locale = name[:i], name[i+1:]
+	if canonical, err := ParseLocale(locale); err == nil {
+		locale = canonical
+	}
+	return name, locale
+}
+
+func parseFile(path string) (*Template, error) {
+	name, locale := NameFromPath(path)
+	label := strings.TrimSuffix(filepath.Base(path), ".json")
+	if locale != "" {
+		if _, err := ParseLocale(locale); err != nil {
+			return nil, fmt.Errorf("template %s: %w", label, err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: failed to read file: %w", label, err)
+	}
+	t, err := Parse(name, data)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", label, err)
+	}
+	t.Locale = locale
+	return t, nil
+}
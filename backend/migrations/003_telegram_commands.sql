@@ -0,0 +1,16 @@
+-- Migration: notification acknowledgement (Telegram inbound commands)
+-- Description: Track the API notification ID and acknowledgement state
+-- Note: InitDB applies missing columns automatically on startup; this file
+--       documents the change for databases managed outside the server.
+
+ALTER TABLE notification_logs ADD COLUMN notification_id TEXT;
+ALTER TABLE notification_logs ADD COLUMN acknowledged_at DATETIME;
+ALTER TABLE notification_logs ADD COLUMN acknowledged_by TEXT;
+
+-- Lookups by the notification ID returned from POST /api/v1/notifications
+CREATE INDEX IF NOT EXISTS idx_notification_id
+ON notification_logs(notification_id);
+
+-- ROLLBACK: SQLite does not support DROP COLUMN on older versions; see
+-- 002_enhanced_deployment.sql for the table rebuild procedure.
+-- DROP INDEX IF EXISTS idx_notification_id;
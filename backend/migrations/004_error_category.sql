@@ -0,0 +1,15 @@
+-- Migration: error category for failed deliveries
+-- Description: Store the typed provider error category (transient, rate_limited,
+--              recipient, auth, permanent, unknown) so history can be filtered by it
+-- Note: InitDB applies missing columns automatically on startup; this file
+--       documents the change for databases managed outside the server.
+
+ALTER TABLE notification_logs ADD COLUMN error_category TEXT;
+
+-- Filtering history by category, e.g. all auth failures last week
+CREATE INDEX IF NOT EXISTS idx_error_category_created
+ON notification_logs(error_category, created_at DESC);
+
+-- ROLLBACK: SQLite does not support DROP COLUMN on older versions; see
+-- 002_enhanced_deployment.sql for the table rebuild procedure.
+-- DROP INDEX IF EXISTS idx_error_category_created;
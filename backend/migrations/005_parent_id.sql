@@ -0,0 +1,16 @@
+-- Migration: parent ID for group fan-out sends
+-- Description: A send to a provider group logs one parent row (status 'fanned_out')
+--              and one row per member delivery whose parent_id is the parent's
+--              notification_id
+-- Note: InitDB applies missing columns automatically on startup; this file
+--       documents the change for databases managed outside the server.
+
+ALTER TABLE notification_logs ADD COLUMN parent_id TEXT;
+
+-- Listing the member deliveries of a group send
+CREATE INDEX IF NOT EXISTS idx_parent_id
+ON notification_logs(parent_id);
+
+-- ROLLBACK: SQLite does not support DROP COLUMN on older versions; see
+-- 002_enhanced_deployment.sql for the table rebuild procedure.
+-- DROP INDEX IF EXISTS idx_parent_id;
@@ -0,0 +1,11 @@
+-- Migration: delivering hop of failover chains
+-- Description: A send through a failover chain records the ID of the hop
+--              provider that delivered it; NULL for other sends and for chains
+--              where every hop failed
+-- Note: InitDB applies missing columns automatically on startup; this file
+--       documents the change for databases managed outside the server.
+
+ALTER TABLE notification_logs ADD COLUMN delivered_via TEXT;
+
+-- ROLLBACK: SQLite does not support DROP COLUMN on older versions; see
+-- 002_enhanced_deployment.sql for the table rebuild procedure.
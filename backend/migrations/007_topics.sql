@@ -0,0 +1,28 @@
+-- Migration: publish/subscribe topics
+-- Description: Topics group (provider, recipient) subscribers under a name that
+--              applications publish to. source is 'config' for topics and
+--              subscribers defined in topics.json and 'api' for those managed
+--              through the REST API
+-- Note: InitDB creates missing tables automatically on startup; this file
+--       documents the change for databases managed outside the server.
+
+CREATE TABLE IF NOT EXISTS topics (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL DEFAULT 'api',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS topic_subscribers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    topic TEXT NOT NULL REFERENCES topics(name) ON DELETE CASCADE,
+    provider_id TEXT NOT NULL,
+    recipient TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL DEFAULT 'api',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (topic, provider_id, recipient)
+);
+
+-- ROLLBACK:
+-- DROP TABLE IF EXISTS topic_subscribers;
+-- DROP TABLE IF EXISTS topics;
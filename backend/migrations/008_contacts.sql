@@ -0,0 +1,30 @@
+-- Migration: contact directory
+-- Description: Contacts have one address per provider (email address, Telegram
+--              chat ID, ...) and preferences: the provider to use first, quiet
+--              hours (HH:MM, in the contact's timezone) and timezone. position
+--              keeps identities in the order they were given
+-- Note: InitDB creates missing tables automatically on startup; this file
+--       documents the change for databases managed outside the server.
+
+CREATE TABLE IF NOT EXISTS contacts (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT '',
+    preferred_provider TEXT NOT NULL DEFAULT '',
+    timezone TEXT NOT NULL DEFAULT '',
+    quiet_hours_start TEXT NOT NULL DEFAULT '',
+    quiet_hours_end TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS contact_identities (
+    contact_id TEXT NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    provider_id TEXT NOT NULL,
+    address TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (contact_id, provider_id)
+);
+
+-- ROLLBACK:
+-- DROP TABLE IF EXISTS contact_identities;
+-- DROP TABLE IF EXISTS contacts;
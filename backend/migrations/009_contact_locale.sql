@@ -0,0 +1,10 @@
+-- Migration: contact locale
+-- Description: A contact's locale selects the variant of localized templates sent
+--              to it and how template helpers format numbers and dates
+-- Note: InitDB applies missing columns automatically on startup; this file
+--       documents the change for databases managed outside the server.
+
+ALTER TABLE contacts ADD COLUMN locale TEXT NOT NULL DEFAULT '';
+
+-- ROLLBACK: SQLite does not support DROP COLUMN on older versions; see
+-- 002_enhanced_deployment.sql for the table rebuild procedure.
@@ -0,0 +1,19 @@
+-- Migration: scheduled notifications
+-- Description: Notifications sent with send_at or delay_seconds are held here
+--              until they are due. send_at is UTC RFC 3339 text; payload is the
+--              JSON notification (and fan-out members). Rows are removed when
+--              the scheduler dispatches them or when they are cancelled
+-- Note: InitDB creates missing tables automatically on startup; this file
+--       documents the change for databases managed outside the server.
+
+CREATE TABLE IF NOT EXISTS scheduled_notifications (
+    id TEXT PRIMARY KEY,
+    provider_id TEXT NOT NULL,
+    provider_type TEXT NOT NULL DEFAULT '',
+    send_at TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_send_at
+ON scheduled_notifications(send_at);
@@ -0,0 +1,32 @@
+-- Migration: recurring jobs
+-- Description: Jobs send a notification on a cron schedule. notification is
+--              the JSON request sent on every run; next_run_at and last_run_at
+--              are UTC RFC 3339 text (next_run_at is NULL while paused).
+--              Source 'config' marks jobs defined in jobs.json. History rows
+--              sent by a job record its ID in notification_logs.job_id
+-- Note: InitDB creates missing tables and columns automatically on startup;
+--       this file documents the change for databases managed outside the server.
+
+ALTER TABLE notification_logs ADD COLUMN job_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_job_id_created
+    ON notification_logs(job_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id TEXT PRIMARY KEY,
+    schedule TEXT NOT NULL,
+    timezone TEXT NOT NULL DEFAULT '',
+    jitter_seconds INTEGER NOT NULL DEFAULT 0,
+    notification TEXT NOT NULL,
+    paused INTEGER NOT NULL DEFAULT 0,
+    source TEXT NOT NULL DEFAULT 'api',
+    next_run_at TEXT,
+    last_run_at TEXT,
+    last_notification_id TEXT NOT NULL DEFAULT '',
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_next_run
+ON jobs(next_run_at);
@@ -0,0 +1,38 @@
+-- Migration: digest mode
+-- Description: Providers and routing rules in digest mode hold notifications
+--              for a recipient in digests until flush_at (UTC RFC 3339 text),
+--              then send one summary whose notification ID is the digest ID.
+--              There is at most one open digest per provider and recipient.
+--              Held notifications are recorded in history with status
+--              'digested' and the digest in notification_logs.digest_id
+-- Note: InitDB creates missing tables and columns automatically on startup;
+--       this file documents the change for databases managed outside the server.
+
+ALTER TABLE notification_logs ADD COLUMN digest_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_digest_id
+    ON notification_logs(digest_id);
+
+CREATE TABLE IF NOT EXISTS digests (
+    id TEXT PRIMARY KEY,
+    provider_id TEXT NOT NULL,
+    provider_type TEXT NOT NULL DEFAULT '',
+    recipient TEXT NOT NULL DEFAULT '',
+    template TEXT NOT NULL DEFAULT '',
+    flush_at TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider_id, recipient)
+);
+
+CREATE INDEX IF NOT EXISTS idx_digests_flush_at
+ON digests(flush_at);
+
+CREATE TABLE IF NOT EXISTS digest_items (
+    notification_id TEXT PRIMARY KEY,
+    digest_id TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_digest_items_digest
+ON digest_items(digest_id);
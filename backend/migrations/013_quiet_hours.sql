@@ -0,0 +1,12 @@
+-- Migration: quiet hours timezone
+-- Description: A contact's quiet hours may name their own timezone instead of
+--              the contact's. Notifications held for quiet hours wait in
+--              scheduled_notifications until the window ends and are recorded
+--              in history with status 'deferred'
+-- Note: InitDB applies missing columns automatically on startup; this file
+--       documents the change for databases managed outside the server.
+
+ALTER TABLE contacts ADD COLUMN quiet_hours_timezone TEXT NOT NULL DEFAULT '';
+
+-- ROLLBACK: SQLite does not support DROP COLUMN on older versions; see
+-- 002_enhanced_deployment.sql for the table rebuild procedure.
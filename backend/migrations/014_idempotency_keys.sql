@@ -0,0 +1,25 @@
+-- Migration: idempotency keys
+-- Description: POST /api/v1/notifications accepts an Idempotency-Key header or
+--              idempotency_key field. The key, scoped by the caller's X-API-Key,
+--              is stored with a hash of the request and the response, which is
+--              replayed when the request is retried. Rows expire at expires_at
+--              (UTC RFC 3339 text) and are removed by the retention job
+-- Note: InitDB creates missing tables automatically on startup; this file
+--       documents the change for databases managed outside the server.
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope TEXT NOT NULL DEFAULT '',
+    key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    response TEXT NOT NULL DEFAULT '',
+    expires_at TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at
+ON idempotency_keys(expires_at);
+
+-- ROLLBACK:
+-- DROP TABLE IF EXISTS idempotency_keys;
@@ -0,0 +1,30 @@
+-- Migration: deduplication windows
+-- Description: Providers with a dedup block send the first notification with a
+--              fingerprint (provider, recipient, subject and selected metadata)
+--              and suppress the rest until expires_at (UTC RFC 3339 text).
+--              Suppressed notifications are recorded in history with status
+--              'suppressed'; closed windows may send a "repeated N times" follow-up
+-- Note: InitDB creates missing tables automatically on startup; this file
+--       documents the change for databases managed outside the server.
+
+CREATE TABLE IF NOT EXISTS dedup_windows (
+    id TEXT PRIMARY KEY,
+    fingerprint TEXT NOT NULL,
+    provider_id TEXT NOT NULL,
+    provider_type TEXT NOT NULL DEFAULT '',
+    recipient TEXT NOT NULL DEFAULT '',
+    suppressed INTEGER NOT NULL DEFAULT 0,
+    notify_repeats INTEGER NOT NULL DEFAULT 0,
+    expires_at TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_dedup_windows_fingerprint
+ON dedup_windows(fingerprint, expires_at);
+
+CREATE INDEX IF NOT EXISTS idx_dedup_windows_expires_at
+ON dedup_windows(expires_at);
+
+-- ROLLBACK:
+-- DROP TABLE IF EXISTS dedup_windows;
@@ -0,0 +1,12 @@
+-- Migration: notification expiry
+-- Description: Notifications may set expires_at or ttl_seconds. One that has
+--              not been delivered by then is recorded in history with status
+--              'expired' instead of being sent late. expires_at (UTC RFC 3339
+--              text) is stored on every history row of the notification
+-- Note: InitDB applies missing columns automatically on startup; this file
+--       documents the change for databases managed outside the server.
+
+ALTER TABLE notification_logs ADD COLUMN expires_at TEXT;
+
+-- ROLLBACK: SQLite does not support DROP COLUMN on older versions; see
+-- 002_enhanced_deployment.sql for the table rebuild procedure.
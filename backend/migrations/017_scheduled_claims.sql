@@ -0,0 +1,14 @@
+-- Migration: scheduled notification claims
+-- Description: The scheduler claims due notifications by setting claimed_at
+--              (fixed-width UTC text, like send_at) instead of deleting them, and
+--              deletes a row only once its delivery outcome is recorded. Rows
+--              whose claim is older than the lease (2 minutes, renewed while the
+--              delivery is in flight) are claimed again,
+--              so notifications claimed before a crash are still sent.
+-- Note: InitDB applies missing columns automatically on startup; this file
+--       documents the change for databases managed outside the server.
+
+ALTER TABLE scheduled_notifications ADD COLUMN claimed_at TEXT;
+
+-- ROLLBACK: SQLite does not support DROP COLUMN on older versions; see
+-- 002_enhanced_deployment.sql for the table rebuild procedure.
@@ -0,0 +1,27 @@
+-- Migration: digest claims
+-- Description: The digest flusher claims a due digest by moving it from digests to
+--              claimed_digests, so new notifications open a new digest, and deletes
+--              the claimed row and its digest_items only once the summary's outcome
+--              is recorded. Claims are renewed while the summary is being sent;
+--              claims older than the lease (2 minutes) are claimed again, so digests
+--              claimed before a crash are still sent.
+-- Note: InitDB applies the schema automatically on startup; this file documents the
+--       change for databases managed outside the server.
+
+CREATE TABLE IF NOT EXISTS claimed_digests (
+    id TEXT PRIMARY KEY,
+    provider_id TEXT NOT NULL,
+    provider_type TEXT NOT NULL DEFAULT '',
+    recipient TEXT NOT NULL DEFAULT '',
+    template TEXT NOT NULL DEFAULT '',
+    flush_at TEXT NOT NULL,
+    created_at DATETIME,
+    claimed_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_claimed_digests_claimed_at
+ON claimed_digests(claimed_at);
+
+-- ROLLBACK:
+-- DROP INDEX IF EXISTS idx_claimed_digests_claimed_at;
+-- DROP TABLE IF EXISTS claimed_digests;
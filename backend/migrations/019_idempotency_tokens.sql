@@ -0,0 +1,12 @@
+-- Migration: idempotency reservation tokens
+-- Description: Each reservation of an idempotency key stores a random token.
+--              Recording the response and releasing the key match on it, so a
+--              request whose reservation lapsed and was taken over by a retry
+--              cannot complete or delete the retry's reservation.
+-- Note: InitDB applies missing columns automatically on startup; this file
+--       documents the change for databases managed outside the server.
+
+ALTER TABLE idempotency_keys ADD COLUMN token TEXT NOT NULL DEFAULT '';
+
+-- ROLLBACK: SQLite does not support DROP COLUMN on older versions; see
+-- 002_enhanced_deployment.sql for the table rebuild procedure.
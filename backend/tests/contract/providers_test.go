@@ -165,7 +165,7 @@ func TestGetProviderByIDContract(t *testing.T) {
 		}
 
 		// Check required fields
-		requiredFields := []string{"id", "type", "status", "last_updated", "config_checksum"}
+		requiredFields := []string{"id", "type", "status", "last_updated", "config_checksum", "capabilities"}
 		for _, field := range requiredFields {
 			if _, ok := response[field]; !ok {
 				t.Errorf("Response should have '%s' field", field)
@@ -184,6 +184,12 @@ func TestGetProviderByIDContract(t *testing.T) {
 		if response["config_checksum"] != "abc123" {
 			t.Errorf("Expected config_checksum 'abc123', got %v", response["config_checksum"])
 		}
+
+		// Providers without their own descriptor advertise the defaults
+		caps, ok := response["capabilities"].(map[string]interface{})
+		if !ok || caps["max_message_length"] != float64(4096) || caps["supports_subject"] != true {
+			t.Errorf("Expected default capabilities, got %v", response["capabilities"])
+		}
 	})
 
 	// Test invalid provider ID
@@ -0,0 +1,172 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/api"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+func TestContactRecipientResolution(t *testing.T) {
+	db, err := sql.Open("sqlite3", t.TempDir()+"/contacts.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(storage.Schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+
+	var (
+		mu       sync.Mutex
+		sent     []string // "provider:recipient"
+		wg       sync.WaitGroup
+		chatDown atomic.Bool
+	)
+	registry := providers.NewRegistry()
+	for _, id := range []string{"chat", "mail"} {
+		id := id
+		err := registry.Register(&testhelpers.MockProvider{
+			IDFunc:   func() string { return id },
+			TypeFunc: func() string { return "mock" },
+			StatusFunc: func() *providers.ProviderStatus {
+				if id == "chat" && chatDown.Load() {
+					return &providers.ProviderStatus{Status: providers.StatusError}
+				}
+				return &providers.ProviderStatus{Status: providers.StatusActive}
+			},
+			SendFunc: func(_ context.Context, n *providers.Notification) error {
+				defer wg.Done()
+				mu.Lock()
+				sent = append(sent, id+":"+n.Recipient)
+				mu.Unlock()
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to register %s: %v", id, err)
+		}
+	}
+
+	server := httptest.NewServer(api.SetupRouter(registry, nil, storage.NewRepository(db)))
+	defer server.Close()
+
+	request := func(method, path string, payload interface{}) *http.Response {
+		t.Helper()
+		var body bytes.Buffer
+		if payload != nil {
+			_ = json.NewEncoder(&body).Encode(payload)
+		}
+		req, _ := http.NewRequest(method, server.URL+path, &body)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		return resp
+	}
+	expectStatus := func(resp *http.Response, want int) {
+		t.Helper()
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("%s %s: expected status %d, got %d", resp.Request.Method, resp.Request.URL.Path, want, resp.StatusCode)
+		}
+	}
+	send := func(payload map[string]string) api.NotificationResponse {
+		t.Helper()
+		resp := request(http.MethodPost, "/api/v1/notifications", payload)
+		defer resp.Body.Close()
+		var result api.NotificationResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("Failed to decode send response: %v", err)
+		}
+		if resp.StatusCode != http.StatusCreated || result.Contact == nil {
+			t.Fatalf("Unexpected send response %d %+v", resp.StatusCode, result)
+		}
+		return result
+	}
+
+	alice := map[string]interface{}{
+		"id":                 "alice",
+		"name":               "Alice",
+		"preferred_provider": "chat",
+		"identities": []map[string]string{
+			{"provider_id": "mail", "address": "alice@example.com"},
+			{"provider_id": "chat", "address": "12345"},
+		},
+	}
+	invalid := map[string]interface{}{
+		"id":                 "bob",
+		"timezone":           "Mars/Olympus",
+		"preferred_provider": "pager",
+		"identities":         []map[string]string{{"provider_id": "mail", "address": ""}},
+	}
+	expectStatus(request(http.MethodPost, "/api/v1/contacts", invalid), http.StatusBadRequest)
+	expectStatus(request(http.MethodPost, "/api/v1/contacts", alice), http.StatusCreated)
+	expectStatus(request(http.MethodPost, "/api/v1/contacts", alice), http.StatusConflict)
+
+	// The preferred provider is used first
+	wg.Add(1)
+	result := send(map[string]string{"recipient": "contact:alice", "message": "Build finished"})
+	if result.Contact.ProviderID != "chat" || result.Contact.Recipient != "12345" {
+		t.Errorf("Expected preferred provider, got %+v", result.Contact)
+	}
+	wg.Wait()
+
+	// An unhealthy preferred provider falls back to the next identity
+	chatDown.Store(true)
+	wg.Add(1)
+	result = send(map[string]string{"recipient": "contact:alice", "message": "Build finished"})
+	if result.Contact.ProviderID != "mail" || result.Contact.Recipient != "alice@example.com" {
+		t.Errorf("Expected fallback to mail, got %+v", result.Contact)
+	}
+	wg.Wait()
+
+	// An explicit provider selects the contact's identity on it
+	wg.Add(1)
+	send(map[string]string{"provider_id": "chat", "recipient": "contact:alice", "message": "Build finished"})
+	wg.Wait()
+	chatDown.Store(false)
+
+	mu.Lock()
+	if len(sent) != 3 || sent[0] != "chat:12345" || sent[1] != "mail:alice@example.com" || sent[2] != "chat:12345" {
+		t.Errorf("Unexpected deliveries %v", sent)
+	}
+	mu.Unlock()
+
+	expectStatus(request(http.MethodPost, "/api/v1/notifications", map[string]string{"recipient": "contact:bob", "message": "hi"}), http.StatusBadRequest)
+	expectStatus(request(http.MethodPost, "/api/v1/notifications", map[string]string{"provider_id": "pager", "recipient": "contact:alice", "message": "hi"}), http.StatusBadRequest)
+
+	// During quiet hours only high priority notifications are delivered; others are deferred
+	now := time.Now().UTC()
+	alice["quiet_hours"] = map[string]string{
+		"start": now.Add(-time.Hour).Format("15:04"),
+		"end":   now.Add(time.Hour).Format("15:04"),
+	}
+	expectStatus(request(http.MethodPut, "/api/v1/contacts/alice", alice), http.StatusOK)
+
+	result = send(map[string]string{"recipient": "contact:alice", "message": "Weekly report"})
+	if result.Status != storage.StatusDeferred || result.SendAt == nil || !result.SendAt.After(now) {
+		t.Errorf("Expected deferral until the end of quiet hours, got %+v", result)
+	}
+	wg.Add(1)
+	result = send(map[string]string{"recipient": "contact:alice", "message": "Production down", "priority": "high"})
+	if result.Status != "queued" {
+		t.Errorf("Expected high priority to be delivered, got %+v", result)
+	}
+	wg.Wait()
+
+	expectStatus(request(http.MethodDelete, "/api/v1/contacts/alice", nil), http.StatusNoContent)
+	expectStatus(request(http.MethodGet, "/api/v1/contacts/alice", nil), http.StatusNotFound)
+}
@@ -0,0 +1,257 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/api"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/scheduler"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+// dedupProvider is a mock provider that suppresses duplicates
+type dedupProvider struct {
+	*testhelpers.MockProvider
+	dedup *providers.DedupConfig
+}
+
+func (p *dedupProvider) Dedup() *providers.DedupConfig {
+	return p.dedup
+}
+
+// TestDuplicateSuppression sends the same alert repeatedly to a provider with
+// deduplication and checks that only the first is delivered, that the repeats are
+// recorded as suppressed, and that a follow-up with their count is sent when the
+// window ends.
+func TestDuplicateSuppression(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		sent []*providers.Notification
+	)
+	registry := providers.NewRegistry()
+	provider := &dedupProvider{
+		MockProvider: &testhelpers.MockProvider{
+			IDFunc:   func() string { return "alerts" },
+			TypeFunc: func() string { return "mock" },
+			SendFunc: func(_ context.Context, n *providers.Notification) error {
+				mu.Lock()
+				defer mu.Unlock()
+				sent = append(sent, n)
+				return nil
+			},
+		},
+		dedup: &providers.DedupConfig{WindowSeconds: 1, MetadataKeys: []string{"check"}, NotifyRepeats: true},
+	}
+	if err := registry.Register(provider); err != nil {
+		t.Fatalf("Failed to register provider: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", t.TempDir()+"/dedup.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(storage.Schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	logger, err := storage.NewNotificationLogger(db)
+	if err != nil {
+		t.Fatalf("Failed to create notification logger: %v", err)
+	}
+	repo := storage.NewRepository(db)
+	server := httptest.NewServer(api.SetupRouter(registry, logger, repo))
+	defer server.Close()
+
+	post := func(check, message string) api.NotificationResponse {
+		t.Helper()
+		body, _ := json.Marshal(map[string]interface{}{
+			"provider_id": "alerts",
+			"recipient":   "ops",
+			"subject":     "Health check failed",
+			"message":     message,
+			"metadata":    map[string]interface{}{"check": check},
+		})
+		resp, err := http.Post(server.URL+"/api/v1/notifications", "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatalf("Failed to send notification: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d", resp.StatusCode)
+		}
+		var result api.NotificationResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return result
+	}
+
+	first := post("api", "api returned 503")
+	repeats := []api.NotificationResponse{post("api", "api returned 503"), post("api", "api returned 502")}
+	other := post("db", "db unreachable")
+	if first.Status != "queued" || other.Status != "queued" {
+		t.Fatalf("Expected the first alert of each check to be queued, got %+v and %+v", first, other)
+	}
+	for _, repeat := range repeats {
+		if repeat.Status != storage.StatusSuppressed || repeat.DuplicateOf != first.ID {
+			t.Fatalf("Expected a duplicate of %s to be suppressed, got %+v", first.ID, repeat)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/api/v1/dedup-windows")
+	if err != nil {
+		t.Fatalf("Failed to list dedup windows: %v", err)
+	}
+	var list struct {
+		Windows []storage.DedupWindow `json:"windows"`
+		Count   int                   `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode dedup windows: %v", err)
+	}
+	resp.Body.Close()
+	if list.Count != 2 || list.Windows[0].ID != first.ID || list.Windows[0].Suppressed != 2 {
+		t.Fatalf("Expected 2 open windows with 2 suppressed for %s, got %+v", first.ID, list.Windows)
+	}
+
+	sweeper := scheduler.NewDedupSweeper(repo, api.RepeatSender(registry, logger, repo), 20*time.Millisecond, nil)
+	sweeper.Start()
+	defer sweeper.Stop()
+
+	// Only the api window suppressed anything, so it alone gets a follow-up
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(sent) == 3
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	followUp := sent[2]
+	count := len(sent)
+	mu.Unlock()
+	if count != 3 {
+		t.Fatalf("Expected one follow-up, got %d deliveries", count)
+	}
+	if followUp.Recipient != "ops" || followUp.Subject != "Health check failed" ||
+		!strings.HasPrefix(followUp.Message, "Repeated 2 more times since ") || followUp.Metadata["repeat_of"] != first.ID {
+		t.Errorf("Unexpected follow-up %+v", followUp)
+	}
+
+	if windows, err := repo.ListDedupWindows(); err != nil || len(windows) != 0 {
+		t.Errorf("Expected every window to be closed, got %+v, %v", windows, err)
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close notification logger: %v", err)
+	}
+
+	resp, err = http.Get(server.URL + "/api/v1/notifications/history?status=suppressed")
+	if err != nil {
+		t.Fatalf("Failed to query history: %v", err)
+	}
+	var history struct {
+		Notifications []storage.NotificationLogEntry `json:"notifications"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		t.Fatalf("Failed to decode history: %v", err)
+	}
+	resp.Body.Close()
+	if len(history.Notifications) != 2 {
+		t.Fatalf("Expected 2 suppressed notifications in history, got %+v", history.Notifications)
+	}
+	for _, entry := range history.Notifications {
+		if !strings.Contains(entry.ErrorMessage.String, "duplicate of "+first.ID) {
+			t.Errorf("Expected %s to reference %s, got %q", entry.NotificationID.String, first.ID, entry.ErrorMessage.String)
+		}
+	}
+}
+
+// TestDuplicateOfFailedSend checks that a notification whose delivery failed does not
+// keep suppressing its duplicates: its window is removed and the next repeat is sent.
+func TestDuplicateOfFailedSend(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+	registry := providers.NewRegistry()
+	provider := &dedupProvider{
+		MockProvider: &testhelpers.MockProvider{
+			IDFunc:   func() string { return "alerts" },
+			TypeFunc: func() string { return "mock" },
+			SendFunc: func(_ context.Context, n *providers.Notification) error {
+				mu.Lock()
+				defer mu.Unlock()
+				attempts++
+				if attempts == 1 {
+					return errors.New("connection refused")
+				}
+				return nil
+			},
+		},
+		dedup: &providers.DedupConfig{WindowSeconds: 3600},
+	}
+	if err := registry.Register(provider); err != nil {
+		t.Fatalf("Failed to register provider: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", t.TempDir()+"/dedup.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(storage.Schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	repo := storage.NewRepository(db)
+	server := httptest.NewServer(api.SetupRouter(registry, nil, repo))
+	defer server.Close()
+
+	post := func() api.NotificationResponse {
+		t.Helper()
+		body, _ := json.Marshal(map[string]interface{}{"provider_id": "alerts", "recipient": "ops", "message": "api returned 503"})
+		resp, err := http.Post(server.URL+"/api/v1/notifications", "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatalf("Failed to send notification: %v", err)
+		}
+		defer resp.Body.Close()
+		var result api.NotificationResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return result
+	}
+
+	if first := post(); first.Status != "queued" {
+		t.Fatalf("Expected the first alert to be queued, got %+v", first)
+	}
+	waitFor(t, func() bool {
+		windows, err := repo.ListDedupWindows()
+		return err == nil && len(windows) == 0
+	})
+
+	if repeat := post(); repeat.Status != "queued" {
+		t.Fatalf("Expected the repeat of a failed alert to be queued, got %+v", repeat)
+	}
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return attempts == 2
+	})
+
+	// The delivered repeat opens a window that suppresses the next one
+	if duplicate := post(); duplicate.Status != storage.StatusSuppressed {
+		t.Errorf("Expected a duplicate of the delivered alert to be suppressed, got %+v", duplicate)
+	}
+}
@@ -0,0 +1,199 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/api"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/scheduler"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+// digestProvider is a mock provider in digest mode
+type digestProvider struct {
+	*testhelpers.MockProvider
+	digest *providers.DigestConfig
+}
+
+func (p *digestProvider) Digest() *providers.DigestConfig {
+	return p.digest
+}
+
+// TestDigestMode sends notifications to providers in digest mode and checks that they are
+// held, that high priority bypasses the digest, and that one summary is sent per digest
+// when its window ends or it fills up.
+func TestDigestMode(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		sent []*providers.Notification
+	)
+	registry := providers.NewRegistry()
+	for id, digest := range map[string]*providers.DigestConfig{
+		"chat":  {WindowSeconds: 1},
+		"pager": {WindowSeconds: 3600, MaxItems: 2},
+	} {
+		id := id
+		provider := &digestProvider{
+			MockProvider: &testhelpers.MockProvider{
+				IDFunc:   func() string { return id },
+				TypeFunc: func() string { return "mock" },
+				SendFunc: func(_ context.Context, n *providers.Notification) error {
+					mu.Lock()
+					defer mu.Unlock()
+					sent = append(sent, n)
+					return nil
+				},
+			},
+			digest: digest,
+		}
+		if err := registry.Register(provider); err != nil {
+			t.Fatalf("Failed to register %s: %v", id, err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", t.TempDir()+"/digests.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(storage.Schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	logger, err := storage.NewNotificationLogger(db)
+	if err != nil {
+		t.Fatalf("Failed to create notification logger: %v", err)
+	}
+	repo := storage.NewRepository(db)
+	server := httptest.NewServer(api.SetupRouter(registry, logger, repo))
+	defer server.Close()
+
+	post := func(payload map[string]interface{}) api.NotificationResponse {
+		t.Helper()
+		body, _ := json.Marshal(payload)
+		resp, err := http.Post(server.URL+"/api/v1/notifications", "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatalf("Failed to send notification: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d", resp.StatusCode)
+		}
+		var result api.NotificationResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return result
+	}
+
+	first := post(map[string]interface{}{"provider_id": "chat", "recipient": "777", "subject": "Disk", "message": "Disk at 80%"})
+	second := post(map[string]interface{}{"provider_id": "chat", "recipient": "777", "message": "Backup finished"})
+	urgent := post(map[string]interface{}{"provider_id": "chat", "recipient": "777", "message": "Database down", "priority": "high"})
+	if first.Status != storage.StatusDigested || first.DigestID == "" || second.DigestID != first.DigestID {
+		t.Fatalf("Expected both notifications in one digest, got %+v and %+v", first, second)
+	}
+	if urgent.Status != "queued" || urgent.DigestID != "" {
+		t.Fatalf("Expected high priority to bypass the digest, got %+v", urgent)
+	}
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(sent) == 1
+	})
+
+	// The pager digest fills up and is due before its window ends
+	post(map[string]interface{}{"provider_id": "pager", "recipient": "ops", "message": "Queue growing"})
+	pagerDigest := post(map[string]interface{}{"provider_id": "pager", "recipient": "ops", "message": "Queue drained"}).DigestID
+
+	resp, err := http.Get(server.URL + "/api/v1/digests")
+	if err != nil {
+		t.Fatalf("Failed to list digests: %v", err)
+	}
+	var list struct {
+		Digests []storage.Digest `json:"digests"`
+		Count   int              `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode digests: %v", err)
+	}
+	resp.Body.Close()
+	if list.Count != 2 || list.Digests[0].Count != 2 || list.Digests[1].Count != 2 {
+		t.Fatalf("Expected 2 open digests of 2 notifications, got %+v", list.Digests)
+	}
+
+	flusher := scheduler.NewDigestFlusher(repo, api.DigestSender(registry, logger, repo, nil), 20*time.Millisecond, nil)
+	flusher.Start()
+	defer flusher.Stop()
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(sent) >= 2 && sent[1].ID == pagerDigest
+	})
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(sent) == 3
+	})
+
+	mu.Lock()
+	summary := sent[2]
+	mu.Unlock()
+	if summary.ID != first.DigestID || summary.Recipient != "777" || summary.Subject != "Digest: 2 notifications" {
+		t.Fatalf("Unexpected digest summary %+v", summary)
+	}
+	if !strings.Contains(summary.Message, "- Disk: Disk at 80%") || !strings.Contains(summary.Message, "- Backup finished") {
+		t.Errorf("Expected the summary to list both notifications, got %q", summary.Message)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close notification logger: %v", err)
+	}
+
+	resp, err = http.Get(server.URL + "/api/v1/notifications/history?digest_id=" + first.DigestID)
+	if err != nil {
+		t.Fatalf("Failed to query history: %v", err)
+	}
+	var history struct {
+		Notifications []storage.NotificationLogEntry `json:"notifications"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		t.Fatalf("Failed to decode history: %v", err)
+	}
+	resp.Body.Close()
+	if len(history.Notifications) != 2 {
+		t.Fatalf("Expected 2 digested notifications in history, got %+v", history.Notifications)
+	}
+	for _, entry := range history.Notifications {
+		if entry.Status != storage.StatusDigested {
+			t.Errorf("Expected %s to be digested, got %q", entry.NotificationID.String, entry.Status)
+		}
+	}
+
+	var status string
+	if err := db.QueryRow(`SELECT status FROM notification_logs WHERE notification_id = ?`, first.DigestID).Scan(&status); err != nil {
+		t.Fatalf("Failed to query digest summary: %v", err)
+	}
+	if status != storage.StatusSent {
+		t.Errorf("Expected the digest summary to be sent, got %q", status)
+	}
+
+	// Sent digests are removed with their notifications once the outcome is recorded
+	var remaining int
+	if err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM claimed_digests) + (SELECT COUNT(*) FROM digest_items)`).Scan(&remaining); err != nil {
+		t.Fatalf("Failed to count digests: %v", err)
+	}
+	if remaining != 0 {
+		t.Errorf("Expected sent digests to be removed, %d rows remain", remaining)
+	}
+}
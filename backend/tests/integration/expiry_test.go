@@ -0,0 +1,195 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/api"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+// TestNotificationExpiry checks that notifications not delivered before their expiry are
+// recorded as expired instead of being sent, wherever they were held, and that expired
+// notifications are counted in stats and can be filtered in history.
+func TestNotificationExpiry(t *testing.T) {
+	db, err := sql.Open("sqlite3", t.TempDir()+"/expiry.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(storage.Schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	logger, err := storage.NewNotificationLogger(db)
+	if err != nil {
+		t.Fatalf("Failed to create notification logger: %v", err)
+	}
+	repo := storage.NewRepository(db)
+
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	registry := providers.NewRegistry()
+	if err := registry.Register(&testhelpers.MockProvider{
+		IDFunc:   func() string { return "chat" },
+		TypeFunc: func() string { return "mock" },
+		SendFunc: func(_ context.Context, n *providers.Notification) error {
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, n.Message)
+			return nil
+		},
+	}); err != nil {
+		t.Fatalf("Failed to register provider: %v", err)
+	}
+	sentMessages := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), sent...)
+	}
+
+	// Deliveries wait in a queue that has not started, like a backlog during an incident
+	queue := providers.NewDeliveryQueue(0, 0, 1, time.Hour, 0, nil)
+	registry.SetDeliveryQueue(queue)
+
+	server := httptest.NewServer(api.SetupRouter(registry, logger, repo))
+	defer server.Close()
+
+	post := func(payload map[string]interface{}) (int, api.NotificationResponse) {
+		t.Helper()
+		body, _ := json.Marshal(payload)
+		resp, err := http.Post(server.URL+"/api/v1/notifications", "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatalf("Failed to send notification: %v", err)
+		}
+		defer resp.Body.Close()
+		var result api.NotificationResponse
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	// Invalid expiries are rejected
+	now := time.Now()
+	for name, payload := range map[string]map[string]interface{}{
+		"both set":        {"expires_at": now.Add(time.Hour).UTC().Format(time.RFC3339), "ttl_seconds": 60},
+		"in the past":     {"expires_at": now.Add(-time.Minute).UTC().Format(time.RFC3339)},
+		"negative ttl":    {"ttl_seconds": -5},
+		"before send_at":  {"expires_at": now.Add(30 * time.Second).UTC().Format(time.RFC3339), "delay_seconds": 60},
+		"not a timestamp": {"expires_at": "in five minutes"},
+	} {
+		payload["provider_id"] = "chat"
+		payload["recipient"] = "777"
+		payload["message"] = "invalid"
+		if status, _ := post(payload); status != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", name, status)
+		}
+	}
+
+	// A notification with a 1s TTL expires in the queue; one without a TTL is still sent
+	if status, result := post(map[string]interface{}{
+		"provider_id": "chat", "recipient": "777", "message": "Server CPU high", "ttl_seconds": 1,
+	}); status != http.StatusCreated || result.Status != "queued" {
+		t.Fatalf("Expected a queued notification, got %d %+v", status, result)
+	}
+	if status, _ := post(map[string]interface{}{
+		"provider_id": "chat", "recipient": "777", "message": "Nightly report",
+	}); status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", status)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	queue.Start()
+	queue.Stop()
+
+	// A scheduled notification that expired before it was dispatched is not sent
+	past := time.Now().Add(-time.Second)
+	api.ScheduledDispatcher(registry, logger, repo)(&storage.ScheduledNotification{
+		ID:           "scheduled-1",
+		ProviderID:   "chat",
+		ProviderType: "mock",
+		SendAt:       time.Now(),
+		Notification: &providers.Notification{
+			ID: "scheduled-1", ProviderID: "chat", Recipient: "777", Message: "Stale reminder", ExpiresAt: &past,
+		},
+	}, func() {})
+
+	// Expired notifications held in a digest are left out of the summary
+	fresh := time.Now().Add(time.Hour)
+	api.DigestSender(registry, logger, repo, nil)(&storage.Digest{
+		ID:           "digest-1",
+		ProviderID:   "chat",
+		ProviderType: "mock",
+		Recipient:    "777",
+		Items: []*providers.Notification{
+			{ID: "item-1", ProviderID: "chat", Recipient: "777", Message: "Stale item", ExpiresAt: &past, Timestamp: past},
+			{ID: "item-2", ProviderID: "chat", Recipient: "777", Message: "Fresh item", ExpiresAt: &fresh, Timestamp: past},
+		},
+	}, func() {})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(sentMessages()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := sentMessages()
+	if len(got) != 2 || got[0] != "Nightly report" || got[1] != "1 notifications:\n\n- Fresh item" {
+		t.Fatalf("Expected only the report and a digest of the fresh item to be sent, got %q", got)
+	}
+
+	// Flush history
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close logger: %v", err)
+	}
+
+	resp, err := http.Get(server.URL + "/api/v1/notifications/history?status=expired")
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	defer resp.Body.Close()
+	var history struct {
+		Notifications []struct {
+			Message   string `json:"message"`
+			Status    string `json:"status"`
+			ExpiresAt struct {
+				String string
+				Valid  bool
+			} `json:"expires_at"`
+		} `json:"notifications"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		t.Fatalf("Failed to decode history: %v", err)
+	}
+	expired := map[string]bool{}
+	for _, n := range history.Notifications {
+		if n.Status != storage.StatusExpired || !n.ExpiresAt.Valid {
+			t.Errorf("Expected an expired row with expires_at, got %+v", n)
+		}
+		expired[n.Message] = true
+	}
+	for _, message := range []string{"Server CPU high", "Stale reminder", "Stale item"} {
+		if !expired[message] {
+			t.Errorf("Expected %q to be recorded as expired, got %+v", message, history.Notifications)
+		}
+	}
+
+	statsResp, err := http.Get(server.URL + "/api/v1/notifications/stats?provider_id=chat")
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	defer statsResp.Body.Close()
+	var stats storage.NotificationStats
+	if err := json.NewDecoder(statsResp.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if stats.ByStatus[storage.StatusExpired] != 3 || stats.ByStatus[storage.StatusSent] != 2 || stats.ByStatus[storage.StatusFailed] != 0 || stats.Total != 5 {
+		t.Errorf("Expected 3 expired, 2 sent and 0 failed of 5, got %+v", stats)
+	}
+}
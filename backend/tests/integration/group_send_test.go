@@ -0,0 +1,180 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/api"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+func TestGroupSendFansOutToMembers(t *testing.T) {
+	db, err := sql.Open("sqlite3", t.TempDir()+"/group.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(storage.Schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+
+	logger, err := storage.NewNotificationLogger(db)
+	if err != nil {
+		t.Fatalf("Failed to create notification logger: %v", err)
+	}
+
+	var (
+		mu   sync.Mutex
+		sent = map[string]string{} // provider ID -> recipient
+		wg   sync.WaitGroup
+	)
+	newMember := func(id string, sendErr error) *testhelpers.MockProvider {
+		return &testhelpers.MockProvider{
+			IDFunc:   func() string { return id },
+			TypeFunc: func() string { return "mock" },
+			SendFunc: func(_ context.Context, n *providers.Notification) error {
+				defer wg.Done()
+				mu.Lock()
+				sent[id] = n.Recipient
+				mu.Unlock()
+				return sendErr
+			},
+		}
+	}
+
+	registry := providers.NewRegistry()
+	group, err := providers.NewGroupProvider("oncall", &providers.GroupConfig{Members: []providers.GroupMember{
+		{ProviderID: "chat", Recipient: "12345"},
+		{ProviderID: "mail", Recipient: "ops@example.com"},
+		{ProviderID: "pager"}, // not loaded
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	for _, p := range []providers.Provider{newMember("chat", nil), newMember("mail", providers.NewRecipientError(errors.New("mailbox unavailable"))), group} {
+		if err := registry.Register(p); err != nil {
+			t.Fatalf("Failed to register %s: %v", p.GetID(), err)
+		}
+	}
+
+	server := httptest.NewServer(api.SetupRouter(registry, logger, storage.NewRepository(db)))
+	defer server.Close()
+
+	wg.Add(2)
+	body, _ := json.Marshal(map[string]interface{}{
+		"provider_id": "oncall",
+		"message":     "Database failover in progress",
+		"recipients":  map[string]string{"mail": "dba@example.com"},
+	})
+	resp, err := http.Post(server.URL+"/api/v1/notifications", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("Failed to send group notification: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", resp.StatusCode)
+	}
+
+	var result api.NotificationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(result.Children) != 3 {
+		t.Fatalf("Expected 3 children, got %+v", result.Children)
+	}
+	wantStatus := map[string]string{"chat": "queued", "mail": "queued", "pager": storage.StatusFailed}
+	for _, child := range result.Children {
+		if child.ID == "" || child.Status != wantStatus[child.ProviderID] {
+			t.Errorf("Unexpected child %+v", child)
+		}
+	}
+
+	// Wait for both member deliveries, then flush the logger
+	wg.Wait()
+	time.Sleep(100 * time.Millisecond)
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close notification logger: %v", err)
+	}
+
+	if sent["chat"] != "12345" || sent["mail"] != "dba@example.com" {
+		t.Errorf("Unexpected member recipients %v", sent)
+	}
+
+	var parentStatus string
+	if err := db.QueryRow(`SELECT status FROM notification_logs WHERE notification_id = ?`, result.ID).Scan(&parentStatus); err != nil {
+		t.Fatalf("Failed to query parent row: %v", err)
+	}
+	if parentStatus != storage.StatusFannedOut {
+		t.Errorf("Expected parent status fanned_out, got %s", parentStatus)
+	}
+
+	// Child rows are linked to the parent and listed by the history filter
+	histResp, err := http.Get(server.URL + "/api/v1/notifications/history?parent_id=" + result.ID)
+	if err != nil {
+		t.Fatalf("Failed to query history: %v", err)
+	}
+	defer histResp.Body.Close()
+
+	var history struct {
+		Notifications []storage.NotificationLogEntry `json:"notifications"`
+	}
+	if err := json.NewDecoder(histResp.Body).Decode(&history); err != nil {
+		t.Fatalf("Failed to decode history: %v", err)
+	}
+	if len(history.Notifications) != 3 {
+		t.Fatalf("Expected 3 child rows, got %d", len(history.Notifications))
+	}
+	gotStatus := map[string]string{}
+	for _, entry := range history.Notifications {
+		gotStatus[entry.ProviderID] = entry.Status
+	}
+	if gotStatus["chat"] != storage.StatusSent || gotStatus["mail"] != storage.StatusFailed || gotStatus["pager"] != storage.StatusFailed {
+		t.Errorf("Unexpected child statuses %v", gotStatus)
+	}
+}
+
+func TestGroupSendValidatesEachMember(t *testing.T) {
+	registry := providers.NewRegistry()
+	member := &testhelpers.MockProvider{
+		IDFunc:   func() string { return "chat" },
+		TypeFunc: func() string { return "mock" },
+	}
+	group, err := providers.NewGroupProvider("oncall", &providers.GroupConfig{Members: []providers.GroupMember{{ProviderID: "chat"}}})
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	for _, p := range []providers.Provider{member, group} {
+		if err := registry.Register(p); err != nil {
+			t.Fatalf("Failed to register %s: %v", p.GetID(), err)
+		}
+	}
+
+	server := httptest.NewServer(api.SetupRouter(registry, nil, nil))
+	defer server.Close()
+
+	for name, payload := range map[string]map[string]interface{}{
+		"member without recipient": {"provider_id": "oncall", "message": "hello"},
+		"recipient for non-member": {"provider_id": "oncall", "recipient": "1", "message": "hello", "recipients": map[string]string{"mail": "x@example.com"}},
+	} {
+		body, _ := json.Marshal(payload)
+		resp, err := http.Post(server.URL+"/api/v1/notifications", "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", name, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/developertyrone/notimulti/internal/api"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+// TestIdempotentSends retries sends with the same idempotency key and checks that the
+// notification is delivered once and every retry gets the original response.
+func TestIdempotentSends(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	registry := providers.NewRegistry()
+	if err := registry.Register(&testhelpers.MockProvider{
+		IDFunc:   func() string { return "chat" },
+		TypeFunc: func() string { return "mock" },
+		SendFunc: func(_ context.Context, n *providers.Notification) error {
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, n.Message)
+			return nil
+		},
+	}); err != nil {
+		t.Fatalf("Failed to register provider: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", t.TempDir()+"/idempotency.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(storage.Schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	server := httptest.NewServer(api.SetupRouter(registry, nil, storage.NewRepository(db)))
+	defer server.Close()
+
+	post := func(headers map[string]string, payload map[string]interface{}) (*http.Response, api.NotificationResponse) {
+		t.Helper()
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/v1/notifications", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send notification: %v", err)
+		}
+		defer resp.Body.Close()
+		var result api.NotificationResponse
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+	backup := map[string]interface{}{"provider_id": "chat", "recipient": "777", "message": "Backup finished"}
+	key := map[string]string{api.IdempotencyKeyHeader: "backup-2025-01-02"}
+
+	resp, first := post(key, backup)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get(api.IdempotentReplayedHeader) != "" {
+		t.Fatalf("Expected the first send to be handled, got %d %+v", resp.StatusCode, first)
+	}
+	resp, retry := post(key, backup)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get(api.IdempotentReplayedHeader) != "true" {
+		t.Fatalf("Expected the retry to be replayed, got %d", resp.StatusCode)
+	}
+	if retry.ID != first.ID || retry.Status != first.Status || !retry.Timestamp.Equal(first.Timestamp) {
+		t.Errorf("Expected the original response %+v, got %+v", first, retry)
+	}
+
+	// The key can be sent in the body instead of the header
+	withField := map[string]interface{}{"idempotency_key": "backup-2025-01-02"}
+	for k, v := range backup {
+		withField[k] = v
+	}
+	if _, result := post(nil, withField); result.ID != first.ID {
+		t.Errorf("Expected idempotency_key to replay %s, got %+v", first.ID, result)
+	}
+
+	// Reusing a key for another request is rejected
+	if resp, _ := post(key, map[string]interface{}{"provider_id": "chat", "recipient": "777", "message": "Other"}); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for a reused key, got %d", resp.StatusCode)
+	}
+	withField["idempotency_key"] = "something-else"
+	if resp, _ := post(key, withField); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 when the header and field differ, got %d", resp.StatusCode)
+	}
+
+	// Keys are scoped by API key
+	scoped := map[string]string{api.IdempotencyKeyHeader: "backup-2025-01-02", api.APIKeyHeader: "billing-service"}
+	if _, result := post(scoped, backup); result.ID == "" || result.ID == first.ID {
+		t.Errorf("Expected a new notification for another API key, got %+v", result)
+	}
+	// API keys are not stored in the database
+	var stored int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM idempotency_keys WHERE scope = ?`, "billing-service").Scan(&stored); err != nil {
+		t.Fatalf("Failed to query idempotency keys: %v", err)
+	}
+	if stored != 0 {
+		t.Errorf("Expected the API key to be stored hashed, found it in %d rows", stored)
+	}
+
+	// Rejected requests do not use up the key
+	invalid := map[string]string{api.IdempotencyKeyHeader: "report"}
+	if resp, _ := post(invalid, map[string]interface{}{"provider_id": "chat", "message": "No recipient"}); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", resp.StatusCode)
+	}
+	if resp, _ := post(invalid, map[string]interface{}{"provider_id": "chat", "recipient": "777", "message": "Report ready"}); resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected the corrected request to be sent, got %d", resp.StatusCode)
+	}
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(sent) == 3
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if sent[0] != "Backup finished" {
+		t.Errorf("Unexpected deliveries %v", sent)
+	}
+}
@@ -0,0 +1,195 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/api"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/scheduler"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+// TestRecurringJobs creates jobs through the API, runs them with the job runner and
+// checks that their deliveries and failures show up in history linked to the job.
+func TestRecurringJobs(t *testing.T) {
+	db, err := sql.Open("sqlite3", t.TempDir()+"/jobs.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(storage.Schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	registry := providers.NewRegistry()
+	mock := &testhelpers.MockProvider{
+		IDFunc:   func() string { return "chat" },
+		TypeFunc: func() string { return "mock" },
+		SendFunc: func(_ context.Context, n *providers.Notification) error {
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, n.Message)
+			return nil
+		},
+	}
+	if err := registry.Register(mock); err != nil {
+		t.Fatalf("Failed to register provider: %v", err)
+	}
+
+	logger, err := storage.NewNotificationLogger(db)
+	if err != nil {
+		t.Fatalf("Failed to create notification logger: %v", err)
+	}
+	defer logger.Close()
+	repo := storage.NewRepository(db)
+	server := httptest.NewServer(api.SetupRouter(registry, logger, repo))
+	defer server.Close()
+
+	do := func(method, path string, payload interface{}) (int, []byte) {
+		t.Helper()
+		var body bytes.Buffer
+		if payload != nil {
+			_ = json.NewEncoder(&body).Encode(payload)
+		}
+		req, _ := http.NewRequest(method, server.URL+path, &body)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		var out bytes.Buffer
+		_, _ = out.ReadFrom(resp.Body)
+		return resp.StatusCode, out.Bytes()
+	}
+	getJob := func(id string) storage.Job {
+		t.Helper()
+		code, body := do(http.MethodGet, "/api/v1/jobs/"+id, nil)
+		if code != http.StatusOK {
+			t.Fatalf("Expected status 200 getting job, got %d: %s", code, body)
+		}
+		var job storage.Job
+		if err := json.Unmarshal(body, &job); err != nil {
+			t.Fatalf("Failed to decode job: %v", err)
+		}
+		return job
+	}
+
+	// Invalid jobs are rejected before they are stored
+	code, body := do(http.MethodPost, "/api/v1/jobs", map[string]interface{}{
+		"id": "heartbeat", "schedule": "every second",
+		"notification": map[string]interface{}{"provider_id": "chat", "recipient": "1", "delay_seconds": 5, "idempotency_key": "beat"},
+	})
+	if code != http.StatusBadRequest || !bytes.Contains(body, []byte("notification.message")) || !bytes.Contains(body, []byte("notification.send_at")) ||
+		!bytes.Contains(body, []byte("notification.idempotency_key")) {
+		t.Fatalf("Expected validation errors, got %d: %s", code, body)
+	}
+
+	code, body = do(http.MethodPost, "/api/v1/jobs", map[string]interface{}{
+		"id": "heartbeat", "schedule": "@every 1s",
+		"notification": map[string]interface{}{"provider_id": "chat", "recipient": "1", "message": "still alive"},
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", code, body)
+	}
+	if code, _ := do(http.MethodPost, "/api/v1/jobs", map[string]interface{}{
+		"id": "heartbeat", "schedule": "@hourly",
+		"notification": map[string]interface{}{"message": "duplicate"},
+	}); code != http.StatusConflict {
+		t.Fatalf("Expected status 409 for a duplicate job, got %d", code)
+	}
+	// Runs against a provider that does not exist record a failed run
+	if code, body := do(http.MethodPost, "/api/v1/jobs", map[string]interface{}{
+		"id": "broken", "schedule": "@every 1s",
+		"notification": map[string]interface{}{"provider_id": "missing", "recipient": "1", "message": "lost"},
+	}); code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", code, body)
+	}
+
+	runner := scheduler.NewJobRunner(repo, api.JobSender(registry, logger, repo, nil, nil), 20*time.Millisecond, nil)
+	runner.Start()
+	defer runner.Stop()
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(sent) >= 1
+	})
+	waitFor(t, func() bool { return getJob("broken").LastError != "" })
+
+	job := getJob("heartbeat")
+	if job.LastRunAt == nil || job.LastNotificationID == "" || job.LastError != "" || job.NextRunAt == nil {
+		t.Fatalf("Expected a recorded successful run, got %+v", job)
+	}
+	broken := getJob("broken")
+	if broken.LastNotificationID == "" {
+		t.Fatalf("Expected the failed run to be linked to a history entry, got %+v", broken)
+	}
+
+	// Pausing stops further runs
+	if code, body := do(http.MethodPost, "/api/v1/jobs/heartbeat/pause", nil); code != http.StatusOK {
+		t.Fatalf("Expected status 200 pausing, got %d: %s", code, body)
+	}
+	mu.Lock()
+	runs := len(sent)
+	mu.Unlock()
+	time.Sleep(1200 * time.Millisecond)
+	mu.Lock()
+	if len(sent) != runs {
+		t.Errorf("Expected no runs while paused, got %d more", len(sent)-runs)
+	}
+	mu.Unlock()
+
+	if code, body := do(http.MethodPost, "/api/v1/jobs/heartbeat/resume", nil); code != http.StatusOK {
+		t.Fatalf("Expected status 200 resuming, got %d: %s", code, body)
+	}
+	if resumed := getJob("heartbeat"); resumed.Paused || resumed.NextRunAt == nil {
+		t.Errorf("Expected the resumed job to have a next run, got %+v", resumed)
+	}
+	runner.Stop()
+
+	// History can be filtered by job once the logger has flushed
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close notification logger: %v", err)
+	}
+	var history struct {
+		Notifications []storage.NotificationLogEntry `json:"notifications"`
+	}
+	for id, status := range map[string]string{"heartbeat": storage.StatusSent, "broken": storage.StatusFailed} {
+		code, body := do(http.MethodGet, "/api/v1/notifications/history?job_id="+id, nil)
+		if code != http.StatusOK {
+			t.Fatalf("Expected status 200 for history, got %d: %s", code, body)
+		}
+		if err := json.Unmarshal(body, &history); err != nil {
+			t.Fatalf("Failed to decode history: %v", err)
+		}
+		if len(history.Notifications) == 0 {
+			t.Fatalf("Expected history for job %s", id)
+		}
+		for _, entry := range history.Notifications {
+			if entry.JobID.String != id || entry.Status != status {
+				t.Errorf("Expected %s history linked to job %s, got %+v", status, id, entry)
+			}
+		}
+	}
+
+	if code, _ := do(http.MethodDelete, "/api/v1/jobs/heartbeat", nil); code != http.StatusNoContent {
+		t.Errorf("Expected status 204 deleting, got %d", code)
+	}
+	if code, _ := do(http.MethodGet, "/api/v1/jobs/heartbeat", nil); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after deleting, got %d", code)
+	}
+}
@@ -0,0 +1,249 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/api"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/scheduler"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+// quietProvider is a mock provider with quiet hours
+type quietProvider struct {
+	*testhelpers.MockProvider
+	quietHours *providers.QuietHoursConfig
+}
+
+func (p *quietProvider) QuietHours() *providers.QuietHoursConfig {
+	return p.quietHours
+}
+
+// TestQuietHoursDeferral sends to a recipient in quiet hours and checks that low and
+// normal priority notifications are deferred until the window ends, directly and
+// through a group, while high priority and other recipients are delivered at once.
+func TestQuietHoursDeferral(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	now := time.Now().UTC()
+	registry := providers.NewRegistry()
+	chat := &quietProvider{
+		MockProvider: &testhelpers.MockProvider{
+			IDFunc:   func() string { return "chat" },
+			TypeFunc: func() string { return "mock" },
+			SendFunc: func(_ context.Context, n *providers.Notification) error {
+				mu.Lock()
+				defer mu.Unlock()
+				sent = append(sent, n.Recipient+":"+n.Message)
+				return nil
+			},
+		},
+		quietHours: &providers.QuietHoursConfig{Recipients: map[string]*providers.QuietHours{
+			"777": {Start: now.Add(-time.Hour).Format("15:04"), End: now.Add(time.Hour).Format("15:04"), Timezone: "UTC"},
+		}},
+	}
+	group, err := providers.NewGroupProvider("team", &providers.GroupConfig{Members: []providers.GroupMember{
+		{ProviderID: "chat", Recipient: "777"},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	for _, provider := range []providers.Provider{chat, group} {
+		if err := registry.Register(provider); err != nil {
+			t.Fatalf("Failed to register %s: %v", provider.GetID(), err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", t.TempDir()+"/quiet.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(storage.Schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	logger, err := storage.NewNotificationLogger(db)
+	if err != nil {
+		t.Fatalf("Failed to create notification logger: %v", err)
+	}
+	repo := storage.NewRepository(db)
+	server := httptest.NewServer(api.SetupRouter(registry, logger, repo))
+	defer server.Close()
+
+	post := func(payload map[string]interface{}) api.NotificationResponse {
+		t.Helper()
+		body, _ := json.Marshal(payload)
+		resp, err := http.Post(server.URL+"/api/v1/notifications", "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatalf("Failed to send notification: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d", resp.StatusCode)
+		}
+		var result api.NotificationResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return result
+	}
+
+	deferred := post(map[string]interface{}{"provider_id": "chat", "recipient": "777", "message": "Nightly batch finished", "priority": "low"})
+	if deferred.Status != storage.StatusDeferred || deferred.SendAt == nil {
+		t.Fatalf("Expected the notification to be deferred, got %+v", deferred)
+	}
+	if until := now.Add(time.Hour).Truncate(time.Minute); !deferred.SendAt.Equal(until) {
+		t.Errorf("Expected release at %s, got %s", until, deferred.SendAt)
+	}
+	fanOut := post(map[string]interface{}{"provider_id": "team", "message": "Report ready"})
+	if len(fanOut.Children) != 1 || fanOut.Children[0].Status != storage.StatusDeferred || fanOut.Children[0].SendAt == nil {
+		t.Fatalf("Expected the group member delivery to be deferred, got %+v", fanOut.Children)
+	}
+
+	post(map[string]interface{}{"provider_id": "chat", "recipient": "777", "message": "Database down", "priority": "high"})
+	post(map[string]interface{}{"provider_id": "chat", "recipient": "888", "message": "Not in quiet hours"})
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(sent) == 2
+	})
+
+	pending, err := repo.ListScheduledNotifications()
+	if err != nil {
+		t.Fatalf("Failed to list scheduled notifications: %v", err)
+	}
+	if len(pending) != 2 || !pending[0].Deferred || pending[1].ParentID != fanOut.ID {
+		t.Fatalf("Expected 2 deferred notifications, got %+v", pending)
+	}
+
+	// Release them as the scheduler does once the window ends
+	due, err := repo.ClaimDueNotifications(*deferred.SendAt, scheduler.ClaimLease, 10)
+	if err != nil || len(due) != 2 {
+		t.Fatalf("ClaimDueNotifications() = %+v, %v", due, err)
+	}
+	dispatch := api.ScheduledDispatcher(registry, logger, repo)
+	for i := range due {
+		dispatch(&due[i], func() {
+			if err := repo.CompleteScheduledNotification(&due[i]); err != nil {
+				t.Errorf("CompleteScheduledNotification() error = %v", err)
+			}
+		})
+	}
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(sent) == 4
+	})
+
+	time.Sleep(100 * time.Millisecond)
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close notification logger: %v", err)
+	}
+
+	rows, err := db.Query(`SELECT status, error_message, parent_id FROM notification_logs WHERE notification_id IN (?, ?) `,
+		deferred.ID, fanOut.Children[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to query history: %v", err)
+	}
+	defer rows.Close()
+	var history []string
+	for rows.Next() {
+		var status string
+		var message, parentID sql.NullString
+		if err := rows.Scan(&status, &message, &parentID); err != nil {
+			t.Fatalf("Failed to scan history: %v", err)
+		}
+		if status == storage.StatusDeferred && !strings.Contains(message.String, deferred.SendAt.Format(time.RFC3339)) {
+			t.Errorf("Expected the deferral to record the release time, got %q", message.String)
+		}
+		history = append(history, status+"/"+parentID.String)
+	}
+	sort.Strings(history)
+	want := []string{"deferred/", "deferred/" + fanOut.ID, "sent/", "sent/" + fanOut.ID}
+	if strings.Join(history, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected history %v, got %v", want, history)
+	}
+}
+
+// TestContactQuietHoursApplyToFanOuts checks that a group member whose address is a
+// contact's identity is deferred for the contact's quiet hours, like a send to the contact.
+func TestContactQuietHoursApplyToFanOuts(t *testing.T) {
+	now := time.Now().UTC()
+	registry := providers.NewRegistry()
+	if err := registry.Register(&testhelpers.MockProvider{
+		IDFunc:   func() string { return "chat" },
+		TypeFunc: func() string { return "mock" },
+	}); err != nil {
+		t.Fatalf("Failed to register provider: %v", err)
+	}
+	group, err := providers.NewGroupProvider("team", &providers.GroupConfig{Members: []providers.GroupMember{
+		{ProviderID: "chat", Recipient: "555"},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	if err := registry.Register(group); err != nil {
+		t.Fatalf("Failed to register group: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", t.TempDir()+"/contact-quiet.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(storage.Schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	repo := storage.NewRepository(db)
+	server := httptest.NewServer(api.SetupRouter(registry, nil, repo))
+	defer server.Close()
+
+	send := func() api.NotificationResponse {
+		t.Helper()
+		body, _ := json.Marshal(map[string]interface{}{"provider_id": "team", "message": "Report ready"})
+		resp, err := http.Post(server.URL+"/api/v1/notifications", "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatalf("Failed to send notification: %v", err)
+		}
+		defer resp.Body.Close()
+		var result api.NotificationResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return result
+	}
+
+	// Before the address belongs to a contact the member is delivered at once
+	if result := send(); len(result.Children) != 1 || result.Children[0].Status != "queued" {
+		t.Fatalf("Expected the member delivery to be queued, got %+v", result.Children)
+	}
+
+	if _, err := repo.CreateContact(&storage.Contact{
+		ID:         "alice",
+		Identities: []storage.ContactIdentity{{ProviderID: "chat", Address: "555"}},
+		QuietHours: &storage.QuietHours{Start: now.Add(-time.Hour).Format("15:04"), End: now.Add(time.Hour).Format("15:04")},
+	}); err != nil {
+		t.Fatalf("Failed to create contact: %v", err)
+	}
+
+	result := send()
+	if len(result.Children) != 1 || result.Children[0].Status != storage.StatusDeferred || result.Children[0].SendAt == nil {
+		t.Fatalf("Expected the member delivery to be deferred for the contact's quiet hours, got %+v", result.Children)
+	}
+	if until := now.Add(time.Hour).Truncate(time.Minute); !result.Children[0].SendAt.Equal(until) {
+		t.Errorf("Expected release at %s, got %s", until, result.Children[0].SendAt)
+	}
+}
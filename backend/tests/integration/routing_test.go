@@ -0,0 +1,174 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/api"
+	"github.com/developertyrone/notimulti/internal/config"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/routing"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+func TestRoutingRulesSelectProvidersAndHotReload(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	configDir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	var (
+		mu   sync.Mutex
+		sent = map[string]string{} // provider ID -> recipient
+		wg   sync.WaitGroup
+	)
+	registry := providers.NewRegistry()
+	for _, id := range []string{"pagerduty", "telegram-oncall", "email-ops"} {
+		id := id
+		err := registry.Register(&testhelpers.MockProvider{
+			IDFunc:   func() string { return id },
+			TypeFunc: func() string { return "mock" },
+			SendFunc: func(_ context.Context, n *providers.Notification) error {
+				defer wg.Done()
+				mu.Lock()
+				sent[id] = n.Recipient
+				mu.Unlock()
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to register %s: %v", id, err)
+		}
+	}
+
+	engine := routing.NewEngine()
+	watcher, err := config.NewWatcher(configDir, registry, logger)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer stopWatcher(t, watcher)
+	watcher.SetRoutingEngine(engine)
+	watcher.Start()
+
+	server := httptest.NewServer(api.SetupRouter(registry, nil, nil, api.WithRoutingEngine(engine)))
+	defer server.Close()
+
+	post := func(path string, payload map[string]interface{}) *http.Response {
+		t.Helper()
+		body, _ := json.Marshal(payload)
+		resp, err := http.Post(server.URL+path, "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatalf("POST %s failed: %v", path, err)
+		}
+		return resp
+	}
+	alert := map[string]interface{}{
+		"recipient": "ops@example.com",
+		"message":   "disk full",
+		"priority":  "high",
+		"metadata":  map[string]interface{}{"env": "prod"},
+	}
+
+	// Without rules a provider_id is still required
+	resp := post("/api/v1/notifications", alert)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status 400 without routing rules, got %d", resp.StatusCode)
+	}
+
+	rules := `{"rules": [
+		{"name": "prod-high", "match": {"priority": ["high"], "metadata": {"env": "prod"}},
+		 "targets": [{"provider_id": "pagerduty"}, {"provider_id": "telegram-oncall", "recipient": "-100123"}]}
+	]}`
+	if err := os.WriteFile(filepath.Join(configDir, routing.FileName), []byte(rules), 0644); err != nil {
+		t.Fatalf("Failed to write routing rules: %v", err)
+	}
+	waitFor(t, func() bool { return len(engine.Rules()) == 1 })
+
+	// Dry runs explain the decision without sending
+	resp = post("/api/v1/routing/dry-run", alert)
+	var dryRun api.RoutingDryRunResponse
+	if err := json.NewDecoder(resp.Body).Decode(&dryRun); err != nil {
+		t.Fatalf("Failed to decode dry run: %v", err)
+	}
+	resp.Body.Close()
+	if !dryRun.Matched || dryRun.Rule != "prod-high" || dryRun.Recipients["telegram-oncall"] != "-100123" || dryRun.Recipients["pagerduty"] != "ops@example.com" {
+		t.Fatalf("Unexpected dry run %+v", dryRun)
+	}
+
+	// A matching send fans out to every target
+	wg.Add(2)
+	resp = post("/api/v1/notifications", alert)
+	var result api.NotificationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || result.Rule != "prod-high" || len(result.Children) != 2 {
+		t.Fatalf("Unexpected routed send: %d %+v", resp.StatusCode, result)
+	}
+	wg.Wait()
+	mu.Lock()
+	if sent["pagerduty"] != "ops@example.com" || sent["telegram-oncall"] != "-100123" {
+		t.Errorf("Unexpected target recipients %v", sent)
+	}
+	mu.Unlock()
+
+	// Unmatched sends are rejected with the explanation
+	resp = post("/api/v1/notifications", map[string]interface{}{"recipient": "x", "message": "hello"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for an unmatched send, got %d", resp.StatusCode)
+	}
+
+	// Rule changes are picked up without a restart
+	rules = `{"rules": [{"name": "everything", "targets": [{"provider_id": "email-ops"}]}]}`
+	if err := os.WriteFile(filepath.Join(configDir, routing.FileName), []byte(rules), 0644); err != nil {
+		t.Fatalf("Failed to write routing rules: %v", err)
+	}
+	waitFor(t, func() bool {
+		rules := engine.Rules()
+		return len(rules) == 1 && rules[0].Name == "everything"
+	})
+
+	wg.Add(1)
+	resp = post("/api/v1/notifications", map[string]interface{}{"recipient": "ops@example.com", "message": "hello"})
+	result = api.NotificationResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || result.Rule != "everything" || len(result.Children) != 0 {
+		t.Fatalf("Unexpected single-target routed send: %d %+v", resp.StatusCode, result)
+	}
+	wg.Wait()
+
+	// Removing the file removes the rules
+	if err := os.Remove(filepath.Join(configDir, routing.FileName)); err != nil {
+		t.Fatalf("Failed to remove routing rules: %v", err)
+	}
+	waitFor(t, func() bool { return len(engine.Rules()) == 0 })
+}
+
+// waitFor polls cond until it holds or a few seconds pass
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before timeout")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
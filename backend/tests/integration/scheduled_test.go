@@ -0,0 +1,350 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/api"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/scheduler"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+// TestScheduledNotificationsSurviveRestart schedules notifications on one server, cancels
+// one of them, and checks that a new server on the same database delivers the rest.
+func TestScheduledNotificationsSurviveRestart(t *testing.T) {
+	dbPath := t.TempDir() + "/scheduled.db"
+
+	var (
+		mu   sync.Mutex
+		sent = map[string]string{} // provider ID -> message
+	)
+	newRegistry := func() *providers.Registry {
+		registry := providers.NewRegistry()
+		group, err := providers.NewGroupProvider("oncall", &providers.GroupConfig{Members: []providers.GroupMember{
+			{ProviderID: "chat", Recipient: "12345"},
+			{ProviderID: "mail", Recipient: "ops@example.com"},
+		}})
+		if err != nil {
+			t.Fatalf("Failed to create group: %v", err)
+		}
+		for _, id := range []string{"chat", "mail"} {
+			id := id
+			mock := &testhelpers.MockProvider{
+				IDFunc:   func() string { return id },
+				TypeFunc: func() string { return "mock" },
+				SendFunc: func(_ context.Context, n *providers.Notification) error {
+					mu.Lock()
+					defer mu.Unlock()
+					sent[id+"/"+n.Recipient] = n.Message
+					return nil
+				},
+			}
+			if err := registry.Register(mock); err != nil {
+				t.Fatalf("Failed to register %s: %v", id, err)
+			}
+		}
+		if err := registry.Register(group); err != nil {
+			t.Fatalf("Failed to register group: %v", err)
+		}
+		return registry
+	}
+	openDB := func() *sql.DB {
+		db, err := sql.Open("sqlite3", dbPath)
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		if _, err := db.Exec(storage.Schema); err != nil {
+			t.Fatalf("Failed to create schema: %v", err)
+		}
+		return db
+	}
+	post := func(serverURL string, payload map[string]interface{}) api.NotificationResponse {
+		t.Helper()
+		body, _ := json.Marshal(payload)
+		resp, err := http.Post(serverURL+"/api/v1/notifications", "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatalf("Failed to send notification: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d", resp.StatusCode)
+		}
+		var result api.NotificationResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if result.Status != api.StatusScheduled || result.SendAt == nil {
+			t.Fatalf("Expected a scheduled notification, got %+v", result)
+		}
+		return result
+	}
+	cancel := func(serverURL, id string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodDelete, serverURL+"/api/v1/notifications/"+id, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to cancel notification: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// First server: schedule a delayed send, a delayed group send and a later send to cancel
+	db := openDB()
+	logger, err := storage.NewNotificationLogger(db)
+	if err != nil {
+		t.Fatalf("Failed to create notification logger: %v", err)
+	}
+	server := httptest.NewServer(api.SetupRouter(newRegistry(), logger, storage.NewRepository(db)))
+
+	single := post(server.URL, map[string]interface{}{
+		"provider_id": "chat", "recipient": "777", "message": "Backup finished", "delay_seconds": 1,
+	})
+	group := post(server.URL, map[string]interface{}{
+		"provider_id": "oncall", "message": "Maintenance starting", "delay_seconds": 1,
+	})
+	if len(group.Children) != 2 || group.Children[0].Status != api.StatusScheduled {
+		t.Fatalf("Expected 2 scheduled children, got %+v", group.Children)
+	}
+	later := post(server.URL, map[string]interface{}{
+		"provider_id": "chat", "recipient": "777", "message": "Never sent",
+		"send_at": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	})
+
+	if code := cancel(server.URL, later.ID); code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 cancelling, got %d", code)
+	}
+	if code := cancel(server.URL, later.ID); code != http.StatusNotFound {
+		t.Fatalf("Expected status 404 cancelling twice, got %d", code)
+	}
+
+	resp, err := http.Get(server.URL + "/api/v1/notifications/scheduled")
+	if err != nil {
+		t.Fatalf("Failed to list scheduled notifications: %v", err)
+	}
+	var list struct {
+		Notifications []storage.ScheduledNotification `json:"notifications"`
+		Count         int                             `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode scheduled notifications: %v", err)
+	}
+	resp.Body.Close()
+	if list.Count != 2 {
+		t.Fatalf("Expected 2 scheduled notifications, got %+v", list.Notifications)
+	}
+
+	// Restart after the notifications became due
+	server.Close()
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close notification logger: %v", err)
+	}
+	db.Close()
+	mu.Lock()
+	if len(sent) != 0 {
+		t.Fatalf("Nothing should be sent before the scheduler runs, got %v", sent)
+	}
+	mu.Unlock()
+	time.Sleep(time.Until(*group.SendAt) + 100*time.Millisecond)
+
+	db = openDB()
+	defer db.Close()
+	logger, err = storage.NewNotificationLogger(db)
+	if err != nil {
+		t.Fatalf("Failed to create notification logger: %v", err)
+	}
+	repo := storage.NewRepository(db)
+	s := scheduler.New(repo, api.ScheduledDispatcher(newRegistry(), logger, repo), 20*time.Millisecond, nil)
+	s.Start()
+	defer s.Stop()
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(sent) == 3
+	})
+	mu.Lock()
+	if sent["chat/777"] != "Backup finished" || sent["chat/12345"] != "Maintenance starting" || sent["mail/ops@example.com"] != "Maintenance starting" {
+		t.Errorf("Unexpected deliveries %v", sent)
+	}
+	mu.Unlock()
+
+	// Rows are removed once the outcome of their deliveries is recorded
+	waitFor(t, func() bool {
+		remaining, err := repo.ListScheduledNotifications()
+		return err == nil && len(remaining) == 0
+	})
+
+	time.Sleep(100 * time.Millisecond)
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close notification logger: %v", err)
+	}
+
+	statuses := map[string]string{}
+	rows, err := db.Query(`SELECT notification_id, status FROM notification_logs`)
+	if err != nil {
+		t.Fatalf("Failed to query history: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, status string
+		if err := rows.Scan(&id, &status); err != nil {
+			t.Fatalf("Failed to scan history: %v", err)
+		}
+		statuses[id] = status
+	}
+	if statuses[single.ID] != storage.StatusSent {
+		t.Errorf("Expected the delayed notification to be sent, got %q", statuses[single.ID])
+	}
+	if statuses[group.ID] != storage.StatusFannedOut {
+		t.Errorf("Expected the group parent to be fanned_out, got %q", statuses[group.ID])
+	}
+	for _, child := range group.Children {
+		if statuses[child.ID] != storage.StatusSent {
+			t.Errorf("Expected child %s to be sent, got %q", child.ProviderID, statuses[child.ID])
+		}
+	}
+	if statuses[later.ID] != storage.StatusCancelled {
+		t.Errorf("Expected the cancelled notification to be recorded as cancelled, got %q", statuses[later.ID])
+	}
+}
+
+// TestScheduledNotificationClaimsAreLeases checks that a claimed notification stays in
+// the database until it is completed, and is claimed again when its lease expires, as
+// after a crash between claiming and delivering it.
+func TestScheduledNotificationClaimsAreLeases(t *testing.T) {
+	db, err := sql.Open("sqlite3", t.TempDir()+"/leases.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(storage.Schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	repo := storage.NewRepository(db)
+
+	now := time.Now()
+	scheduled := &storage.ScheduledNotification{
+		ID:           "lease-1",
+		ProviderID:   "chat",
+		ProviderType: "mock",
+		SendAt:       now.Add(-time.Second),
+		Notification: &providers.Notification{ID: "lease-1", ProviderID: "chat", Recipient: "777", Message: "Backup finished"},
+	}
+	if err := repo.ScheduleNotification(scheduled); err != nil {
+		t.Fatalf("ScheduleNotification() error = %v", err)
+	}
+
+	due, err := repo.ClaimDueNotifications(now, time.Minute, 10)
+	if err != nil || len(due) != 1 || due[0].ClaimedAt == nil {
+		t.Fatalf("ClaimDueNotifications() = %+v, %v", due, err)
+	}
+
+	// A claimed notification is not claimed twice within its lease, nor cancelled
+	if again, err := repo.ClaimDueNotifications(now.Add(30*time.Second), time.Minute, 10); err != nil || len(again) != 0 {
+		t.Fatalf("Expected no claim within the lease, got %+v, %v", again, err)
+	}
+	if _, err := repo.CancelScheduledNotification("lease-1"); err != storage.ErrScheduledNotFound {
+		t.Errorf("Expected a claimed notification not to be cancellable, got %v", err)
+	}
+
+	// The process stopped before completing it: once the lease expires it is claimed again
+	reclaimed, err := repo.ClaimDueNotifications(now.Add(2*time.Minute), time.Minute, 10)
+	if err != nil || len(reclaimed) != 1 || reclaimed[0].ID != "lease-1" {
+		t.Fatalf("Expected the notification to be claimed again, got %+v, %v", reclaimed, err)
+	}
+
+	// Completing the stale claim leaves the new one in place
+	if err := repo.CompleteScheduledNotification(&due[0]); err != nil {
+		t.Fatalf("CompleteScheduledNotification() error = %v", err)
+	}
+	if pending, _ := repo.ListScheduledNotifications(); len(pending) != 1 {
+		t.Fatalf("Expected the reclaimed notification to remain, got %+v", pending)
+	}
+
+	// Holding it again while claimed, e.g. for quiet hours, replaces the claimed row
+	held := *scheduled
+	held.SendAt = now.Add(time.Hour)
+	held.Deferred = true
+	if err := repo.ScheduleNotification(&held); err != nil {
+		t.Fatalf("ScheduleNotification() of a claimed notification error = %v", err)
+	}
+	if err := repo.CompleteScheduledNotification(&reclaimed[0]); err != nil {
+		t.Fatalf("CompleteScheduledNotification() error = %v", err)
+	}
+	pending, err := repo.ListScheduledNotifications()
+	if err != nil || len(pending) != 1 || !pending[0].Deferred || pending[0].ClaimedAt != nil {
+		t.Fatalf("Expected the held notification to remain unclaimed, got %+v, %v", pending, err)
+	}
+
+	// Unclaimed rows are not replaced
+	if err := repo.ScheduleNotification(&held); err == nil {
+		t.Error("Expected scheduling a pending ID again to fail")
+	}
+}
+
+// TestScheduledClaimsAreRenewedDuringDelivery checks that a delivery outlasting the claim
+// lease, e.g. with a long retry policy, is not dispatched a second time.
+func TestScheduledClaimsAreRenewedDuringDelivery(t *testing.T) {
+	db, err := sql.Open("sqlite3", t.TempDir()+"/renew.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(storage.Schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	repo := storage.NewRepository(db)
+
+	if err := repo.ScheduleNotification(&storage.ScheduledNotification{
+		ID:           "slow-1",
+		ProviderID:   "chat",
+		ProviderType: "mock",
+		SendAt:       time.Now().Add(-time.Second),
+		Notification: &providers.Notification{ID: "slow-1", ProviderID: "chat", Recipient: "777", Message: "Report"},
+	}); err != nil {
+		t.Fatalf("ScheduleNotification() error = %v", err)
+	}
+
+	var (
+		mu         sync.Mutex
+		dispatched int
+		finished   = make(chan struct{})
+	)
+	s := scheduler.New(repo, func(_ *storage.ScheduledNotification, done func()) {
+		mu.Lock()
+		dispatched++
+		mu.Unlock()
+		// The delivery takes several leases
+		go func() {
+			time.Sleep(600 * time.Millisecond)
+			done()
+			close(finished)
+		}()
+	}, 20*time.Millisecond, nil)
+	s.SetLease(100 * time.Millisecond)
+	s.Start()
+	defer s.Stop()
+
+	<-finished
+	waitFor(t, func() bool {
+		pending, err := repo.ListScheduledNotifications()
+		return err == nil && len(pending) == 0
+	})
+	time.Sleep(200 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if dispatched != 1 {
+		t.Fatalf("Expected the notification to be dispatched once, got %d", dispatched)
+	}
+}
@@ -113,6 +113,82 @@ func TestTableStructure(t *testing.T) {
 	}
 }
 
+func TestSchemaMigrationAddsColumns(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "legacy.db")
+
+	// Create a database with the original (Phase 1) table layout
+	legacy, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open legacy database: %v", err)
+	}
+	_, err = legacy.Exec(`CREATE TABLE notification_logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		provider_id TEXT NOT NULL,
+		provider_type TEXT NOT NULL,
+		recipient TEXT NOT NULL,
+		message TEXT NOT NULL,
+		subject TEXT,
+		metadata TEXT,
+		priority TEXT DEFAULT 'normal',
+		status TEXT NOT NULL,
+		error_message TEXT,
+		attempts INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		delivered_at DATETIME
+	)`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy table: %v", err)
+	}
+	if _, err := legacy.Exec(`INSERT INTO notification_logs (provider_id, provider_type, recipient, message, status)
+		VALUES ('legacy', 'email', 'a@example.com', 'hello', 'sent')`); err != nil {
+		t.Fatalf("Failed to insert legacy row: %v", err)
+	}
+	// Contacts as first released, before the locale column
+	_, err = legacy.Exec(`CREATE TABLE contacts (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL DEFAULT '',
+		preferred_provider TEXT NOT NULL DEFAULT '',
+		timezone TEXT NOT NULL DEFAULT '',
+		quiet_hours_start TEXT NOT NULL DEFAULT '',
+		quiet_hours_end TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO contacts (id, name) VALUES ('alice', 'Alice')`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy contacts table: %v", err)
+	}
+	if err := legacy.Close(); err != nil {
+		t.Fatalf("Failed to close legacy database: %v", err)
+	}
+
+	db, err := storage.InitDB(dbPath)
+	if err != nil {
+		t.Fatalf("InitDB() failed on legacy database: %v", err)
+	}
+	defer closeStorageDB(t, db)
+
+	repo := storage.NewRepository(db.GetConn())
+	entry, err := repo.GetNotificationByID(1)
+	if err != nil {
+		t.Fatalf("GetNotificationByID() failed after migration: %v", err)
+	}
+	if entry == nil || entry.ProviderID != "legacy" || entry.IsTest {
+		t.Fatalf("unexpected legacy entry after migration: %+v", entry)
+	}
+
+	found, err := repo.AcknowledgeNotification("1", "@oncall")
+	if err != nil || !found {
+		t.Fatalf("AcknowledgeNotification() = %v, %v; want true, nil", found, err)
+	}
+
+	contact, err := repo.GetContact("alice")
+	if err != nil || contact.Name != "Alice" || contact.Locale != "" {
+		t.Fatalf("GetContact() after migration = %+v, %v", contact, err)
+	}
+}
+
 func TestIndexCreation(t *testing.T) {
 	tmpDir := t.TempDir()
 	dbPath := filepath.Join(tmpDir, "test.db")
@@ -0,0 +1,262 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/api"
+	"github.com/developertyrone/notimulti/internal/config"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/internal/templates"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+func TestTemplatedSendsAndHotReload(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	configDir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	var (
+		mu   sync.Mutex
+		sent = map[string]*providers.Notification{} // provider type -> last notification
+		wg   sync.WaitGroup
+	)
+	registry := providers.NewRegistry()
+	for _, typ := range []string{"email", "telegram"} {
+		typ := typ
+		err := registry.Register(&testhelpers.MockProvider{
+			IDFunc:   func() string { return typ + "-main" },
+			TypeFunc: func() string { return typ },
+			SendFunc: func(_ context.Context, n *providers.Notification) error {
+				defer wg.Done()
+				mu.Lock()
+				sent[typ] = n
+				mu.Unlock()
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to register %s: %v", typ, err)
+		}
+	}
+
+	store := templates.NewStore()
+	watcher, err := config.NewWatcher(configDir, registry, logger)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer stopWatcher(t, watcher)
+	watcher.SetTemplateStore(store)
+	watcher.Start()
+
+	server := httptest.NewServer(api.SetupRouter(registry, nil, nil, api.WithTemplates(store)))
+	defer server.Close()
+
+	post := func(payload map[string]interface{}) int {
+		t.Helper()
+		body, _ := json.Marshal(payload)
+		resp, err := http.Post(server.URL+"/api/v1/notifications", "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// The templates directory is picked up when it is created after startup
+	templatesDir := filepath.Join(configDir, templates.Dir)
+	if err := os.Mkdir(templatesDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTemplate := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(templatesDir, "disk-alert.json"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTemplate(`{
+		"variables": {"host": {"type": "string", "required": true}, "used": {"type": "number", "required": true}},
+		"subject": "Disk alert on {{.host}}",
+		"text": "{{.host}} is {{.used}}% full",
+		"html": "<p><strong>{{.host}}</strong> is {{.used}}% full</p>",
+		"telegram": "<b>{{.host}}</b> is {{.used}}% full"
+	}`)
+	waitFor(t, func() bool { _, ok := store.Get("disk-alert"); return ok })
+
+	data := map[string]interface{}{"host": "db<1>", "used": 93}
+	wg.Add(2)
+	for _, providerID := range []string{"email-main", "telegram-main"} {
+		status := post(map[string]interface{}{
+			"provider_id": providerID,
+			"recipient":   "ops@example.com",
+			"template":    "disk-alert",
+			"data":        data,
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected 201 for %s, got %d", providerID, status)
+		}
+	}
+	wg.Wait()
+
+	mu.Lock()
+	email, telegram := sent["email"], sent["telegram"]
+	mu.Unlock()
+	if email.Subject != "Disk alert on db<1>" || email.Message != "db<1> is 93% full" {
+		t.Errorf("Unexpected email text %q / %q", email.Subject, email.Message)
+	}
+	if email.HTML != "<p><strong>db&lt;1&gt;</strong> is 93% full</p>" {
+		t.Errorf("Unexpected email HTML %q", email.HTML)
+	}
+	if telegram.HTML != "<b>db&lt;1&gt;</b> is 93% full" {
+		t.Errorf("Unexpected Telegram HTML %q", telegram.HTML)
+	}
+
+	// Invalid requests are rejected before anything is sent
+	for name, payload := range map[string]map[string]interface{}{
+		"unknown template": {"provider_id": "email-main", "recipient": "ops@example.com", "template": "missing"},
+		"wrong type":       {"provider_id": "email-main", "recipient": "ops@example.com", "template": "disk-alert", "data": map[string]interface{}{"host": "db1", "used": "93"}},
+		"with message":     {"provider_id": "email-main", "recipient": "ops@example.com", "template": "disk-alert", "data": data, "message": "hi"},
+	} {
+		if status := post(payload); status != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, status)
+		}
+	}
+
+	// A broken edit keeps the previous version; removing the file removes the template
+	writeTemplate(`{"text": "{{.host"}`)
+	time.Sleep(600 * time.Millisecond) // Past the watcher's debounce delay
+	wg.Add(1)
+	if status := post(map[string]interface{}{"provider_id": "email-main", "recipient": "ops@example.com", "template": "disk-alert", "data": data}); status != http.StatusCreated {
+		t.Fatalf("Expected previous template to stay in use, got %d", status)
+	}
+	wg.Wait()
+
+	if err := os.Remove(filepath.Join(templatesDir, "disk-alert.json")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { _, ok := store.Get("disk-alert"); return !ok })
+}
+
+func TestLocalizedTemplatesForContacts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	db, err := sql.Open("sqlite3", t.TempDir()+"/contacts.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(storage.Schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+
+	var (
+		mu   sync.Mutex
+		sent *providers.Notification
+		wg   sync.WaitGroup
+	)
+	registry := providers.NewRegistry()
+	err = registry.Register(&testhelpers.MockProvider{
+		IDFunc:   func() string { return "mail" },
+		TypeFunc: func() string { return "email" },
+		SendFunc: func(_ context.Context, n *providers.Notification) error {
+			defer wg.Done()
+			mu.Lock()
+			sent = n
+			mu.Unlock()
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to register provider: %v", err)
+	}
+
+	dir := t.TempDir()
+	for file, text := range map[string]string{
+		"invoice.json":       "Invoice of {{formatNumber .amount 2}} due {{formatDateTime .due}}",
+		"invoice.zh-TW.json": "發票金額 {{formatNumber .amount 2}}，到期 {{formatDateTime .due}}",
+	} {
+		content := `{"variables": {"amount": {"type": "number", "required": true}, "due": {"type": "time", "required": true}}, "text": "` + text + `"}`
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := templates.NewStore()
+	if errs := store.LoadDir(dir); len(errs) != 0 {
+		t.Fatalf("LoadDir() errors = %v", errs)
+	}
+
+	server := httptest.NewServer(api.SetupRouter(registry, nil, storage.NewRepository(db), api.WithTemplates(store)))
+	defer server.Close()
+
+	post := func(path string, payload map[string]interface{}) int {
+		t.Helper()
+		body, _ := json.Marshal(payload)
+		resp, err := http.Post(server.URL+path, "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	send := func(payload map[string]interface{}) string {
+		t.Helper()
+		wg.Add(1)
+		if status := post("/api/v1/notifications", payload); status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", status)
+		}
+		wg.Wait()
+		mu.Lock()
+		defer mu.Unlock()
+		return sent.Message
+	}
+
+	status := post("/api/v1/contacts", map[string]interface{}{
+		"id":         "mei",
+		"locale":     "zh-tw",
+		"timezone":   "Asia/Taipei",
+		"identities": []map[string]string{{"provider_id": "mail", "address": "mei@example.com"}},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected contact to be created, got %d", status)
+	}
+
+	data := map[string]interface{}{"amount": 1234.5, "due": "2026-03-01T16:30:00Z"}
+
+	// The contact's locale picks the variant and its timezone the formatted time
+	if got := send(map[string]interface{}{"recipient": "contact:mei", "template": "invoice", "data": data}); got != "發票金額 1,234.50，到期 2026年3月2日 00:30 CST" {
+		t.Errorf("Unexpected message for contact %q", got)
+	}
+
+	// A locale on the request overrides the contact's; unknown locales fall back to the unlocalized template
+	if got := send(map[string]interface{}{"recipient": "contact:mei", "template": "invoice", "data": data, "locale": "de"}); got != "Invoice of 1.234,50 due 02.03.2026 00:30 CST" {
+		t.Errorf("Unexpected message for de %q", got)
+	}
+	if got := send(map[string]interface{}{"provider_id": "mail", "recipient": "a@example.com", "template": "invoice", "data": data}); got != "Invoice of 1,234.50 due Mar 1, 2026 4:30 PM UTC" {
+		t.Errorf("Unexpected message without locale %q", got)
+	}
+
+	for name, payload := range map[string]map[string]interface{}{
+		"invalid locale":   {"provider_id": "mail", "recipient": "a@example.com", "template": "invoice", "data": data, "locale": "chinese!"},
+		"unknown timezone": {"provider_id": "mail", "recipient": "a@example.com", "template": "invoice", "data": data, "timezone": "Mars/Olympus"},
+	} {
+		if status := post("/api/v1/notifications", payload); status != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, status)
+		}
+	}
+}
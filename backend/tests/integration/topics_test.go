@@ -0,0 +1,134 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/developertyrone/notimulti/internal/api"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+func TestTopicsRESTAndPublish(t *testing.T) {
+	db, err := sql.Open("sqlite3", t.TempDir()+"/topics.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(storage.Schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+
+	var (
+		mu   sync.Mutex
+		sent []string // "provider:recipient"
+		wg   sync.WaitGroup
+	)
+	registry := providers.NewRegistry()
+	for _, id := range []string{"chat", "mail"} {
+		id := id
+		err := registry.Register(&testhelpers.MockProvider{
+			IDFunc:   func() string { return id },
+			TypeFunc: func() string { return "mock" },
+			SendFunc: func(_ context.Context, n *providers.Notification) error {
+				defer wg.Done()
+				mu.Lock()
+				sent = append(sent, id+":"+n.Recipient)
+				mu.Unlock()
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to register %s: %v", id, err)
+		}
+	}
+
+	server := httptest.NewServer(api.SetupRouter(registry, nil, storage.NewRepository(db)))
+	defer server.Close()
+
+	request := func(method, path string, payload interface{}) *http.Response {
+		t.Helper()
+		var body bytes.Buffer
+		if payload != nil {
+			_ = json.NewEncoder(&body).Encode(payload)
+		}
+		req, _ := http.NewRequest(method, server.URL+path, &body)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		return resp
+	}
+	expectStatus := func(resp *http.Response, want int) {
+		t.Helper()
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("%s %s: expected status %d, got %d", resp.Request.Method, resp.Request.URL.Path, want, resp.StatusCode)
+		}
+	}
+
+	expectStatus(request(http.MethodPost, "/api/v1/topics", map[string]string{"name": "Billing Alerts"}), http.StatusBadRequest)
+	expectStatus(request(http.MethodPost, "/api/v1/topics", map[string]string{"name": "billing-alerts"}), http.StatusCreated)
+	expectStatus(request(http.MethodPost, "/api/v1/topics", map[string]string{"name": "billing-alerts"}), http.StatusConflict)
+
+	// Publishing to a topic without subscribers is rejected
+	publish := map[string]string{"subject": "Payment failed", "message": "Invoice 42 bounced"}
+	expectStatus(request(http.MethodPost, "/api/v1/topics/billing-alerts/publish", publish), http.StatusUnprocessableEntity)
+
+	expectStatus(request(http.MethodPost, "/api/v1/topics/billing-alerts/subscribers", map[string]string{"provider_id": "pager", "recipient": "1"}), http.StatusBadRequest)
+	for _, s := range []map[string]string{
+		{"provider_id": "chat", "recipient": "12345"},
+		{"provider_id": "mail", "recipient": "billing@example.com"},
+		{"provider_id": "mail", "recipient": "cfo@example.com"},
+	} {
+		expectStatus(request(http.MethodPost, "/api/v1/topics/billing-alerts/subscribers", s), http.StatusCreated)
+	}
+	expectStatus(request(http.MethodPost, "/api/v1/topics/missing/subscribers", map[string]string{"provider_id": "chat", "recipient": "1"}), http.StatusNotFound)
+
+	resp := request(http.MethodGet, "/api/v1/topics/billing-alerts", nil)
+	var topic api.TopicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&topic); err != nil {
+		t.Fatalf("Failed to decode topic: %v", err)
+	}
+	resp.Body.Close()
+	if topic.SubscriberCount != 3 || len(topic.Subscribers) != 3 {
+		t.Fatalf("Unexpected topic %+v", topic)
+	}
+
+	// Unsubscribe the CFO, then publish to the rest
+	expectStatus(request(http.MethodDelete, fmt.Sprintf("/api/v1/topics/billing-alerts/subscribers/%d", topic.Subscribers[2].ID), nil), http.StatusNoContent)
+
+	wg.Add(2)
+	resp = request(http.MethodPost, "/api/v1/topics/billing-alerts/publish", publish)
+	var result api.NotificationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode publish response: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || len(result.Children) != 2 {
+		t.Fatalf("Unexpected publish response %d %+v", resp.StatusCode, result)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	got := map[string]bool{}
+	for _, s := range sent {
+		got[s] = true
+	}
+	mu.Unlock()
+	if len(got) != 2 || !got["chat:12345"] || !got["mail:billing@example.com"] {
+		t.Errorf("Unexpected deliveries %v", got)
+	}
+
+	expectStatus(request(http.MethodDelete, "/api/v1/topics/billing-alerts", nil), http.StatusNoContent)
+	expectStatus(request(http.MethodGet, "/api/v1/topics/billing-alerts", nil), http.StatusNotFound)
+}
@@ -49,6 +49,18 @@ func TestHandleGetNotificationHistoryResponses(t *testing.T) {
 		}
 	})
 
+	t.Run("invalid status", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/notifications/history?status=delivered", nil)
+
+		handler(c)
+
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "expired") {
+			t.Fatalf("expected 400 listing the valid statuses, got %d %s", w.Code, w.Body.String())
+		}
+	})
+
 	t.Run("success with pagination", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
@@ -292,6 +304,53 @@ func TestHandleTestProvider(t *testing.T) {
 	})
 }
 
+func TestHandleSendNotificationMutedProvider(t *testing.T) {
+	registry := providers.NewRegistry()
+	sent := make(chan struct{}, 1)
+	mock := &testhelpers.MockProvider{
+		IDFunc:   func() string { return "muted" },
+		TypeFunc: func() string { return "telegram" },
+		SendFunc: func(ctx context.Context, n *providers.Notification) error {
+			sent <- struct{}{}
+			return nil
+		},
+	}
+	if err := registry.Register(mock); err != nil {
+		t.Fatalf("failed to register provider: %v", err)
+	}
+	if err := registry.Mute("muted", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to mute provider: %v", err)
+	}
+
+	handler := api.HandleSendNotification(registry, nil, nil, nil, nil, 0)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	body := `{"provider_id":"muted","recipient":"12345","message":"hello"}`
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/notifications", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handler(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+
+	var resp api.NotificationResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Status != storage.StatusMuted {
+		t.Fatalf("expected status muted, got %s", resp.Status)
+	}
+
+	select {
+	case <-sent:
+		t.Fatalf("muted provider must not deliver notifications")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
 func TestServeFrontend(t *testing.T) {
 	router := gin.New()
 	api.ServeFrontend(router, testFrontendFS)
@@ -329,3 +388,72 @@ func TestServeFrontend(t *testing.T) {
 		}
 	})
 }
+
+func TestHandleSendNotificationScheduling(t *testing.T) {
+	registry := providers.NewRegistry()
+	mock := &testhelpers.MockProvider{
+		IDFunc:   func() string { return "chat" },
+		TypeFunc: func() string { return "telegram" },
+	}
+	if err := registry.Register(mock); err != nil {
+		t.Fatalf("failed to register provider: %v", err)
+	}
+	repo, _ := setupTestRepository(t)
+
+	send := func(repo *storage.Repository, body string) *httptest.ResponseRecorder {
+		handler := api.HandleSendNotification(registry, nil, repo, nil, nil, 0)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/notifications", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		handler(c)
+		return w
+	}
+
+	invalid := []struct {
+		name string
+		body string
+		repo *storage.Repository
+	}{
+		{"both send_at and delay", `{"provider_id":"chat","recipient":"1","message":"hi","delay_seconds":60,"send_at":"2099-01-01T00:00:00Z"}`, repo},
+		{"negative delay", `{"provider_id":"chat","recipient":"1","message":"hi","delay_seconds":-5}`, repo},
+		{"malformed send_at", `{"provider_id":"chat","recipient":"1","message":"hi","send_at":"tomorrow"}`, repo},
+		{"send_at in the past", `{"provider_id":"chat","recipient":"1","message":"hi","send_at":"2001-01-01T00:00:00Z"}`, repo},
+		{"send_at too far ahead", `{"provider_id":"chat","recipient":"1","message":"hi","send_at":"2999-01-01T00:00:00Z"}`, repo},
+		{"no database", `{"provider_id":"chat","recipient":"1","message":"hi","delay_seconds":60}`, nil},
+	}
+	for _, tc := range invalid {
+		t.Run(tc.name, func(t *testing.T) {
+			if w := send(tc.repo, tc.body); w.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("delay schedules the notification", func(t *testing.T) {
+		before := time.Now()
+		w := send(repo, `{"provider_id":"chat","recipient":"1","message":"hi","delay_seconds":600}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp api.NotificationResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.Status != api.StatusScheduled || resp.SendAt == nil {
+			t.Fatalf("expected a scheduled response, got %+v", resp)
+		}
+		if resp.SendAt.Before(before.Add(599*time.Second)) || resp.SendAt.After(time.Now().Add(600*time.Second)) {
+			t.Errorf("unexpected send_at %s", resp.SendAt)
+		}
+
+		scheduled, err := repo.ListScheduledNotifications()
+		if err != nil {
+			t.Fatalf("failed to list scheduled notifications: %v", err)
+		}
+		if len(scheduled) != 1 || scheduled[0].ID != resp.ID || scheduled[0].Notification.Recipient != "1" {
+			t.Fatalf("unexpected scheduled notifications %+v", scheduled)
+		}
+	})
+}
@@ -1,11 +1,20 @@
 package unit
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/developertyrone/notimulti/internal/api"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
 )
 
 func TestValidateNotificationRequest(t *testing.T) {
@@ -70,7 +79,7 @@ func TestValidateNotificationRequest(t *testing.T) {
 
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			errs := api.ValidateNotificationRequest(tt.req)
+			errs := api.ValidateNotificationRequest(tt.req, nil)
 			if len(errs) != tt.want {
 				t.Fatalf("expected %d errors, got %d (%v)", tt.want, len(errs), errs)
 			}
@@ -78,6 +87,103 @@ func TestValidateNotificationRequest(t *testing.T) {
 	}
 }
 
+func TestValidateNotificationRequestCapabilities(t *testing.T) {
+	telegramCaps := &providers.Capabilities{
+		SupportsSubject:  true,
+		MaxMessageLength: 40960,
+		MaxSubjectLength: 200,
+		RecipientPattern: `^-?[0-9]+$`,
+	}
+
+	// Long messages are accepted when the provider can deliver them
+	req := &api.NotificationRequest{ProviderID: "telegram-1", Recipient: "-100123", Message: strings.Repeat("a", 5000)}
+	if errs := api.ValidateNotificationRequest(req, telegramCaps); len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+
+	// Recipients must match the provider's format
+	req = &api.NotificationRequest{ProviderID: "telegram-1", Recipient: "user@example.com", Message: "hi"}
+	errs := api.ValidateNotificationRequest(req, telegramCaps)
+	if len(errs) != 1 || errs[0].Field != "recipient" {
+		t.Fatalf("expected a recipient error, got %v", errs)
+	}
+
+	// Validation leaves requests unchanged; sends adapt them first, see TestSendAdaptsToCapabilities
+	noSubject := &providers.Capabilities{MaxMessageLength: 160}
+	req = &api.NotificationRequest{ProviderID: "sms-1", Recipient: "+15550100", Subject: "Alert", Message: "**disk** full", Format: "markdown"}
+	if errs := api.ValidateNotificationRequest(req, noSubject); len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+	if req.Subject != "Alert" || req.Message != "**disk** full" || req.Format != "markdown" {
+		t.Fatalf("expected the request to be left unchanged, got %+v", req)
+	}
+	req = &api.NotificationRequest{ProviderID: "sms-1", Recipient: "+15550100", Message: "hi", Format: "html"}
+	if errs := api.ValidateNotificationRequest(req, noSubject); len(errs) != 1 || errs[0].Field != "format" {
+		t.Fatalf("expected a format error, got %v", errs)
+	}
+
+	req = &api.NotificationRequest{ProviderID: "sms-1", Recipient: "+15550100", Message: strings.Repeat("a", 161)}
+	errs = api.ValidateNotificationRequest(req, noSubject)
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, "≤160") {
+		t.Fatalf("expected message length error against provider limit, got %v", errs)
+	}
+}
+
+// plainTextProvider is a mock provider without subject or Markdown support, like SMS
+type plainTextProvider struct {
+	testhelpers.MockProvider
+}
+
+func (p *plainTextProvider) Capabilities() providers.Capabilities {
+	return providers.Capabilities{MaxMessageLength: 160}
+}
+
+func TestSendAdaptsToCapabilities(t *testing.T) {
+	sent := make(chan *providers.Notification, 1)
+	registry := providers.NewRegistry()
+	if err := registry.Register(&plainTextProvider{testhelpers.MockProvider{
+		IDFunc: func() string { return "sms-1" },
+		SendFunc: func(_ context.Context, n *providers.Notification) error {
+			sent <- n
+			return nil
+		},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	router := api.SetupRouter(registry, nil, nil)
+
+	post := func(payload map[string]interface{}) int {
+		t.Helper()
+		body, _ := json.Marshal(payload)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/notifications", bytes.NewReader(body)))
+		return w.Code
+	}
+
+	// The subject is folded into the message and Markdown reduced to plain text
+	if code := post(map[string]interface{}{
+		"provider_id": "sms-1", "recipient": "+15550100", "subject": "Alert",
+		"message": "**disk** [full](https://x.io)", "format": "markdown",
+	}); code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", code)
+	}
+	select {
+	case n := <-sent:
+		if n.Subject != "" || n.Message != "Alert\n\ndisk full (https://x.io)" || n.Format != "" {
+			t.Errorf("expected an adapted plain text notification, got subject=%q message=%q format=%q", n.Subject, n.Message, n.Format)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the notification to be sent")
+	}
+
+	// Limits apply to the adapted message
+	if code := post(map[string]interface{}{
+		"provider_id": "sms-1", "recipient": "+15550100", "subject": strings.Repeat("s", 60), "message": strings.Repeat("m", 100),
+	}); code != http.StatusBadRequest {
+		t.Errorf("expected the folded message to exceed the limit, got %d", code)
+	}
+}
+
 func TestValidateEmailAndTelegram(t *testing.T) {
 	if api.ValidateEmailAddress("invalid") {
 		t.Fatal("expected invalid email to return false")
@@ -119,6 +225,12 @@ func TestValidateHistoryQueryParams(t *testing.T) {
 	if len(errs) != 0 {
 		t.Fatalf("expected no errors for valid params, got %d", len(errs))
 	}
+
+	// Every recorded status and provider type can be filtered, with dates as the dashboard sends them
+	errs = api.ValidateHistoryQueryParams(api.TopicProviderType, storage.StatusExpired, "2025-11-01T00:00:00.000Z", "2025-11-02T00:00:00+02:00", 100)
+	if len(errs) != 0 {
+		t.Fatalf("expected no errors, got %+v", errs)
+	}
 }
 
 func TestValidateTestRequest(t *testing.T) {
@@ -0,0 +1,184 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	breaker := providers.NewCircuitBreaker(2, 50*time.Millisecond)
+	failure := errors.New("smtp down")
+
+	for i := 0; i < 2; i++ {
+		if err := breaker.Allow(); err != nil {
+			t.Fatalf("expected closed breaker to allow send %d, got %v", i, err)
+		}
+		breaker.Record(failure)
+	}
+	if state := breaker.State(); state != providers.CircuitOpen {
+		t.Fatalf("expected open after 2 failures, got %s", state)
+	}
+	if err := breaker.Allow(); !errors.Is(err, providers.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen while open, got %v", err)
+	}
+
+	// After the cooldown a single probe is allowed
+	time.Sleep(60 * time.Millisecond)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("expected probe to be allowed after cooldown, got %v", err)
+	}
+	if state := breaker.State(); state != providers.CircuitHalfOpen {
+		t.Fatalf("expected half_open during probe, got %s", state)
+	}
+	if err := breaker.Allow(); !errors.Is(err, providers.ErrCircuitOpen) {
+		t.Fatalf("expected concurrent sends to be rejected during probe, got %v", err)
+	}
+
+	// A failed probe reopens the circuit
+	breaker.Record(failure)
+	if state := breaker.State(); state != providers.CircuitOpen {
+		t.Fatalf("expected open after failed probe, got %s", state)
+	}
+
+	// A successful probe closes it
+	time.Sleep(60 * time.Millisecond)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("expected probe to be allowed, got %v", err)
+	}
+	breaker.Record(nil)
+	if state := breaker.State(); state != providers.CircuitClosed {
+		t.Fatalf("expected closed after successful probe, got %s", state)
+	}
+}
+
+func TestRegistrySendFailsFastWhenCircuitOpen(t *testing.T) {
+	registry := providers.NewRegistry()
+	registry.SetCircuitBreakerSettings(3, time.Minute)
+
+	var sends int32
+	provider := &testhelpers.MockProvider{
+		IDFunc: func() string { return "email-down" },
+		SendFunc: func(ctx context.Context, n *providers.Notification) error {
+			atomic.AddInt32(&sends, 1)
+			return errors.New("connection refused")
+		},
+	}
+	if err := registry.Register(provider); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	notification := &providers.Notification{ID: "n-1", Recipient: "user@example.com", Message: "hi"}
+	for i := 0; i < 5; i++ {
+		if err := registry.Send(context.Background(), provider, notification); err == nil {
+			t.Fatalf("expected send %d to fail", i)
+		}
+	}
+
+	if n := atomic.LoadInt32(&sends); n != 3 {
+		t.Fatalf("expected provider to be called 3 times before the circuit opened, got %d", n)
+	}
+	if err := registry.Send(context.Background(), provider, notification); !errors.Is(err, providers.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if state := registry.StatusOf(provider).CircuitState; state != providers.CircuitOpen {
+		t.Fatalf("expected circuit_state open in status, got %q", state)
+	}
+
+	// Reloading the provider starts with a fresh breaker
+	if err := registry.Replace("email-down", provider); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	if state := registry.StatusOf(provider).CircuitState; state != providers.CircuitClosed {
+		t.Fatalf("expected closed circuit after reload, got %q", state)
+	}
+}
+
+func TestRegistrySendIgnoresExpiredNotificationsInBreaker(t *testing.T) {
+	registry := providers.NewRegistry()
+	registry.SetCircuitBreakerSettings(1, time.Minute)
+
+	provider := &testhelpers.MockProvider{
+		IDFunc: func() string { return "telegram-slow" },
+		SendFunc: func(ctx context.Context, n *providers.Notification) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+	if err := registry.Register(provider); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	// The delivery deadline is the notification's expiry, as set by the API
+	expiresAt := time.Now().Add(20 * time.Millisecond)
+	notification := &providers.Notification{ID: "n-1", Recipient: "42", Message: "CPU high", ExpiresAt: &expiresAt}
+	ctx, cancel := context.WithDeadline(context.Background(), expiresAt)
+	defer cancel()
+
+	err := registry.Send(ctx, provider, notification)
+	if !providers.ExpiredDuring(ctx, notification, err) {
+		t.Fatalf("expected the send to be attributed to the expiry, got %v", err)
+	}
+	if state := registry.StatusOf(provider).CircuitState; state != providers.CircuitClosed {
+		t.Fatalf("expected an expired send not to open the circuit, got %q", state)
+	}
+
+	// A half-open probe stopped by an expiry lets the next send probe
+	breaker := providers.NewCircuitBreaker(1, 10*time.Millisecond)
+	breaker.Record(errors.New("connection refused"))
+	time.Sleep(20 * time.Millisecond)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("expected a probe to be allowed, got %v", err)
+	}
+	breaker.Skip()
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("expected a skipped probe to allow another, got %v", err)
+	}
+	if state := breaker.State(); state != providers.CircuitHalfOpen {
+		t.Fatalf("expected half_open after a skipped probe, got %s", state)
+	}
+}
+
+func TestRegistrySendRateLimitsAndBreaker(t *testing.T) {
+	registry := providers.NewRegistry()
+	registry.SetCircuitBreakerSettings(2, time.Minute)
+
+	var upstream atomic.Bool
+	provider := &testhelpers.MockProvider{
+		IDFunc: func() string { return "telegram-busy" },
+		SendFunc: func(ctx context.Context, n *providers.Notification) error {
+			if upstream.Load() {
+				return providers.NewRateLimitedError(errors.New("429 Too Many Requests"), time.Second)
+			}
+			// The provider's own limiter turns the send away before it leaves the process
+			return providers.NewRateLimitedError(providers.ErrRateLimitExceeded, time.Second)
+		},
+	}
+	if err := registry.Register(provider); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	notification := &providers.Notification{ID: "n-1", Recipient: "42", Message: "burst"}
+
+	for i := 0; i < 5; i++ {
+		if err := registry.Send(context.Background(), provider, notification); !errors.Is(err, providers.ErrRateLimitExceeded) {
+			t.Fatalf("expected ErrRateLimitExceeded, got %v", err)
+		}
+	}
+	if state := registry.StatusOf(provider).CircuitState; state != providers.CircuitClosed {
+		t.Fatalf("expected local rate limit rejections not to open the circuit, got %q", state)
+	}
+
+	// Rate limits reported by the provider count as failures
+	upstream.Store(true)
+	for i := 0; i < 2; i++ {
+		_ = registry.Send(context.Background(), provider, notification)
+	}
+	if state := registry.StatusOf(provider).CircuitState; state != providers.CircuitOpen {
+		t.Fatalf("expected repeated upstream rate limits to open the circuit, got %q", state)
+	}
+}
@@ -0,0 +1,89 @@
+package unit
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/commands"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+func TestCommandHandlerAcknowledge(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	defer closeSQLDB(t, db)
+
+	id := insertLog(t, db, "telegram-main", "telegram", storage.StatusSent, time.Now(), false)
+	if _, err := db.Exec(`UPDATE notification_logs SET notification_id = ? WHERE id = ?`, "notif-uuid-1", id); err != nil {
+		t.Fatalf("failed to set notification_id: %v", err)
+	}
+
+	handler := commands.NewHandler(providers.NewRegistry(), repo, nil)
+
+	if err := handler.Acknowledge(context.Background(), "notif-uuid-1", "@oncall"); err != nil {
+		t.Fatalf("Acknowledge() error = %v", err)
+	}
+
+	entry, err := repo.GetNotificationByID(id)
+	if err != nil || entry == nil {
+		t.Fatalf("GetNotificationByID() = %v, %v", entry, err)
+	}
+	if !entry.AcknowledgedAt.Valid || entry.AcknowledgedBy.String != "@oncall" {
+		t.Fatalf("expected notification to be acknowledged by @oncall, got %+v", entry)
+	}
+
+	if err := handler.Acknowledge(context.Background(), "missing", "@oncall"); err == nil {
+		t.Fatalf("expected error when acknowledging unknown notification")
+	}
+}
+
+func TestCommandHandlerMuteAndStatus(t *testing.T) {
+	registry := providers.NewRegistry()
+	for _, id := range []string{"telegram-main", "email-main"} {
+		providerID := id
+		if err := registry.Register(&testhelpers.MockProvider{
+			IDFunc:   func() string { return providerID },
+			TypeFunc: func() string { return strings.Split(providerID, "-")[0] },
+		}); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+
+	handler := commands.NewHandler(registry, nil, nil)
+	ctx := context.Background()
+
+	if err := handler.Mute(ctx, "unknown", time.Hour, "@oncall"); err == nil {
+		t.Fatalf("expected error muting unknown provider")
+	}
+	if err := handler.Mute(ctx, "email-main", time.Hour, "@oncall"); err != nil {
+		t.Fatalf("Mute() error = %v", err)
+	}
+
+	until, muted := registry.MutedUntil("email-main")
+	if !muted || time.Until(until) < 59*time.Minute {
+		t.Fatalf("expected email-main muted for an hour, got %v (muted=%v)", until, muted)
+	}
+
+	status, err := handler.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	lines := strings.Split(status, "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "email-main (email): active, muted until") ||
+		lines[1] != "telegram-main (telegram): active" {
+		t.Fatalf("unexpected status output:\n%s", status)
+	}
+
+	if err := handler.Unmute(ctx, "email-main", "@oncall"); err != nil {
+		t.Fatalf("Unmute() error = %v", err)
+	}
+	if _, muted := registry.MutedUntil("email-main"); muted {
+		t.Fatalf("expected email-main to be unmuted")
+	}
+	if err := handler.Unmute(ctx, "email-main", "@oncall"); err == nil {
+		t.Fatalf("expected error unmuting a provider that is not muted")
+	}
+}
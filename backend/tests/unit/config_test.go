@@ -1,11 +1,13 @@
 package unit
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/developertyrone/notimulti/internal/config"
+	"github.com/developertyrone/notimulti/internal/providers"
 )
 
 func TestLoadAll(t *testing.T) {
@@ -389,6 +391,218 @@ func TestValidateConfigEmailStringPort(t *testing.T) {
 	}
 }
 
+func TestValidateAndBuildRetryConfig(t *testing.T) {
+	newConfig := func(retry interface{}) *config.ProviderConfig {
+		return &config.ProviderConfig{
+			ID:      "email-retry",
+			Type:    "email",
+			Enabled: true,
+			Config: map[string]interface{}{
+				"host":     "smtp.example.com",
+				"port":     float64(587),
+				"username": "user@example.com",
+				"password": "secret",
+				"from":     "user@example.com",
+				"retry":    retry,
+			},
+		}
+	}
+
+	invalid := []map[string]interface{}{
+		{"max_attempts": float64(0)},
+		{"max_attempts": float64(2.5)},
+		{"initial_delay_ms": float64(-1)},
+		{"initial_delay_ms": float64(5000), "max_delay_ms": float64(1000)},
+		{"jitter": float64(1.5)},
+		{"retryable_errors": []interface{}{""}},
+	}
+	for _, retry := range invalid {
+		if err := config.ValidateConfig(newConfig(retry)); err == nil {
+			t.Errorf("expected validation error for retry %v", retry)
+		}
+	}
+
+	cfg := newConfig(map[string]interface{}{
+		"max_attempts":     float64(4),
+		"initial_delay_ms": float64(60000),
+		"max_delay_ms":     float64(360000),
+		"multiplier":       float64(5),
+		"retryable_errors": []interface{}{"451"},
+	})
+	if err := config.ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig failed for valid retry block: %v", err)
+	}
+
+	built, err := config.BuildProviderConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildProviderConfig() error = %v", err)
+	}
+	retry := built.Email.Retry
+	if retry == nil || retry.MaxAttempts != 4 || retry.InitialDelayMs != 60000 || retry.MaxDelayMs != 360000 ||
+		retry.Multiplier != 5 || len(retry.RetryableErrors) != 1 {
+		t.Fatalf("unexpected retry config %+v", retry)
+	}
+}
+
+func TestValidateAndBuildRateLimitConfig(t *testing.T) {
+	newConfig := func(rateLimit interface{}) *config.ProviderConfig {
+		return &config.ProviderConfig{
+			ID:      "telegram-limited",
+			Type:    "telegram",
+			Enabled: true,
+			Config: map[string]interface{}{
+				"bot_token":       "123456:ABC-DEF",
+				"default_chat_id": "-100123",
+				"rate_limit":      rateLimit,
+			},
+		}
+	}
+
+	invalid := []interface{}{
+		"30/s",
+		map[string]interface{}{},
+		map[string]interface{}{"global": map[string]interface{}{"limit": float64(0), "interval_ms": float64(1000)}},
+		map[string]interface{}{"global": map[string]interface{}{"limit": float64(30)}},
+		map[string]interface{}{"per_recipient": map[string]interface{}{"limit": float64(1), "interval_ms": float64(1000), "burst": float64(0.5)}},
+		map[string]interface{}{"global": map[string]interface{}{"limit": float64(1), "interval_ms": float64(1000)}, "max_wait_ms": float64(-1)},
+	}
+	for _, rateLimit := range invalid {
+		if err := config.ValidateConfig(newConfig(rateLimit)); err == nil {
+			t.Errorf("expected validation error for rate_limit %v", rateLimit)
+		}
+	}
+
+	cfg := newConfig(map[string]interface{}{
+		"global":        map[string]interface{}{"limit": float64(30), "interval_ms": float64(1000)},
+		"per_recipient": map[string]interface{}{"limit": float64(1), "interval_ms": float64(1000), "burst": float64(3)},
+		"max_wait_ms":   float64(5000),
+	})
+	if err := config.ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig failed for valid rate_limit block: %v", err)
+	}
+
+	built, err := config.BuildProviderConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildProviderConfig() error = %v", err)
+	}
+	rateLimit := built.Telegram.RateLimit
+	if rateLimit == nil || rateLimit.Global == nil || rateLimit.Global.Limit != 30 || rateLimit.Global.IntervalMs != 1000 ||
+		rateLimit.PerRecipient == nil || rateLimit.PerRecipient.Burst != 3 || rateLimit.MaxWaitMs != 5000 {
+		t.Fatalf("unexpected rate_limit config %+v", rateLimit)
+	}
+}
+
+func TestValidateAndBuildGroupConfig(t *testing.T) {
+	newConfig := func(members interface{}) *config.ProviderConfig {
+		return &config.ProviderConfig{
+			ID:      "oncall",
+			Type:    "group",
+			Enabled: true,
+			Config:  map[string]interface{}{"members": members},
+		}
+	}
+
+	invalid := []interface{}{
+		nil,
+		[]interface{}{},
+		[]interface{}{"telegram-main"},
+		[]interface{}{map[string]interface{}{"provider_id": "Bad ID"}},
+		[]interface{}{map[string]interface{}{"provider_id": "oncall"}},
+		[]interface{}{map[string]interface{}{"provider_id": "a"}, map[string]interface{}{"provider_id": "a"}},
+		[]interface{}{map[string]interface{}{"provider_id": "a", "recipient": true}},
+	}
+	for _, members := range invalid {
+		if err := config.ValidateConfig(newConfig(members)); err == nil {
+			t.Errorf("expected validation error for members %v", members)
+		}
+	}
+
+	cfg := newConfig([]interface{}{
+		map[string]interface{}{"provider_id": "telegram-main", "recipient": float64(-1001234567890)},
+		map[string]interface{}{"provider_id": "email-ops"},
+	})
+	if err := config.ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig failed for valid group: %v", err)
+	}
+
+	built, err := config.BuildProviderConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildProviderConfig() error = %v", err)
+	}
+	provider, err := providers.NewFactory().NewProvider(built)
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	group, ok := provider.(*providers.GroupProvider)
+	if !ok {
+		t.Fatalf("expected a *GroupProvider, got %T", provider)
+	}
+	members := group.Members()
+	if len(members) != 2 || members[0].Recipient != "-1001234567890" || members[1].ProviderID != "email-ops" {
+		t.Fatalf("unexpected members %+v", members)
+	}
+	if err := group.Send(context.Background(), &providers.Notification{}); err == nil {
+		t.Error("expected direct sends through a group to fail")
+	}
+}
+
+func TestValidateAndBuildFailoverConfig(t *testing.T) {
+	newConfig := func(hops interface{}) *config.ProviderConfig {
+		return &config.ProviderConfig{
+			ID:      "critical",
+			Type:    "failover",
+			Enabled: true,
+			Config:  map[string]interface{}{"hops": hops},
+		}
+	}
+
+	invalid := []interface{}{
+		nil,
+		[]interface{}{},
+		[]interface{}{"email-ops"},
+		[]interface{}{map[string]interface{}{"provider_id": "Bad ID"}},
+		[]interface{}{map[string]interface{}{"provider_id": "critical"}},
+		[]interface{}{map[string]interface{}{"provider_id": "a"}, map[string]interface{}{"provider_id": "a"}},
+		[]interface{}{map[string]interface{}{"provider_id": "a", "recipient": ""}},
+		[]interface{}{map[string]interface{}{"provider_id": "a", "timeout_seconds": float64(0)}},
+		[]interface{}{map[string]interface{}{"provider_id": "a", "timeout_seconds": 1.5}},
+		[]interface{}{map[string]interface{}{"provider_id": "a", "timeout_seconds": float64(3601)}},
+	}
+	for _, hops := range invalid {
+		if err := config.ValidateConfig(newConfig(hops)); err == nil {
+			t.Errorf("expected validation error for hops %v", hops)
+		}
+	}
+
+	cfg := newConfig([]interface{}{
+		map[string]interface{}{"provider_id": "email-ops", "recipient": "ops@example.com", "timeout_seconds": float64(60)},
+		map[string]interface{}{"provider_id": "telegram-main", "recipient": float64(-1001234567890)},
+	})
+	if err := config.ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig failed for valid failover chain: %v", err)
+	}
+
+	built, err := config.BuildProviderConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildProviderConfig() error = %v", err)
+	}
+	provider, err := providers.NewFactory().NewProvider(built)
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	chain, ok := provider.(*providers.FailoverProvider)
+	if !ok {
+		t.Fatalf("expected a *FailoverProvider, got %T", provider)
+	}
+	hops := chain.Hops()
+	if len(hops) != 2 || hops[0].TimeoutSeconds != 60 || hops[1].Recipient != "-1001234567890" {
+		t.Fatalf("unexpected hops %+v", hops)
+	}
+	if err := chain.Send(context.Background(), &providers.Notification{}); err == nil {
+		t.Error("expected direct sends through a failover chain to fail")
+	}
+}
+
 func TestGetConfigPath(t *testing.T) {
 	baseDir := filepath.Join(os.TempDir(), "configs")
 	loader := config.NewLoader(baseDir)
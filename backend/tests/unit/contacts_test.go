@@ -0,0 +1,112 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/storage"
+)
+
+func TestContactStoreCRUD(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	defer db.Close()
+
+	alice := &storage.Contact{
+		ID:                "alice",
+		Name:              "Alice",
+		PreferredProvider: "telegram-main",
+		Timezone:          "Europe/Berlin",
+		QuietHours:        &storage.QuietHours{Start: "22:00", End: "07:00"},
+		Identities: []storage.ContactIdentity{
+			{ProviderID: "telegram-main", Address: "12345"},
+			{ProviderID: "email-ops", Address: "alice@example.com"},
+		},
+	}
+	created, err := repo.CreateContact(alice)
+	if err != nil {
+		t.Fatalf("CreateContact() error = %v", err)
+	}
+	if created.QuietHours == nil || created.QuietHours.Start != "22:00" || len(created.Identities) != 2 {
+		t.Errorf("unexpected contact %+v", created)
+	}
+	if _, err := repo.CreateContact(alice); !errors.Is(err, storage.ErrContactExists) {
+		t.Errorf("expected ErrContactExists, got %v", err)
+	}
+
+	// Updates replace the identities and keep their order
+	alice.QuietHours = nil
+	alice.Identities = []storage.ContactIdentity{
+		{ProviderID: "email-ops", Address: "alice@example.org"},
+		{ProviderID: "telegram-main", Address: "12345"},
+	}
+	updated, err := repo.UpdateContact(alice)
+	if err != nil {
+		t.Fatalf("UpdateContact() error = %v", err)
+	}
+	if updated.QuietHours != nil || updated.Identities[0].Address != "alice@example.org" {
+		t.Errorf("unexpected contact after update %+v", updated)
+	}
+	if address, ok := updated.Identity("telegram-main"); !ok || address != "12345" {
+		t.Errorf("Identity(telegram-main) = %q, %v", address, ok)
+	}
+	if _, err := repo.UpdateContact(&storage.Contact{ID: "bob"}); !errors.Is(err, storage.ErrContactNotFound) {
+		t.Errorf("expected ErrContactNotFound, got %v", err)
+	}
+
+	contacts, err := repo.ListContacts()
+	if err != nil || len(contacts) != 1 || len(contacts[0].Identities) != 2 {
+		t.Fatalf("unexpected contacts %+v, %v", contacts, err)
+	}
+
+	if err := repo.DeleteContact("alice"); err != nil {
+		t.Fatalf("DeleteContact() error = %v", err)
+	}
+	if err := repo.DeleteContact("alice"); !errors.Is(err, storage.ErrContactNotFound) {
+		t.Errorf("expected ErrContactNotFound, got %v", err)
+	}
+	var remaining int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM contact_identities`).Scan(&remaining); err != nil || remaining != 0 {
+		t.Errorf("expected identities to be deleted, got %d (%v)", remaining, err)
+	}
+}
+
+func TestQuietHoursActive(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 3, 10, hour, minute, 0, 0, berlin)
+	}
+
+	overnight := &storage.QuietHours{Start: "22:00", End: "07:00"}
+	daytime := &storage.QuietHours{Start: "12:00", End: "13:30"}
+
+	tests := []struct {
+		name      string
+		quiet     *storage.QuietHours
+		now       time.Time
+		wantQuiet bool
+		wantUntil time.Time
+	}{
+		{"before overnight window", overnight, at(21, 59), false, time.Time{}},
+		{"evening in overnight window", overnight, at(23, 0), true, time.Date(2026, 3, 11, 7, 0, 0, 0, berlin)},
+		{"morning in overnight window", overnight, at(6, 59), true, at(7, 0)},
+		{"end is exclusive", overnight, at(7, 0), false, time.Time{}},
+		{"inside daytime window", daytime, at(12, 45), true, at(13, 30)},
+		{"after daytime window", daytime, at(14, 0), false, time.Time{}},
+		{"no quiet hours", nil, at(23, 0), false, time.Time{}},
+		{"window timezone wins", &storage.QuietHours{Start: "22:00", End: "07:00", Timezone: "America/New_York"}, at(5, 0), true, at(12, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Evaluated in the contact's timezone whatever the server's
+			quiet, until := tt.quiet.Active(tt.now.UTC(), berlin)
+			if quiet != tt.wantQuiet || !until.Equal(tt.wantUntil) {
+				t.Errorf("Active() = %v, %v; want %v, %v", quiet, until, tt.wantQuiet, tt.wantUntil)
+			}
+		})
+	}
+}
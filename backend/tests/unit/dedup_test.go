@@ -0,0 +1,156 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/config"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+)
+
+func TestValidateAndBuildDedupConfig(t *testing.T) {
+	newConfig := func(dedup interface{}) *config.ProviderConfig {
+		return &config.ProviderConfig{
+			ID:      "email-alerts",
+			Type:    "email",
+			Enabled: true,
+			Config: map[string]interface{}{
+				"host":     "smtp.example.com",
+				"port":     float64(587),
+				"username": "alerts",
+				"password": "secret",
+				"from":     "alerts@example.com",
+				"use_tls":  true,
+				"dedup":    dedup,
+			},
+		}
+	}
+
+	invalid := []interface{}{
+		"5m",
+		map[string]interface{}{},
+		map[string]interface{}{"window_seconds": float64(0)},
+		map[string]interface{}{"window_seconds": float64(90000)},
+		map[string]interface{}{"window_seconds": float64(2.5)},
+		map[string]interface{}{"window_seconds": float64(300), "metadata_keys": "check"},
+		map[string]interface{}{"window_seconds": float64(300), "metadata_keys": []interface{}{""}},
+		map[string]interface{}{"window_seconds": float64(300), "notify_repeats": "yes"},
+	}
+	for _, dedup := range invalid {
+		if err := config.ValidateConfig(newConfig(dedup)); err == nil {
+			t.Errorf("expected validation error for dedup %v", dedup)
+		}
+	}
+
+	cfg := newConfig(map[string]interface{}{
+		"window_seconds": float64(300),
+		"metadata_keys":  []interface{}{"check", "host"},
+		"notify_repeats": true,
+	})
+	if err := config.ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig failed for valid dedup block: %v", err)
+	}
+
+	built, err := config.BuildProviderConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildProviderConfig() error = %v", err)
+	}
+	dedup := built.Email.Dedup
+	if dedup == nil || dedup.Window() != 5*time.Minute || len(dedup.MetadataKeys) != 2 || !dedup.NotifyRepeats {
+		t.Fatalf("unexpected dedup config %+v", dedup)
+	}
+}
+
+func TestDedupFingerprint(t *testing.T) {
+	dedup := &providers.DedupConfig{WindowSeconds: 60, MetadataKeys: []string{"check"}}
+	base := providers.Notification{
+		ProviderID: "alerts",
+		Recipient:  "ops",
+		Subject:    "Health check failed",
+		Message:    "api returned 503",
+		Metadata:   map[string]interface{}{"check": "api", "attempt": float64(1)},
+	}
+	fingerprint := dedup.Fingerprint(&base)
+
+	same := base
+	same.Message = "api returned 502"
+	same.Metadata = map[string]interface{}{"check": "api", "attempt": float64(2)}
+	if dedup.Fingerprint(&same) != fingerprint {
+		t.Error("message and unselected metadata should not change the fingerprint")
+	}
+
+	tests := []struct {
+		name   string
+		change func(n *providers.Notification)
+	}{
+		{"provider", func(n *providers.Notification) { n.ProviderID = "other" }},
+		{"recipient", func(n *providers.Notification) { n.Recipient = "dev" }},
+		{"subject", func(n *providers.Notification) { n.Subject = "Health check recovered" }},
+		{"metadata key", func(n *providers.Notification) { n.Metadata = map[string]interface{}{"check": "db"} }},
+		{"missing metadata key", func(n *providers.Notification) { n.Metadata = nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := base
+			tt.change(&n)
+			if dedup.Fingerprint(&n) == fingerprint {
+				t.Errorf("changing the %s should change the fingerprint", tt.name)
+			}
+		})
+	}
+}
+
+func TestDedupWindowStore(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	defer db.Close()
+
+	now := time.Now()
+	track := func(id, fingerprint string, window time.Duration) (*storage.DedupWindow, bool) {
+		t.Helper()
+		tracked, duplicate, err := repo.TrackDuplicate(&storage.DedupWindow{
+			ID:            id,
+			Fingerprint:   fingerprint,
+			ProviderID:    "alerts",
+			ProviderType:  "email",
+			Recipient:     "ops",
+			NotifyRepeats: true,
+			ExpiresAt:     now.Add(window),
+			Notification:  &providers.Notification{ID: id, ProviderID: "alerts", Recipient: "ops", Message: "down", Timestamp: now},
+		})
+		if err != nil {
+			t.Fatalf("TrackDuplicate() error = %v", err)
+		}
+		return tracked, duplicate
+	}
+
+	// The first notification opens a window; repeats are counted on it
+	if window, duplicate := track("n1", "fp-a", time.Minute); duplicate || window.ID != "n1" {
+		t.Fatalf("expected n1 to open a window, got %+v, %v", window, duplicate)
+	}
+	track("n2", "fp-a", time.Hour)
+	window, duplicate := track("n3", "fp-a", time.Hour)
+	if !duplicate || window.ID != "n1" || window.Suppressed != 2 || !window.ExpiresAt.Equal(now.Add(time.Minute).Truncate(time.Millisecond)) {
+		t.Fatalf("expected n3 to be the second duplicate of n1, got %+v, %v", window, duplicate)
+	}
+	if _, duplicate := track("n4", "fp-b", time.Hour); duplicate {
+		t.Fatal("another fingerprint should open its own window")
+	}
+
+	open, err := repo.ListDedupWindows()
+	if err != nil || len(open) != 2 || open[0].ID != "n1" || open[0].Notification.Message != "down" {
+		t.Fatalf("ListDedupWindows() = %+v, %v", open, err)
+	}
+
+	// Windows are closed once when they end; later notifications open a new one
+	closed, err := repo.CloseExpiredDedupWindows(now.Add(time.Minute), 10)
+	if err != nil || len(closed) != 1 || closed[0].ID != "n1" || closed[0].Suppressed != 2 || !closed[0].NotifyRepeats {
+		t.Fatalf("CloseExpiredDedupWindows() = %+v, %v", closed, err)
+	}
+	if again, _ := repo.CloseExpiredDedupWindows(now.Add(time.Minute), 10); len(again) != 0 {
+		t.Fatalf("a window should be closed once, got %+v", again)
+	}
+	if window, duplicate := track("n5", "fp-a", time.Minute); duplicate || window.ID != "n5" {
+		t.Fatalf("expected a new window after the close, got %+v, %v", window, duplicate)
+	}
+}
@@ -0,0 +1,246 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+)
+
+// recorder collects the order in which queued deliveries run
+type recorder struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (r *recorder) task(name string) func(context.Context) {
+	return func(context.Context) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.order = append(r.order, name)
+	}
+}
+
+func (r *recorder) names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.order...)
+}
+
+func assertOrder(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected deliveries %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected deliveries %v, got %v", want, got)
+		}
+	}
+}
+
+func TestDeliveryQueueRunsHighPriorityFirst(t *testing.T) {
+	// A single low lane worker serves every lane, so the order is deterministic
+	queue := providers.NewDeliveryQueue(0, 0, 1, time.Hour, 0, nil)
+	rec := &recorder{}
+
+	queue.Submit(providers.PriorityLow, rec.task("low"))
+	queue.Submit("", rec.task("default"))
+	queue.Submit(providers.PriorityNormal, rec.task("normal"))
+	queue.Submit(providers.PriorityHigh, rec.task("high"))
+
+	queue.Start()
+	queue.Stop()
+
+	assertOrder(t, rec.names(), "high", "default", "normal", "low")
+}
+
+func TestDeliveryQueuePromotesStarvedDeliveries(t *testing.T) {
+	queue := providers.NewDeliveryQueue(0, 0, 1, 20*time.Millisecond, 0, nil)
+	rec := &recorder{}
+
+	queue.Submit(providers.PriorityLow, rec.task("old-low"))
+	time.Sleep(40 * time.Millisecond)
+	queue.Submit(providers.PriorityHigh, rec.task("high"))
+	queue.Submit(providers.PriorityLow, rec.task("new-low"))
+
+	queue.Start()
+	queue.Stop()
+
+	assertOrder(t, rec.names(), "old-low", "high", "new-low")
+}
+
+func TestDeliveryQueueReservesHighPriorityWorkers(t *testing.T) {
+	queue := providers.NewDeliveryQueue(1, 0, 1, time.Hour, 0, nil)
+	queue.Start()
+	defer queue.Stop()
+
+	// Block the only low lane worker with a low priority delivery
+	release := make(chan struct{})
+	started := make(chan struct{})
+	queue.Submit(providers.PriorityLow, func(context.Context) {
+		close(started)
+		<-release
+	})
+	<-started
+
+	// Normal work waits for the low worker; the high worker never takes it
+	normalDone := make(chan struct{})
+	queue.Submit(providers.PriorityNormal, func(context.Context) { close(normalDone) })
+
+	highDone := make(chan struct{})
+	queue.Submit(providers.PriorityHigh, func(context.Context) { close(highDone) })
+
+	select {
+	case <-highDone:
+	case <-time.After(time.Second):
+		t.Fatal("expected the reserved high priority worker to deliver while the low worker is busy")
+	}
+
+	select {
+	case <-normalDone:
+		t.Fatal("expected normal priority work to wait for a worker that serves its lane")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	status := queue.Status()
+	if status[1].Lane != providers.PriorityNormal || status[1].Queued != 1 {
+		t.Errorf("expected one queued normal delivery, got %+v", status[1])
+	}
+	if status[2].Lane != providers.PriorityLow || status[2].Busy != 1 {
+		t.Errorf("expected the low worker to be busy, got %+v", status[2])
+	}
+	if status[1].OldestQueuedAt == nil {
+		t.Error("expected oldest_queued_at for the normal lane")
+	}
+
+	close(release)
+	select {
+	case <-normalDone:
+	case <-time.After(time.Second):
+		t.Fatal("expected normal priority work to run once the low worker is free")
+	}
+}
+
+func TestDeliveryQueueStopDrainsAndFallsBack(t *testing.T) {
+	queue := providers.NewDeliveryQueue(-1, -1, 0, 0, 0, nil)
+	status := queue.Status()
+	if status[0].Workers != providers.DefaultHighPriorityWorkers ||
+		status[1].Workers != providers.DefaultNormalPriorityWorkers ||
+		status[2].Workers != providers.DefaultLowPriorityWorkers {
+		t.Fatalf("expected default worker counts, got %+v", status)
+	}
+
+	rec := &recorder{}
+	for i := 0; i < 20; i++ {
+		queue.Submit(providers.PriorityLow, rec.task("queued"))
+	}
+	queue.Start()
+	queue.Stop()
+	if n := len(rec.names()); n != 20 {
+		t.Fatalf("expected Stop to finish all 20 queued deliveries, got %d", n)
+	}
+
+	// Deliveries after Stop still run
+	done := make(chan struct{})
+	queue.Submit(providers.PriorityHigh, func(context.Context) { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected a delivery submitted after Stop to run")
+	}
+}
+
+func TestRegistryEnqueue(t *testing.T) {
+	registry := providers.NewRegistry()
+
+	// Without a queue each delivery runs in its own goroutine
+	done := make(chan struct{})
+	registry.Enqueue(providers.PriorityNormal, func(context.Context) { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected delivery without a queue to run")
+	}
+
+	queue := providers.NewDeliveryQueue(0, 0, 1, time.Hour, 0, nil)
+	registry.SetDeliveryQueue(queue)
+	if registry.DeliveryQueue() != queue {
+		t.Fatal("expected DeliveryQueue to return the queue that was set")
+	}
+
+	rec := &recorder{}
+	registry.Enqueue(providers.PriorityLow, rec.task("low"))
+	registry.Enqueue(providers.PriorityHigh, rec.task("high"))
+	queue.Start()
+	queue.Stop()
+	assertOrder(t, rec.names(), "high", "low")
+}
+
+func TestDeliveryQueueReleasesWorkersWhileWaiting(t *testing.T) {
+	queue := providers.NewDeliveryQueue(0, 0, 1, time.Hour, 0, nil)
+	queue.Start()
+	defer queue.Stop()
+
+	// The only worker's delivery fails once and backs off before retrying
+	policy := providers.RetryPolicy{MaxAttempts: 2, InitialDelay: 300 * time.Millisecond, MaxDelay: 300 * time.Millisecond, Multiplier: 1}
+	backingOff := make(chan struct{})
+	retried := make(chan struct{})
+	if err := queue.Submit(providers.PriorityLow, func(ctx context.Context) {
+		_ = policy.Do(ctx, func(attempt int) error {
+			if attempt == 1 {
+				close(backingOff)
+				return errors.New("temporary failure")
+			}
+			close(retried)
+			return nil
+		})
+	}); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	<-backingOff
+
+	done := make(chan struct{})
+	if err := queue.Submit(providers.PriorityLow, func(context.Context) { close(done) }); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	select {
+	case <-done:
+	case <-retried:
+		t.Fatal("expected the worker to run other deliveries during the backoff")
+	case <-time.After(time.Second):
+		t.Fatal("expected the worker to run other deliveries during the backoff")
+	}
+
+	if status := queue.Status()[2]; status.Waiting != 1 {
+		t.Errorf("expected one delivery waiting for its retry, got %+v", status)
+	}
+
+	select {
+	case <-retried:
+	case <-time.After(time.Second):
+		t.Fatal("expected the delivery to be retried after the backoff")
+	}
+}
+
+func TestDeliveryQueueRejectsDeliveriesWhenFull(t *testing.T) {
+	queue := providers.NewDeliveryQueue(0, 0, 1, time.Hour, 2, nil)
+	rec := &recorder{}
+
+	for _, name := range []string{"first", "second"} {
+		if err := queue.Submit(providers.PriorityNormal, rec.task(name)); err != nil {
+			t.Fatalf("Submit() error = %v", err)
+		}
+	}
+	err := queue.Submit(providers.PriorityHigh, rec.task("third"))
+	if !errors.Is(err, providers.ErrQueueFull) || providers.ErrorCategory(err) != providers.ErrorCategoryTransient {
+		t.Fatalf("expected a transient ErrQueueFull error, got %v", err)
+	}
+
+	queue.Start()
+	queue.Stop()
+	assertOrder(t, rec.names(), "first", "second")
+}
@@ -0,0 +1,170 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/config"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/routing"
+	"github.com/developertyrone/notimulti/internal/storage"
+)
+
+func TestValidateAndBuildDigestConfig(t *testing.T) {
+	newConfig := func(digest interface{}) *config.ProviderConfig {
+		return &config.ProviderConfig{
+			ID:      "telegram-batch",
+			Type:    "telegram",
+			Enabled: true,
+			Config: map[string]interface{}{
+				"bot_token":       "123456:ABC-DEF",
+				"default_chat_id": "-100123",
+				"digest":          digest,
+			},
+		}
+	}
+
+	invalid := []interface{}{
+		"10m",
+		map[string]interface{}{},
+		map[string]interface{}{"window_seconds": float64(0)},
+		map[string]interface{}{"window_seconds": float64(90000)},
+		map[string]interface{}{"window_seconds": float64(1.5)},
+		map[string]interface{}{"window_seconds": float64(600), "max_items": float64(5000)},
+		map[string]interface{}{"window_seconds": float64(600), "template": ""},
+	}
+	for _, digest := range invalid {
+		if err := config.ValidateConfig(newConfig(digest)); err == nil {
+			t.Errorf("expected validation error for digest %v", digest)
+		}
+	}
+
+	cfg := newConfig(map[string]interface{}{
+		"window_seconds": float64(600),
+		"max_items":      float64(50),
+		"template":       "nightly-digest",
+	})
+	if err := config.ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig failed for valid digest block: %v", err)
+	}
+
+	built, err := config.BuildProviderConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildProviderConfig() error = %v", err)
+	}
+	digest := built.Telegram.Digest
+	if digest == nil || digest.Window() != 10*time.Minute || digest.Limit() != 50 || digest.Template != "nightly-digest" {
+		t.Fatalf("unexpected digest config %+v", digest)
+	}
+	if limit := (&providers.DigestConfig{WindowSeconds: 60}).Limit(); limit != providers.DefaultDigestMaxItems {
+		t.Errorf("Limit() without max_items = %d, want %d", limit, providers.DefaultDigestMaxItems)
+	}
+}
+
+func TestRoutingRuleDigest(t *testing.T) {
+	_, err := routing.Parse([]byte(`{"rules": [{"name": "batch", "targets": [{"provider_id": "chat"}],
+		"digest": {"window_seconds": 0}}]}`))
+	if err == nil || !strings.Contains(err.Error(), "rules[0].digest.window_seconds") {
+		t.Fatalf("expected digest validation error, got %v", err)
+	}
+
+	rules, err := routing.Parse([]byte(`{"rules": [{"name": "batch", "match": {"priority": ["low"]},
+		"targets": [{"provider_id": "chat"}], "digest": {"window_seconds": 300, "max_items": 20}}]}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if rules[0].Digest == nil || rules[0].Digest.WindowSeconds != 300 {
+		t.Fatalf("unexpected rule %+v", rules[0])
+	}
+}
+
+func TestDigestStore(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	defer db.Close()
+
+	now := time.Now()
+	add := func(id, recipient, message string, window time.Duration, maxItems int) *storage.Digest {
+		t.Helper()
+		digest, err := repo.AddToDigest(&storage.Digest{
+			ID:           "digest-" + id,
+			ProviderID:   "chat",
+			ProviderType: "telegram",
+			Recipient:    recipient,
+			FlushAt:      now.Add(window),
+		}, &providers.Notification{ID: id, ProviderID: "chat", Recipient: recipient, Message: message, Timestamp: now}, maxItems)
+		if err != nil {
+			t.Fatalf("AddToDigest() error = %v", err)
+		}
+		return digest
+	}
+
+	// Notifications for the same recipient share the open digest and its flush time
+	first := add("n1", "100", "first", time.Minute, 10)
+	second := add("n2", "100", "second", time.Hour, 10)
+	if second.ID != first.ID || second.Count != 2 || !second.FlushAt.Equal(first.FlushAt) {
+		t.Fatalf("expected both notifications in digest %s, got %+v", first.ID, second)
+	}
+	other := add("n3", "200", "other", time.Hour, 2)
+	if other.ID == first.ID {
+		t.Fatal("another recipient should get its own digest")
+	}
+
+	// Filling a digest makes it due now
+	full := add("n4", "200", "full", time.Hour, 2)
+	if full.ID != other.ID || full.FlushAt.After(time.Now()) {
+		t.Fatalf("expected full digest to be due now, got %+v", full)
+	}
+
+	open, err := repo.ListDigests()
+	if err != nil || len(open) != 2 || open[0].ID != other.ID || open[0].Count != 2 {
+		t.Fatalf("ListDigests() = %+v, %v", open, err)
+	}
+
+	due, err := repo.ClaimDueDigests(time.Now(), time.Minute, 10)
+	if err != nil || len(due) != 1 || due[0].ID != other.ID {
+		t.Fatalf("ClaimDueDigests() = %+v, %v", due, err)
+	}
+	if len(due[0].Items) != 2 || due[0].Items[0].Message != "other" || due[0].Items[1].Message != "full" {
+		t.Fatalf("expected items in the order they were added, got %+v", due[0].Items)
+	}
+	if again, _ := repo.ClaimDueDigests(time.Now(), time.Minute, 10); len(again) != 0 {
+		t.Fatalf("a digest should be claimed once, got %+v", again)
+	}
+
+	// After the window the first digest is due; later notifications start a new one
+	due, err = repo.ClaimDueDigests(now.Add(time.Minute), time.Minute, 10)
+	if err != nil || len(due) != 1 || due[0].ID != first.ID || len(due[0].Items) != 2 {
+		t.Fatalf("ClaimDueDigests() after the window = %+v, %v", due, err)
+	}
+	if next := add("n5", "100", "later", time.Hour, 10); next.ID == first.ID || next.Count != 1 {
+		t.Fatalf("expected a new digest after the flush, got %+v", next)
+	}
+
+	// A claimed digest is kept until it is completed: the process stopped before sending
+	// the summary, so it is claimed again with its notifications once the lease expires
+	reclaimed, err := repo.ClaimDueDigests(now.Add(3*time.Minute), time.Minute, 10)
+	if err != nil || len(reclaimed) != 2 || reclaimed[0].ID != other.ID || reclaimed[1].ID != first.ID || len(reclaimed[1].Items) != 2 {
+		t.Fatalf("expected both claimed digests to be claimed again, got %+v, %v", reclaimed, err)
+	}
+
+	// Completing a stale claim leaves the digest in place; completing the current one removes it
+	if err := repo.CompleteDigest(&due[0]); err != nil {
+		t.Fatalf("CompleteDigest() error = %v", err)
+	}
+	for i := range reclaimed {
+		if err := repo.CompleteDigest(&reclaimed[i]); err != nil {
+			t.Fatalf("CompleteDigest() error = %v", err)
+		}
+	}
+	var items int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM digest_items`).Scan(&items); err != nil {
+		t.Fatalf("Failed to count digest items: %v", err)
+	}
+	if items != 1 {
+		t.Errorf("expected only the open digest's item to remain, got %d", items)
+	}
+	if again, _ := repo.ClaimDueDigests(now.Add(2*time.Hour), time.Minute, 10); len(again) != 1 || again[0].Items[0].Message != "later" {
+		t.Errorf("expected only the open digest to be claimed, got %+v", again)
+	}
+}
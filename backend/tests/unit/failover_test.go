@@ -0,0 +1,212 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+// hopRecorder records the sends made to mock hop providers
+type hopRecorder struct {
+	mu   sync.Mutex
+	sent []string // "provider:recipient" in send order
+}
+
+func (h *hopRecorder) hop(id string, send func(ctx context.Context) error) *testhelpers.MockProvider {
+	return &testhelpers.MockProvider{
+		IDFunc:   func() string { return id },
+		TypeFunc: func() string { return "mock" },
+		SendFunc: func(ctx context.Context, n *providers.Notification) error {
+			h.mu.Lock()
+			h.sent = append(h.sent, id+":"+n.Recipient)
+			h.mu.Unlock()
+			return send(ctx)
+		},
+	}
+}
+
+func (h *hopRecorder) calls() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.sent...)
+}
+
+func newFailoverChain(t *testing.T, hops ...providers.FailoverHop) *providers.FailoverProvider {
+	t.Helper()
+	chain, err := providers.NewFailoverProvider("critical", &providers.FailoverConfig{Hops: hops})
+	if err != nil {
+		t.Fatalf("NewFailoverProvider() error = %v", err)
+	}
+	return chain
+}
+
+func TestFailoverFallsBackToNextHop(t *testing.T) {
+	recorder := &hopRecorder{}
+	registry := providers.NewRegistry()
+	registry.Register(recorder.hop("mail", func(context.Context) error {
+		return providers.NewTransientError(errors.New("smtp relay unreachable"))
+	}))
+	registry.Register(recorder.hop("chat", func(context.Context) error { return nil }))
+
+	chain := newFailoverChain(t,
+		providers.FailoverHop{ProviderID: "mail"},
+		providers.FailoverHop{ProviderID: "chat", Recipient: "12345"},
+	)
+
+	result, err := registry.SendFailover(context.Background(), chain, &providers.Notification{
+		ID:        "n-1",
+		Recipient: "ops@example.com",
+		Message:   "disk full",
+	})
+	if err != nil {
+		t.Fatalf("SendFailover() error = %v", err)
+	}
+	if result.ProviderID != "chat" || result.Hop != 2 {
+		t.Errorf("expected delivery through hop 2 (chat), got %+v", result)
+	}
+	if len(result.Failures) != 1 || result.Failures[0].ProviderID != "mail" || result.Failures[0].Category != providers.ErrorCategoryTransient {
+		t.Errorf("unexpected failures %+v", result.Failures)
+	}
+
+	want := []string{"mail:ops@example.com", "chat:12345"}
+	if got := recorder.calls(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected sends %v, got %v", want, got)
+	}
+}
+
+func TestFailoverHopTimeout(t *testing.T) {
+	recorder := &hopRecorder{}
+	registry := providers.NewRegistry()
+	registry.Register(recorder.hop("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return providers.NewTransientError(ctx.Err())
+	}))
+	registry.Register(recorder.hop("fast", func(context.Context) error { return nil }))
+
+	chain := newFailoverChain(t,
+		providers.FailoverHop{ProviderID: "slow", TimeoutSeconds: 1},
+		providers.FailoverHop{ProviderID: "fast"},
+	)
+
+	start := time.Now()
+	result, err := registry.SendFailover(context.Background(), chain, &providers.Notification{Recipient: "x", Message: "m"})
+	if err != nil {
+		t.Fatalf("SendFailover() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("slow hop was not cut off by its timeout, took %s", elapsed)
+	}
+	if result.ProviderID != "fast" {
+		t.Errorf("expected delivery through fast, got %+v", result)
+	}
+
+	if got := registry.DeliveryTimeout(chain); got != time.Second+providers.DefaultDeliveryTimeout {
+		t.Errorf("expected chain delivery timeout of 1s + default, got %s", got)
+	}
+}
+
+func TestFailoverResolvesHopsAtSendTime(t *testing.T) {
+	recorder := &hopRecorder{}
+	registry := providers.NewRegistry()
+	registry.Register(recorder.hop("mail", func(context.Context) error {
+		return providers.NewTransientError(errors.New("smtp relay unreachable"))
+	}))
+	registry.Register(recorder.hop("chat", func(context.Context) error { return nil }))
+
+	chain := newFailoverChain(t,
+		providers.FailoverHop{ProviderID: "pager"}, // not loaded
+		providers.FailoverHop{ProviderID: "mail"},
+		providers.FailoverHop{ProviderID: "chat"},
+	)
+	notification := &providers.Notification{Recipient: "r", Message: "m"}
+
+	result, err := registry.SendFailover(context.Background(), chain, notification)
+	if err != nil || result.ProviderID != "chat" {
+		t.Fatalf("expected delivery through chat, got %+v, %v", result, err)
+	}
+	if len(result.Failures) != 2 || result.Failures[0].ProviderID != "pager" {
+		t.Errorf("expected the missing hop to be skipped, got %+v", result.Failures)
+	}
+
+	// A reloaded hop is used without reloading the chain
+	if err := registry.Replace("mail", recorder.hop("mail", func(context.Context) error { return nil })); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	result, err = registry.SendFailover(context.Background(), chain, notification)
+	if err != nil || result.ProviderID != "mail" {
+		t.Fatalf("expected delivery through the reloaded mail hop, got %+v, %v", result, err)
+	}
+
+	// Muted hops are skipped without sending
+	if err := registry.Mute("mail", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Mute() error = %v", err)
+	}
+	before := len(recorder.calls())
+	result, err = registry.SendFailover(context.Background(), chain, notification)
+	if err != nil || result.ProviderID != "chat" {
+		t.Fatalf("expected delivery through chat while mail is muted, got %+v, %v", result, err)
+	}
+	if calls := recorder.calls()[before:]; len(calls) != 1 || calls[0] != "chat:r" {
+		t.Errorf("expected only chat to be sent to, got %v", calls)
+	}
+}
+
+func TestFailoverAllHopsFail(t *testing.T) {
+	recorder := &hopRecorder{}
+	registry := providers.NewRegistry()
+	registry.Register(recorder.hop("mail", func(context.Context) error {
+		return providers.NewTransientError(errors.New("smtp relay unreachable"))
+	}))
+	registry.Register(recorder.hop("chat", func(context.Context) error {
+		return providers.NewRecipientError(errors.New("chat not found"))
+	}))
+
+	chain := newFailoverChain(t,
+		providers.FailoverHop{ProviderID: "mail"},
+		providers.FailoverHop{ProviderID: "chat"},
+	)
+
+	// Registry.Send delegates failover chains to SendFailover
+	err := registry.Send(context.Background(), chain, &providers.Notification{Recipient: "r", Message: "m"})
+	if err == nil {
+		t.Fatal("expected an error when every hop fails")
+	}
+	if !strings.Contains(err.Error(), "smtp relay unreachable") || !strings.Contains(err.Error(), "chat not found") {
+		t.Errorf("expected every hop's error in %q", err)
+	}
+	if category := providers.ErrorCategory(err); category != providers.ErrorCategoryRecipient {
+		t.Errorf("expected the last hop's category, got %s", category)
+	}
+}
+
+func TestFailoverCapabilities(t *testing.T) {
+	registry := providers.NewRegistry()
+	registry.Register(&testhelpers.MockProvider{
+		IDFunc:   func() string { return "mail" },
+		TypeFunc: func() string { return "email" },
+	})
+
+	chain := newFailoverChain(t,
+		providers.FailoverHop{ProviderID: "mail", Recipient: "ops@example.com"},
+		providers.FailoverHop{ProviderID: "chat", Recipient: "12345"},
+	)
+
+	caps := registry.CapabilitiesOf(chain)
+	if !caps.RecipientOptional {
+		t.Error("expected recipient to be optional when every hop has one")
+	}
+
+	chain = newFailoverChain(t,
+		providers.FailoverHop{ProviderID: "mail"},
+		providers.FailoverHop{ProviderID: "chat", Recipient: "12345"},
+	)
+	if registry.CapabilitiesOf(chain).RecipientOptional {
+		t.Error("expected recipient to be required when a hop has none")
+	}
+}
@@ -0,0 +1,163 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+// checkingProvider is a mock provider that counts health checks
+type checkingProvider struct {
+	*testhelpers.MockProvider
+	checks int32
+	block  bool
+}
+
+func (p *checkingProvider) CheckHealth(ctx context.Context) error {
+	atomic.AddInt32(&p.checks, 1)
+	if p.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func newCheckingProvider(id string, block bool) *checkingProvider {
+	return &checkingProvider{
+		MockProvider: &testhelpers.MockProvider{IDFunc: func() string { return id }},
+		block:        block,
+	}
+}
+
+func TestHealthMonitorProbesProviders(t *testing.T) {
+	registry := providers.NewRegistry()
+	fast := newCheckingProvider("fast", false)
+	slow := newCheckingProvider("slow", true)
+	for _, p := range []providers.Provider{fast, slow} {
+		if err := registry.Register(p); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+
+	monitor := providers.NewHealthMonitor(registry, 50*time.Millisecond, 20*time.Millisecond, nil)
+	monitor.Start()
+
+	time.Sleep(300 * time.Millisecond)
+
+	// A hanging provider is bounded by the timeout and does not hold up the others
+	if n := atomic.LoadInt32(&fast.checks); n < 3 {
+		t.Errorf("expected fast provider to be probed repeatedly, got %d checks", n)
+	}
+	if n := atomic.LoadInt32(&slow.checks); n < 3 {
+		t.Errorf("expected slow provider probes to time out and repeat, got %d checks", n)
+	}
+
+	// Replaced providers are probed right away
+	replacement := newCheckingProvider("fast", false)
+	if err := registry.Replace("fast", replacement); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&replacement.checks) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if atomic.LoadInt32(&replacement.checks) == 0 {
+		t.Fatalf("expected replacement provider to be probed")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		monitor.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatalf("Stop() did not return")
+	}
+}
+
+func TestTelegramProviderCachedHealthCheck(t *testing.T) {
+	var getMeCalls int32
+	var mode atomic.Value
+	mode.Store("ok")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.Contains(r.URL.Path, "getMe") {
+			writeTelegramResponse(t, w, `{"ok":true,"result":true}`)
+			return
+		}
+		atomic.AddInt32(&getMeCalls, 1)
+		switch mode.Load() {
+		case "fail":
+			w.WriteHeader(http.StatusUnauthorized)
+			writeTelegramResponse(t, w, `{"ok":false,"error_code":401,"description":"Unauthorized"}`)
+		case "slow":
+			time.Sleep(300 * time.Millisecond)
+			writeTelegramResponse(t, w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"Unit","username":"unit_bot"}}`)
+		default:
+			writeTelegramResponse(t, w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"Unit","username":"unit_bot"}}`)
+		}
+	}))
+	defer server.Close()
+
+	provider, err := providers.NewTelegramProvider("telegram-health", &providers.TelegramConfig{
+		BotToken:      "token",
+		DefaultChatID: "5551234",
+		APIEndpoint:   server.URL + "/bot%s/%s",
+	})
+	if err != nil {
+		t.Fatalf("failed to create telegram provider: %v", err)
+	}
+	defer closeTelegramProvider(t, provider)
+
+	// GetStatus serves the cached result and never calls the API
+	calls := atomic.LoadInt32(&getMeCalls)
+	if status := provider.GetStatus(); status.Status != providers.StatusActive {
+		t.Fatalf("expected initial active status, got %+v", status)
+	}
+	if atomic.LoadInt32(&getMeCalls) != calls {
+		t.Fatalf("GetStatus() must not call getMe")
+	}
+
+	mode.Store("fail")
+	for i := 0; i < 2; i++ {
+		if err := provider.CheckHealth(context.Background()); err == nil {
+			t.Fatalf("expected health check to fail")
+		}
+	}
+	status := provider.GetStatus()
+	if status.Status != providers.StatusError || status.ConsecutiveFailures != 2 || status.LastCheckedAt == nil {
+		t.Fatalf("expected error status with 2 consecutive failures, got %+v", status)
+	}
+
+	mode.Store("slow")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := provider.CheckHealth(ctx); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Fatalf("health check did not honor the timeout (took %s)", elapsed)
+	}
+
+	// Wait for the slow probe to finish, then recover
+	time.Sleep(350 * time.Millisecond)
+	mode.Store("ok")
+	if err := provider.CheckHealth(context.Background()); err != nil {
+		t.Fatalf("expected health check to recover, got %v", err)
+	}
+	status = provider.GetStatus()
+	if status.Status != providers.StatusActive || status.ConsecutiveFailures != 0 {
+		t.Fatalf("expected recovered active status, got %+v", status)
+	}
+}
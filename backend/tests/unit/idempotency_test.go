@@ -0,0 +1,90 @@
+package unit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKeyStore(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	defer db.Close()
+
+	expiresAt := time.Now().Add(time.Hour)
+	existing, err := repo.ReserveIdempotencyKey("billing", "order-1", "hash-a", "token-1", expiresAt)
+	if err != nil || existing != nil {
+		t.Fatalf("ReserveIdempotencyKey() on a new key = %+v, %v", existing, err)
+	}
+
+	// The key is in progress until its response is recorded
+	existing, err = repo.ReserveIdempotencyKey("billing", "order-1", "hash-a", "token-2", expiresAt)
+	if err != nil || existing == nil || existing.StatusCode != 0 {
+		t.Fatalf("expected the key to be in progress, got %+v, %v", existing, err)
+	}
+	// Only the request that reserved the key can record its response
+	if err := repo.CompleteIdempotencyKey("billing", "order-1", "token-2", 201, []byte(`{"id":"n0"}`), expiresAt); err == nil {
+		t.Errorf("expected completing a key reserved by another request to fail")
+	}
+	if err := repo.CompleteIdempotencyKey("billing", "order-1", "token-1", 201, []byte(`{"id":"n1"}`), expiresAt); err != nil {
+		t.Fatalf("CompleteIdempotencyKey() error = %v", err)
+	}
+	// Releasing a completed key keeps its response
+	if err := repo.ReleaseIdempotencyKey("billing", "order-1", "token-1"); err != nil {
+		t.Fatalf("ReleaseIdempotencyKey() error = %v", err)
+	}
+	existing, err = repo.ReserveIdempotencyKey("billing", "order-1", "hash-b", "token-3", expiresAt)
+	if err != nil || existing == nil || existing.StatusCode != 201 || string(existing.Response) != `{"id":"n1"}` || existing.RequestHash != "hash-a" {
+		t.Fatalf("expected the recorded response, got %+v, %v", existing, err)
+	}
+
+	// Keys are scoped by API key
+	if existing, err := repo.ReserveIdempotencyKey("shipping", "order-1", "hash-a", "token-4", expiresAt); err != nil || existing != nil {
+		t.Fatalf("expected a key of another scope to be free, got %+v, %v", existing, err)
+	}
+
+	// A released key can be reserved again
+	if err := repo.ReleaseIdempotencyKey("shipping", "order-1", "token-4"); err != nil {
+		t.Fatalf("ReleaseIdempotencyKey() error = %v", err)
+	}
+	if existing, err := repo.ReserveIdempotencyKey("shipping", "order-1", "hash-c", "token-5", expiresAt); err != nil || existing != nil {
+		t.Fatalf("expected a released key to be free, got %+v, %v", existing, err)
+	}
+
+	// An expired key is free, and the retention cleanup removes expired keys
+	if existing, err := repo.ReserveIdempotencyKey("", "expired", "hash-a", "token-6", time.Now().Add(-time.Second)); err != nil || existing != nil {
+		t.Fatalf("ReserveIdempotencyKey() = %+v, %v", existing, err)
+	}
+	if existing, err := repo.ReserveIdempotencyKey("", "expired", "hash-b", "token-7", time.Now().Add(-time.Second)); err != nil || existing != nil {
+		t.Fatalf("expected an expired key to be free, got %+v, %v", existing, err)
+	}
+
+	// A reservation whose lease lapsed before its request completed can be taken over,
+	// and the stale request can no longer release it or record its response
+	if existing, err := repo.ReserveIdempotencyKey("", "stale", "hash-a", "token-8", time.Now().Add(-time.Second)); err != nil || existing != nil {
+		t.Fatalf("ReserveIdempotencyKey() = %+v, %v", existing, err)
+	}
+	if existing, err := repo.ReserveIdempotencyKey("", "stale", "hash-a", "token-9", time.Now().Add(time.Minute)); err != nil || existing != nil {
+		t.Fatalf("expected a stale reservation to be taken over, got %+v, %v", existing, err)
+	}
+	if err := repo.ReleaseIdempotencyKey("", "stale", "token-8"); err != nil {
+		t.Fatalf("ReleaseIdempotencyKey() error = %v", err)
+	}
+	if err := repo.CompleteIdempotencyKey("", "stale", "token-8", 201, []byte(`{"id":"n2"}`), expiresAt); err == nil {
+		t.Errorf("expected the stale request to fail to complete the key")
+	}
+	if err := repo.CompleteIdempotencyKey("", "stale", "token-9", 201, []byte(`{"id":"n3"}`), expiresAt); err != nil {
+		t.Fatalf("CompleteIdempotencyKey() error = %v", err)
+	}
+	if err := repo.CompleteIdempotencyKey("", "stale", "token-9", 201, []byte(`{"id":"n4"}`), expiresAt); err == nil {
+		t.Errorf("expected completing an already completed key to fail")
+	}
+	if err := repo.CleanupOldLogs(90); err != nil {
+		t.Fatalf("CleanupOldLogs() error = %v", err)
+	}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM idempotency_keys`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count idempotency keys: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("expected the 3 unexpired keys to remain, got %d", count)
+	}
+}
@@ -0,0 +1,187 @@
+package unit
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/config"
+	"github.com/developertyrone/notimulti/internal/scheduler"
+	"github.com/developertyrone/notimulti/internal/storage"
+)
+
+func TestJobScheduleTimezoneAndJitter(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC) // Monday
+
+	job := &storage.Job{Schedule: "0 9 * * MON-FRI", Timezone: "Asia/Taipei"}
+	next, err := scheduler.NextRun(job, now)
+	if err != nil {
+		t.Fatalf("NextRun() error = %v", err)
+	}
+	// 09:00 in Taipei (UTC+8) on Tuesday is 01:00 UTC
+	if want := time.Date(2025, 3, 11, 1, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("NextRun() = %s, want %s", next, want)
+	}
+
+	job = &storage.Job{Schedule: "@hourly", JitterSeconds: 120}
+	for i := 0; i < 20; i++ {
+		next, err := scheduler.NextRun(job, now)
+		if err != nil {
+			t.Fatalf("NextRun() error = %v", err)
+		}
+		base := time.Date(2025, 3, 10, 13, 0, 0, 0, time.UTC)
+		if next.Before(base) || !next.Before(base.Add(2*time.Minute)) {
+			t.Fatalf("NextRun() with jitter = %s, want within 2m after %s", next, base)
+		}
+	}
+
+	for _, invalid := range []struct{ schedule, timezone string }{
+		{"every day", ""},
+		{"0 9 * *", ""},
+		{"0 9 * * *", "Mars/Olympus"},
+	} {
+		if _, err := scheduler.ParseSchedule(invalid.schedule, invalid.timezone); err == nil {
+			t.Errorf("ParseSchedule(%q, %q) should fail", invalid.schedule, invalid.timezone)
+		}
+	}
+}
+
+func TestLoadJobsFile(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	valid := filepath.Join(dir, "jobs.json")
+	if err := os.WriteFile(valid, []byte(`{"jobs": [
+		{"id": "backup-heartbeat", "schedule": "*/15 * * * *", "jitter_seconds": 30,
+		 "notification": {"provider_id": "telegram-ops", "recipient": "12345", "message": "backup job still alive"}},
+		{"id": "weekly-report", "schedule": "0 9 * * MON", "timezone": "Europe/Berlin",
+		 "notification": {"provider_id": "email-team", "template": "weekly-report"}}
+	]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := config.LoadJobsFile(valid, now)
+	if err != nil {
+		t.Fatalf("LoadJobsFile() error = %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != "backup-heartbeat" || jobs[1].Timezone != "Europe/Berlin" {
+		t.Fatalf("unexpected jobs %+v", jobs)
+	}
+	for _, job := range jobs {
+		if job.NextRunAt == nil || !job.NextRunAt.After(now) {
+			t.Errorf("job %s has next run %v, want after now", job.ID, job.NextRunAt)
+		}
+	}
+
+	invalid := map[string]string{
+		"jobs[0].schedule":                     `{"jobs": [{"id": "a", "schedule": "sometimes", "notification": {"message": "x"}}]}`,
+		"jobs[0].notification.message":         `{"jobs": [{"id": "a", "schedule": "@daily", "notification": {"provider_id": "p"}}]}`,
+		"jobs[0].notification.send_at":         `{"jobs": [{"id": "a", "schedule": "@daily", "notification": {"message": "x", "delay_seconds": 5}}]}`,
+		"jobs[0].notification.expires_at":      `{"jobs": [{"id": "a", "schedule": "@daily", "notification": {"message": "x", "expires_at": "2030-01-01T00:00:00Z"}}]}`,
+		"jobs[0].notification.idempotency_key": `{"jobs": [{"id": "a", "schedule": "@daily", "notification": {"message": "x", "idempotency_key": "daily"}}]}`,
+		"jobs[0].jitter_seconds":               `{"jobs": [{"id": "a", "schedule": "@daily", "jitter_seconds": 7200, "notification": {"message": "x"}}]}`,
+		"jobs[1].id":                           `{"jobs": [{"id": "a", "schedule": "@daily", "notification": {"message": "x"}}, {"id": "a", "schedule": "@daily", "notification": {"message": "y"}}]}`,
+		"jobs[0].id":                           `{"jobs": [{"id": "Not Valid", "schedule": "@daily", "notification": {"message": "x"}}]}`,
+	}
+	for field, content := range invalid {
+		path := filepath.Join(dir, "invalid.json")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := config.LoadJobsFile(path, now); err == nil || !strings.Contains(err.Error(), field+":") {
+			t.Errorf("expected error for %s, got %v", field, err)
+		}
+	}
+}
+
+func TestJobStore(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	defer db.Close()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	next := now.Add(time.Minute)
+	job := &storage.Job{
+		ID:           "heartbeat",
+		Schedule:     "* * * * *",
+		Notification: json.RawMessage(`{"provider_id":"chat","recipient":"1","message":"alive"}`),
+		NextRunAt:    &next,
+	}
+	created, err := repo.CreateJob(job)
+	if err != nil {
+		t.Fatalf("CreateJob() error = %v", err)
+	}
+	if created.Source != storage.JobSourceAPI || created.NextRunAt == nil || !created.NextRunAt.Equal(next) {
+		t.Errorf("unexpected job %+v", created)
+	}
+	if _, err := repo.CreateJob(job); !errors.Is(err, storage.ErrJobExists) {
+		t.Errorf("expected ErrJobExists, got %v", err)
+	}
+
+	// Not due yet, then due and claimed exactly once
+	if due, err := repo.DueJobs(now); err != nil || len(due) != 0 {
+		t.Fatalf("DueJobs() before next run = %+v, %v", due, err)
+	}
+	due, err := repo.DueJobs(next)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("DueJobs() = %+v, %v", due, err)
+	}
+	following := next.Add(time.Minute)
+	if claimed, err := repo.ClaimJobRun(&due[0], next, &following); err != nil || !claimed {
+		t.Fatalf("ClaimJobRun() = %v, %v; want true", claimed, err)
+	}
+	if claimed, err := repo.ClaimJobRun(&due[0], next, &following); err != nil || claimed {
+		t.Fatalf("second ClaimJobRun() = %v, %v; want false", claimed, err)
+	}
+	if err := repo.RecordJobRun("heartbeat", "notification-1", ""); err != nil {
+		t.Fatalf("RecordJobRun() error = %v", err)
+	}
+
+	paused, err := repo.SetJobPaused("heartbeat", true, nil)
+	if err != nil || !paused.Paused || paused.NextRunAt != nil || paused.LastNotificationID != "notification-1" {
+		t.Fatalf("SetJobPaused() = %+v, %v", paused, err)
+	}
+	if due, _ := repo.DueJobs(following.Add(time.Hour)); len(due) != 0 {
+		t.Errorf("paused job should not be due, got %+v", due)
+	}
+
+	// Config jobs replace API jobs with the same ID and cannot be edited through the API
+	configNext := now.Add(2 * time.Minute)
+	if err := repo.SyncConfigJobs([]storage.Job{{
+		ID: "heartbeat", Schedule: "*/5 * * * *", Notification: job.Notification, NextRunAt: &configNext,
+	}}); err != nil {
+		t.Fatalf("SyncConfigJobs() error = %v", err)
+	}
+	synced, err := repo.GetJob("heartbeat")
+	if err != nil || synced.Source != storage.JobSourceConfig || !synced.Paused || synced.NextRunAt != nil {
+		t.Fatalf("synced job = %+v, %v; want config job that stays paused", synced, err)
+	}
+	if _, err := repo.UpdateJob(synced); !errors.Is(err, storage.ErrJobConfigManaged) {
+		t.Errorf("expected ErrJobConfigManaged updating, got %v", err)
+	}
+	if err := repo.DeleteJob("heartbeat"); !errors.Is(err, storage.ErrJobConfigManaged) {
+		t.Errorf("expected ErrJobConfigManaged deleting, got %v", err)
+	}
+
+	// Reloading an unchanged file keeps the next run; removing the job deletes it
+	if _, err := repo.SetJobPaused("heartbeat", false, &configNext); err != nil {
+		t.Fatalf("SetJobPaused() error = %v", err)
+	}
+	later := now.Add(time.Hour)
+	if err := repo.SyncConfigJobs([]storage.Job{{
+		ID: "heartbeat", Schedule: "*/5 * * * *", Notification: job.Notification, NextRunAt: &later,
+	}}); err != nil {
+		t.Fatalf("SyncConfigJobs() error = %v", err)
+	}
+	if synced, _ := repo.GetJob("heartbeat"); synced.NextRunAt == nil || !synced.NextRunAt.Equal(configNext) {
+		t.Errorf("unchanged config job should keep its next run, got %+v", synced.NextRunAt)
+	}
+	if err := repo.SyncConfigJobs(nil); err != nil {
+		t.Fatalf("SyncConfigJobs() error = %v", err)
+	}
+	if _, err := repo.GetJob("heartbeat"); !errors.Is(err, storage.ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound after removing the job from the file, got %v", err)
+	}
+}
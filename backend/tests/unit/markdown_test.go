@@ -0,0 +1,78 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/developertyrone/notimulti/internal/markdown"
+	"github.com/developertyrone/notimulti/internal/providers"
+)
+
+func TestMarkdownConversions(t *testing.T) {
+	tests := []struct {
+		name         string
+		source       string
+		wantTelegram string
+		wantText     string
+	}{
+		{
+			name:         "inline markup",
+			source:       "**Deploy** of *api* <b>ok</b> & `a<b>`",
+			wantTelegram: "<b>Deploy</b> of <i>api</i> ok &amp; <code>a&lt;b&gt;</code>",
+			wantText:     "Deploy of api ok & a<b>",
+		},
+		{
+			name:         "links",
+			source:       "See [the runbook](https://example.com/?a=1&b=2) or <https://status.example.com>",
+			wantTelegram: `See <a href="https://example.com/?a=1&amp;b=2">the runbook</a> or <a href="https://status.example.com">https://status.example.com</a>`,
+			wantText:     "See the runbook (https://example.com/?a=1&b=2) or https://status.example.com",
+		},
+		{
+			name:         "heading and lists",
+			source:       "# Failed checks\n\n- disk\n- memory\n  1. swap\n  2. cache",
+			wantTelegram: "<b>Failed checks</b>\n\n• disk\n• memory\n  1. swap\n  2. cache",
+			wantText:     "Failed checks\n\n- disk\n- memory\n  1. swap\n  2. cache",
+		},
+		{
+			name:         "quotes and code",
+			source:       "> on call\n> since 9:00\n\n```sh\nkubectl get pods > out\n```",
+			wantTelegram: "<blockquote>on call\nsince 9:00</blockquote>\n\n<pre><code class=\"language-sh\">kubectl get pods &gt; out</code></pre>",
+			wantText:     "> on call\n> since 9:00\n\nkubectl get pods > out",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdown.TelegramHTML(tt.source); got != tt.wantTelegram {
+				t.Errorf("TelegramHTML() = %q, want %q", got, tt.wantTelegram)
+			}
+			if got := markdown.Text(tt.source); got != tt.wantText {
+				t.Errorf("Text() = %q, want %q", got, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestEmailProviderSendsMarkdownAsHTML(t *testing.T) {
+	provider, err := providers.NewEmailProvider("email-md", &providers.EmailConfig{
+		Host: "smtp.example.com", Port: 587, Username: "u", Password: "p", From: "alerts@example.com",
+	})
+	if err != nil {
+		t.Fatalf("NewEmailProvider() error = %v", err)
+	}
+
+	payload, ok := providers.PreviewOf(provider, &providers.Notification{
+		Recipient: "ops@example.com",
+		Message:   "**Disk** full on [db-1](https://grafana.example.com/db-1) <script>x</script>",
+		Format:    providers.FormatMarkdown,
+	})
+	if !ok {
+		t.Fatal("expected the email provider to preview")
+	}
+	email := payload.(providers.EmailPayload)
+	if email.Text != "Disk full on db-1 (https://grafana.example.com/db-1) x" {
+		t.Errorf("unexpected text alternative %q", email.Text)
+	}
+	if email.HTML != `<p><strong>Disk</strong> full on <a href="https://grafana.example.com/db-1">db-1</a> <!-- raw HTML omitted -->x<!-- raw HTML omitted --></p>` {
+		t.Errorf("unexpected HTML %q", email.HTML)
+	}
+}
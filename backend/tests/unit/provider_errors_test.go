@@ -0,0 +1,211 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/storage"
+)
+
+func TestErrorCategoryHelpers(t *testing.T) {
+	base := errors.New("boom")
+
+	tests := []struct {
+		name      string
+		err       error
+		category  string
+		retryable bool
+	}{
+		{"nil", nil, "", false},
+		{"unclassified", base, providers.ErrorCategoryUnknown, false},
+		{"transient", providers.NewTransientError(base), providers.ErrorCategoryTransient, true},
+		{"rate limited", providers.NewRateLimitedError(base, time.Second), providers.ErrorCategoryRateLimited, true},
+		{"recipient", providers.NewRecipientError(base), providers.ErrorCategoryRecipient, false},
+		{"auth", providers.NewAuthError(base), providers.ErrorCategoryAuth, false},
+		{"permanent", providers.NewPermanentError(base), providers.ErrorCategoryPermanent, false},
+		{"wrapped", fmt.Errorf("chunk 2/3: %w", providers.NewRecipientError(base)), providers.ErrorCategoryRecipient, false},
+		{"circuit open", providers.ErrCircuitOpen, providers.ErrorCategoryTransient, true},
+		{"deadline", context.DeadlineExceeded, providers.ErrorCategoryTransient, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := providers.ErrorCategory(tt.err); got != tt.category {
+				t.Errorf("ErrorCategory() = %q, want %q", got, tt.category)
+			}
+			if got := providers.IsRetryable(tt.err); got != tt.retryable {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.retryable)
+			}
+		})
+	}
+
+	if got := providers.RetryAfter(fmt.Errorf("wrapped: %w", providers.NewRateLimitedError(base, 3*time.Second))); got != 3*time.Second {
+		t.Errorf("RetryAfter() = %s, want 3s", got)
+	}
+	if !errors.Is(providers.NewAuthError(base), base) {
+		t.Error("expected ProviderError to unwrap to the original error")
+	}
+}
+
+func TestTelegramProviderClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		category     string
+		wantAttempts int32
+	}{
+		{
+			name:         "chat not found",
+			status:       http.StatusBadRequest,
+			body:         `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`,
+			category:     providers.ErrorCategoryRecipient,
+			wantAttempts: 1,
+		},
+		{
+			name:         "bot blocked",
+			status:       http.StatusForbidden,
+			body:         `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`,
+			category:     providers.ErrorCategoryRecipient,
+			wantAttempts: 1,
+		},
+		{
+			name:         "unauthorized",
+			status:       http.StatusUnauthorized,
+			body:         `{"ok":false,"error_code":401,"description":"Unauthorized"}`,
+			category:     providers.ErrorCategoryAuth,
+			wantAttempts: 1,
+		},
+		{
+			name:         "server error",
+			status:       http.StatusBadGateway,
+			body:         `{"ok":false,"error_code":502,"description":"Bad Gateway"}`,
+			category:     providers.ErrorCategoryTransient,
+			wantAttempts: 2,
+		},
+		{
+			name:         "rate limited",
+			status:       http.StatusTooManyRequests,
+			body:         `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`,
+			category:     providers.ErrorCategoryRateLimited,
+			wantAttempts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if strings.Contains(r.URL.Path, "getMe") {
+					writeTelegramResponse(t, w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"Unit","username":"unit_bot"}}`)
+					return
+				}
+				attempts.Add(1)
+				w.WriteHeader(tt.status)
+				writeTelegramResponse(t, w, tt.body)
+			}))
+			t.Cleanup(server.Close)
+
+			provider, err := providers.NewTelegramProvider("telegram-errors", &providers.TelegramConfig{
+				BotToken:       "token",
+				TimeoutSeconds: 5,
+				APIEndpoint:    server.URL + "/bot%s/%s",
+				Retry:          &providers.RetryConfig{MaxAttempts: 2, InitialDelayMs: 1},
+			})
+			if err != nil {
+				t.Fatalf("failed to create telegram provider: %v", err)
+			}
+			t.Cleanup(func() { closeTelegramProvider(t, provider) })
+
+			err = provider.Send(context.Background(), &providers.Notification{Recipient: "5551234", Message: "hello"})
+			if err == nil {
+				t.Fatal("expected send to fail")
+			}
+			if got := providers.ErrorCategory(err); got != tt.category {
+				t.Errorf("ErrorCategory() = %q, want %q (err: %v)", got, tt.category, err)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempts, got)
+			}
+		})
+	}
+}
+
+func TestEmailProviderClassifiesErrors(t *testing.T) {
+	provider, err := providers.NewEmailProvider("email-errors", &providers.EmailConfig{
+		Host:  "127.0.0.1",
+		Port:  1, // nothing listens here
+		From:  "noreply@example.com",
+		Retry: &providers.RetryConfig{MaxAttempts: 1},
+	})
+	if err != nil {
+		t.Fatalf("failed to create email provider: %v", err)
+	}
+
+	err = provider.Send(context.Background(), &providers.Notification{Recipient: "not-an-email", Message: "hello"})
+	if got := providers.ErrorCategory(err); got != providers.ErrorCategoryRecipient {
+		t.Errorf("invalid address: ErrorCategory() = %q, want recipient (err: %v)", got, err)
+	}
+
+	err = provider.Send(context.Background(), &providers.Notification{Recipient: "user@example.com", Message: "hello"})
+	if got := providers.ErrorCategory(err); got != providers.ErrorCategoryTransient {
+		t.Errorf("connection refused: ErrorCategory() = %q, want transient (err: %v)", got, err)
+	}
+}
+
+func TestCircuitBreakerIgnoresRecipientErrors(t *testing.T) {
+	breaker := providers.NewCircuitBreaker(2, time.Minute)
+
+	for i := 0; i < 5; i++ {
+		breaker.Record(providers.NewRecipientError(errors.New("chat not found")))
+	}
+	if state := breaker.State(); state != providers.CircuitClosed {
+		t.Fatalf("expected recipient errors to keep the circuit closed, got %s", state)
+	}
+
+	for i := 0; i < 2; i++ {
+		breaker.Record(providers.NewTransientError(errors.New("connection reset")))
+	}
+	if state := breaker.State(); state != providers.CircuitOpen {
+		t.Fatalf("expected transient errors to open the circuit, got %s", state)
+	}
+}
+
+func TestHistoryFiltersByErrorCategory(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	defer db.Close()
+
+	now := time.Now().UTC()
+	recipientID := insertLog(t, db, "telegram-1", "telegram", storage.StatusFailed, now, false)
+	transientID := insertLog(t, db, "telegram-1", "telegram", storage.StatusFailed, now, false)
+	insertLog(t, db, "telegram-1", "telegram", storage.StatusSent, now, false)
+
+	for id, category := range map[int]string{recipientID: providers.ErrorCategoryRecipient, transientID: providers.ErrorCategoryTransient} {
+		if _, err := db.Exec(`UPDATE notification_logs SET error_category = ? WHERE id = ?`, category, id); err != nil {
+			t.Fatalf("failed to set error category: %v", err)
+		}
+	}
+
+	entries, _, err := repo.GetNotificationHistory(storage.HistoryFilters{
+		ErrorCategory: providers.ErrorCategoryRecipient,
+		PageSize:      10,
+	})
+	if err != nil {
+		t.Fatalf("GetNotificationHistory failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != recipientID {
+		t.Fatalf("expected only log %d, got %+v", recipientID, entries)
+	}
+	if got := entries[0].ErrorCategory.String; got != providers.ErrorCategoryRecipient {
+		t.Errorf("expected error_category recipient, got %q", got)
+	}
+}
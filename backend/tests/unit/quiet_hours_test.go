@@ -0,0 +1,112 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/config"
+	"github.com/developertyrone/notimulti/internal/providers"
+)
+
+func TestValidateAndBuildQuietHoursConfig(t *testing.T) {
+	newConfig := func(quietHours interface{}) *config.ProviderConfig {
+		return &config.ProviderConfig{
+			ID:      "email-eu",
+			Type:    "email",
+			Enabled: true,
+			Config: map[string]interface{}{
+				"host":        "smtp.example.com",
+				"port":        float64(587),
+				"username":    "notify@example.com",
+				"password":    "secret",
+				"from":        "notify@example.com",
+				"quiet_hours": quietHours,
+			},
+		}
+	}
+
+	invalid := []interface{}{
+		"22:00-07:00",
+		map[string]interface{}{},
+		map[string]interface{}{"start": "22:00"},
+		map[string]interface{}{"start": "22:00", "end": "22:00"},
+		map[string]interface{}{"start": "10pm", "end": "07:00"},
+		map[string]interface{}{"start": "22:00", "end": "07:00", "timezone": "Mars/Olympus"},
+		map[string]interface{}{"end": "07:00", "recipients": map[string]interface{}{"a@example.com": map[string]interface{}{"start": "20:00", "end": "06:00"}}},
+		map[string]interface{}{"recipients": map[string]interface{}{}},
+		map[string]interface{}{"recipients": map[string]interface{}{"a@example.com": map[string]interface{}{"start": "20:00"}}},
+	}
+	for _, quietHours := range invalid {
+		if err := config.ValidateConfig(newConfig(quietHours)); err == nil {
+			t.Errorf("expected validation error for quiet_hours %v", quietHours)
+		}
+	}
+
+	cfg := newConfig(map[string]interface{}{
+		"start":    "22:00",
+		"end":      "07:00",
+		"timezone": "Europe/Berlin",
+		"recipients": map[string]interface{}{
+			"us-team@example.com": map[string]interface{}{"start": "21:00", "end": "06:00", "timezone": "America/Chicago"},
+		},
+	})
+	if err := config.ValidateConfig(cfg); err != nil {
+		t.Fatalf("ValidateConfig failed for valid quiet_hours block: %v", err)
+	}
+	built, err := config.BuildProviderConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildProviderConfig() error = %v", err)
+	}
+	quietHours := built.Email.QuietHours
+	if quietHours == nil || quietHours.Timezone != "Europe/Berlin" || quietHours.Recipients["us-team@example.com"].Timezone != "America/Chicago" {
+		t.Fatalf("unexpected quiet hours %+v", quietHours)
+	}
+
+	// Recipient windows alone are valid
+	recipientsOnly := newConfig(map[string]interface{}{
+		"recipients": map[string]interface{}{"oncall@example.com": map[string]interface{}{"start": "23:00", "end": "06:00"}},
+	})
+	if err := config.ValidateConfig(recipientsOnly); err != nil {
+		t.Errorf("ValidateConfig failed for recipient-only quiet_hours: %v", err)
+	}
+}
+
+func TestQuietHoursOf(t *testing.T) {
+	provider, err := providers.NewEmailProvider("email-eu", &providers.EmailConfig{
+		Host:     "smtp.example.com",
+		Port:     587,
+		Username: "notify@example.com",
+		Password: "secret",
+		From:     "notify@example.com",
+		QuietHours: &providers.QuietHoursConfig{
+			QuietHours: providers.QuietHours{Start: "22:00", End: "07:00", Timezone: "Europe/Berlin"},
+			Recipients: map[string]*providers.QuietHours{
+				"us-team@example.com": {Start: "21:00", End: "06:00", Timezone: "America/Chicago"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewEmailProvider() error = %v", err)
+	}
+
+	// 23:30 in Berlin is 16:30 in Chicago
+	at := time.Date(2026, 1, 15, 22, 30, 0, 0, time.UTC)
+
+	quiet, until := providers.QuietHoursOf(provider, "eu-team@example.com").Active(at, time.UTC)
+	if !quiet || !until.Equal(time.Date(2026, 1, 16, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("provider window: Active() = %v, %v", quiet, until)
+	}
+	if quiet, _ := providers.QuietHoursOf(provider, "us-team@example.com").Active(at, time.UTC); quiet {
+		t.Error("a recipient's window should replace the provider's")
+	}
+
+	plain, err := providers.NewEmailProvider("email-plain", &providers.EmailConfig{
+		Host: "smtp.example.com", Port: 587, Username: "u", Password: "p", From: "notify@example.com",
+	})
+	if err != nil {
+		t.Fatalf("NewEmailProvider() error = %v", err)
+	}
+	if window := providers.QuietHoursOf(plain, "eu-team@example.com"); window != nil {
+		t.Errorf("expected no quiet hours, got %+v", window)
+	}
+}
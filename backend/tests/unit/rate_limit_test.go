@@ -0,0 +1,91 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+)
+
+func TestRateLimiterDelaysSends(t *testing.T) {
+	limiter := providers.NewRateLimiter(&providers.RateLimitConfig{
+		Global: &providers.RateLimitRule{Limit: 2, IntervalMs: 100},
+	})
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := limiter.Wait(context.Background(), "chat"); err != nil {
+			t.Fatalf("send %d: unexpected error %v", i, err)
+		}
+	}
+	// Two sends use the burst, the next two wait 50ms each
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("expected sends to be delayed ~100ms, took %s", elapsed)
+	}
+
+	status := limiter.Status()
+	if status.Global == nil || status.Global.Limit != 2 || status.Global.Burst != 2 || status.DelayedSends != 2 {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestRateLimiterPerRecipient(t *testing.T) {
+	limiter := providers.NewRateLimiter(&providers.RateLimitConfig{
+		PerRecipient: &providers.RateLimitRule{Limit: 1, IntervalMs: 60000},
+		MaxWaitMs:    10,
+	})
+
+	if err := limiter.Wait(context.Background(), "alice@example.com"); err != nil {
+		t.Fatalf("first send to alice: %v", err)
+	}
+	if err := limiter.Wait(context.Background(), "bob@example.com"); err != nil {
+		t.Fatalf("recipients must not share a bucket: %v", err)
+	}
+
+	// The next slot for alice is a minute away, beyond max_wait_ms
+	err := limiter.Wait(context.Background(), "alice@example.com")
+	if !errors.Is(err, providers.ErrRateLimitExceeded) || providers.ErrorCategory(err) != providers.ErrorCategoryRateLimited {
+		t.Fatalf("expected a rate_limited error, got %v", err)
+	}
+	if after := providers.RetryAfter(err); after < 59*time.Second {
+		t.Errorf("expected retry after ~1m, got %s", after)
+	}
+
+	status := limiter.Status()
+	if status.PerRecipient == nil || status.PerRecipient.TrackedRecipients != 2 || status.RejectedSends != 1 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if wait := status.PerRecipient.Throttled["alice@example.com"]; wait <= 0 {
+		t.Errorf("expected alice to be throttled, got %v", status.PerRecipient.Throttled)
+	}
+}
+
+func TestRateLimiterHonorsDeadline(t *testing.T) {
+	limiter := providers.NewRateLimiter(&providers.RateLimitConfig{
+		Global: &providers.RateLimitRule{Limit: 1, IntervalMs: 1000},
+	})
+	if err := limiter.Wait(context.Background(), ""); err != nil {
+		t.Fatalf("first send: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx, ""); providers.ErrorCategory(err) != providers.ErrorCategoryRateLimited {
+		t.Fatalf("expected rejection when the wait exceeds the deadline, got %v", err)
+	}
+
+	// A rejected send does not consume a token
+	if status := limiter.Status(); status.Global.WaitMs > 1000 {
+		t.Errorf("rejected send should not queue, wait is %dms", status.Global.WaitMs)
+	}
+
+	if providers.NewRateLimiter(nil) != nil || providers.NewRateLimiter(&providers.RateLimitConfig{}) != nil {
+		t.Error("expected no limiter without configured rules")
+	}
+	var none *providers.RateLimiter
+	if err := none.Wait(context.Background(), "x"); err != nil || none.Status() != nil {
+		t.Error("nil limiter must allow every send")
+	}
+}
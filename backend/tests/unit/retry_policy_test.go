@@ -0,0 +1,110 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+)
+
+func TestRetryPolicyDelays(t *testing.T) {
+	policy := providers.NewRetryPolicy(&providers.RetryConfig{
+		MaxAttempts:    5,
+		InitialDelayMs: 100,
+		MaxDelayMs:     500,
+		Multiplier:     3,
+	})
+
+	want := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 500 * time.Millisecond, 500 * time.Millisecond}
+	for i, expected := range want {
+		if got := policy.Delay(i + 1); got != expected {
+			t.Errorf("Delay(%d) = %s, want %s", i+1, got, expected)
+		}
+	}
+	if total := policy.TotalDelay(); total != 1400*time.Millisecond {
+		t.Errorf("TotalDelay() = %s, want 1.4s", total)
+	}
+
+	jittered := providers.NewRetryPolicy(&providers.RetryConfig{InitialDelayMs: 1000, Jitter: 0.5})
+	for i := 0; i < 20; i++ {
+		if d := jittered.Delay(1); d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("jittered delay %s outside ±50%%", d)
+		}
+	}
+
+	// Defaults match the previous hard-coded 3 attempts with 1s, 2s backoff
+	defaults := providers.NewRetryPolicy(nil)
+	if defaults.MaxAttempts != 3 || defaults.Delay(1) != time.Second || defaults.Delay(2) != 2*time.Second {
+		t.Errorf("unexpected default policy %+v", defaults)
+	}
+}
+
+func TestRetryPolicyDoCountsAttempts(t *testing.T) {
+	transient := errors.New("451 4.7.1 greylisted, try again later")
+	permanent := errors.New("550 mailbox unavailable")
+
+	policy := providers.NewRetryPolicy(&providers.RetryConfig{
+		MaxAttempts:     4,
+		InitialDelayMs:  1,
+		RetryableErrors: []string{"451"},
+	})
+
+	// Configured patterns extend the error classification
+	ctx, counter := providers.WithAttemptCounter(context.Background())
+	calls := 0
+	err := policy.Do(ctx, func(attempt int) error {
+		calls++
+		if attempt < 3 {
+			return transient
+		}
+		return nil
+	})
+	if err != nil || calls != 3 || counter.Count() != 3 {
+		t.Fatalf("expected success on attempt 3, got err=%v calls=%d counted=%d", err, calls, counter.Count())
+	}
+
+	// Non-retryable errors stop immediately
+	ctx, counter = providers.WithAttemptCounter(context.Background())
+	err = policy.Do(ctx, func(int) error { return permanent })
+	if err == nil || !strings.Contains(err.Error(), "non-retryable") || counter.Count() != 1 {
+		t.Fatalf("expected a single non-retryable attempt, got err=%v counted=%d", err, counter.Count())
+	}
+
+	// Exhausted attempts report the attempt count
+	ctx, counter = providers.WithAttemptCounter(context.Background())
+	err = policy.Do(ctx, func(int) error { return transient })
+	if err == nil || !strings.Contains(err.Error(), "failed after 4 attempts") || counter.Count() != 4 {
+		t.Fatalf("expected 4 failed attempts, got err=%v counted=%d", err, counter.Count())
+	}
+}
+
+func TestRetryPolicyStopsBeforeDeadline(t *testing.T) {
+	policy := providers.NewRetryPolicy(&providers.RetryConfig{
+		MaxAttempts:    3,
+		InitialDelayMs: 500,
+	})
+
+	// A retry that would start after the deadline, such as a notification's expiry, is not waited for
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	transient := providers.NewTransientError(errors.New("connection reset by peer"))
+	calls := 0
+	started := time.Now()
+	err := policy.Do(ctx, func(int) error {
+		calls++
+		return transient
+	})
+
+	if calls != 1 {
+		t.Errorf("expected 1 attempt, got %d", calls)
+	}
+	if !errors.Is(err, providers.ErrRetryPastDeadline) || !errors.Is(err, transient) {
+		t.Errorf("expected ErrRetryPastDeadline wrapping the send error, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 50*time.Millisecond {
+		t.Errorf("expected Do to return without waiting, took %s", elapsed)
+	}
+}
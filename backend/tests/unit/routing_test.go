@@ -0,0 +1,211 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/api"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/routing"
+	"github.com/developertyrone/notimulti/internal/templates"
+	"github.com/developertyrone/notimulti/tests/testhelpers"
+)
+
+const testRoutingRules = `{
+  "rules": [
+    {
+      "name": "prod-high",
+      "match": {"priority": ["high"], "metadata": {"env": "prod"}},
+      "targets": [{"provider_id": "pagerduty"}, {"provider_id": "telegram-oncall", "recipient": "-100123"}]
+    },
+    {
+      "name": "billing",
+      "match": {"api_key": ["billing-key"], "subject": "(?i)^invoice"},
+      "targets": [{"provider_id": "email-billing"}]
+    },
+    {
+      "name": "default",
+      "targets": [{"provider_id": "email-ops"}]
+    }
+  ]
+}`
+
+func TestRoutingParseRejectsInvalidRules(t *testing.T) {
+	invalid := map[string]string{
+		"unknown field":     `{"rules": [{"name": "a", "mach": {}, "targets": [{"provider_id": "x"}]}]}`,
+		"missing name":      `{"rules": [{"targets": [{"provider_id": "x"}]}]}`,
+		"duplicate name":    `{"rules": [{"name": "a", "targets": [{"provider_id": "x"}]}, {"name": "a", "targets": [{"provider_id": "y"}]}]}`,
+		"invalid priority":  `{"rules": [{"name": "a", "match": {"priority": ["urgent"]}, "targets": [{"provider_id": "x"}]}]}`,
+		"invalid subject":   `{"rules": [{"name": "a", "match": {"subject": "("}, "targets": [{"provider_id": "x"}]}]}`,
+		"no targets":        `{"rules": [{"name": "a"}]}`,
+		"invalid target":    `{"rules": [{"name": "a", "targets": [{"provider_id": "Bad ID"}]}]}`,
+		"duplicate target":  `{"rules": [{"name": "a", "targets": [{"provider_id": "x"}, {"provider_id": "x"}]}]}`,
+		"malformed json":    `{"rules": [`,
+		"rules not a slice": `{"rules": {}}`,
+	}
+	for name, data := range invalid {
+		if _, err := routing.Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected a parse error", name)
+		}
+	}
+
+	rules, err := routing.Parse([]byte(testRoutingRules))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("expected 3 rules, got %d", len(rules))
+	}
+}
+
+func TestRoutingFirstMatchWinsAndExplains(t *testing.T) {
+	engine := routing.NewEngine()
+	path := filepath.Join(t.TempDir(), routing.FileName)
+	if err := os.WriteFile(path, []byte(testRoutingRules), 0644); err != nil {
+		t.Fatalf("failed to write rules: %v", err)
+	}
+	if err := engine.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+
+	decision := engine.Route(routing.Input{Priority: "high", Metadata: map[string]interface{}{"env": "prod"}})
+	if !decision.Matched || decision.Rule != "prod-high" || len(decision.Targets) != 2 {
+		t.Fatalf("expected prod-high with 2 targets, got %+v", decision)
+	}
+	if len(decision.Evaluations) != 1 {
+		t.Errorf("expected evaluation to stop at the first match, got %+v", decision.Evaluations)
+	}
+
+	decision = engine.Route(routing.Input{
+		Priority: "high",
+		Subject:  "Invoice #42",
+		Metadata: map[string]interface{}{"env": "staging"},
+		APIKey:   "billing-key",
+	})
+	if decision.Rule != "billing" {
+		t.Fatalf("expected billing, got %+v", decision)
+	}
+	if reason := decision.Evaluations[0].Reason; reason != `metadata.env is "staging", want "prod"` {
+		t.Errorf("unexpected explanation for prod-high: %q", reason)
+	}
+
+	decision = engine.Route(routing.Input{Subject: "Invoice #43", APIKey: "secret-key"})
+	if decision.Rule != "default" || len(decision.Evaluations) != 3 {
+		t.Fatalf("expected the default rule after 3 evaluations, got %+v", decision)
+	}
+	if reason := decision.Evaluations[0].Reason; !strings.Contains(reason, `priority "normal"`) {
+		t.Errorf("expected unset priority to be treated as normal, got %q", reason)
+	}
+	if reason := decision.Evaluations[1].Reason; strings.Contains(reason, "secret-key") {
+		t.Errorf("API key leaked in explanation %q", reason)
+	}
+}
+
+func TestRoutingKeepsRulesWhenReloadFails(t *testing.T) {
+	engine := routing.NewEngine()
+	path := filepath.Join(t.TempDir(), routing.FileName)
+	if err := os.WriteFile(path, []byte(testRoutingRules), 0644); err != nil {
+		t.Fatalf("failed to write rules: %v", err)
+	}
+	if err := engine.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"rules": [{"name": "broken"}]}`), 0644); err != nil {
+		t.Fatalf("failed to write rules: %v", err)
+	}
+	if err := engine.LoadFile(path); err == nil {
+		t.Fatal("expected invalid rules to be rejected")
+	}
+	if got := len(engine.Rules()); got != 3 {
+		t.Errorf("expected the previous 3 rules to stay loaded, got %d", got)
+	}
+
+	engine.Clear()
+	if decision := engine.Route(routing.Input{}); decision.Matched {
+		t.Errorf("expected no match without rules, got %+v", decision)
+	}
+
+	var nilEngine *routing.Engine
+	if decision := nilEngine.Route(routing.Input{}); decision.Matched {
+		t.Errorf("expected a nil engine to match nothing, got %+v", decision)
+	}
+}
+
+func TestRoutingMatchesTemplatedSubjects(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "invoice.json"), []byte(`{
+		"variables": {"number": {"type": "string", "required": true}},
+		"subject": "Invoice {{.number}}",
+		"text": "Invoice {{.number}} is ready"
+	}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := templates.NewStore()
+	if errs := store.LoadDir(dir); len(errs) != 0 {
+		t.Fatalf("LoadDir() errors = %v", errs)
+	}
+
+	rulesPath := filepath.Join(dir, routing.FileName)
+	if err := os.WriteFile(rulesPath, []byte(testRoutingRules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine := routing.NewEngine()
+	if err := engine.LoadFile(rulesPath); err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+
+	sent := make(chan *providers.Notification, 1)
+	registry := providers.NewRegistry()
+	if err := registry.Register(&testhelpers.MockProvider{
+		IDFunc: func() string { return "email-billing" },
+		SendFunc: func(_ context.Context, n *providers.Notification) error {
+			sent <- n
+			return nil
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	router := api.SetupRouter(registry, nil, nil, api.WithRoutingEngine(engine), api.WithTemplates(store))
+
+	post := func(path string) *httptest.ResponseRecorder {
+		t.Helper()
+		body, _ := json.Marshal(map[string]interface{}{
+			"recipient": "billing@example.com",
+			"template":  "invoice",
+			"data":      map[string]interface{}{"number": "INV-7"},
+		})
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		req.Header.Set(api.APIKeyHeader, "billing-key")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The rule's subject pattern matches the rendered template subject
+	var dryRun api.RoutingDryRunResponse
+	if w := post("/api/v1/routing/dry-run"); w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &dryRun) != nil || dryRun.Rule != "billing" {
+		t.Fatalf("expected the dry run to match the billing rule, got %d %s", w.Code, w.Body.String())
+	}
+
+	var result api.NotificationResponse
+	if w := post("/api/v1/notifications"); w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &result) != nil || result.Rule != "billing" {
+		t.Fatalf("expected the send to be routed by the billing rule, got %d %s", w.Code, w.Body.String())
+	}
+	select {
+	case n := <-sent:
+		if n.Subject != "Invoice INV-7" {
+			t.Errorf("expected the rendered subject, got %q", n.Subject)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the routed notification to be sent")
+	}
+}
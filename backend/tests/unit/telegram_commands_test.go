@@ -0,0 +1,246 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+)
+
+// recordingCommandHandler records every command it receives
+type recordingCommandHandler struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (h *recordingCommandHandler) record(call string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls = append(h.calls, call)
+}
+
+func (h *recordingCommandHandler) snapshot() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.calls...)
+}
+
+func (h *recordingCommandHandler) Acknowledge(ctx context.Context, notificationID, actor string) error {
+	h.record(fmt.Sprintf("ack %s by %s", notificationID, actor))
+	return nil
+}
+
+func (h *recordingCommandHandler) Mute(ctx context.Context, providerID string, duration time.Duration, actor string) error {
+	h.record(fmt.Sprintf("mute %s %s by %s", providerID, duration, actor))
+	return nil
+}
+
+func (h *recordingCommandHandler) Unmute(ctx context.Context, providerID, actor string) error {
+	h.record(fmt.Sprintf("unmute %s by %s", providerID, actor))
+	return nil
+}
+
+func (h *recordingCommandHandler) Status(ctx context.Context) (string, error) {
+	h.record("status")
+	return "all good", nil
+}
+
+const telegramCommandUpdates = `{"ok":true,"result":[
+	{"update_id":1,"message":{"message_id":5,"from":{"id":7,"is_bot":false,"first_name":"On","username":"oncall"},
+		"chat":{"id":999,"type":"private"},"date":0,"text":"/status",
+		"entities":[{"type":"bot_command","offset":0,"length":7}]}},
+	{"update_id":2,"message":{"message_id":6,"from":{"id":7,"is_bot":false,"first_name":"On","username":"oncall"},
+		"chat":{"id":5551234,"type":"group"},"date":0,"text":"/mute telegram-cmd 30m",
+		"entities":[{"type":"bot_command","offset":0,"length":5}]}},
+	{"update_id":3,"callback_query":{"id":"cb-1","from":{"id":8,"is_bot":false,"first_name":"Second"},
+		"message":{"message_id":7,"chat":{"id":5551234,"type":"group"},"date":0},"data":"ack:notif-42"}}
+]}`
+
+func TestTelegramCommandReceiver(t *testing.T) {
+	var (
+		polls       int32
+		replyMarkup atomic.Value
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(r.URL.Path, "getMe"):
+			writeTelegramResponse(t, w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"Unit","username":"unit_bot"}}`)
+		case strings.Contains(r.URL.Path, "getUpdates"):
+			if atomic.AddInt32(&polls, 1) == 1 {
+				writeTelegramResponse(t, w, telegramCommandUpdates)
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+			writeTelegramResponse(t, w, `{"ok":true,"result":[]}`)
+		case strings.Contains(r.URL.Path, "sendMessage"):
+			if err := r.ParseForm(); err == nil && r.FormValue("reply_markup") != "" {
+				replyMarkup.Store(r.FormValue("reply_markup"))
+			}
+			writeTelegramResponse(t, w, `{"ok":true,"result":{"message_id":42,"chat":{"id":5551234},"date":0}}`)
+		default:
+			writeTelegramResponse(t, w, `{"ok":true,"result":true}`)
+		}
+	}))
+	defer server.Close()
+
+	provider, err := providers.NewTelegramProvider("telegram-cmd", &providers.TelegramConfig{
+		BotToken:      "token",
+		DefaultChatID: "5551234",
+		APIEndpoint:   server.URL + "/bot%s/%s",
+		Commands: &providers.TelegramCommandsConfig{
+			Enabled:            true,
+			PollTimeoutSeconds: 1,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create telegram provider: %v", err)
+	}
+
+	handler := &recordingCommandHandler{}
+	if err := provider.StartReceiving(handler); err != nil {
+		t.Fatalf("StartReceiving() error = %v", err)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for len(handler.snapshot()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	calls := handler.snapshot()
+	want := []string{
+		"mute telegram-cmd 30m0s by @oncall",
+		"ack notif-42 by Second (8)",
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("expected calls %v, got %v (commands from unauthorized chats must be ignored)", want, calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("call %d = %q, want %q", i, calls[i], want[i])
+		}
+	}
+
+	// Outgoing notifications carry acknowledge/mute buttons
+	err = provider.Send(context.Background(), &providers.Notification{
+		ID:        "notif-43",
+		Recipient: "5551234",
+		Message:   "disk almost full",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	markup, _ := replyMarkup.Load().(string)
+	if !strings.Contains(markup, "ack:notif-43") || !strings.Contains(markup, "mute:telegram-cmd:1h") {
+		t.Errorf("expected inline buttons in reply_markup, got %q", markup)
+	}
+
+	// Closing stops polling
+	if err := provider.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	settled := atomic.LoadInt32(&polls)
+	time.Sleep(200 * time.Millisecond)
+	if atomic.LoadInt32(&polls) != settled {
+		t.Fatalf("expected polling to stop after Close()")
+	}
+
+	if err := provider.StartReceiving(handler); err == nil {
+		t.Fatalf("expected StartReceiving() to fail on a closed provider")
+	}
+}
+
+func TestTelegramReceiverHandsOverOnReplace(t *testing.T) {
+	var active, overlaps, polls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(r.URL.Path, "getMe"):
+			writeTelegramResponse(t, w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"Unit","username":"unit_bot"}}`)
+		case strings.Contains(r.URL.Path, "getUpdates"):
+			// Reading the body lets the server notice when the client aborts the poll
+			_ = r.ParseForm()
+
+			// Telegram answers 409 Conflict when two pollers overlap; record the overlap
+			// instead, allowing a moment for an aborted poll to be noticed
+			if atomic.AddInt32(&active, 1) > 1 {
+				time.Sleep(200 * time.Millisecond)
+				if atomic.LoadInt32(&active) > 1 {
+					atomic.AddInt32(&overlaps, 1)
+				}
+			}
+			defer atomic.AddInt32(&active, -1)
+			atomic.AddInt32(&polls, 1)
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			writeTelegramResponse(t, w, `{"ok":true,"result":[]}`)
+		default:
+			writeTelegramResponse(t, w, `{"ok":true,"result":true}`)
+		}
+	}))
+	defer server.Close()
+
+	newProvider := func() *providers.TelegramProvider {
+		provider, err := providers.NewTelegramProvider("telegram-reload", &providers.TelegramConfig{
+			BotToken:      "token",
+			DefaultChatID: "5551234",
+			APIEndpoint:   server.URL + "/bot%s/%s",
+			Commands: &providers.TelegramCommandsConfig{
+				Enabled:            true,
+				PollTimeoutSeconds: 5,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to create telegram provider: %v", err)
+		}
+		return provider
+	}
+	waitForPolls := func(n int32) {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for atomic.LoadInt32(&polls) < n && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if atomic.LoadInt32(&polls) < n {
+			t.Fatalf("expected %d polls, got %d", n, atomic.LoadInt32(&polls))
+		}
+	}
+
+	registry := providers.NewRegistry()
+	registry.SetCommandHandler(&recordingCommandHandler{})
+	if err := registry.Register(newProvider()); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	waitForPolls(1)
+
+	// The old poll is interrupted rather than left to run out its 5s timeout
+	start := time.Now()
+	if err := registry.Replace("telegram-reload", newProvider()); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected Replace to interrupt the pending poll, took %s", elapsed)
+	}
+	waitForPolls(2)
+
+	if err := registry.Clear(); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+	if n := atomic.LoadInt32(&overlaps); n != 0 {
+		t.Errorf("expected the old poller to stop before the new one started, got %d overlapping polls", n)
+	}
+}
@@ -0,0 +1,343 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/developertyrone/notimulti/internal/providers"
+)
+
+type telegramSentMessage struct {
+	Text      string
+	ParseMode string
+}
+
+// newRecordingTelegramServer fakes the Bot API and records every sendMessage call.
+// reject decides whether a given message should fail with an entity parse error.
+func newRecordingTelegramServer(t *testing.T, reject func(telegramSentMessage) bool) (*httptest.Server, func() []telegramSentMessage) {
+	t.Helper()
+
+	var (
+		mu   sync.Mutex
+		sent []telegramSentMessage
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(r.URL.Path, "getMe"):
+			writeTelegramResponse(t, w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"Unit","username":"unit_bot"}}`)
+		case strings.Contains(r.URL.Path, "sendMessage"):
+			if err := r.ParseForm(); err != nil {
+				t.Errorf("failed to parse form: %v", err)
+			}
+			msg := telegramSentMessage{Text: r.FormValue("text"), ParseMode: r.FormValue("parse_mode")}
+			if reject != nil && reject(msg) {
+				w.WriteHeader(http.StatusBadRequest)
+				writeTelegramResponse(t, w, `{"ok":false,"error_code":400,"description":"Bad Request: can't parse entities: unsupported start tag"}`)
+				return
+			}
+			mu.Lock()
+			sent = append(sent, msg)
+			mu.Unlock()
+			writeTelegramResponse(t, w, `{"ok":true,"result":{"message_id":42}}`)
+		default:
+			writeTelegramResponse(t, w, `{"ok":true}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []telegramSentMessage {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]telegramSentMessage(nil), sent...)
+	}
+}
+
+func newFormatTestProvider(t *testing.T, server *httptest.Server, parseMode string) *providers.TelegramProvider {
+	t.Helper()
+
+	provider, err := providers.NewTelegramProvider("telegram-format", &providers.TelegramConfig{
+		BotToken:       "token",
+		DefaultChatID:  "5551234",
+		ParseMode:      parseMode,
+		TimeoutSeconds: 5,
+		APIEndpoint:    server.URL + "/bot%s/%s",
+	})
+	if err != nil {
+		t.Fatalf("failed to create telegram provider: %v", err)
+	}
+	t.Cleanup(func() { closeTelegramProvider(t, provider) })
+	return provider
+}
+
+func TestTelegramProviderEscapesContent(t *testing.T) {
+	tests := []struct {
+		name          string
+		parseMode     string
+		subject       string
+		message       string
+		wantText      string
+		wantParseMode string
+	}{
+		{
+			name:          "html default",
+			parseMode:     "",
+			subject:       "CPU > 90%",
+			message:       "load <high> & rising",
+			wantText:      "<b>CPU &gt; 90%</b>\n\nload &lt;high&gt; &amp; rising",
+			wantParseMode: "HTML",
+		},
+		{
+			name:          "markdown v2",
+			parseMode:     "MarkdownV2",
+			subject:       "deploy v1.2",
+			message:       "done (100%)! see_logs",
+			wantText:      "*deploy v1\\.2*\n\ndone \\(100%\\)\\! see\\_logs",
+			wantParseMode: "MarkdownV2",
+		},
+		{
+			name:          "plain text",
+			parseMode:     "None",
+			subject:       "Subject",
+			message:       "<b>raw</b>",
+			wantText:      "Subject\n\n<b>raw</b>",
+			wantParseMode: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, sent := newRecordingTelegramServer(t, nil)
+			provider := newFormatTestProvider(t, server, tt.parseMode)
+
+			err := provider.Send(context.Background(), &providers.Notification{
+				ID:        "notif-escape",
+				Recipient: "12345",
+				Subject:   tt.subject,
+				Message:   tt.message,
+			})
+			if err != nil {
+				t.Fatalf("expected send to succeed, got %v", err)
+			}
+
+			messages := sent()
+			if len(messages) != 1 {
+				t.Fatalf("expected 1 message, got %d", len(messages))
+			}
+			if messages[0].Text != tt.wantText {
+				t.Errorf("text = %q, want %q", messages[0].Text, tt.wantText)
+			}
+			if messages[0].ParseMode != tt.wantParseMode {
+				t.Errorf("parse_mode = %q, want %q", messages[0].ParseMode, tt.wantParseMode)
+			}
+		})
+	}
+}
+
+func TestTelegramProviderSplitsLongMessages(t *testing.T) {
+	server, sent := newRecordingTelegramServer(t, nil)
+	provider := newFormatTestProvider(t, server, "HTML")
+
+	line := strings.Repeat("a&b ", 25) + "\n" // 101 chars, 141 once escaped
+	message := strings.Repeat(line, 100)
+
+	err := provider.Send(context.Background(), &providers.Notification{
+		ID:        "notif-long",
+		Recipient: "12345",
+		Subject:   "Report",
+		Message:   message,
+	})
+	if err != nil {
+		t.Fatalf("expected send to succeed, got %v", err)
+	}
+
+	messages := sent()
+	if len(messages) < 4 {
+		t.Fatalf("expected message to be split into at least 4 chunks, got %d", len(messages))
+	}
+
+	var rebuilt strings.Builder
+	for i, msg := range messages {
+		if n := utf8.RuneCountInString(msg.Text); n > 4096 {
+			t.Errorf("chunk %d has %d characters, exceeds 4096", i, n)
+		}
+		if strings.LastIndex(msg.Text, "&") > strings.LastIndex(msg.Text, ";") {
+			t.Errorf("chunk %d ends inside an HTML entity", i)
+		}
+		if i > 0 && strings.HasPrefix(msg.Text, "<b>") {
+			t.Errorf("chunk %d repeats the subject header", i)
+		}
+		rebuilt.WriteString(msg.Text)
+	}
+
+	want := "<b>Report</b>\n\n" + strings.ReplaceAll(message, "&", "&amp;")
+	if rebuilt.String() != want {
+		t.Fatalf("chunks do not reassemble into the original message in order")
+	}
+}
+
+func TestTelegramProviderCapsChunks(t *testing.T) {
+	server, sent := newRecordingTelegramServer(t, nil)
+	provider := newFormatTestProvider(t, server, "HTML")
+
+	// Fits the advertised limit, but escaping "<" as "&lt;" makes it four times longer
+	message := strings.Repeat("<", 4096*10-100)
+
+	preview := provider.Preview(&providers.Notification{Recipient: "12345", Message: message}).(providers.TelegramPayload)
+	if len(preview.Messages) != 10 {
+		t.Fatalf("expected preview to be capped at 10 chunks, got %d", len(preview.Messages))
+	}
+
+	if err := provider.Send(context.Background(), &providers.Notification{Recipient: "12345", Message: message}); err != nil {
+		t.Fatalf("expected send to succeed, got %v", err)
+	}
+	messages := sent()
+	if len(messages) != 10 {
+		t.Fatalf("expected 10 chunks to be sent, got %d", len(messages))
+	}
+	last := messages[len(messages)-1].Text
+	if !strings.HasSuffix(last, "… (message truncated)") || utf8.RuneCountInString(last) > 4096 {
+		t.Errorf("expected the last chunk to fit and end with a truncation notice, got %d characters ending %q",
+			utf8.RuneCountInString(last), last[len(last)-40:])
+	}
+}
+
+func TestTelegramProviderBalancesTagsAcrossChunks(t *testing.T) {
+	server, sent := newRecordingTelegramServer(t, nil)
+	provider := newFormatTestProvider(t, server, "HTML")
+
+	line := strings.Repeat("x", 99) + "\n"
+	code := strings.Repeat(line, 60) // 6000 characters
+	body := "<b>Build log</b>\n<pre><code class=\"language-text\">" + code + "</code></pre>\n<a href=\"https://ci.example.com/1\">details</a>"
+
+	if err := provider.Send(context.Background(), &providers.Notification{Recipient: "12345", Subject: "CI", HTML: body}); err != nil {
+		t.Fatalf("expected send to succeed, got %v", err)
+	}
+	messages := sent()
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(messages))
+	}
+
+	var rebuilt strings.Builder
+	for i, msg := range messages {
+		text := msg.Text
+		if n := utf8.RuneCountInString(text); n > 4096 {
+			t.Errorf("chunk %d has %d characters, exceeds 4096", i, n)
+		}
+		for _, tag := range []string{"pre", "code", "b", "a"} {
+			opened := strings.Count(text, "<"+tag+">") + strings.Count(text, "<"+tag+" ")
+			if closed := strings.Count(text, "</"+tag+">"); opened != closed {
+				t.Errorf("chunk %d opens <%s> %d times and closes it %d times", i, tag, opened, closed)
+			}
+		}
+		// Drop the tags added at the chunk boundary to rebuild the original
+		if i > 0 {
+			text = strings.TrimPrefix(text, `<pre><code class="language-text">`)
+		}
+		if i < len(messages)-1 {
+			text = strings.TrimSuffix(text, "</code></pre>")
+		}
+		rebuilt.WriteString(text)
+	}
+	if want := "<b>CI</b>\n\n" + body; rebuilt.String() != want {
+		t.Errorf("chunks do not reassemble into the original message")
+	}
+}
+
+func TestTelegramProviderCapsChunksWithBalancedTags(t *testing.T) {
+	server, _ := newRecordingTelegramServer(t, nil)
+	provider := newFormatTestProvider(t, server, "HTML")
+
+	body := "<pre>" + strings.Repeat(strings.Repeat("y", 99)+"\n", 500) + "</pre>"
+	preview := provider.Preview(&providers.Notification{Recipient: "12345", HTML: body}).(providers.TelegramPayload)
+	if len(preview.Messages) != 10 {
+		t.Fatalf("expected preview to be capped at 10 chunks, got %d", len(preview.Messages))
+	}
+	last := preview.Messages[9]
+	if !strings.HasPrefix(last, "<pre>") || !strings.HasSuffix(last, "</pre>\n… (message truncated)") || utf8.RuneCountInString(last) > 4096 {
+		t.Errorf("expected the last chunk to close <pre> before the truncation notice, got %d characters ending %q",
+			utf8.RuneCountInString(last), last[len(last)-40:])
+	}
+}
+
+func TestTelegramProviderPartialDeliveryIsPermanent(t *testing.T) {
+	// The second chunk fails in every parse mode
+	server, sent := newRecordingTelegramServer(t, func(msg telegramSentMessage) bool {
+		return strings.HasPrefix(msg.Text, "b")
+	})
+	provider := newFormatTestProvider(t, server, "HTML")
+
+	message := strings.Repeat("a", 4096) + strings.Repeat("b", 100)
+	err := provider.Send(context.Background(), &providers.Notification{Recipient: "12345", Message: message})
+
+	if !errors.Is(err, providers.ErrPartialDelivery) {
+		t.Fatalf("expected ErrPartialDelivery, got %v", err)
+	}
+	if category := providers.ErrorCategory(err); category != providers.ErrorCategoryPermanent {
+		t.Errorf("expected a permanent error so the send is not repeated, got %s", category)
+	}
+	if n := len(sent()); n != 1 {
+		t.Errorf("expected only the first chunk to be delivered, got %d", n)
+	}
+}
+
+func TestTelegramProviderFallsBackToPlainText(t *testing.T) {
+	server, sent := newRecordingTelegramServer(t, func(msg telegramSentMessage) bool {
+		return msg.ParseMode != ""
+	})
+	provider := newFormatTestProvider(t, server, "HTML")
+
+	err := provider.Send(context.Background(), &providers.Notification{
+		ID:        "notif-fallback",
+		Recipient: "12345",
+		Subject:   "Alert",
+		Message:   "a < b & c",
+	})
+	if err != nil {
+		t.Fatalf("expected plain text fallback to succeed, got %v", err)
+	}
+
+	messages := sent()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 delivered message, got %d", len(messages))
+	}
+	if messages[0].ParseMode != "" {
+		t.Errorf("expected fallback without parse_mode, got %q", messages[0].ParseMode)
+	}
+	if messages[0].Text != "Alert\n\na < b & c" {
+		t.Errorf("unexpected fallback text %q", messages[0].Text)
+	}
+}
+
+func TestTelegramProviderConvertsMarkdown(t *testing.T) {
+	server, sent := newRecordingTelegramServer(t, nil)
+	// Markdown notifications are sent as HTML whatever parse mode is configured
+	provider := newFormatTestProvider(t, server, "MarkdownV2")
+
+	err := provider.Send(context.Background(), &providers.Notification{
+		ID:        "notif-markdown",
+		Recipient: "12345",
+		Subject:   "Deploy <api>",
+		Message:   "**done** in _3.2s_, see [logs](https://logs.example.com/?id=1&x=2)",
+		Format:    providers.FormatMarkdown,
+	})
+	if err != nil {
+		t.Fatalf("expected send to succeed, got %v", err)
+	}
+
+	messages := sent()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	want := "<b>Deploy &lt;api&gt;</b>\n\n<b>done</b> in <i>3.2s</i>, see <a href=\"https://logs.example.com/?id=1&amp;x=2\">logs</a>"
+	if messages[0].Text != want || messages[0].ParseMode != "HTML" {
+		t.Errorf("sent %q (%s), want %q (HTML)", messages[0].Text, messages[0].ParseMode, want)
+	}
+}
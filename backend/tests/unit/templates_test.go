@@ -0,0 +1,330 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developertyrone/notimulti/internal/api"
+	"github.com/developertyrone/notimulti/internal/providers"
+	"github.com/developertyrone/notimulti/internal/templates"
+)
+
+const deployTemplate = `{
+	"variables": {
+		"service": {"type": "string", "required": true},
+		"version": {"type": "string"},
+		"hosts":   {"type": "list"},
+		"ok":      {"type": "boolean", "required": true}
+	},
+	"subject": "Deploy of {{.service}} {{if .ok}}succeeded{{else}}failed{{end}}",
+	"text": "{{.service}} {{default \"latest\" .version}} on {{join .hosts \", \"}}",
+	"html": "<p>{{.service}} on {{range .hosts}}<code>{{.}}</code>{{end}}</p>",
+	"telegram": "<b>{{.service}}</b> {{$.version}}"
+}`
+
+func TestParseTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{"syntax error", `{"text": "{{.service"}`, "text:"},
+		{"undeclared variable", `{"variables": {"a": {"type": "string"}}, "text": "{{.a}} {{.b}}"}`, "undeclared variables: b"},
+		{"undeclared variable via $", `{"text": "{{range .x}}{{$.y}}{{end}}"}`, "undeclared variables: x, y"},
+		{"unknown type", `{"variables": {"a": {"type": "date"}}, "text": "{{.a}}"}`, "variables.a.type"},
+		{"missing text", `{"subject": "hi"}`, "text is required"},
+		{"unknown field", `{"text": "hi", "body": "x"}`, "unknown field"},
+		{"html escaping error", `{"variables": {"a": {"type": "string"}}, "text": "x", "html": "<a href=\"{{.a}}"}`, "html:"},
+		{"unsupported telegram tag", `{"text": "x", "telegram": "<b>ok</b>{{if true}}<div>no</div>{{end}}<br>"}`, "telegram: unsupported tags: div, br"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := templates.Parse("deploy", []byte(tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := templates.Parse("Deploy", []byte(`{"text": "hi"}`)); err == nil {
+		t.Error("expected invalid name to be rejected")
+	}
+	// Fields inside range and with refer to the element, not the data
+	if _, err := templates.Parse("deploy", []byte(deployTemplate)); err != nil {
+		t.Errorf("Parse() error = %v", err)
+	}
+}
+
+func TestTemplateCheckData(t *testing.T) {
+	tmpl, err := templates.Parse("deploy", []byte(deployTemplate))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	problems := tmpl.CheckData(map[string]interface{}{
+		"version": 3.0,
+		"hosts":   []interface{}{"a"},
+		"extra":   "x",
+	})
+	got := make([]string, len(problems))
+	for i, p := range problems {
+		got[i] = p.Variable
+	}
+	if strings.Join(got, ",") != "extra,ok,service,version" {
+		t.Errorf("unexpected problems %+v", problems)
+	}
+
+	if problems := tmpl.CheckData(map[string]interface{}{"service": "api", "ok": true}); len(problems) != 0 {
+		t.Errorf("expected optional variables to be optional, got %+v", problems)
+	}
+}
+
+func TestTemplateRenderPerProviderType(t *testing.T) {
+	tmpl, err := templates.Parse("deploy", []byte(deployTemplate))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	data := map[string]interface{}{
+		"service": "<api>",
+		"hosts":   []interface{}{"web-1", "web-2"},
+		"ok":      true,
+	}
+
+	email, err := tmpl.Render("email", data)
+	if err != nil {
+		t.Fatalf("Render(email) error = %v", err)
+	}
+	if email.Subject != "Deploy of <api> succeeded" || email.Text != "<api> latest on web-1, web-2" {
+		t.Errorf("unexpected email text rendering %+v", email)
+	}
+	if email.HTML != "<p>&lt;api&gt; on <code>web-1</code><code>web-2</code></p>" {
+		t.Errorf("unexpected email HTML %q", email.HTML)
+	}
+
+	telegram, err := tmpl.Render("telegram", data)
+	if err != nil {
+		t.Fatalf("Render(telegram) error = %v", err)
+	}
+	if telegram.HTML != "<b>&lt;api&gt;</b> " {
+		t.Errorf("unexpected Telegram HTML %q", telegram.HTML)
+	}
+
+	other, err := tmpl.Render("group", data)
+	if err != nil {
+		t.Fatalf("Render(group) error = %v", err)
+	}
+	if other.HTML != "" || other.Text != email.Text {
+		t.Errorf("expected text-only rendering, got %+v", other)
+	}
+}
+
+func TestTemplateStoreLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "deploy.json"), []byte(deployTemplate), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"text": "{{.x"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := templates.NewStore()
+	errs := store.LoadDir(dir)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "template broken") {
+		t.Errorf("expected one error for broken.json, got %v", errs)
+	}
+	if _, ok := store.Get("deploy"); !ok {
+		t.Error("expected deploy template to be loaded")
+	}
+
+	// A broken update keeps the previous version
+	if err := os.WriteFile(filepath.Join(dir, "deploy.json"), []byte(`{"text": "{{.nope}}"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.LoadFile(filepath.Join(dir, "deploy.json")); err == nil {
+		t.Error("expected LoadFile() to reject the undeclared variable")
+	}
+	if tmpl, ok := store.Get("deploy"); !ok || tmpl.Variables["service"].Type != templates.TypeString {
+		t.Error("expected previous deploy template to be kept")
+	}
+
+	store.Remove("deploy", "")
+	if len(store.List()) != 0 {
+		t.Errorf("expected empty store, got %d templates", len(store.List()))
+	}
+}
+
+func TestTemplateLocaleFallbacks(t *testing.T) {
+	dir := t.TempDir()
+	for file, text := range map[string]string{
+		"alert.json":       "unlocalized",
+		"alert.en.json":    "english",
+		"alert.zh-tw.json": "traditional chinese",
+		"alert.zh.json":    "chinese",
+		"notice.fr.json":   "french",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(`{"text": "`+text+`"}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "alert.not_a_locale.json"), []byte(`{"text": "x"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := templates.NewStore()
+	if errs := store.LoadDir(dir); len(errs) != 1 || !strings.Contains(errs[0].Error(), "invalid locale") {
+		t.Errorf("expected one invalid locale error, got %v", errs)
+	}
+
+	tests := []struct {
+		name, locale, want string
+		found              bool
+	}{
+		{"alert", "zh-TW", "traditional chinese", true},
+		{"alert", "zh-HK", "chinese", true},
+		{"alert", "zh-Hant-TW", "chinese", true},
+		{"alert", "de", "unlocalized", true},
+		{"alert", "", "unlocalized", true},
+		{"alert", "en-GB", "english", true},
+		{"notice", "fr-CA", "french", true},
+		{"notice", "de", "", false},
+		{"missing", "en", "", false},
+	}
+	for _, tt := range tests {
+		tmpl, ok := store.Resolve(tt.name, tt.locale)
+		if ok != tt.found || (ok && tmpl.Text != tt.want) {
+			t.Errorf("Resolve(%q, %q) = %v, %v, want %q", tt.name, tt.locale, tmpl, ok, tt.want)
+		}
+	}
+
+	if got := store.Locales("alert"); strings.Join(got, ",") != ",en,zh,zh-TW" {
+		t.Errorf("Locales() = %q", got)
+	}
+	// Removing a file uses the locale from its name
+	name, locale := templates.NameFromPath(filepath.Join(dir, "alert.zh-tw.json"))
+	store.Remove(name, locale)
+	if tmpl, _ := store.Resolve("alert", "zh-TW"); tmpl.Text != "chinese" {
+		t.Errorf("expected zh-TW to fall back to zh after removal, got %q", tmpl.Text)
+	}
+}
+
+func TestTemplateFormattingHelpers(t *testing.T) {
+	tmpl, err := templates.Parse("usage", []byte(`{
+		"variables": {"bytes": {"type": "number"}, "ratio": {"type": "number"}, "at": {"type": "time"}},
+		"text": "{{formatNumber .bytes}} {{formatNumber .ratio 1}} {{formatDate .at}} {{formatTime .at}} {{formatDateTime .at}}"
+	}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	data := map[string]interface{}{"bytes": 1234567.0, "ratio": 0.5, "at": "2026-03-01T15:04:00Z"}
+	taipei, _ := time.LoadLocation("Asia/Taipei")
+
+	tests := []struct {
+		format templates.Format
+		want   string
+	}{
+		{templates.Format{}, "1,234,567 0.5 Mar 1, 2026 3:04 PM Mar 1, 2026 3:04 PM UTC"},
+		{templates.Format{Locale: "de"}, "1.234.567 0,5 01.03.2026 15:04 01.03.2026 15:04 UTC"},
+		{templates.Format{Locale: "zh-TW", Location: taipei}, "1,234,567 0.5 2026年3月1日 23:04 2026年3月1日 23:04 CST"},
+		{templates.Format{Locale: "en-GB"}, "1,234,567 0.5 1 Mar 2026 15:04 1 Mar 2026 15:04 UTC"},
+	}
+	for _, tt := range tests {
+		rendered, err := tmpl.RenderWith("email", data, tt.format)
+		if err != nil {
+			t.Fatalf("RenderWith(%+v) error = %v", tt.format, err)
+		}
+		if rendered.Text != tt.want {
+			t.Errorf("RenderWith(%+v) = %q, want %q", tt.format, rendered.Text, tt.want)
+		}
+	}
+
+	// Missing optional values render empty; time variables must be timestamps
+	if rendered, err := tmpl.Render("email", nil); err != nil || rendered.Text != "0 0.0   " {
+		t.Errorf("Render(nil) = %q, %v", rendered.Text, err)
+	}
+	if problems := tmpl.CheckData(map[string]interface{}{"at": "yesterday"}); len(problems) != 1 || problems[0].Variable != "at" {
+		t.Errorf("expected invalid timestamp to be reported, got %+v", problems)
+	}
+}
+
+func TestRenderTemplateEndpoint(t *testing.T) {
+	dir := t.TempDir()
+	content := `{
+		"variables": {"host": {"type": "string", "required": true}, "details": {"type": "string"}},
+		"subject": "Disk alert on {{.host}}",
+		"text": "{{.host}} is almost full. {{.details}}",
+		"html": "<p>{{.host}}</p>"
+	}`
+	if err := os.WriteFile(filepath.Join(dir, "disk-alert.json"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := templates.NewStore()
+	if errs := store.LoadDir(dir); len(errs) != 0 {
+		t.Fatalf("LoadDir() errors = %v", errs)
+	}
+
+	registry := providers.NewRegistry()
+	email, err := providers.NewEmailProvider("email-ops", &providers.EmailConfig{
+		Host: "smtp.example.com", Port: 587, Username: "u", Password: "p", From: "alerts@example.com",
+	})
+	if err != nil {
+		t.Fatalf("NewEmailProvider() error = %v", err)
+	}
+	if err := registry.Register(email); err != nil {
+		t.Fatal(err)
+	}
+	router := api.SetupRouter(registry, nil, nil, api.WithTemplates(store))
+
+	render := func(name string, payload map[string]interface{}) (int, api.RenderResponse) {
+		t.Helper()
+		body, _ := json.Marshal(payload)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/templates/"+name+"/render", bytes.NewReader(body)))
+		var response api.RenderResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	// The payload is exactly what the email provider would send
+	code, response := render("disk-alert", map[string]interface{}{
+		"provider_type": "email",
+		"recipient":     "ops@example.com",
+		"data":          map[string]interface{}{"host": "db-1"},
+	})
+	if code != http.StatusOK || !response.Sendable || response.ProviderID != "email-ops" {
+		t.Fatalf("unexpected response %d %+v", code, response)
+	}
+	payload, _ := response.Payload.(map[string]interface{})
+	if payload["subject"] != "Disk alert on db-1" || payload["to"] != "ops@example.com" || payload["html"] != "<p>db-1</p>" {
+		t.Errorf("unexpected payload %+v", response.Payload)
+	}
+
+	// Missing variables and oversized output are reported, not rejected
+	code, response = render("disk-alert", map[string]interface{}{
+		"provider_id": "email-ops",
+		"data":        map[string]interface{}{"details": strings.Repeat("x", 100001)},
+	})
+	if code != http.StatusOK || response.Sendable {
+		t.Fatalf("expected an unsendable preview, got %d %+v", code, response)
+	}
+	if len(response.VariableErrors) != 1 || response.VariableErrors[0].Variable != "host" {
+		t.Errorf("expected host to be reported missing, got %+v", response.VariableErrors)
+	}
+	if len(response.SizeErrors) != 1 || response.SizeErrors[0].Field != "message" {
+		t.Errorf("expected a message size error, got %+v", response.SizeErrors)
+	}
+
+	if code, _ := render("missing", map[string]interface{}{"provider_type": "email"}); code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown template, got %d", code)
+	}
+	if code, _ := render("disk-alert", map[string]interface{}{"data": map[string]interface{}{}}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 without provider, got %d", code)
+	}
+}
@@ -0,0 +1,160 @@
+package unit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/developertyrone/notimulti/internal/config"
+	"github.com/developertyrone/notimulti/internal/storage"
+)
+
+func TestTopicStoreCRUD(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	defer db.Close()
+
+	if _, err := repo.CreateTopic("billing-alerts", "Payment failures"); err != nil {
+		t.Fatalf("CreateTopic() error = %v", err)
+	}
+	if _, err := repo.CreateTopic("billing-alerts", ""); !errors.Is(err, storage.ErrTopicExists) {
+		t.Errorf("expected ErrTopicExists, got %v", err)
+	}
+
+	chat, err := repo.AddSubscriber("billing-alerts", "telegram-main", "12345")
+	if err != nil {
+		t.Fatalf("AddSubscriber() error = %v", err)
+	}
+	if _, err := repo.AddSubscriber("billing-alerts", "email-ops", "billing@example.com"); err != nil {
+		t.Fatalf("AddSubscriber() error = %v", err)
+	}
+	if _, err := repo.AddSubscriber("billing-alerts", "telegram-main", "12345"); !errors.Is(err, storage.ErrSubscriberExists) {
+		t.Errorf("expected ErrSubscriberExists, got %v", err)
+	}
+	if _, err := repo.AddSubscriber("missing", "telegram-main", "12345"); !errors.Is(err, storage.ErrTopicNotFound) {
+		t.Errorf("expected ErrTopicNotFound, got %v", err)
+	}
+
+	topic, err := repo.GetTopic("billing-alerts")
+	if err != nil {
+		t.Fatalf("GetTopic() error = %v", err)
+	}
+	if topic.SubscriberCount != 2 || topic.Source != storage.TopicSourceAPI || topic.Description != "Payment failures" {
+		t.Errorf("unexpected topic %+v", topic)
+	}
+
+	if err := repo.RemoveSubscriber("billing-alerts", chat.ID); err != nil {
+		t.Fatalf("RemoveSubscriber() error = %v", err)
+	}
+	if err := repo.RemoveSubscriber("billing-alerts", chat.ID); !errors.Is(err, storage.ErrSubscriberNotFound) {
+		t.Errorf("expected ErrSubscriberNotFound, got %v", err)
+	}
+	subscribers, err := repo.ListSubscribers("billing-alerts")
+	if err != nil || len(subscribers) != 1 || subscribers[0].ProviderID != "email-ops" {
+		t.Fatalf("unexpected subscribers %+v, %v", subscribers, err)
+	}
+
+	if err := repo.DeleteTopic("billing-alerts"); err != nil {
+		t.Fatalf("DeleteTopic() error = %v", err)
+	}
+	var remaining int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM topic_subscribers`).Scan(&remaining); err != nil || remaining != 0 {
+		t.Errorf("expected subscribers to be deleted with the topic, %d left (%v)", remaining, err)
+	}
+}
+
+func TestSyncConfigTopicsKeepsAPISubscribers(t *testing.T) {
+	repo, db := setupTestRepository(t)
+	defer db.Close()
+
+	path := filepath.Join(t.TempDir(), config.TopicsFileName)
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write topics: %v", err)
+		}
+	}
+
+	write(`{"topics": [
+		{"name": "billing-alerts", "description": "Payments", "subscribers": [
+			{"provider_id": "telegram-main", "recipient": -1001234567890},
+			{"provider_id": "email-ops", "recipient": "billing@example.com"}
+		]},
+		{"name": "deploys", "subscribers": []}
+	]}`)
+	if count, err := config.SyncTopicsFile(repo, path); err != nil || count != 2 {
+		t.Fatalf("SyncTopicsFile() = %d, %v", count, err)
+	}
+
+	// Config-managed topics and subscribers cannot be changed through the API
+	if err := repo.DeleteTopic("billing-alerts"); !errors.Is(err, storage.ErrConfigManaged) {
+		t.Errorf("expected ErrConfigManaged deleting a config topic, got %v", err)
+	}
+	subscribers, _ := repo.ListSubscribers("billing-alerts")
+	if len(subscribers) != 2 || subscribers[0].Recipient != "-1001234567890" {
+		t.Fatalf("unexpected subscribers %+v", subscribers)
+	}
+	if err := repo.RemoveSubscriber("billing-alerts", subscribers[0].ID); !errors.Is(err, storage.ErrConfigManaged) {
+		t.Errorf("expected ErrConfigManaged removing a config subscriber, got %v", err)
+	}
+
+	// Subscribers added through the API survive a reload
+	if _, err := repo.AddSubscriber("billing-alerts", "email-ops", "cfo@example.com"); err != nil {
+		t.Fatalf("AddSubscriber() error = %v", err)
+	}
+	write(`{"topics": [
+		{"name": "billing-alerts", "subscribers": [{"provider_id": "email-ops", "recipient": "billing@example.com"}]}
+	]}`)
+	if _, err := config.SyncTopicsFile(repo, path); err != nil {
+		t.Fatalf("SyncTopicsFile() error = %v", err)
+	}
+	subscribers, _ = repo.ListSubscribers("billing-alerts")
+	if len(subscribers) != 2 || subscribers[0].Recipient != "billing@example.com" || subscribers[1].Recipient != "cfo@example.com" {
+		t.Errorf("unexpected subscribers after reload %+v", subscribers)
+	}
+	if _, err := repo.GetTopic("deploys"); !errors.Is(err, storage.ErrTopicNotFound) {
+		t.Errorf("expected topics removed from the file to be deleted, got %v", err)
+	}
+
+	// Invalid files leave the store unchanged
+	write(`{"topics": [{"name": "Bad Name", "subscribers": []}]}`)
+	if _, err := config.SyncTopicsFile(repo, path); err == nil {
+		t.Error("expected an invalid topics file to be rejected")
+	}
+	if _, err := repo.GetTopic("billing-alerts"); err != nil {
+		t.Errorf("expected billing-alerts to survive an invalid reload, got %v", err)
+	}
+
+	// Removing the file removes the topics it defined
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed to remove topics: %v", err)
+	}
+	if _, err := config.SyncTopicsFile(repo, path); err != nil {
+		t.Fatalf("SyncTopicsFile() error = %v", err)
+	}
+	if topics, _ := repo.ListTopics(); len(topics) != 0 {
+		t.Errorf("expected no topics without topics.json, got %+v", topics)
+	}
+}
+
+func TestLoadTopicsFileValidation(t *testing.T) {
+	invalid := map[string]string{
+		"malformed":            `{"topics": [`,
+		"invalid name":         `{"topics": [{"name": "Billing", "subscribers": []}]}`,
+		"duplicate topic":      `{"topics": [{"name": "a", "subscribers": []}, {"name": "a", "subscribers": []}]}`,
+		"missing subscribers":  `{"topics": [{"name": "a"}]}`,
+		"invalid provider":     `{"topics": [{"name": "a", "subscribers": [{"provider_id": "Bad ID"}]}]}`,
+		"invalid recipient":    `{"topics": [{"name": "a", "subscribers": [{"provider_id": "x", "recipient": true}]}]}`,
+		"duplicate subscriber": `{"topics": [{"name": "a", "subscribers": [{"provider_id": "x", "recipient": "1"}, {"provider_id": "x", "recipient": 1}]}]}`,
+	}
+	dir := t.TempDir()
+	for name, content := range invalid {
+		path := filepath.Join(dir, config.TopicsFileName)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write topics: %v", err)
+		}
+		if _, err := config.LoadTopicsFile(path); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
@@ -11,6 +11,10 @@ export interface Provider {
   config_checksum?: string
   last_test_at?: string
   last_test_status?: string
+  last_checked_at?: string
+  latency_ms?: number
+  consecutive_failures?: number
+  circuit_state?: string
 }
 
 export interface ProvidersResponse {
@@ -29,6 +33,9 @@ export interface NotificationLogEntry {
   priority: string
   status: string
   error_message?: string
+  error_category?: string
+  parent_id?: string
+  delivered_via?: string
   attempts: number
   created_at: string
   delivered_at?: string
@@ -48,6 +55,8 @@ export interface HistoryFilters {
   provider_id?: string
   provider_type?: string
   status?: string
+  error_category?: string
+  parent_id?: string
   date_from?: string
   date_to?: string
   include_tests?: boolean
@@ -123,6 +132,8 @@ export async function getNotificationHistory(filters?: HistoryFilters): Promise<
     if (filters?.provider_id) params.append('provider_id', filters.provider_id)
     if (filters?.provider_type) params.append('provider_type', filters.provider_type)
     if (filters?.status) params.append('status', filters.status)
+    if (filters?.error_category) params.append('error_category', filters.error_category)
+    if (filters?.parent_id) params.append('parent_id', filters.parent_id)
     if (filters?.date_from) params.append('date_from', filters.date_from)
     if (filters?.date_to) params.append('date_to', filters.date_to)
     if (filters?.include_tests !== undefined) params.append('include_tests', String(filters.include_tests))